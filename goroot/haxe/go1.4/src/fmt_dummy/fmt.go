@@ -49,8 +49,37 @@ func Printf(format string, a ...interface{}) (n int, err error) {
 	return 0, nil
 }
 
+// Sprintf replaces each verb in the format with the Haxe string of the next argument, ignoring any flags, width or precision.
 func Sprintf(format string, a ...interface{}) string {
-	return " { " + Sprint(a...) + " } " + Sprint(format)
+	ret := ""
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			ret += format[i : i+1]
+			continue
+		}
+		i++
+		if format[i] == '%' {
+			ret += "%"
+			continue
+		}
+		for i < len(format)-1 && (format[i] < 'a' || format[i] > 'z') && (format[i] < 'A' || format[i] > 'Z') {
+			i++ // skip flags, width and precision
+		}
+		if len(a) == 0 {
+			ret += "%!" + format[i:i+1] + "(MISSING)"
+			continue
+		}
+		s := hx.CallString("", "Std.string", 1, a[0])
+		if format[i] == 'q' {
+			s = `"` + s + `"`
+		}
+		ret += s
+		a = a[1:]
+	}
+	if len(a) > 0 {
+		ret += "%!(EXTRA " + Sprint(a...) + ")"
+	}
+	return ret
 }
 
 func Sprintln(a ...interface{}) string {
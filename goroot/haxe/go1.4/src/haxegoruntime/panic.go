@@ -0,0 +1,84 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package haxegoruntime
+
+import "github.com/tardisgo/tardisgo/haxe/hx"
+
+func init() { // to avoid DCE, as only called from the Haxe Scheduler
+	if false {
+		_ = PanicMessage(nil)
+	}
+}
+
+type stringer interface {
+	String() string
+}
+
+// PanicMessage describes the value of an unrecovered panic, in the way that Go prints it after "panic: ".
+func PanicMessage(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "nil"
+	case error:
+		return x.Error()
+	case stringer:
+		return x.String()
+	case string:
+		return x
+	case bool:
+		if x {
+			return "true"
+		}
+		return "false"
+	case int:
+		return itoa(int64(x))
+	case int8:
+		return itoa(int64(x))
+	case int16:
+		return itoa(int64(x))
+	case int32:
+		return itoa(int64(x))
+	case int64:
+		return itoa(x)
+	case uint:
+		return utoa(uint64(x))
+	case uint8:
+		return utoa(uint64(x))
+	case uint16:
+		return utoa(uint64(x))
+	case uint32:
+		return utoa(uint64(x))
+	case uint64:
+		return utoa(x)
+	case uintptr:
+		return utoa(uint64(x))
+	case float32, float64, complex64, complex128:
+		return hx.CallString("", "Std.string", 1, v)
+	}
+	// Go would also print the address of the value, which we don't have
+	return "(" + getTypeString(hx.CodeInt("", "_a.itemAddr(0).load().typ;", v)) + ") " +
+		hx.CallString("", "Std.string", 1, v)
+}
+
+func itoa(i int64) string {
+	if i < 0 {
+		return "-" + utoa(uint64(-i))
+	}
+	return utoa(uint64(i))
+}
+
+func utoa(u uint64) string {
+	var buf [20]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = byte('0' + u%10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	return string(buf[i:])
+}
@@ -0,0 +1,37 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package haxegoruntime
+
+import (
+	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
+)
+
+// Park gives up control of the calling goroutine to the Scheduler, it is how the runtime packages block a goroutine,
+// other than by channel operations.
+// If until is non-zero, the goroutine sleeps until that time, as from haxe.Timer.stamp(), unless woken earlier by Scheduler.wakeAddr(on).
+// Otherwise, if why is not "", the goroutine is blocked, as Go would describe it, until what it waits on is ready, see Scheduler.wait().
+// Otherwise it is simply run again after the other goroutines have had a turn, for example once runtime.Goexit has told the Scheduler to end it.
+// The caller must hold the Haxe runtime lock, which Park releases while the goroutine is not running, and takes again before returning,
+// so that what the goroutine waits for cannot happen between its caller checking for it and the goroutine being blocked.
+func Park(why string, until float64, on unsafe.Pointer) {
+	gr := hx.GetInt("", "this._goroutine")
+	switch {
+	case until > 0:
+		hx.Call("", "Scheduler.sleep", 3, gr, until, on)
+	case why != "":
+		hx.Call("", "Scheduler.wait", 3, gr, why, on)
+	}
+	hx.Call("", "Scheduler.unlock", 0) // never hold the lock while giving up control
+	// The Scheduler only sees that the goroutine is blocked while this function is the latest on its stack.
+	select { // a select is where the Scheduler may run other goroutines, and this one is never ready
+	case <-never:
+	default:
+	}
+	hx.Call("", "Scheduler.lock", 0)
+}
+
+var never chan bool // always nil, so never ready
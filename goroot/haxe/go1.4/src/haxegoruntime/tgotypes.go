@@ -53,9 +53,11 @@ type method struct {
 }
 
 func addMethod(meths []method, name, pkgPath string, mtyp, typ, ifn, tfn unsafe.Pointer) []method {
-	return append(meths, method{
-		name: addrString(name), pkgPath: addrString(pkgPath), mtyp: mtyp, typ: typ, ifn: ifn, tfn: tfn,
-	})
+	m := method{name: addrString(name), mtyp: mtyp, typ: typ, ifn: ifn, tfn: tfn}
+	if pkgPath != "" { // as for struct fields, nil for exported names
+		m.pkgPath = addrString(pkgPath)
+	}
+	return append(meths, m)
 }
 
 func newMethodSlice() []method {
@@ -130,7 +132,11 @@ func newImethodSlice() []imethod {
 }
 
 func addImethodSlice(imeths []imethod, name, pkgPath string, typ *rtype) []imethod {
-	return append(imeths, imethod{addrString(name), addrString(pkgPath), typ})
+	im := imethod{name: addrString(name), typ: typ}
+	if pkgPath != "" { // as for struct fields, nil for exported names
+		im.pkgPath = addrString(pkgPath)
+	}
+	return append(imeths, im)
 }
 
 // interfaceType represents an interface type.
@@ -196,12 +202,16 @@ func newStructFieldSlice() []structField {
 
 func addStructFieldSlice(sl []structField, name, pkgPath string, typ *rtype, tag string, offset uintptr) []structField {
 	sf := structField{
-		name:   addrString(name),
 		typ:    typ,
 		tag:    addrString(tag),
 		offset: offset,
 	}
 
+	// as in gc, the name of an embedded field is nil, reflect uses the name of its type
+	if name != "" {
+		sf.name = addrString(name)
+	}
+
 	// VERY important that pkgPath = nil rather than "" for reflection to work!
 	if pkgPath != "" {
 		sf.pkgPath = addrString(pkgPath)
@@ -235,6 +245,16 @@ func init() {
 	//println("DEBUG sizeof(funcType{}) = ", unsafe.Sizeof(funcType{}))
 }
 
+// AddTypeTable adds t, a type created at run time by reflect, to the TypeTable, returning its new type number.
+func AddTypeTable(t unsafe.Pointer) int {
+	hx.Call("", "Scheduler.lock", 0)
+	TypeTable = append(TypeTable, (*rtype)(t))
+	id := len(TypeTable) - 1
+	hx.SetInt("", "TypeInfo.nextTypeID", len(TypeTable))
+	hx.Call("", "Scheduler.unlock", 0)
+	return id
+}
+
 func typetest() {
 	for i, tp := range TypeTable {
 		if tp != nil {
@@ -0,0 +1,166 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package haxegoruntime
+
+import (
+	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
+)
+
+// The runtime timer heap, used to implement the timers of packages time and syscall.
+// As in the Go runtime, a single goroutine sleeps until the earliest timer is due, then runs the functions of those that are.
+// It only exists while there are timers in the heap, so that it cannot hide a deadlock.
+// The heap is guarded by the Haxe runtime lock, for when goroutines run on many threads (Haxe -D gothreads),
+// which is never held while a goroutine gives up control, or while a timer function runs.
+
+// Timer is an entry in the timer heap, it mirrors the runtimeTimer of packages time and syscall.
+type Timer struct {
+	i      int // index in the heap, or -1 if not in it
+	When   int64
+	Period int64
+	F      func(interface{}, uintptr) // NOTE: must not block, as it runs on the timer goroutine
+	Arg    interface{}
+	Seq    uintptr
+}
+
+var (
+	timers       []*Timer // a 4-heap, ordered by When, as in the Go runtime
+	timerRunning bool     // is the timer goroutine running
+	timerWake    bool     // the address the timer goroutine sleeps on, so that it can be woken when an earlier timer is added
+)
+
+// Nanotime returns the runtime clock in nanoseconds, the same clock used by the When field of a Timer.
+func Nanotime() int64 {
+	return int64(hx.CallFloat("", "haxe.Timer.stamp", 0) * 1000000000)
+}
+
+// AddTimer puts t in the timer heap, to run t.F(t.Arg, t.Seq) at t.When, and every t.Period after that if t.Period > 0.
+func AddTimer(t *Timer) {
+	hx.Call("", "Scheduler.lock", 0)
+	t.i = len(timers)
+	timers = append(timers, t)
+	siftupTimer(t.i)
+	start := !timerRunning
+	timerRunning = true
+	if !start && t.i == 0 { // the new earliest timer
+		hx.Call("", "Scheduler.wakeAddr", 1, unsafe.Pointer(&timerWake))
+	}
+	hx.Call("", "Scheduler.unlock", 0)
+	if start {
+		go timerproc()
+	}
+}
+
+// DelTimer removes t from the timer heap, returning false if it was not there because it had already run or been removed.
+func DelTimer(t *Timer) bool {
+	hx.Call("", "Scheduler.lock", 0)
+	i := t.i
+	found := i >= 0 && i < len(timers) && timers[i] == t
+	if found {
+		removeTimer(i)
+	}
+	hx.Call("", "Scheduler.unlock", 0)
+	return found
+}
+
+func removeTimer(i int) {
+	t := timers[i]
+	last := len(timers) - 1
+	if i != last {
+		timers[i] = timers[last]
+		timers[i].i = i
+	}
+	timers[last] = nil
+	timers = timers[:last]
+	if i != last {
+		siftupTimer(i)
+		siftdownTimer(i)
+	}
+	t.i = -1
+}
+
+// timerproc runs the timers when they are due, sleeping in between.
+func timerproc() {
+	for {
+		hx.Call("", "Scheduler.lock", 0)
+		if len(timers) == 0 {
+			timerRunning = false
+			hx.Call("", "Scheduler.unlock", 0)
+			return
+		}
+		now := Nanotime()
+		t := timers[0]
+		if t.When > now {
+			Park("sleep", float64(t.When)/1000000000, unsafe.Pointer(&timerWake)) // t.When is in nanoseconds, haxe works in seconds
+			hx.Call("", "Scheduler.unlock", 0)
+			continue
+		}
+		if t.Period > 0 { // leave in the heap, but adjust the next time to fire
+			t.When += t.Period * (1 + (now-t.When)/t.Period)
+			siftdownTimer(0)
+		} else {
+			removeTimer(0)
+		}
+		f, arg, seq := t.F, t.Arg, t.Seq
+		hx.Call("", "Scheduler.unlock", 0)
+		f(arg, seq)
+	}
+}
+
+// Heap maintenance algorithms, from the Go runtime.
+
+func siftupTimer(i int) {
+	when := timers[i].When
+	tmp := timers[i]
+	for i > 0 {
+		p := (i - 1) / 4 // parent
+		if when >= timers[p].When {
+			break
+		}
+		timers[i] = timers[p]
+		timers[i].i = i
+		timers[p] = tmp
+		timers[p].i = p
+		i = p
+	}
+}
+
+func siftdownTimer(i int) {
+	n := len(timers)
+	when := timers[i].When
+	tmp := timers[i]
+	for {
+		c := i*4 + 1 // left child
+		c3 := c + 2  // mid child
+		if c >= n {
+			break
+		}
+		w := timers[c].When
+		if c+1 < n && timers[c+1].When < w {
+			w = timers[c+1].When
+			c++
+		}
+		if c3 < n {
+			w3 := timers[c3].When
+			if c3+1 < n && timers[c3+1].When < w3 {
+				w3 = timers[c3+1].When
+				c3++
+			}
+			if w3 < w {
+				w = w3
+				c = c3
+			}
+		}
+		if w >= when {
+			break
+		}
+		timers[i] = timers[c]
+		timers[i].i = i
+		timers[c] = tmp
+		timers[c].i = c
+		i = c
+	}
+}
@@ -11,6 +11,8 @@ package os
 import (
 	"runtime"
 	"syscall"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
 )
 
 // Args hold the command-line arguments, starting with the program name.
@@ -24,7 +26,21 @@ func init() {
 	Args = runtime_args()
 }
 
-func runtime_args() []string { return []string{"tardisgo"} } // dummy for haxe, should be in package runtime
+// runtime_args returns the program name, then any command-line arguments, should be in package runtime
+// The program name is its path where the target gives it (Sys.programPath(), or process.argv[1] on Node), otherwise "tardisgo".
+// The host array is read by index, leaving it unchanged, as other Haxe code may share it.
+func runtime_args() []string {
+	args := []string{"tardisgo"}
+	if path := hx.CallString("", "Console.programPath", 0); path != "" {
+		args[0] = path
+	}
+	haxeArgs := hx.CallDynamic("", "Console.args", 0)
+	n := hx.FgetInt("", haxeArgs, "", "length")
+	for i := 0; i < n; i++ {
+		args = append(args, hx.CodeString("", "_a.itemAddr(0).load().val[_a.itemAddr(1).load().val];", haxeArgs, i))
+	}
+	return args
+}
 
 // Getuid returns the numeric user id of the caller.
 func Getuid() int { return syscall.Getuid() }
@@ -0,0 +1,155 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// +build haxe
+
+package reflect_test
+
+// These tests check the reflect behaviour that encoding/json, encoding/xml, encoding/gob and encoding/binary rely on,
+// where it has been made to match gc.
+
+import (
+	. "reflect"
+	"sort"
+	"testing"
+	"unsafe"
+)
+
+type Named struct {
+	N int
+}
+
+type gcFields struct {
+	Named
+	_ int
+	x int
+	Y string `json:"y,omitempty" xml:"why"`
+}
+
+func TestEmbeddedAndBlankFields(t *testing.T) {
+	typ := TypeOf(gcFields{})
+	if n := typ.NumField(); n != 4 {
+		t.Fatalf("NumField = %d, want 4", n)
+	}
+	tests := []struct {
+		name      string
+		pkgPath   bool
+		anonymous bool
+	}{
+		{"Named", false, true},
+		{"_", true, false},
+		{"x", true, false},
+		{"Y", false, false},
+	}
+	for i, tt := range tests {
+		f := typ.Field(i)
+		if f.Name != tt.name || (f.PkgPath != "") != tt.pkgPath || f.Anonymous != tt.anonymous {
+			t.Errorf("Field(%d) = %q, PkgPath %q, Anonymous %v, want %q, exported %v, Anonymous %v",
+				i, f.Name, f.PkgPath, f.Anonymous, tt.name, !tt.pkgPath, tt.anonymous)
+		}
+	}
+	if tag := typ.Field(3).Tag; tag.Get("json") != "y,omitempty" || tag.Get("xml") != "why" {
+		t.Errorf("Field(3).Tag = %q", tag)
+	}
+
+	f, ok := typ.FieldByName("N")
+	if !ok || len(f.Index) != 2 || f.Index[0] != 0 || f.Index[1] != 0 {
+		t.Errorf("FieldByName(N) = %v, %v, want the promoted field at index [0 0]", f.Index, ok)
+	}
+	v := ValueOf(&gcFields{Named: Named{N: 42}}).Elem()
+	if n := v.FieldByName("N"); !n.IsValid() || n.Int() != 42 {
+		t.Errorf("Value.FieldByName(N) = %v, want 42", n)
+	}
+	if !v.Field(0).CanSet() || v.Field(2).CanSet() || v.Field(1).CanInterface() {
+		t.Errorf("the embedded field must be settable, and the unexported and blank fields not")
+	}
+}
+
+func TestSetMapIndex(t *testing.T) {
+	m := map[string]int{"a": 1}
+	mv := ValueOf(m)
+	mv.SetMapIndex(ValueOf("b"), ValueOf(2))
+	if m["b"] != 2 {
+		t.Errorf("after SetMapIndex, m[b] = %d, want 2", m["b"])
+	}
+	if v := mv.MapIndex(ValueOf("a")); !v.IsValid() || v.Int() != 1 {
+		t.Errorf("MapIndex(a) = %v, want 1", v)
+	}
+	if v := mv.MapIndex(ValueOf("z")); v.IsValid() {
+		t.Errorf("MapIndex of a missing key = %v, want the zero Value", v)
+	}
+	var keys []string
+	for _, k := range mv.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("MapKeys = %v, want [a b]", keys)
+	}
+	mv.SetMapIndex(ValueOf("a"), Value{})
+	if _, ok := m["a"]; ok || len(m) != 1 {
+		t.Errorf("SetMapIndex with the zero Value did not delete, m = %v", m)
+	}
+
+	type key struct{ A, B int }
+	mm := MakeMap(TypeOf(map[key][]byte{}))
+	mm.SetMapIndex(ValueOf(key{1, 2}), ValueOf([]byte("x")))
+	made := mm.Interface().(map[key][]byte)
+	if string(made[key{1, 2}]) != "x" {
+		t.Errorf("MakeMap then SetMapIndex gave %v", made)
+	}
+
+	var nilMap map[string]int
+	pv := ValueOf(&nilMap).Elem()
+	if !pv.IsNil() {
+		t.Errorf("IsNil of a nil map = false")
+	}
+	pv.Set(MakeMap(pv.Type()))
+	pv.SetMapIndex(ValueOf("c"), ValueOf(3))
+	if pv.IsNil() || nilMap["c"] != 3 {
+		t.Errorf("after Set(MakeMap) and SetMapIndex, map = %v", nilMap)
+	}
+}
+
+type celsius float64
+type name string
+
+func TestConvertGC(t *testing.T) {
+	if v := ValueOf(42).Convert(TypeOf(float64(0))); v.Float() != 42 {
+		t.Errorf("Convert int to float64 = %v", v)
+	}
+	if v := ValueOf(3.5).Convert(TypeOf(celsius(0))); v.Type() != TypeOf(celsius(0)) || v.Float() != 3.5 {
+		t.Errorf("Convert float64 to celsius = %v of %v", v, v.Type())
+	}
+	if v := ValueOf([]byte("hi")).Convert(TypeOf("")); v.String() != "hi" {
+		t.Errorf("Convert []byte to string = %q", v.String())
+	}
+	if v := ValueOf("hi").Convert(TypeOf([]byte(nil))); string(v.Bytes()) != "hi" {
+		t.Errorf("Convert string to []byte = %q", v.Bytes())
+	}
+	if v := ValueOf(name("gc")).Convert(TypeOf("")); v.Type() != TypeOf("") || v.String() != "gc" {
+		t.Errorf("Convert name to string = %v of %v", v, v.Type())
+	}
+	if v := ValueOf(65).Convert(TypeOf("")); v.String() != "A" {
+		t.Errorf("Convert int to string = %q", v.String())
+	}
+	var e interface{}
+	if v := ValueOf(7).Convert(TypeOf(&e).Elem()); v.Kind() != Interface || v.Elem().Int() != 7 {
+		t.Errorf("Convert int to interface{} = %v", v)
+	}
+}
+
+func TestNewAtGC(t *testing.T) {
+	var x gcFields
+	p := NewAt(TypeOf(x), unsafe.Pointer(&x))
+	p.Elem().FieldByName("Y").SetString("set")
+	if x.Y != "set" {
+		t.Errorf("setting through NewAt gave %q", x.Y)
+	}
+	n := New(TypeOf(x))
+	n.Elem().Field(0).Field(0).SetInt(9)
+	if n.Interface().(*gcFields).N != 9 {
+		t.Errorf("setting through New gave %d", n.Interface().(*gcFields).N)
+	}
+}
@@ -524,6 +524,18 @@ func haxe2go(ret *emptyInterface, i interface{}) {
 
 }
 
+// haxeValue returns a Value of type t holding the Haxe value val,
+// as passed to or returned from a Haxe function.
+func haxeValue(t *rtype, val uintptr) Value {
+	e := &emptyInterface{typ: t}
+	haxe2go(e, val)
+	f := flag(t.Kind())
+	if ifaceIndir(t) {
+		f |= flagIndir
+	}
+	return Value{t, e.word, f}
+}
+
 func typeIdFromPtr(ptr *rtype) int {
 	for typ := 0; typ < len(haxegoruntime.TypeTable); typ++ {
 		if unsafe.Pointer(ptr) == unsafe.Pointer(haxegoruntime.TypeTable[typ]) {
@@ -532,6 +544,30 @@ func typeIdFromPtr(ptr *rtype) int {
 	}
 	return 0
 }
+
+// addHaxeType gives t, a type created at run time, the next type number, so that its values may be held in Haxe Interfaces.
+// The Haxe runtime is also given its zero value, the methods it has for Interface.invoke,
+// and the interface types it may be asserted to, as the compiler gives them for the types it knows.
+func addHaxeType(t *rtype) {
+	id := haxegoruntime.AddTypeTable(unsafe.Pointer(t))
+	zero := hx.CodeDynamic("", "_a.itemAddr(0).load().val;", packEface(Zero(t)))
+	hx.Code("", "CreatedTypeInfo.add(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val);", id, zero)
+	if ut := t.uncommon(); ut != nil {
+		for i := range ut.methods {
+			m := &ut.methods[i]
+			if m.ifn != nil {
+				hx.Code("", "CreatedTypeInfo.addMethod(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val,_a.itemAddr(2).load().val.fn);",
+					id, *m.name, m.ifn)
+			}
+		}
+	}
+	for i := 1; i < id; i++ {
+		it := (*rtype)(unsafe.Pointer(haxegoruntime.TypeTable[i]))
+		if it != nil && it.Kind() == Interface && implements(it, t) {
+			hx.Code("", "CreatedTypeInfo.addAssertable(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val);", id, i)
+		}
+	}
+}
 func haxeInterfacePack(ei *emptyInterface) interface{} {
 	i := haxeInterfacePackB(ei)
 
@@ -611,10 +647,7 @@ func haxeInterfacePackB(ei *emptyInterface) interface{} {
 		}
 		//println("DEBUG pack haxe ptr type=", hx.CallString("", "Type.getClassName", 1, ei.word),
 		//	" Go type=", ei.typ.Kind().String(), "PtrVal=", ei.word)
-		val := uintptr(ei.word) //?????? TODO this must be wrong, but needed for fmt to pass
-		if ei.typ.Kind() != Map {
-			val = *(*uintptr)(unsafe.Pointer(ei.word))
-		}
+		val := *(*uintptr)(unsafe.Pointer(ei.word))
 		return hx.CodeIface("", ei.typ.String(), "_a.itemAddr(0).load().val;", val)
 
 	}
@@ -0,0 +1,134 @@
+// modifications Copyright 2015 Elliott Stoneham
+
+// Copyright 2012 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build haxe
+
+// MakeFunc implementation.
+
+package reflect
+
+import (
+	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
+)
+
+// makeFuncImpl is the closure value implementing the function
+// returned by MakeFunc.
+type makeFuncImpl struct {
+	typ *funcType
+	fn  func([]Value) []Value
+}
+
+// MakeFunc returns a new function of the given Type
+// that wraps the function fn. When called, that new function
+// does the following:
+//
+//	- converts its arguments to a slice of Values.
+//	- runs results := fn(args).
+//	- returns the results as a slice of Values, one per formal result.
+//
+// The implementation fn can assume that the argument Value slice
+// has the number and type of arguments given by typ.
+// If typ describes a variadic function, the final Value is itself
+// a slice representing the variadic arguments, as in the
+// body of a variadic function. The result Value slice returned by fn
+// must have the number and type of results given by typ.
+//
+// The Value.Call method allows the caller to invoke a typed function
+// in terms of Values; in contrast, MakeFunc allows the caller to implement
+// a typed function in terms of Values.
+//
+// The Examples section of the documentation includes an illustration
+// of how to use MakeFunc to build a swap function for different types.
+//
+func MakeFunc(typ Type, fn func(args []Value) (results []Value)) Value {
+	if typ.Kind() != Func {
+		panic("reflect: call of MakeFunc with non-Func type")
+	}
+
+	t := typ.common()
+	ftyp := (*funcType)(unsafe.Pointer(t))
+
+	impl := &makeFuncImpl{typ: ftyp, fn: fn}
+	stub := func(args uintptr) uintptr { return callReflect(impl, args) }
+
+	// The Haxe Closure of the new function passes all of its parameters to the stub in a single Array.
+	// As with any other func Value, ptr addresses the memory holding that Closure.
+	p := unsafe_New(t)
+	*(*uintptr)(p) = hx.CodeDynamic("", "Closure.varArgs(_a.itemAddr(0).load().val);", stub)
+
+	return Value{t, p, flag(Func)}
+}
+
+// makeMethodValue converts v from the rcvr+method index representation
+// of a method value to an actual method func value, which is
+// basically the receiver value with a special bit set, into a true
+// func value - a value holding an actual func. The output is
+// semantically equivalent to the input as far as the user of package
+// reflect can tell, but the true func representation can be handled
+// by code like Convert and Interface and Assign.
+// In Haxe, the func value is made by MakeFunc, from a function that calls the method.
+func makeMethodValue(op string, v Value) Value {
+	if v.flag&flagMethod == 0 {
+		panic("reflect: internal error: invalid use of makeMethodValue")
+	}
+
+	// Ignoring the flagMethod bit, v describes the receiver, not the method type.
+	fl := v.flag & (flagRO | flagAddr | flagIndir)
+	fl |= flag(v.typ.Kind())
+	rcvr := Value{v.typ, v.ptr, fl}
+	method := int(v.flag) >> flagMethodShift
+
+	// v.Type returns the actual type of the method value.
+	funcType := v.Type().(*rtype)
+
+	// Cause panic if method is not appropriate.
+	// The panic would still happen during the call if we omit this,
+	// but we want Interface() and other operations to fail early.
+	methodReceiver(op, rcvr, method)
+
+	// The method value uses the receiver as it is now, so take a copy of an indirect one.
+	if rcvr.flag&flagIndir != 0 {
+		c := unsafe_New(rcvr.typ)
+		memmove(c, rcvr.ptr, rcvr.typ.size)
+		rcvr.ptr = c
+	}
+	mv := Value{rcvr.typ, rcvr.ptr, rcvr.flag&flagIndir | flag(Func) | flag(method)<<flagMethodShift | flagMethod}
+	callOp := "Call"
+	if funcType.IsVariadic() {
+		callOp = "CallSlice" // MakeFunc gives the variadic arguments in a slice
+	}
+	fv := MakeFunc(funcType, func(in []Value) []Value { return mv.call(callOp, in) })
+
+	return Value{funcType, fv.ptr, v.flag&flagRO | flag(Func)}
+}
+
+// ptrMethod returns the Closure for a method of p, a pointer type synthesized by ptrTo,
+// which calls fn, the Closure for the method of the element type, with the value pointed to.
+// It is called in the same way as the functions of the methods the compiler knows.
+func ptrMethod(p *rtype, fn unsafe.Pointer) unsafe.Pointer {
+	stub := func(args uintptr) uintptr {
+		rcvr := Value{p, unsafe.Pointer(hx.CodeDynamic("", "_a.itemAddr(0).load().val[0];", args)), flag(Ptr)}
+		if rcvr.IsNil() {
+			panic("reflect: value method of " + p.Elem().String() + " called using nil pointer")
+		}
+		in := hx.CodeDynamic("", "_a.itemAddr(0).load().val.copy();", args)
+		hx.Code("", "_a.itemAddr(0).load().val[0]=_a.itemAddr(1).load().val;", in, packEface(rcvr.Elem()))
+
+		// Call, via a Closure taking no parameters, so that the call is made by the generated code, as in Value.call.
+		var f func() uintptr
+		*(*uintptr)(unsafe.Pointer(&f)) = hx.CodeDynamic("",
+			"Closure.withArgs(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val);", fn, in)
+		return f()
+	}
+	return unsafe.Pointer(hx.CodeDynamic("", "Closure.varArgs(_a.itemAddr(0).load().val);", stub))
+}
+
+// methodValueCall is an assembly function that is the code half of
+// the function returned from makeMethodValue by the gc compilers.
+// It is not used in Haxe, but remains for the upstream code in Value.Pointer.
+func methodValueCall()
@@ -0,0 +1,63 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// +build haxe
+
+package reflect_test
+
+import (
+	. "reflect"
+	"testing"
+)
+
+type getter interface {
+	Get() int
+}
+
+type getInt int
+
+func (g getInt) Get() int { return int(g) }
+
+func TestStructOf(t *testing.T) {
+	fields := []StructField{
+		{Name: "A", Type: TypeOf(byte(0))},
+		{Name: "B", Type: TypeOf(""), Tag: `json:"b"`},
+	}
+	st := StructOf(fields)
+	checkSameType(t, Zero(st).Interface(), struct {
+		A byte
+		B string `json:"b"`
+	}{})
+	if st != StructOf(fields) {
+		t.Errorf("StructOf made two types for %s", st)
+	}
+
+	v := New(StructOf([]StructField{{Name: "X", Type: TypeOf(int64(0))}, {Name: "Y", Type: TypeOf([]int{})}})).Elem()
+	v.Field(0).SetInt(42)
+	v.Field(1).Set(ValueOf([]int{1, 2}))
+	if x, n := v.Field(0).Int(), v.Field(1).Len(); x != 42 || n != 2 {
+		t.Errorf("struct fields are %d and len %d, want 42 and len 2", x, n)
+	}
+	if f, _ := v.Type().FieldByName("Y"); f.Offset != 8 {
+		t.Errorf("field Y is at offset %d, want 8", f.Offset)
+	}
+	shouldPanic(func() { StructOf([]StructField{{Name: "a", Type: TypeOf(0)}}) })
+	shouldPanic(func() { StructOf([]StructField{{Name: "A", Type: TypeOf(0)}, {Name: "A", Type: TypeOf(0)}}) })
+}
+
+func TestCreatedTypeAssert(t *testing.T) {
+	// PtrTo a type with only value methods creates a new pointer type, which must implement the interface
+	v := New(TypeOf(getInt(0)))
+	v.Elem().SetInt(7)
+	g, ok := v.Interface().(getter)
+	if !ok {
+		t.Fatalf("%s does not implement getter", v.Type())
+	}
+	if n := g.Get(); n != 7 {
+		t.Errorf("Get() = %d, want 7", n)
+	}
+	if _, ok := Zero(SliceOf(TypeOf(getInt(0)))).Interface().(getter); ok {
+		t.Errorf("[]getInt implements getter")
+	}
+}
@@ -18,9 +18,12 @@
 package reflect
 
 import (
+	"haxegoruntime"
 	"runtime"
 	"strconv"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 	"unsafe"
 
 	"github.com/tardisgo/tardisgo/haxe/hx"
@@ -511,7 +514,6 @@ func (t *rtype) pointers() bool {
 func (t *rtype) common() *rtype { return t }
 
 func (t *uncommonType) Method(i int) (m Method) {
-	panic("reflect.Method not yet implemented")
 	if t == nil || i < 0 || i >= len(t.methods) {
 		panic("reflect: Method index out of range")
 	}
@@ -541,7 +543,6 @@ func (t *uncommonType) NumMethod() int {
 }
 
 func (t *uncommonType) MethodByName(name string) (m Method, ok bool) {
-	panic("reflect.MethodByName not yet implemented")
 	if t == nil {
 		return
 	}
@@ -569,7 +570,6 @@ func (t *rtype) NumMethod() int {
 }
 
 func (t *rtype) Method(i int) (m Method) {
-	panic("reflect.Method not yet implemented - *interfce")
 	if t.Kind() == Interface {
 		tt := (*interfaceType)(unsafe.Pointer(t))
 		return tt.Method(i)
@@ -578,7 +578,6 @@ func (t *rtype) Method(i int) (m Method) {
 }
 
 func (t *rtype) MethodByName(name string) (m Method, ok bool) {
-	panic("reflect.MethodByName not yet implemented - *interface")
 	if t.Kind() == Interface {
 		tt := (*interfaceType)(unsafe.Pointer(t))
 		return tt.MethodByName(name)
@@ -748,7 +747,6 @@ func (d ChanDir) String() string {
 
 // Method returns the i'th method in the type's method set.
 func (t *interfaceType) Method(i int) (m Method) {
-	panic("reflect.Method not yet implemented")
 	if i < 0 || i >= len(t.methods) {
 		return
 	}
@@ -770,7 +768,6 @@ func (t *interfaceType) NumMethod() int {
 
 // MethodByName method with the given name in the type's method set.
 func (t *interfaceType) MethodByName(name string) (m Method, ok bool) {
-	panic("reflect.MethodByName not yet implemented")
 	if t == nil {
 		return
 	}
@@ -1082,10 +1079,10 @@ func (t *rtype) ptrTo() *rtype {
 	if p := t.ptrToThis; p != nil {
 		return p
 	}
-	//panic("reflect.PtrTo pointer synthesis not yet implemented")
 
 	// Otherwise, synthesize one.
-	// This only happens for pointers with no methods.
+	// This only happens for pointers that the compiler did not see,
+	// which have the methods of t, called via the value pointed to.
 	// We keep the mapping in a map on the side, because
 	// this operation is rare and a separate map lets us keep
 	// the type structures in read-only memory.
@@ -1127,14 +1124,135 @@ func (t *rtype) ptrTo() *rtype {
 
 	p.uncommonType = nil
 	p.ptrToThis = nil
-	p.zero = unsafe.Pointer(&make([]byte, p.size)[0])
+	p.zero = hx.Malloc(p.size)
 	p.elem = t
+	p.uncommonType = ptrMethods(&p.rtype, t)
+	addHaxeType(&p.rtype)
 
 	ptrMap.m[t] = p
 	ptrMap.Unlock()
 	return &p.rtype
 }
 
+// ptrMethods returns the uncommonType for p, a pointer type synthesized by ptrTo,
+// giving it the methods of its element type t, or nil if t has none.
+// Each method calls that of t with the value pointed to, and has a func type made by funcOf.
+func ptrMethods(p, t *rtype) *uncommonType {
+	ut := t.uncommon()
+	if ut == nil || len(ut.methods) == 0 || t.Kind() == Interface {
+		return nil
+	}
+	pt := &uncommonType{methods: make([]method, len(ut.methods))}
+	for i := range ut.methods {
+		m := ut.methods[i]
+		if m.mtyp != nil {
+			mt := (*funcType)(unsafe.Pointer(m.mtyp))
+			m.typ = funcOf(append([]*rtype{p}, mt.in...), mt.out, mt.dotdotdot)
+		} else {
+			m.typ = nil
+		}
+		if m.ifn != nil {
+			m.ifn = ptrMethod(p, m.ifn)
+		}
+		m.tfn = m.ifn
+		pt.methods[i] = m
+	}
+	return pt
+}
+
+// The funcCache caches the func types made by funcOf, by their string.
+var funcCache struct {
+	sync.Mutex
+	m map[string][]*funcType
+}
+
+// funcOf returns the func type with the given parameter and result types,
+// used by ptrMethods for the types of the methods of synthesized pointer types.
+func funcOf(in, out []*rtype, variadic bool) *rtype {
+	same := func(ft *funcType) bool {
+		if ft.dotdotdot != variadic || len(ft.in) != len(in) || len(ft.out) != len(out) {
+			return false
+		}
+		for i := range in {
+			if ft.in[i] != in[i] {
+				return false
+			}
+		}
+		for i := range out {
+			if ft.out[i] != out[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	s := "func("
+	for i, t := range in {
+		if i > 0 {
+			s += ", "
+		}
+		if variadic && i == len(in)-1 {
+			s += "..." + *(*sliceType)(unsafe.Pointer(t)).elem.string
+		} else {
+			s += *t.string
+		}
+	}
+	s += ")"
+	switch len(out) {
+	case 0:
+	case 1:
+		s += " " + *out[0].string
+	default:
+		s += " ("
+		for i, t := range out {
+			if i > 0 {
+				s += ", "
+			}
+			s += *t.string
+		}
+		s += ")"
+	}
+
+	// Look in cache.
+	funcCache.Lock()
+	defer funcCache.Unlock()
+	if funcCache.m == nil {
+		funcCache.m = make(map[string][]*funcType)
+	}
+	for _, ft := range funcCache.m[s] {
+		if same(ft) {
+			return &ft.rtype
+		}
+	}
+
+	// Look in known types, whose strings also name their parameters, so may not match.
+	for _, tt := range haxegoruntime.TypeTable {
+		t := (*rtype)(unsafe.Pointer(tt))
+		if t != nil && t.Kind() == Func && t.Name() == "" && same((*funcType)(unsafe.Pointer(t))) {
+			ft := (*funcType)(unsafe.Pointer(t))
+			funcCache.m[s] = append(funcCache.m[s], ft)
+			return t
+		}
+	}
+
+	// Make a func type.
+	prototype := (*funcType)(unsafe.Pointer(haxeInterfaceUnpack((func())(nil)).typ))
+	ft := new(funcType)
+	*ft = *prototype
+	ft.string = &s
+	ft.hash = fnv1(0, []byte(s)...)
+	ft.dotdotdot = variadic
+	ft.in = in
+	ft.out = out
+	ft.uncommonType = nil
+	ft.ptrToThis = nil
+	ft.zero = hx.Malloc(ft.size)
+	addHaxeType(&ft.rtype)
+
+	funcCache.m[s] = append(funcCache.m[s], ft)
+	return &ft.rtype
+}
+
 // fnv1 incorporates the list of bytes into the hash x using the FNV-1 hash function.
 func fnv1(x uint32, list ...byte) uint32 {
 	for _, b := range list {
@@ -1205,7 +1323,7 @@ func implements(T, V *rtype) bool {
 		for j := 0; j < len(v.methods); j++ {
 			tm := &t.methods[i]
 			vm := &v.methods[j]
-			if vm.name == tm.name && vm.pkgPath == tm.pkgPath && vm.typ == tm.typ {
+			if sameName(vm.name, vm.pkgPath, tm.name, tm.pkgPath) && vm.typ == tm.typ {
 				if i++; i >= len(t.methods) {
 					return true
 				}
@@ -1222,7 +1340,7 @@ func implements(T, V *rtype) bool {
 	for j := 0; j < len(v.methods); j++ {
 		tm := &t.methods[i]
 		vm := &v.methods[j]
-		if vm.name == tm.name && vm.pkgPath == tm.pkgPath && vm.mtyp == tm.typ {
+		if sameName(vm.name, vm.pkgPath, tm.name, tm.pkgPath) && vm.mtyp == tm.typ {
 			if i++; i >= len(t.methods) {
 				return true
 			}
@@ -1231,6 +1349,13 @@ func implements(T, V *rtype) bool {
 	return false
 }
 
+// sameName returns true if two methods have the same name and package path.
+// In Haxe, each type holds its own copies of these strings, so they are compared by value, rather than by address.
+func sameName(name1, pkgPath1, name2, pkgPath2 *string) bool {
+	return *name1 == *name2 &&
+		(pkgPath1 == nil && pkgPath2 == nil || pkgPath1 != nil && pkgPath2 != nil && *pkgPath1 == *pkgPath2)
+}
+
 // directlyAssignable returns true if a value x of type V can be directly
 // assigned (using memmove) to a value of type T.
 // http://golang.org/doc/go_spec.html#Assignability
@@ -1349,46 +1474,35 @@ func haveIdenticalUnderlyingType(T, V *rtype) bool {
 	return false
 }
 
-// typelinks is implemented in package runtime.
-// It returns a slice of all the 'typelink' information in the binary,
-// which is to say a slice of known types, sorted by string.
-// Note that strings are not unique identifiers for types:
-// there can be more than one with a given string.
-// Only types we might want to look up are included:
+// typelinks returns a slice of the known types that we might want to look up:
 // channels, maps, slices, and arrays.
-func typelinks() []*rtype { panic("reflect: typelinks not yet implemented in haxe") }
+// In Haxe, these are found in the TypeTable made by the compiler, in the order of their type numbers.
+func typelinks() []*rtype {
+	var typ []*rtype
+	for _, tt := range haxegoruntime.TypeTable {
+		t := (*rtype)(unsafe.Pointer(tt))
+		if t != nil {
+			switch t.Kind() {
+			case Chan, Map, Slice, Array:
+				typ = append(typ, t)
+			}
+		}
+	}
+	return typ
+}
 
-// typesByString returns the subslice of typelinks() whose elements have
+// typesByString returns the elements of typelinks() that have
 // the given string representation.
 // It may be empty (no known types with that string) or may have
 // multiple elements (multiple types with that string).
 func typesByString(s string) []*rtype {
-	typ := typelinks()
-
-	// We are looking for the first index i where the string becomes >= s.
-	// This is a copy of sort.Search, with f(h) replaced by (*typ[h].string >= s).
-	i, j := 0, len(typ)
-	for i < j {
-		h := i + (j-i)/2 // avoid overflow when computing h
-		// i ≤ h < j
-		if !(*typ[h].string >= s) {
-			i = h + 1 // preserves f(i-1) == false
-		} else {
-			j = h // preserves f(j) == true
+	var typ []*rtype
+	for _, t := range typelinks() {
+		if *t.string == s {
+			typ = append(typ, t)
 		}
 	}
-	// i == j, f(i-1) == false, and f(j) (= f(i)) == true  =>  answer is i.
-
-	// Having found the first, linear scan forward to find the last.
-	// We could do a second binary search, but the caller is going
-	// to do a linear scan anyway.
-	j = i
-	for j < len(typ) && *typ[j].string == s {
-		j++
-	}
-
-	// This slice will be empty if the string is not found.
-	return typ[i:j]
+	return typ
 }
 
 // The lookupCache caches ChanOf, MapOf, and SliceOf lookups.
@@ -1448,7 +1562,6 @@ func cachePut(k cacheKey, t *rtype) Type {
 // The gc runtime imposes a limit of 64 kB on channel element types.
 // If t's size is equal to or exceeds this limit, ChanOf panics.
 func ChanOf(dir ChanDir, t Type) Type {
-	panic("reflect.ChanOf not yet implemented")
 	typ := t.(*rtype)
 
 	// Look in cache.
@@ -1486,20 +1599,39 @@ func ChanOf(dir ChanDir, t Type) Type {
 
 	// Make a channel type.
 	var ichan interface{} = (chan unsafe.Pointer)(nil)
-	prototype := *(**chanType)(unsafe.Pointer(&ichan))
+	prototype := (*chanType)(unsafe.Pointer(haxeInterfaceUnpack(ichan).typ))
 	ch := new(chanType)
 	*ch = *prototype
 	ch.string = &s
 	ch.hash = fnv1(typ.hash, 'c', byte(dir))
 	ch.elem = typ
+	ch.dir = uintptr(dir)
 	ch.uncommonType = nil
 	ch.ptrToThis = nil
-	ch.zero = unsafe.Pointer(&make([]byte, ch.size)[0])
+	ch.zero = hx.Malloc(ch.size)
+	addHaxeType(&ch.rtype)
 
 	return cachePut(ckey, &ch.rtype)
 }
 
-func ismapkey(*rtype) bool { panic("reflect.ismapkey() not yet implemented in haxe") } // implemented in runtime
+// ismapkey reports whether values of type t may be map keys, that is whether they may be compared.
+// In gc it is implemented in runtime.
+func ismapkey(t *rtype) bool {
+	switch t.Kind() {
+	case Func, Map, Slice:
+		return false
+	case Array:
+		return ismapkey((*arrayType)(unsafe.Pointer(t)).elem)
+	case Struct:
+		st := (*structType)(unsafe.Pointer(t))
+		for i := range st.fields {
+			if !ismapkey(st.fields[i].typ) {
+				return false
+			}
+		}
+	}
+	return true
+}
 
 // MapOf returns the map type with the given key and element types.
 // For example, if k represents int and e represents string,
@@ -1508,8 +1640,6 @@ func ismapkey(*rtype) bool { panic("reflect.ismapkey() not yet implemented in ha
 // If the key type is not a valid map key type (that is, if it does
 // not implement Go's == operator), MapOf panics.
 func MapOf(key, elem Type) Type {
-	panic("reflect.MapOf not yet implemented")
-
 	ktyp := key.(*rtype)
 	etyp := elem.(*rtype)
 
@@ -1533,15 +1663,15 @@ func MapOf(key, elem Type) Type {
 	}
 
 	// Make a map type.
+	// In Haxe, maps are GOmap objects, so there is no bucket type.
 	var imap interface{} = (map[unsafe.Pointer]unsafe.Pointer)(nil)
-	prototype := *(**mapType)(unsafe.Pointer(&imap))
+	prototype := (*mapType)(unsafe.Pointer(haxeInterfaceUnpack(imap).typ))
 	mt := new(mapType)
 	*mt = *prototype
 	mt.string = &s
 	mt.hash = fnv1(etyp.hash, 'm', byte(ktyp.hash>>24), byte(ktyp.hash>>16), byte(ktyp.hash>>8), byte(ktyp.hash))
 	mt.key = ktyp
 	mt.elem = etyp
-	mt.bucket = bucketOf(ktyp, etyp)
 	if ktyp.size > maxKeySize {
 		mt.keysize = uint8(ptrSize)
 		mt.indirectkey = 1
@@ -1556,10 +1686,10 @@ func MapOf(key, elem Type) Type {
 		mt.valuesize = uint8(etyp.size)
 		mt.indirectvalue = 0
 	}
-	mt.bucketsize = uint16(mt.bucket.size)
 	mt.uncommonType = nil
 	mt.ptrToThis = nil
-	mt.zero = unsafe.Pointer(&make([]byte, mt.size)[0])
+	mt.zero = hx.Malloc(mt.size)
+	addHaxeType(&mt.rtype)
 
 	return cachePut(ckey, &mt.rtype)
 }
@@ -1716,8 +1846,6 @@ func bucketOf(ktyp, etyp *rtype) *rtype {
 // SliceOf returns the slice type with element type t.
 // For example, if t represents int, SliceOf(t) represents []int.
 func SliceOf(t Type) Type {
-	panic("reflect.SliceOf not yet implemented")
-
 	typ := t.(*rtype)
 
 	// Look in cache.
@@ -1737,7 +1865,7 @@ func SliceOf(t Type) Type {
 
 	// Make a slice type.
 	var islice interface{} = ([]unsafe.Pointer)(nil)
-	prototype := *(**sliceType)(unsafe.Pointer(&islice))
+	prototype := (*sliceType)(unsafe.Pointer(haxeInterfaceUnpack(islice).typ))
 	slice := new(sliceType)
 	*slice = *prototype
 	slice.string = &s
@@ -1745,11 +1873,167 @@ func SliceOf(t Type) Type {
 	slice.elem = typ
 	slice.uncommonType = nil
 	slice.ptrToThis = nil
-	slice.zero = unsafe.Pointer(&make([]byte, slice.size)[0])
+	slice.zero = hx.Malloc(slice.size)
+	addHaxeType(&slice.rtype)
 
 	return cachePut(ckey, &slice.rtype)
 }
 
+// The structCache caches the struct types made by StructOf, by their string.
+var structCache struct {
+	sync.Mutex
+	m map[string][]*structType
+}
+
+// StructOf returns the struct type containing fields.
+// The Offset and Index fields are ignored and computed as they would be by the compiler.
+//
+// As in gc, StructOf does not support embedded fields of types with methods,
+// which would need those methods promoting to the new type.
+func StructOf(fields []StructField) Type {
+	fs := make([]structField, len(fields))
+	seen := make(map[string]bool)
+	s := "struct{"
+	size, maxAlign := uintptr(0), uintptr(1)
+	for i, field := range fields {
+		if field.Type == nil {
+			panic("reflect.StructOf: field " + strconv.Itoa(i) + " has no type")
+		}
+		ft := field.Type.common()
+		name := field.Name
+		if field.Anonymous {
+			if ft.NumMethod() > 0 || ft.Kind() == Ptr && ft.Elem().NumMethod() > 0 {
+				panic("reflect.StructOf: embedded field " + strconv.Itoa(i) + " has methods, which is not implemented")
+			}
+			if ft.Kind() == Ptr {
+				name = ft.Elem().Name()
+			} else {
+				name = ft.Name()
+			}
+		}
+		if !isValidFieldName(name) {
+			panic("reflect.StructOf: field " + strconv.Itoa(i) + " has invalid name " + strconv.Quote(name))
+		}
+		if seen[name] {
+			panic("reflect.StructOf: duplicate field " + name)
+		}
+		seen[name] = true
+
+		f := &fs[i]
+		f.typ = ft
+		if !field.Anonymous {
+			f.name = &name
+		}
+		if c, _ := utf8.DecodeRuneInString(name); !unicode.IsUpper(c) {
+			if field.PkgPath == "" {
+				panic("reflect.StructOf: field " + strconv.Quote(name) + " is unexported but missing PkgPath")
+			}
+			pkgPath := field.PkgPath
+			f.pkgPath = &pkgPath
+		}
+		tag := string(field.Tag) // as for the types the compiler knows, never nil
+		f.tag = &tag
+		size = align(size, uintptr(ft.fieldAlign))
+		f.offset = size
+		size += ft.size
+		if uintptr(ft.align) > maxAlign {
+			maxAlign = uintptr(ft.align)
+		}
+
+		if i > 0 {
+			s += "; "
+		}
+		if !field.Anonymous {
+			s += name + " "
+		}
+		s += *ft.string
+		if tag != "" {
+			s += " " + strconv.Quote(tag)
+		}
+	}
+	s += "}"
+
+	// Look in cache.
+	structCache.Lock()
+	defer structCache.Unlock()
+	if structCache.m == nil {
+		structCache.m = make(map[string][]*structType)
+	}
+	for _, st := range structCache.m[s] {
+		if sameFields(st.fields, fs) {
+			return &st.rtype
+		}
+	}
+
+	// Look in known types.
+	for _, tt := range haxegoruntime.TypeTable {
+		t := (*rtype)(unsafe.Pointer(tt))
+		if t != nil && t.Kind() == Struct && t.Name() == "" {
+			st := (*structType)(unsafe.Pointer(t))
+			if sameFields(st.fields, fs) {
+				structCache.m[s] = append(structCache.m[s], st)
+				return t
+			}
+		}
+	}
+
+	// Make a struct type.
+	var istruct interface{} = struct{}{}
+	prototype := (*structType)(unsafe.Pointer(haxeInterfaceUnpack(istruct).typ))
+	st := new(structType)
+	*st = *prototype
+	st.string = &s
+	st.hash = fnv1(0, []byte(s)...)
+	st.size = size
+	st.align = uint8(maxAlign)
+	st.fieldAlign = uint8(maxAlign)
+	st.fields = fs
+	st.uncommonType = nil
+	st.ptrToThis = nil
+	st.zero = hx.Malloc(st.size)
+	addHaxeType(&st.rtype)
+
+	structCache.m[s] = append(structCache.m[s], st)
+	return &st.rtype
+}
+
+// isValidFieldName reports whether name is a valid Go identifier.
+func isValidFieldName(name string) bool {
+	for i, c := range name {
+		if !(unicode.IsLetter(c) || c == '_' || i > 0 && unicode.IsDigit(c)) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// sameFields reports whether the struct fields f1 and f2 are the same,
+// as they are for identical struct types.
+func sameFields(f1, f2 []structField) bool {
+	if len(f1) != len(f2) {
+		return false
+	}
+	for i := range f1 {
+		tf, vf := &f1[i], &f2[i]
+		if tf.name != vf.name && (tf.name == nil || vf.name == nil || *tf.name != *vf.name) {
+			return false
+		}
+		if tf.pkgPath != vf.pkgPath && (tf.pkgPath == nil || vf.pkgPath == nil || *tf.pkgPath != *vf.pkgPath) {
+			return false
+		}
+		if tf.typ != vf.typ {
+			return false
+		}
+		if tf.tag != vf.tag && (tf.tag == nil || vf.tag == nil || *tf.tag != *vf.tag) {
+			return false
+		}
+		if tf.offset != vf.offset {
+			return false
+		}
+	}
+	return true
+}
+
 // ArrayOf returns the array type with the given count and element type.
 // For example, if t represents int, ArrayOf(5, t) represents [5]int.
 //
@@ -87,12 +87,18 @@ func (v Value) pointer() unsafe.Pointer {
 	if v.typ.size != ptrSize || !v.typ.pointers() {
 		panic("can't call pointer on a non-pointer Value")
 	}
-	if v.flag&flagIndir != 0 {
+	if v.flag&flagIndir != 0 || haxeHeld(v.kind()) {
 		return *(*unsafe.Pointer)(v.ptr)
 	}
 	return v.ptr
 }
 
+// haxeHeld reports whether Values of kind k hold a Haxe object rather than a Go pointer,
+// in which case both direct and indirect Values point to the memory holding that object.
+func haxeHeld(k Kind) bool {
+	return k == Map || k == Func || k == Chan
+}
+
 // packEface converts v to the empty interface.
 func packEface(v Value) interface{} {
 	t := v.typ
@@ -101,6 +107,9 @@ func packEface(v Value) interface{} {
 	e := &emptyInterface{} //(*emptyInterface)(unsafe.Pointer(&i))
 	// First, fill in the data portion of the interface.
 	switch {
+	case haxeHeld(t.Kind()):
+		// the Haxe object is loaded from the memory v.ptr points to when packed
+		e.word = v.ptr
 	case ifaceIndir(t):
 		if v.flag&flagIndir == 0 {
 			panic("bad indir")
@@ -303,7 +312,6 @@ func (v Value) CanSet() bool {
 // If v is a variadic function, Call creates the variadic slice parameter
 // itself, copying in the corresponding values.
 func (v Value) Call(in []Value) []Value {
-	panic("reflect.Call not yet implemented")
 	v.mustBe(Func)
 	v.mustBeExported()
 	return v.call("Call", in)
@@ -317,7 +325,6 @@ func (v Value) Call(in []Value) []Value {
 // As in Go, each input argument must be assignable to the
 // type of the function's corresponding input parameter.
 func (v Value) CallSlice(in []Value) []Value {
-	panic("reflect.CallSlice not yet implemented")
 	v.mustBe(Func)
 	v.mustBeExported()
 	return v.call("CallSlice", in)
@@ -326,7 +333,7 @@ func (v Value) CallSlice(in []Value) []Value {
 var callGC bool // for testing; see TestCallMethodJump
 
 func (v Value) call(op string, in []Value) []Value {
-	// Get function pointer, type.
+	// Get function type, and the memory holding the Haxe Closure of the function.
 	t := v.typ
 	var (
 		fn       unsafe.Pointer
@@ -336,13 +343,11 @@ func (v Value) call(op string, in []Value) []Value {
 	if v.flag&flagMethod != 0 {
 		rcvr = v
 		rcvrtype, t, fn = methodReceiver(op, v, int(v.flag)>>flagMethodShift)
-	} else if v.flag&flagIndir != 0 {
-		fn = *(*unsafe.Pointer)(v.ptr)
 	} else {
-		fn = v.ptr
+		fn = v.ptr // both direct and indirect func Values point to the memory holding the Closure
 	}
 
-	if fn == nil {
+	if hx.IsNull(*(*uintptr)(fn)) {
 		panic("reflect.Value.Call: call of nil function")
 	}
 
@@ -403,50 +408,36 @@ func (v Value) call(op string, in []Value) []Value {
 	}
 	nout := t.NumOut()
 
-	// Compute frame type, allocate a chunk of memory for frame
-	frametype, _, retOffset, _ := funcLayout(t, rcvrtype)
-	args := unsafe_New(frametype)
-	off := uintptr(0)
-
-	// Copy inputs into args.
+	// Copy inputs into a Haxe Array, as the values they would have in an interface.
+	args := hx.CodeDynamic("", "new Array<Dynamic>();")
 	if rcvrtype != nil {
 		storeRcvr(rcvr, args)
-		off = ptrSize
 	}
 	for i, v := range in {
 		v.mustBeExported()
-		targ := t.In(i).(*rtype)
-		a := uintptr(targ.align)
-		off = (off + a - 1) &^ (a - 1)
-		n := targ.size
-		addr := unsafe.Pointer(uintptr(args) + off)
-		v = v.assignTo("reflect.Value.Call", targ, addr)
-		if v.flag&flagIndir != 0 {
-			memmove(addr, v.ptr, n)
-		} else {
-			*(*unsafe.Pointer)(addr) = v.ptr
-		}
-		off += n
+		v = v.assignTo("reflect.Value.Call", t.In(i).(*rtype), nil)
+		hx.Code("", "_a.itemAddr(0).load().val.push(_a.itemAddr(1).load().val);", args, packEface(v))
 	}
 
-	// Call.
-	call(fn, args, uint32(frametype.size), uint32(retOffset))
+	// Call, via a Closure taking no parameters, so that the call is made by the generated code.
+	var f func() uintptr
+	*(*uintptr)(unsafe.Pointer(&f)) = hx.CodeDynamic("",
+		"Closure.withArgs(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val);", *(*uintptr)(fn), args)
+	res := f()
 
 	// For testing; see TestCallMethodJump.
 	if callGC {
 		runtime.GC()
 	}
 
-	// Copy return values out of args.
+	// Copy return values out of the result, which holds multiple values in fields r0, r1...
 	ret := make([]Value, nout)
-	off = retOffset
 	for i := 0; i < nout; i++ {
-		tv := t.Out(i)
-		a := uintptr(tv.Align())
-		off = (off + a - 1) &^ (a - 1)
-		fl := flagIndir | flag(tv.Kind())
-		ret[i] = Value{tv.common(), unsafe.Pointer(uintptr(args) + off), fl}
-		off += tv.Size()
+		r := res
+		if nout > 1 {
+			r = hx.CodeDynamic("", "Reflect.field(_a.itemAddr(0).load().val,'r'+_a.itemAddr(1).load().val);", res, i)
+		}
+		ret[i] = haxeValue(t.Out(i).common(), r)
 	}
 
 	return ret
@@ -455,41 +446,22 @@ func (v Value) call(op string, in []Value) []Value {
 // callReflect is the call implementation used by a function
 // returned by MakeFunc. In many ways it is the opposite of the
 // method Value.call above. The method above converts a call using Values
-// into a call of a function with a concrete argument frame, while
-// callReflect converts a call of a function with a concrete argument
-// frame into a call using Values.
+// into a call of a Haxe function, while callReflect converts a call
+// of a Haxe function into a call using Values.
+// The args are a Haxe Array of the parameter values,
+// the result is in the form expected by the generated code:
+// nothing, a single value, or an object with multiple values in fields r0, r1...
 // It is in this file so that it can be next to the call method above.
-// The remainder of the MakeFunc implementation is in makefunc.go.
-//
-// NOTE: This function must be marked as a "wrapper" in the generated code,
-// so that the linker can make it work correctly for panic and recover.
-// The gc compilers know to do that for the name "reflect.callReflect".
-func callReflect(ctxt *makeFuncImpl, frame unsafe.Pointer) {
+// The remainder of the MakeFunc implementation is in makefunc_haxe.go.
+func callReflect(ctxt *makeFuncImpl, args uintptr) uintptr {
 	ftyp := ctxt.typ
 	f := ctxt.fn
 
-	// Copy argument frame into Values.
-	ptr := frame
-	off := uintptr(0)
+	// Copy arguments into Values.
 	in := make([]Value, 0, len(ftyp.in))
-	for _, arg := range ftyp.in {
-		typ := arg
-		off += -off & uintptr(typ.align-1)
-		addr := unsafe.Pointer(uintptr(ptr) + off)
-		v := Value{typ, nil, flag(typ.Kind())}
-		if ifaceIndir(typ) {
-			// value cannot be inlined in interface data.
-			// Must make a copy, because f might keep a reference to it,
-			// and we cannot let f keep a reference to the stack frame
-			// after this function returns, not even a read-only reference.
-			v.ptr = unsafe_New(typ)
-			memmove(v.ptr, addr, typ.size)
-			v.flag |= flagIndir
-		} else {
-			v.ptr = *(*unsafe.Pointer)(addr)
-		}
-		in = append(in, v)
-		off += typ.size
+	for i, arg := range ftyp.in {
+		in = append(in, haxeValue(arg,
+			hx.CodeDynamic("", "_a.itemAddr(0).load().val[_a.itemAddr(1).load().val];", args, i)))
 	}
 
 	// Call underlying function.
@@ -498,34 +470,22 @@ func callReflect(ctxt *makeFuncImpl, frame unsafe.Pointer) {
 		panic("reflect: wrong return count from function created by MakeFunc")
 	}
 
-	// Copy results back into argument frame.
-	if len(ftyp.out) > 0 {
-		off += -off & (ptrSize - 1)
-		if runtime.GOARCH == "amd64p32" {
-			off = align(off, 8)
+	// Copy results back into Haxe values.
+	res := hx.CodeDynamic("", "new Array<Dynamic>();")
+	for i, typ := range ftyp.out {
+		v := out[i]
+		if v.typ != typ {
+			panic("reflect: function created by MakeFunc using " + funcName(f) +
+				" returned wrong type: have " +
+				out[i].typ.String() + " for " + typ.String())
 		}
-		for i, arg := range ftyp.out {
-			typ := arg
-			v := out[i]
-			if v.typ != typ {
-				panic("reflect: function created by MakeFunc using " + funcName(f) +
-					" returned wrong type: have " +
-					out[i].typ.String() + " for " + typ.String())
-			}
-			if v.flag&flagRO != 0 {
-				panic("reflect: function created by MakeFunc using " + funcName(f) +
-					" returned value obtained from unexported field")
-			}
-			off += -off & uintptr(typ.align-1)
-			addr := unsafe.Pointer(uintptr(ptr) + off)
-			if v.flag&flagIndir != 0 {
-				memmove(addr, v.ptr, typ.size)
-			} else {
-				*(*unsafe.Pointer)(addr) = v.ptr
-			}
-			off += typ.size
+		if v.flag&flagRO != 0 {
+			panic("reflect: function created by MakeFunc using " + funcName(f) +
+				" returned value obtained from unexported field")
 		}
+		hx.Code("", "_a.itemAddr(0).load().val.push(_a.itemAddr(1).load().val);", res, packEface(v))
 	}
+	return hx.CodeDynamic("", "Closure.results(_a.itemAddr(0).load().val);", res)
 }
 
 // methodReceiver returns information about the receiver
@@ -534,11 +494,10 @@ func callReflect(ctxt *makeFuncImpl, frame unsafe.Pointer) {
 // not be used.
 // The return value rcvrtype gives the method's actual receiver type.
 // The return value t gives the method type signature (without the receiver).
-// The return value fn is a pointer to the method code.
+// The return value fn is a pointer to the memory holding the Haxe Closure of the method.
 func methodReceiver(op string, v Value, methodIndex int) (rcvrtype, t *rtype, fn unsafe.Pointer) {
 	i := methodIndex
 	if v.typ.Kind() == Interface {
-		panic("reflect.methodReciever unhandled *interface ")
 		tt := (*interfaceType)(unsafe.Pointer(v.typ))
 		if uint(i) >= uint(len(tt.methods)) {
 			panic("reflect: internal error: invalid method index")
@@ -547,12 +506,24 @@ func methodReceiver(op string, v Value, methodIndex int) (rcvrtype, t *rtype, fn
 		if m.pkgPath != nil {
 			panic("reflect: " + op + " of unexported method")
 		}
-		iface := (*nonEmptyInterface)(v.ptr)
-		if iface.itab == nil {
+		// Haxe has no itab, so find the method of the dynamic type with the same name.
+		iface := *(*interface{})(v.ptr)
+		if iface == nil {
 			panic("reflect: " + op + " of method on nil interface value")
 		}
-		rcvrtype = iface.itab.typ
-		fn = unsafe.Pointer(&iface.itab.fun[i])
+		rcvrtype = createHaxeType(hx.CodeInt("", "_a.itemAddr(0).load().typ;", iface))
+		ut := rcvrtype.uncommon()
+		if ut != nil {
+			for j := range ut.methods {
+				if sameName(ut.methods[j].name, ut.methods[j].pkgPath, m.name, m.pkgPath) {
+					fn = unsafe.Pointer(&ut.methods[j].ifn)
+					break
+				}
+			}
+		}
+		if fn == nil {
+			panic("reflect: internal error: " + rcvrtype.String() + " has no method " + *m.name)
+		}
 		t = m.typ
 	} else {
 		rcvrtype = v.typ
@@ -570,21 +541,20 @@ func methodReceiver(op string, v Value, methodIndex int) (rcvrtype, t *rtype, fn
 	return
 }
 
-// v is a method receiver.  Store at p the word which is used to
+// v is a method receiver.  Add to the Haxe Array args the value which is used to
 // encode that receiver at the start of the argument list.
-// Reflect uses the "interface" calling convention for
-// methods, which always uses one word to record the receiver.
-func storeRcvr(v Value, p unsafe.Pointer) {
+// As for calls through an interface, this is the value held in an interface.
+func storeRcvr(v Value, args uintptr) {
 	t := v.typ
+	var rcvr interface{}
 	if t.Kind() == Interface {
-		// the interface data word becomes the receiver word
-		iface := (*nonEmptyInterface)(v.ptr)
-		*(*unsafe.Pointer)(p) = unsafe.Pointer(iface.word)
-	} else if v.flag&flagIndir != 0 && !ifaceIndir(t) {
-		*(*unsafe.Pointer)(p) = *(*unsafe.Pointer)(v.ptr)
+		// the value held in the interface becomes the receiver
+		rcvr = *(*interface{})(v.ptr)
 	} else {
-		*(*unsafe.Pointer)(p) = v.ptr
+		// ignoring the flagMethod bit, v describes the receiver
+		rcvr = packEface(Value{t, v.ptr, v.flag&flagIndir | flag(t.Kind())})
 	}
+	hx.Code("", "_a.itemAddr(0).load().val.push(_a.itemAddr(1).load().val);", args, rcvr)
 }
 
 // align returns the result of rounding x up to a multiple of n.
@@ -593,45 +563,6 @@ func align(x, n uintptr) uintptr {
 	return (x + n - 1) &^ (n - 1)
 }
 
-// callMethod is the call implementation used by a function returned
-// by makeMethodValue (used by v.Method(i).Interface()).
-// It is a streamlined version of the usual reflect call: the caller has
-// already laid out the argument frame for us, so we don't have
-// to deal with individual Values for each argument.
-// It is in this file so that it can be next to the two similar functions above.
-// The remainder of the makeMethodValue implementation is in makefunc.go.
-//
-// NOTE: This function must be marked as a "wrapper" in the generated code,
-// so that the linker can make it work correctly for panic and recover.
-// The gc compilers know to do that for the name "reflect.callMethod".
-func callMethod(ctxt *methodValue, frame unsafe.Pointer) {
-	rcvr := ctxt.rcvr
-	rcvrtype, t, fn := methodReceiver("call", rcvr, ctxt.method)
-	frametype, argSize, retOffset, _ := funcLayout(t, rcvrtype)
-
-	// Make a new frame that is one word bigger so we can store the receiver.
-	args := unsafe_New(frametype)
-
-	// Copy in receiver and rest of args.
-	storeRcvr(rcvr, args)
-	memmove(unsafe.Pointer(uintptr(args)+ptrSize), frame, argSize-ptrSize)
-
-	// Call.
-	call(fn, args, uint32(frametype.size), uint32(retOffset))
-
-	// Copy return values. On amd64p32, the beginning of return values
-	// is 64-bit aligned, so the caller's frame layout (which doesn't have
-	// a receiver) is different from the layout of the fn call, which has
-	// a receiver.
-	// Ignore any changes to args and just copy return values.
-	callerRetOffset := retOffset - ptrSize
-	if runtime.GOARCH == "amd64p32" {
-		callerRetOffset = align(argSize-ptrSize, 8)
-	}
-	memmove(unsafe.Pointer(uintptr(frame)+callerRetOffset),
-		unsafe.Pointer(uintptr(args)+retOffset), frametype.size-retOffset)
-}
-
 // funcName returns the name of f, for use in error messages.
 func funcName(f func([]Value) []Value) string {
 	pc := *(*uintptr)(unsafe.Pointer(&f))
@@ -784,7 +715,6 @@ func (v Value) FieldByIndex(index []int) Value {
 // It returns the zero Value if no field was found.
 // It panics if v's Kind is not struct.
 func (v Value) FieldByName(name string) Value {
-	panic("reflect.value.FieldByName not yet implemented")
 	v.mustBe(Struct)
 	if f, ok := v.typ.FieldByName(name); ok {
 		return v.FieldByIndex(f.Index)
@@ -797,7 +727,6 @@ func (v Value) FieldByName(name string) Value {
 // It panics if v's Kind is not struct.
 // It returns the zero Value if no field was found.
 func (v Value) FieldByNameFunc(match func(string) bool) Value {
-	panic("reflect.value.FieldByNameFunc not yet implemented")
 	if f, ok := v.typ.FieldByNameFunc(match); ok {
 		return v.FieldByIndex(f.Index)
 	}
@@ -964,10 +893,15 @@ func (v Value) InterfaceData() [2]uintptr {
 func (v Value) IsNil() bool {
 	k := v.kind()
 	switch k {
-	case Chan, Func, Map, Ptr:
+	case Func:
 		if v.flag&flagMethod != 0 {
 			return false
 		}
+		// in Haxe, both direct and indirect func Values point to the memory holding the Closure
+		return hx.IsNull(*(*uintptr)(v.ptr))
+	case Chan, Map:
+		return hx.IsNull(*(*uintptr)(v.ptr))
+	case Ptr:
 		ptr := v.ptr
 		if v.flag&flagIndir != 0 {
 			ptr = *(*unsafe.Pointer)(ptr)
@@ -1051,28 +985,15 @@ func (v Value) MapIndex(key Value) Value {
 	// of unexported fields.
 	key = key.assignTo("reflect.Value.MapIndex", tt.key, nil)
 
-	var k unsafe.Pointer
-	if key.flag&flagIndir != 0 {
-		k = key.ptr
-	} else {
-		k = unsafe.Pointer(&key.ptr)
-	}
-	e := mapaccess(v.typ, *(*uintptr)(v.ptr) /*v.pointer()*/, k)
-	if e == nil {
+	e, ok := mapaccess(v.typ, uintptr(v.pointer()), packEface(key))
+	if !ok {
 		return Value{}
 	}
-	typ := tt.elem
-	fl := (v.flag | key.flag) & flagRO
-	fl |= flag(typ.Kind())
-	if ifaceIndir(typ) {
-		// Copy result so future changes to the map
-		// won't change the underlying value.
-		c := unsafe_New(typ)
-		memmove(c, e, typ.size)
-		return Value{typ, c, fl | flagIndir}
-	} else {
-		return Value{typ, *(*unsafe.Pointer)(e), fl}
-	}
+	// haxeValue copies the result, so future changes to the map
+	// won't change the underlying value.
+	ret := haxeValue(tt.elem, e)
+	ret.flag |= (v.flag | key.flag) & flagRO
+	return ret
 }
 
 // MapKeys returns a slice containing all the keys present in the map,
@@ -1085,9 +1006,9 @@ func (v Value) MapKeys() []Value {
 	tt := (*mapType)(unsafe.Pointer(v.typ))
 	keyType := tt.key
 
-	fl := v.flag&flagRO | flag(keyType.Kind())
+	fl := v.flag & flagRO
 
-	m := *(*uintptr)(v.ptr) //v.pointer()
+	m := uintptr(v.pointer())
 	mlen := int(0)
 	if !hx.IsNull(m) /*!= nil*/ {
 		mlen = maplen(m)
@@ -1097,22 +1018,15 @@ func (v Value) MapKeys() []Value {
 	a := make([]Value, mlen)
 	var i int
 	for i = 0; i < len(a); i++ {
-		key := mapiterkey(it)
-		if key == nil {
+		key, ok := mapiterkey(it)
+		if !ok {
 			// Someone deleted an entry from the map since we
 			// called maplen above.  It's a data race, but nothing
 			// we can do about it.
 			break
 		}
-		if ifaceIndir(keyType) {
-			// Copy result so future changes to the map
-			// won't change the underlying value.
-			c := unsafe_New(keyType)
-			memmove(c, key, keyType.size)
-			a[i] = Value{keyType, c, fl | flagIndir}
-		} else {
-			a[i] = Value{keyType, *(*unsafe.Pointer)(key), fl}
-		}
+		a[i] = haxeValue(keyType, key)
+		a[i].flag |= fl
 		mapiternext(it)
 	}
 	return a[:i]
@@ -1123,7 +1037,6 @@ func (v Value) MapKeys() []Value {
 // a receiver; the returned function will always use v as the receiver.
 // Method panics if i is out of range or if v is a nil interface value.
 func (v Value) Method(i int) Value {
-	panic("reflect.value.Method not yet implemented")
 	if v.typ == nil {
 		panic(&ValueError{"reflect.Value.Method", Invalid})
 	}
@@ -1157,7 +1070,6 @@ func (v Value) NumMethod() int {
 // a receiver; the returned function will always use v as the receiver.
 // It returns the zero Value if no method was found.
 func (v Value) MethodByName(name string) Value {
-	panic("reflect.value.MethodByName not yet implemented")
 	if v.typ == nil {
 		panic(&ValueError{"reflect.Value.MethodByName", Invalid})
 	}
@@ -1266,7 +1178,7 @@ func (v Value) Pointer() uintptr {
 			"_a.itemAddr(0).load().val.load().len()==0?null:_a.itemAddr(0).load().val.load().itemAddr(0);",
 			v.ptr)
 	case Map, Func, Chan:
-		return uintptr(unsafe.Pointer(v.pointer())) // TODO this is an untested value
+		return uintptr(v.pointer()) // the Haxe object
 	default:
 		panic("reflect.value.Pointer not yet implemented for " + v.Kind().String())
 	}
@@ -1377,7 +1289,7 @@ func (v Value) Set(x Value) {
 		target = v.ptr
 	}
 	x = x.assignTo("reflect.Set", v.typ, target)
-	if x.flag&flagIndir != 0 {
+	if x.flag&flagIndir != 0 || haxeHeld(x.kind()) {
 		memmove(v.ptr, x.ptr, v.typ.size)
 	} else {
 		*(*unsafe.Pointer)(v.ptr) = x.ptr
@@ -1504,31 +1416,19 @@ func (v Value) SetCap(n int) {
 // As in Go, key's value must be assignable to the map's key type,
 // and val's value must be assignable to the map's value type.
 func (v Value) SetMapIndex(key, val Value) {
-	panic("reflect.value.SetMapIndex not yet implemented")
 	v.mustBe(Map)
 	v.mustBeExported()
 	key.mustBeExported()
 	tt := (*mapType)(unsafe.Pointer(v.typ))
 	key = key.assignTo("reflect.Value.SetMapIndex", tt.key, nil)
-	var k unsafe.Pointer
-	if key.flag&flagIndir != 0 {
-		k = key.ptr
-	} else {
-		k = unsafe.Pointer(&key.ptr)
-	}
+	k := packEface(key)
 	if val.typ == nil {
-		mapdelete(v.typ, v.pointer(), k)
+		mapdelete(v.typ, uintptr(v.pointer()), k)
 		return
 	}
 	val.mustBeExported()
 	val = val.assignTo("reflect.Value.SetMapIndex", tt.elem, nil)
-	var e unsafe.Pointer
-	if val.flag&flagIndir != 0 {
-		e = val.ptr
-	} else {
-		e = unsafe.Pointer(&val.ptr)
-	}
-	mapassign(v.typ, v.pointer(), k, e)
+	mapassign(v.typ, uintptr(v.pointer()), k, packEface(val))
 }
 
 // SetUint sets v's underlying value to x.
@@ -1769,13 +1669,11 @@ func (v Value) Type() Type {
 		// Easy case
 		return v.typ
 	}
-	panic("reflect.value.Type for methods etc not yet implemented")
 
 	// Method value.
 	// v.typ describes the receiver, not the method type.
 	i := int(v.flag) >> flagMethodShift
 	if v.typ.Kind() == Interface {
-		panic("reflect.Value.Type unhandled *interfaceType ?")
 		// Method on interface.
 		tt := (*interfaceType)(unsafe.Pointer(v.typ))
 		if uint(i) >= uint(len(tt.methods)) {
@@ -2197,7 +2095,6 @@ func MakeChan(typ Type, buffer int) Value {
 
 // MakeMap creates a new map of the specified type.
 func MakeMap(typ Type) Value {
-	panic("reflect.MakeMap not yet implemented")
 	if typ.Kind() != Map {
 		panic("reflect.MakeMap of non-map type")
 	}
@@ -2248,6 +2145,9 @@ func Zero(typ Type) Value {
 	if ifaceIndir(t) {
 		return Value{t, unsafe_New(typ.(*rtype)), fl | flagIndir}
 	}
+	if haxeHeld(t.Kind()) {
+		return Value{t, unsafe_New(typ.(*rtype)), fl} // the memory holding a null Haxe object
+	}
 	return Value{t, nil, fl}
 }
 
@@ -2266,7 +2166,6 @@ func New(typ Type) Value {
 // NewAt returns a Value representing a pointer to a value of the
 // specified type, using p as that pointer.
 func NewAt(typ Type, p unsafe.Pointer) Value {
-	panic("reflect.NewAt not yet implemented")
 	fl := flag(Ptr)
 	return Value{typ.common().ptrTo(), p, fl}
 }
@@ -2310,7 +2209,6 @@ func (v Value) assignTo(context string, dst *rtype, target unsafe.Pointer) Value
 // If the usual Go conversion rules do not allow conversion
 // of the value v to type t, Convert panics.
 func (v Value) Convert(t Type) Value {
-	panic("reflect.Convert not yet implemented")
 	if v.flag&flagMethod != 0 {
 		v = makeMethodValue("Convert", v)
 	}
@@ -2566,7 +2464,6 @@ func cvtT2I(v Value, typ Type) Value {
 	target := unsafe_New(typ.common())
 	x := valueInterface(v, false)
 	if typ.NumMethod() == 0 {
-		panic("reflect.cvtT2I unhandled *interface ")
 		*(*interface{})(target) = x
 	} else {
 		ifaceE2I(typ.(*rtype), x, target)
@@ -2623,46 +2520,50 @@ func makechan(typ *rtype, size uint64) (ch unsafe.Pointer) {
 	return chPtr
 }
 func makemap(t *rtype) (m unsafe.Pointer) {
-	panic("reflectmakemap() not yet implemented in haxe")
-	return nil
+	tt := (*mapType)(unsafe.Pointer(t))
+	kind := 0 // the kind of key, as the compiler gives it: GOmap.KeyOther
+	switch tt.key.Kind() {
+	case Int, Int8, Int16, Int32, Uint, Uint8, Uint16, Uint32:
+		kind = 1 // GOmap.KeyInt
+	case String:
+		kind = 2 // GOmap.KeyString
+	case Float32, Float64:
+		kind = 3 // GOmap.KeyFloat
+	}
+	mapPtr := hx.Malloc(t.Size())
+	*((*uintptr)(mapPtr)) = hx.CodeDynamic("",
+		"new GOmap(_a.itemAddr(0).load().val,_a.itemAddr(1).load().val,_a.itemAddr(2).load().val);",
+		packEface(Zero(tt.key)), packEface(Zero(tt.elem)), kind)
+	return mapPtr
 }
 
-func mapaccess(t *rtype, m uintptr /*unsafe.Pointer*/, key unsafe.Pointer) (val unsafe.Pointer) {
+// the map functions below take the Haxe GOmap object, and keys and values packed as by packEface
+
+func mapaccess(t *rtype, m uintptr /*unsafe.Pointer*/, key interface{}) (val uintptr, ok bool) {
 	if t == nil {
 		panic("reflect.mapaccess() nil pointer to type info")
 	}
-	//panic("reflect.mapaccess() not yet implemented in haxe")
-	//return nil
-	kt := (*mapType)(unsafe.Pointer(t)).key
-	et := (*mapType)(unsafe.Pointer(t)).elem
-	ei := new(emptyInterface)
-	var el uintptr
-	if !hx.IsNull(m) /*m != nil*/ {
-		if key != nil {
-			ei.typ = kt
-			ei.word = key
-			hk := haxeInterfacePack(ei)
-			el = hx.CodeDynamic("",
-				"cast(_a.itemAddr(0).load().val,GOmap).get(_a.itemAddr(1).load().val);",
-				m, hk)
-			//println("DEBUG mapaccess key,val=", hk, el)
-		} else {
-			el = hx.CodeDynamic("",
-				"cast(_a.itemAddr(0).load().val,GOmap).vz;",
-				m)
-			//println("DEBUG mapaccess default val=", el)
-		}
+	if hx.IsNull(m) /*m == nil*/ {
+		return val, false
 	}
-	ei.typ = et
-	ei.word = nil
-	haxe2go(ei, el)
-	return ei.word
+	if !hx.CodeBool("", "cast(_a.itemAddr(0).load().val,GOmap).exists(_a.itemAddr(1).load().val);", m, key) {
+		return val, false
+	}
+	val = hx.CodeDynamic("", "cast(_a.itemAddr(0).load().val,GOmap).get(_a.itemAddr(1).load().val);", m, key)
+	return val, true
 }
-func mapassign(t *rtype, m unsafe.Pointer, key, val unsafe.Pointer) {
-	panic("reflect.mapassign() not yet implemented in haxe")
+func mapassign(t *rtype, m uintptr /*unsafe.Pointer*/, key, val interface{}) {
+	if hx.IsNull(m) /*m == nil*/ {
+		panic("assignment to entry in nil map")
+	}
+	hx.Code("", "cast(_a.itemAddr(0).load().val,GOmap).set(_a.itemAddr(1).load().val,_a.itemAddr(2).load().val);",
+		m, key, val)
 }
-func mapdelete(t *rtype, m unsafe.Pointer, key unsafe.Pointer) {
-	panic("reflect.mapdelete() not yet implemented in haxe")
+func mapdelete(t *rtype, m uintptr /*unsafe.Pointer*/, key interface{}) {
+	if hx.IsNull(m) /*m == nil*/ {
+		return
+	}
+	hx.Code("", "cast(_a.itemAddr(0).load().val,GOmap).remove(_a.itemAddr(1).load().val);", m, key)
 }
 
 type mapIter struct {
@@ -2688,18 +2589,12 @@ func mapiterinit(t *rtype, m uintptr /*unsafe.Pointer*/) unsafe.Pointer {
 	mapiternext(unsafe.Pointer(mi))
 	return unsafe.Pointer(mi)
 }
-func mapiterkey(it unsafe.Pointer) (key unsafe.Pointer) {
-	//panic("reflect.mapiterkey() not yet implemented in haxe")
+func mapiterkey(it unsafe.Pointer) (key uintptr, ok bool) {
 	if it == nil {
-		//println("DEBUG reflect.mapiterkey() nil pointer to iterator")
-		return nil
+		return key, false
 	}
 	mi := (*mapIter)(it)
-	kt := (*mapType)(unsafe.Pointer(mi.t)).key
-	ei := new(emptyInterface)
-	ei.typ = kt
-	haxe2go(ei, mi.key)
-	return ei.word
+	return mi.key, mi.ok
 }
 func mapiternext(it unsafe.Pointer) {
 	//panic("reflect.mspiternext() not yet implemented in haxe")
@@ -2724,12 +2619,9 @@ func maplen(m uintptr /*unsafe.Pointer*/) int {
 	}
 	return hx.CodeInt("", "cast(_a.itemAddr(0).load().val,GOmap).len();", m)
 }
-func call(fn, arg unsafe.Pointer, n uint32, retoffset uint32) {
-	panic("reflect.call() not yet implemented in haxe")
-}
-
 func ifaceE2I(t *rtype, src interface{}, dst unsafe.Pointer) {
-	panic("reflect.ifaceE2I() not yet implemented in haxe")
+	// in Haxe, all interfaces share the same representation
+	*(*interface{})(dst) = src
 }
 
 //go:noescape
@@ -0,0 +1,63 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGoexit(t *testing.T) {
+	done := make(chan []string, 1) // buffered, as deferred calls run during Goexit should not block
+	go func() {
+		var ran []string
+		defer func() { done <- ran }()
+		defer func() {
+			if r := recover(); r != nil {
+				ran = append(ran, "recovered")
+			}
+			ran = append(ran, "outer")
+		}()
+		func() {
+			defer func() { ran = append(ran, "inner") }()
+			runtime.Goexit()
+			ran = append(ran, "after Goexit")
+		}()
+		ran = append(ran, "after call")
+	}()
+	if got := strings.Join(<-done, ","); got != "inner,outer" {
+		t.Errorf("Goexit ran %q, want %q", got, "inner,outer")
+	}
+}
+
+func callers() []uintptr {
+	pc := make([]uintptr, 10)
+	return pc[:runtime.Callers(1, pc)]
+}
+
+func TestCallers(t *testing.T) {
+	pcs := callers()
+	if len(pcs) < 2 {
+		t.Fatalf("Callers found %d frames, want at least 2", len(pcs))
+	}
+	for i, want := range []string{"runtime_test.callers", "runtime_test.TestCallers"} {
+		f := runtime.FuncForPC(pcs[i])
+		if f == nil {
+			t.Fatalf("FuncForPC(%d) = nil, for frame %d", pcs[i], i)
+		}
+		if !strings.HasSuffix(f.Name(), want) {
+			t.Errorf("frame %d is %q, want %q", i, f.Name(), want)
+		}
+		if file, line := f.FileLine(pcs[i]); !strings.HasSuffix(file, "goexit_haxe_test.go") || line == 0 {
+			t.Errorf("frame %d is at %s:%d, want a line in goexit_haxe_test.go", i, file, line)
+		}
+	}
+	frames := runtime.CallersFrames(pcs)
+	frame, more := frames.Next()
+	if !strings.HasSuffix(frame.Function, "runtime_test.callers") || !more {
+		t.Errorf("CallersFrames gave %q first, more %v", frame.Function, more)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var memSink [][]byte
+
+func allocate(n int) {
+	for i := 0; i < n; i++ {
+		memSink = append(memSink, make([]byte, 1000))
+	}
+}
+
+func TestReadMemStats(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	allocate(100)
+	runtime.ReadMemStats(&after)
+	if got := after.TotalAlloc - before.TotalAlloc; got < 100*1000 {
+		t.Errorf("TotalAlloc grew by %d, want at least %d", got, 100*1000)
+	}
+	if got := after.Mallocs - before.Mallocs; got < 100 {
+		t.Errorf("Mallocs grew by %d, want at least 100", got)
+	}
+	if after.HeapAlloc != after.TotalAlloc || after.Frees != 0 {
+		t.Errorf("HeapAlloc %d and Frees %d, want %d and 0", after.HeapAlloc, after.Frees, after.TotalAlloc)
+	}
+}
+
+func TestMemProfile(t *testing.T) {
+	old := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	allocate(10)
+	runtime.MemProfileRate = old
+
+	n, ok := runtime.MemProfile(nil, true)
+	if n == 0 || ok {
+		t.Fatalf("MemProfile(nil) = %d, %v, want some records and false", n, ok)
+	}
+	p := make([]runtime.MemProfileRecord, n+50)
+	n, ok = runtime.MemProfile(p, true)
+	if !ok {
+		t.Fatalf("MemProfile of %d records failed", len(p))
+	}
+	for _, r := range p[:n] {
+		for _, pc := range r.Stack() {
+			if f := runtime.FuncForPC(pc); f != nil && strings.HasSuffix(f.Name(), "runtime_test.allocate") {
+				if r.InUseBytes() < 1000 || r.InUseObjects() < 1 {
+					t.Errorf("allocate has %d bytes in %d objects, want at least 1000 in 1", r.InUseBytes(), r.InUseObjects())
+				}
+				return
+			}
+		}
+	}
+	t.Errorf("no memory profile record for allocate among %d", n)
+}
@@ -213,9 +213,11 @@ func (p *Profile) Remove(value interface{}) {
 // Otherwise, WriteTo returns nil.
 //
 // The debug parameter enables additional output.
-// Passing debug=0 prints only the hexadecimal addresses that pprof needs.
-// Passing debug=1 adds comments translating addresses to function names
-// and line numbers, so that a programmer can read the profile without tools.
+// Passing debug=0 writes the gzip-compressed protocol buffer described
+// in https://github.com/google/pprof/tree/master/proto#overview.
+// Passing debug=1 writes the legacy text format with comments
+// translating addresses to function names and line numbers, so that a
+// programmer can read the profile without tools.
 //
 // The predefined profiles may assign meaning to other debug values;
 // for example, when printing the "goroutine" profile, debug=2 means to
@@ -268,7 +270,12 @@ type countProfile interface {
 }
 
 // printCountProfile prints a countProfile at the specified debug level.
+// The profile will be in compressed proto format unless debug is nonzero.
 func printCountProfile(w io.Writer, debug int, name string, p countProfile) error {
+	if debug == 0 {
+		return writeCountProto(w, name, p)
+	}
+
 	b := bufio.NewWriter(w)
 	var tw *tabwriter.Writer
 	w = b
@@ -364,7 +371,8 @@ func (x byInUseBytes) Len() int           { return len(x) }
 func (x byInUseBytes) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
 func (x byInUseBytes) Less(i, j int) bool { return x[i].InUseBytes() > x[j].InUseBytes() }
 
-// WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0).
+// WriteHeapProfile is shorthand for Lookup("heap").WriteTo(w, 0),
+// so it writes a compressed protocol buffer.
 // It is preserved for backwards compatibility.
 func WriteHeapProfile(w io.Writer) error {
 	return writeHeap(w, 0)
@@ -399,6 +407,10 @@ func writeHeap(w io.Writer, debug int) error {
 		// Profile grew; try again.
 	}
 
+	if debug == 0 {
+		return writeHeapProto(w, p, int64(runtime.MemProfileRate))
+	}
+
 	sort.Sort(byInUseBytes(p))
 
 	b := bufio.NewWriter(w)
@@ -553,67 +565,15 @@ type runtimeProfile []runtime.StackRecord
 func (p runtimeProfile) Len() int              { return len(p) }
 func (p runtimeProfile) Stack(i int) []uintptr { return p[i].Stack() }
 
-var cpu struct {
-	sync.Mutex
-	profiling bool
-	done      chan bool
-}
-
-// StartCPUProfile enables CPU profiling for the current process.
-// While profiling, the profile will be buffered and written to w.
-// StartCPUProfile returns an error if profiling is already enabled.
+// StartCPUProfile would enable CPU profiling for the current process,
+// but Haxe code cannot be interrupted to sample where it is running,
+// so it always returns an error, rather than write an empty profile to w.
 func StartCPUProfile(w io.Writer) error {
-	// The runtime routines allow a variable profiling rate,
-	// but in practice operating systems cannot trigger signals
-	// at more than about 500 Hz, and our processing of the
-	// signal is not cheap (mostly getting the stack trace).
-	// 100 Hz is a reasonable choice: it is frequent enough to
-	// produce useful data, rare enough not to bog down the
-	// system, and a nice round number to make it easy to
-	// convert sample counts to seconds.  Instead of requiring
-	// each client to specify the frequency, we hard code it.
-	const hz = 100
-
-	cpu.Lock()
-	defer cpu.Unlock()
-	if cpu.done == nil {
-		cpu.done = make(chan bool)
-	}
-	// Double-check.
-	if cpu.profiling {
-		return fmt.Errorf("cpu profiling already in use")
-	}
-	cpu.profiling = true
-	runtime.SetCPUProfileRate(hz)
-	go profileWriter(w)
-	return nil
-}
-
-func profileWriter(w io.Writer) {
-	for {
-		data := runtime.CPUProfile()
-		if data == nil {
-			break
-		}
-		w.Write(data)
-	}
-	cpu.done <- true
+	return fmt.Errorf("cpu profiling is not available in TARDIS Go")
 }
 
-// StopCPUProfile stops the current CPU profile, if any.
-// StopCPUProfile only returns after all the writes for the
-// profile have completed.
-func StopCPUProfile() {
-	cpu.Lock()
-	defer cpu.Unlock()
-
-	if !cpu.profiling {
-		return
-	}
-	cpu.profiling = false
-	runtime.SetCPUProfileRate(0)
-	<-cpu.done
-}
+// StopCPUProfile stops the current CPU profile, if any, which there never is in TARDIS Go.
+func StopCPUProfile() {}
 
 type byCycles []runtime.BlockProfileRecord
 
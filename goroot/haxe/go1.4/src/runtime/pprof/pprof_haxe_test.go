@@ -0,0 +1,73 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pprof_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"runtime"
+	. "runtime/pprof"
+	"strings"
+	"testing"
+)
+
+var sink []byte
+
+// readProfile checks that a profile is gzip-compressed, and returns its protocol buffer.
+func readProfile(t *testing.T, b *bytes.Buffer) []byte {
+	zr, err := gzip.NewReader(b)
+	if err != nil {
+		t.Fatalf("profile is not gzip-compressed: %v", err)
+	}
+	data, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("reading profile: %v", err)
+	}
+	return data
+}
+
+func TestHeapProfile(t *testing.T) {
+	old := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	sink = make([]byte, 10000)
+	runtime.MemProfileRate = old
+
+	var b bytes.Buffer
+	if err := WriteHeapProfile(&b); err != nil {
+		t.Fatal(err)
+	}
+	data := string(readProfile(t, &b))
+	for _, want := range []string{"alloc_space", "inuse_space", "bytes", "TestHeapProfile"} {
+		if !strings.Contains(data, want) {
+			t.Errorf("heap profile does not contain %q", want)
+		}
+	}
+
+	b.Reset()
+	if err := Lookup("heap").WriteTo(&b, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(b.String(), "heap profile: ") {
+		t.Errorf("debug=1 heap profile starts %q", b.String())
+	}
+}
+
+func TestGoroutineProfile(t *testing.T) {
+	var b bytes.Buffer
+	if err := Lookup("goroutine").WriteTo(&b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if data := string(readProfile(t, &b)); !strings.Contains(data, "TestGoroutineProfile") {
+		t.Errorf("goroutine profile does not contain the running test")
+	}
+}
+
+func TestCPUProfile(t *testing.T) {
+	if err := StartCPUProfile(ioutil.Discard); err == nil {
+		StopCPUProfile()
+		t.Errorf("StartCPUProfile did not report that cpu profiling is not available")
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pprof
+
+import (
+	"compress/gzip"
+	"io"
+	"runtime"
+	"time"
+)
+
+// The profiles written with debug=0 are gzip-compressed protocol buffers, as described by
+// https://github.com/google/pprof/blob/master/proto/profile.proto, which later versions of "go tool pprof" read.
+// The program counters of TARDIS Go are source positions, so each location has exactly one line,
+// and they are all given a single mapping that says the functions, files and lines are already known.
+
+// protobuf encodes the protocol buffer wire format, just as far as profile.proto needs.
+type protobuf struct {
+	data []byte
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	if x == 0 {
+		return // the default value
+	}
+	b.varint(uint64(tag) << 3)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bool(tag int, x bool) {
+	if x {
+		b.uint64(tag, 1)
+	}
+}
+
+func (b *protobuf) bytes(tag int, x []byte) {
+	b.varint(uint64(tag)<<3 | 2)
+	b.varint(uint64(len(x)))
+	b.data = append(b.data, x...)
+}
+
+func (b *protobuf) string(tag int, x string) {
+	b.bytes(tag, []byte(x))
+}
+
+func (b *protobuf) message(tag int, m *protobuf) {
+	b.bytes(tag, m.data)
+}
+
+// packed writes repeated numbers, as the packed encoding that proto3 uses by default.
+func (b *protobuf) packed(tag int, x []uint64) {
+	var p protobuf
+	for _, u := range x {
+		p.varint(u)
+	}
+	b.bytes(tag, p.data)
+}
+
+// Field numbers from profile.proto.
+const (
+	tagProfile_SampleType    = 1
+	tagProfile_Sample        = 2
+	tagProfile_Mapping       = 3
+	tagProfile_Location      = 4
+	tagProfile_Function      = 5
+	tagProfile_StringTable   = 6
+	tagProfile_TimeNanos     = 9
+	tagProfile_PeriodType    = 11
+	tagProfile_Period        = 12
+	tagValueType_Type        = 1
+	tagValueType_Unit        = 2
+	tagSample_Location       = 1
+	tagSample_Value          = 2
+	tagMapping_ID            = 1
+	tagMapping_Limit         = 3
+	tagMapping_Filename      = 5
+	tagMapping_HasFunctions  = 7
+	tagMapping_HasFilenames  = 8
+	tagMapping_HasLineNumber = 9
+	tagLocation_ID           = 1
+	tagLocation_MappingID    = 2
+	tagLocation_Address      = 3
+	tagLocation_Line         = 4
+	tagLine_FunctionID       = 1
+	tagLine_Line             = 2
+	tagFunction_ID           = 1
+	tagFunction_Name         = 2
+	tagFunction_SystemName   = 3
+	tagFunction_Filename     = 4
+	tagFunction_StartLine    = 5
+)
+
+// profileBuilder collects the samples of a profile, with the locations and functions they refer to.
+type profileBuilder struct {
+	p         protobuf // the profile, without its mapping, locations, functions and strings
+	locations protobuf // the encoded Location messages
+	functions protobuf // the encoded Function messages
+	strings   []string
+	stringIDs map[string]int64
+	locIDs    map[int]uint64 // keyed by program counter, as uintptr map keys are not supported
+	funcIDs   map[string]uint64
+	limit     uintptr // above the largest program counter seen
+}
+
+func newProfileBuilder() *profileBuilder {
+	b := &profileBuilder{
+		strings:   []string{""}, // string 0 must be the empty string
+		stringIDs: map[string]int64{"": 0},
+		locIDs:    map[int]uint64{},
+		funcIDs:   map[string]uint64{},
+	}
+	b.p.int64(tagProfile_TimeNanos, time.Now().UnixNano())
+	return b
+}
+
+func (b *profileBuilder) stringID(s string) int64 {
+	id, ok := b.stringIDs[s]
+	if !ok {
+		id = int64(len(b.strings))
+		b.strings = append(b.strings, s)
+		b.stringIDs[s] = id
+	}
+	return id
+}
+
+func (b *profileBuilder) valueType(tag int, typ, unit string) {
+	var vt protobuf
+	vt.int64(tagValueType_Type, b.stringID(typ))
+	vt.int64(tagValueType_Unit, b.stringID(unit))
+	b.p.message(tag, &vt)
+}
+
+// sampleTypes describes the values of each sample, and the period between them.
+func (b *profileBuilder) sampleTypes(periodType, periodUnit string, period int64, types ...string) {
+	for i := 0; i+1 < len(types); i += 2 {
+		b.valueType(tagProfile_SampleType, types[i], types[i+1])
+	}
+	b.valueType(tagProfile_PeriodType, periodType, periodUnit)
+	b.p.int64(tagProfile_Period, period)
+}
+
+func (b *profileBuilder) sample(stk []uintptr, values ...int64) {
+	locs := make([]uint64, 0, len(stk))
+	for _, pc := range stk {
+		locs = append(locs, b.locationID(pc))
+	}
+	vals := make([]uint64, len(values))
+	for i, v := range values {
+		vals[i] = uint64(v)
+	}
+	var s protobuf
+	s.packed(tagSample_Location, locs)
+	s.packed(tagSample_Value, vals)
+	b.p.message(tagProfile_Sample, &s)
+}
+
+func (b *profileBuilder) locationID(pc uintptr) uint64 {
+	if id, ok := b.locIDs[int(pc)]; ok {
+		return id
+	}
+	id := uint64(len(b.locIDs) + 1)
+	b.locIDs[int(pc)] = id
+	if pc >= b.limit {
+		b.limit = pc + 1
+	}
+	var loc protobuf
+	loc.uint64(tagLocation_ID, id)
+	loc.uint64(tagLocation_MappingID, 1)
+	loc.uint64(tagLocation_Address, uint64(pc))
+	if f := runtime.FuncForPC(pc); f != nil {
+		_, line := f.FileLine(pc)
+		var ln protobuf
+		ln.uint64(tagLine_FunctionID, b.functionID(f))
+		ln.int64(tagLine_Line, int64(line))
+		loc.message(tagLocation_Line, &ln)
+	}
+	b.locations.message(tagProfile_Location, &loc)
+	return id
+}
+
+func (b *profileBuilder) functionID(f *runtime.Func) uint64 {
+	name := f.Name()
+	if id, ok := b.funcIDs[name]; ok {
+		return id
+	}
+	id := uint64(len(b.funcIDs) + 1)
+	b.funcIDs[name] = id
+	file, line := f.FileLine(f.Entry())
+	var fn protobuf
+	fn.uint64(tagFunction_ID, id)
+	fn.int64(tagFunction_Name, b.stringID(name))
+	fn.int64(tagFunction_SystemName, b.stringID(name))
+	fn.int64(tagFunction_Filename, b.stringID(file))
+	fn.int64(tagFunction_StartLine, int64(line))
+	b.functions.message(tagProfile_Function, &fn)
+	return id
+}
+
+// build writes the gzip-compressed profile to w.
+func (b *profileBuilder) build(w io.Writer) error {
+	var m protobuf
+	m.uint64(tagMapping_ID, 1)
+	m.uint64(tagMapping_Limit, uint64(b.limit))
+	m.int64(tagMapping_Filename, b.stringID("tardisgo"))
+	m.bool(tagMapping_HasFunctions, true)
+	m.bool(tagMapping_HasFilenames, true)
+	m.bool(tagMapping_HasLineNumber, true)
+	b.p.message(tagProfile_Mapping, &m)
+	b.p.data = append(b.p.data, b.locations.data...)
+	b.p.data = append(b.p.data, b.functions.data...)
+	for _, s := range b.strings {
+		b.p.string(tagProfile_StringTable, s)
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b.p.data); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeCountProto writes a countProfile as a protocol buffer, with one sample for each different stack.
+func writeCountProto(w io.Writer, name string, p countProfile) error {
+	b := newProfileBuilder()
+	b.sampleTypes(name, "count", 1, name, "count")
+	index := map[string]int{}
+	var stks [][]uintptr
+	var counts []int64
+	n := p.Len()
+	for i := 0; i < n; i++ {
+		stk := p.Stack(i)
+		key := string(stackKey(stk))
+		j, ok := index[key]
+		if !ok {
+			j = len(stks)
+			index[key] = j
+			stks = append(stks, stk)
+			counts = append(counts, 0)
+		}
+		counts[j]++
+	}
+	for j, stk := range stks {
+		b.sample(stk, counts[j])
+	}
+	return b.build(w)
+}
+
+// writeHeapProto writes the memory profile records as a protocol buffer.
+// The records already estimate all the memory allocated, rather than only what was sampled, so they are not scaled.
+func writeHeapProto(w io.Writer, p []runtime.MemProfileRecord, rate int64) error {
+	b := newProfileBuilder()
+	b.sampleTypes("space", "bytes", rate,
+		"alloc_objects", "count", "alloc_space", "bytes", "inuse_objects", "count", "inuse_space", "bytes")
+	for i := range p {
+		r := &p[i]
+		b.sample(r.Stack(), r.AllocObjects, r.AllocBytes, r.InUseObjects(), r.InUseBytes())
+	}
+	return b.build(w)
+}
+
+// stackKey returns the bytes of the program counters in stk, to group the samples with the same stack.
+func stackKey(stk []uintptr) []byte {
+	var b protobuf
+	for _, pc := range stk {
+		b.varint(uint64(pc))
+	}
+	return b.data
+}
@@ -9,15 +9,22 @@ package runtime
 // THE GOLANG RUNTIME PACKAGE IS NOT CURRENTLY ALL USABLE
 
 import (
+	"haxegoruntime"
+	"unsafe"
+
 	"github.com/tardisgo/tardisgo/haxe/hx"
 )
 
 func init() {
+	hx.Call("", "MemProfile.setRate", 1, unsafe.Pointer(&MemProfileRate)) // read by the Haxe runtime whenever it allocates
 }
 
 // Haxe specific
 func UnzipTestFS() {} // this will be overwritten by the compiler
 
+// SelectedTests returns the newline-terminated names of the tests selected by "tardisgo test -run", or "" to run them all.
+func SelectedTests() string { return "" } // this will be overwritten by the compiler
+
 // Constant values
 
 const Compiler = "TARDISgo"
@@ -26,7 +33,11 @@ var GOARCH string = hx.CallString("", "Go.Platform", 0) // this is a const in th
 
 const GOOS string = "nacl" // of course it is only an emulation of nacl...
 
-var MemProfileRate int = 512 * 1024 // TODO not currently used
+// MemProfileRate controls the fraction of memory allocations that are recorded and reported in the memory profile.
+// The profiler aims to sample an average of one allocation per MemProfileRate bytes allocated.
+// To include every allocated block in the profile, set MemProfileRate to 1.
+// To turn off profiling entirely, set MemProfileRate to 0.
+var MemProfileRate int = 512 * 1024
 
 // NOT YET IMPLEMENTED
 
@@ -36,48 +47,143 @@ type BlockProfileRecord struct {
 	StackRecord
 }
 
+// BlockProfile returns n, the number of records in the current blocking profile, which is always 0,
+// as SetBlockProfileRate is a no-op.
 func BlockProfile(p []BlockProfileRecord) (n int, ok bool) {
-	panic("TODO:runtime.BlockProfile")
-	return
+	return 0, true
 }
 
 func Breakpoint() {} // this will be overwritten by the compiler
 
+// CPUProfile returns nil, as there is no way to interrupt Haxe code to sample where it is,
+// so that runtime/pprof.StartCPUProfile writes no data.
 func CPUProfile() []byte {
-	panic("TODO:runtime.CPUProfile")
+	return nil
 }
 
+// GoroutineProfile returns n, the number of records in the active goroutine stack profile.
+// If len(p) >= n, GoroutineProfile copies the profile into p and returns n, true.
+// If len(p) < n, GoroutineProfile does not change p and returns n, false.
+// The stacks are the latest positions of the Go functions that keep stack frames.
 func GoroutineProfile(p []StackRecord) (n int, ok bool) {
-	panic("TODO:runtime.GoroutineProfile")
-	return
+	hx.Call("", "Scheduler.lock", 0)
+	defer hx.Call("", "Scheduler.unlock", 0)
+	all := hx.CallInt("", "Scheduler.NumGoroutine", 0)
+	for gr := 0; gr < all; gr++ {
+		if hx.CallInt("", "Scheduler.getNumCallers", 1, gr) > 0 {
+			n++
+		}
+	}
+	if len(p) < n {
+		return n, false
+	}
+	i := 0
+	for gr := 0; gr < all && i < n; gr++ {
+		depth := hx.CallInt("", "Scheduler.getNumCallers", 1, gr)
+		if depth == 0 {
+			continue
+		}
+		r := &p[i]
+		for x := range r.Stack0 {
+			if x < depth {
+				r.Stack0[x] = uintptr(hx.CallInt("", "Scheduler.getCallerX", 2, gr, x))
+			} else {
+				r.Stack0[x] = 0
+			}
+		}
+		i++
+	}
+	return n, true
 }
 
+// MemProfile returns n, the number of records in the current memory profile.
+// If len(p) >= n, MemProfile copies the profile into p and returns n, true.
+// If len(p) < n, MemProfile does not change p and returns n, false.
+//
+// There is one record for each allocation site sampled while MemProfileRate was non-zero,
+// holding the bytes and objects allocated since the sample before, so the records estimate where all memory was allocated.
+// The Haxe garbage collector does not report what it frees, so FreeBytes and FreeObjects are always 0,
+// and inuseZero makes no difference.
 func MemProfile(p []MemProfileRecord, inuseZero bool) (n int, ok bool) {
-	panic("TODO:runtime.MemProfile")
-	return
+	n = hx.CallInt("", "MemProfile.numSites", 0)
+	if len(p) < n {
+		return n, false
+	}
+	for i := 0; i < n; i++ {
+		r := &p[i]
+		r.AllocBytes = int64(hx.CallFloat("", "MemProfile.bytes", 1, i))
+		r.AllocObjects = int64(hx.CallFloat("", "MemProfile.objects", 1, i))
+		r.FreeBytes, r.FreeObjects = 0, 0
+		depth := hx.CallInt("", "MemProfile.depth", 1, i)
+		for x := range r.Stack0 {
+			if x < depth {
+				r.Stack0[x] = uintptr(hx.CallInt("", "MemProfile.pc", 2, i, x))
+			} else {
+				r.Stack0[x] = 0
+			}
+		}
+	}
+	return n, true
 }
 
+// ReadMemStats populates m with memory allocator statistics.
+// All Go memory is allocated as Haxe objects, which are counted as they are made,
+// but the Haxe garbage collector does not report what it frees,
+// so the bytes and objects ever allocated are all reported as in use, and the GC statistics are zero.
 func ReadMemStats(m *MemStats) {
-	//panic("TODO:runtime.ReadMemStats")
-	// bytes testing calls this, so no-op
+	bytes := uint64(hx.GetFloat("", "Object.allocBytes"))
+	objects := uint64(hx.GetFloat("", "Object.allocObjects"))
+	*m = MemStats{
+		Alloc:       bytes,
+		TotalAlloc:  bytes,
+		Sys:         bytes,
+		Mallocs:     objects,
+		HeapAlloc:   bytes,
+		HeapSys:     bytes,
+		HeapInuse:   bytes,
+		HeapObjects: objects,
+		EnableGC:    true,
+	}
 }
+
+// ThreadCreateProfile returns n, the number of records in the thread creation profile, which is always 0,
+// as Haxe threads are not created by Go code.
 func ThreadCreateProfile(p []StackRecord) (n int, ok bool) {
-	panic("TODO:runtime.ThreadCreateProfile")
-	return
+	return 0, true
 }
 
+// A MemProfileRecord describes the live objects allocated by a particular call sequence (stack trace).
 type MemProfileRecord struct {
 	AllocBytes, FreeBytes     int64       // number of bytes allocated, freed
 	AllocObjects, FreeObjects int64       // number of objects allocated, freed
 	Stack0                    [32]uintptr // stack trace for this record; ends at first 0 entry
 }
 
-func (r *MemProfileRecord) InUseBytes() int64   { return 0 }
-func (r *MemProfileRecord) InUseObjects() int64 { return 0 }
-func (r *MemProfileRecord) Stack() []uintptr    { return nil }
+// InUseBytes returns the number of bytes in use (AllocBytes - FreeBytes).
+func (r *MemProfileRecord) InUseBytes() int64 { return r.AllocBytes - r.FreeBytes }
+
+// InUseObjects returns the number of objects in use (AllocObjects - FreeObjects).
+func (r *MemProfileRecord) InUseObjects() int64 { return r.AllocObjects - r.FreeObjects }
+
+// Stack returns the stack trace associated with the record,
+// a prefix of r.Stack0.
+func (r *MemProfileRecord) Stack() []uintptr {
+	for i, v := range r.Stack0 {
+		if v == 0 {
+			return r.Stack0[0:i]
+		}
+	}
+	return r.Stack0[0:]
+}
 
+// Goexit terminates the goroutine that calls it, after running all of its deferred calls.
+// Calling Goexit from the main goroutine ends it, but the program continues with the other goroutines,
+// until they are all blocked or have ended, which is reported as a deadlock.
 func Goexit() {
-	panic("TODO:runtime.Goexit")
+	hx.Call("", "Scheduler.goexit", 1, hx.GetInt("", "this._goroutine"))
+	hx.Call("", "Scheduler.lock", 0)
+	haxegoruntime.Park("", 0, nil) // give up control, so that the Scheduler unwinds the stack of this goroutine
+	hx.Call("", "Scheduler.unlock", 0)
 }
 
 type MemStats struct {
@@ -133,7 +239,16 @@ type StackRecord struct {
 	Stack0 [32]uintptr // stack trace for this record; ends at first 0 entry
 }
 
-func (r *StackRecord) Stack() []uintptr { return nil }
+// Stack returns the stack trace associated with the record,
+// a prefix of r.Stack0.
+func (r *StackRecord) Stack() []uintptr {
+	for i, v := range r.Stack0 {
+		if v == 0 {
+			return r.Stack0[0:i]
+		}
+	}
+	return r.Stack0[0:]
+}
 
 type TypeAssertionError struct {
 	// contains filtered or unexported fields
@@ -142,16 +257,19 @@ type TypeAssertionError struct {
 func (e *TypeAssertionError) Error() string { return "TODO:runtime.TypeAssertionError.Error" }
 func (*TypeAssertionError) RuntimeError()   {}
 
+// NumCPU returns the number of logical CPUs, it is only more than 1 for Haxe -D gothreads on the java and cs targets.
+func NumCPU() int { return hx.CallInt("", "Scheduler.numCPU", 0) }
+
+// GOMAXPROCS sets the number of threads that may run goroutines if n > 0, returning the previous setting.
+// Without Haxe -D gothreads there is only ever one.
+func GOMAXPROCS(n int) int { return hx.CallInt("", "Scheduler.setMaxProcs", 1, n) }
+
 // NO-OP functions
 
 func SetBlockProfileRate(rate int) {}
 
 func SetCPUProfileRate(hz int) {}
 
-func NumCPU() int { return 1 }
-
-func GOMAXPROCS(n int) int { return 1 }
-
 func NumCgoCall() int64 { return 0 }
 
 func GOROOT() string { return "" } // TODO set as compile time value
@@ -177,19 +295,26 @@ type Error interface {
 
 // Part-Implemented
 
+// Callers fills the slice pc with the program counters of the function calls on the stack of the calling goroutine,
+// skipping the first skip of them, with 0 meaning the frame of Callers itself, and returns the number written.
+// The program counters are the latest source positions of each function call, which FuncForPC and CallersFrames describe.
 func Callers(skip int, pc []uintptr) int {
-	limit := hx.CallInt("", "Scheduler.getNumCallers", 1, hx.GetInt("", "this._goroutine"))
-	for i := 0; i < limit; i++ {
-		if i > skip {
-			pc = append(pc, uintptr(hx.CallInt("", "Scheduler.getCallerX", 2, hx.GetInt("", "this._goroutine"), i)))
-		}
+	gr := hx.GetInt("", "this._goroutine")
+	limit := hx.CallInt("", "Scheduler.getNumCallers", 1, gr)
+	n := 0
+	for i := skip; i < limit && n < len(pc); i++ {
+		pc[n] = uintptr(hx.CallInt("", "Scheduler.getCallerX", 2, gr, i))
+		n++
 	}
-	return limit - skip
+	return n
 }
 
 func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
 	pc = uintptr(hx.CallInt("", "Scheduler.getCallerX", 2, hx.GetInt("", "this._goroutine"), 1+skip))
 	fnc := FuncForPC(pc)
+	if fnc == nil {
+		return
+	}
 	file, line = fnc.FileLine(pc)
 	ok = true
 	if file == "" || line == 0 {
@@ -198,18 +323,28 @@ func Caller(skip int) (pc uintptr, file string, line int, ok bool) {
 	return
 }
 
+// A Func represents a Go function in the running binary.
 type Func struct {
-	// contains filtered or unexported fields
+	name  string
+	entry uintptr
 }
 
+// FuncForPC returns a *Func describing the function that contains the given program counter,
+// as returned by Callers or Caller, or else nil.
 func FuncForPC(pc uintptr) *Func {
-	//FuncForPC returns a *Func describing the function that contains the given program counter address, or else nil.
-	return nil
+	name := hx.CallString("", "Scheduler.funcName", 1, pc)
+	if name == "" {
+		return nil
+	}
+	return &Func{name: name, entry: uintptr(hx.CallInt("", "Scheduler.funcEntry", 1, pc))}
 }
 
+// Entry returns the entry address of the function, the source position of its start.
 func (f *Func) Entry() uintptr {
-	//Entry returns the entry address of the function.
-	return 0 // TODO
+	if f == nil {
+		return 0
+	}
+	return f.entry
 }
 
 func (f *Func) FileLine(pc uintptr) (file string, line int) {
@@ -221,11 +356,11 @@ func (f *Func) FileLine(pc uintptr) (file string, line int) {
 	if detail[0] == '(' { // error return
 		return "", 0
 	}
-	if detail[0:5] == "near " {
+	if len(detail) > 5 && detail[0:5] == "near " {
 		detail = detail[5:]
 	}
-	for i, c := range detail {
-		if c == ':' {
+	for i := len(detail) - 1; i >= 0; i-- { // the last colon, in case the file name has one
+		if detail[i] == ':' {
 			file = detail[:i]
 			x := hx.CallIface("", "int", "Std.parseInt", 1, detail[i+1:])
 			if x == nil {
@@ -239,8 +374,49 @@ func (f *Func) FileLine(pc uintptr) (file string, line int) {
 	return "", 0
 }
 
+// Name returns the name of the function, as Go would give it, for example "main.main" or "sync.(*Mutex).Lock".
 func (f *Func) Name() string {
-	return "TODO:runtime.Func.Name"
+	if f == nil {
+		return ""
+	}
+	return f.name
+}
+
+// Frames may be used to get function/file/line information for a slice of PC values returned by Callers,
+// as in later versions of Go.
+type Frames struct {
+	callers []uintptr
+}
+
+// Frame is the information returned by Frames for each call frame.
+type Frame struct {
+	PC       uintptr
+	Func     *Func
+	Function string
+	File     string
+	Line     int
+	Entry    uintptr
+}
+
+// CallersFrames takes a slice of PCs returned by Callers and prepares to return function/file/line information.
+func CallersFrames(callers []uintptr) *Frames {
+	return &Frames{callers: callers}
+}
+
+// Next returns frame information for the next caller, and whether there are more of them.
+func (ci *Frames) Next() (frame Frame, more bool) {
+	for len(ci.callers) > 0 {
+		pc := ci.callers[0]
+		ci.callers = ci.callers[1:]
+		f := FuncForPC(pc)
+		if f == nil {
+			continue
+		}
+		frame = Frame{PC: pc, Func: f, Function: f.name, Entry: f.entry}
+		frame.File, frame.Line = f.FileLine(pc)
+		break
+	}
+	return frame, len(ci.callers) > 0
 }
 
 var gosched_chan = make(chan interface{})
@@ -15,10 +15,14 @@ package atomic
 
 import (
 	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
 )
 
-// Non-parallel basic replacement for the sync/atomic package
+// Basic replacement for the sync/atomic package
 //***********************************************************
+// Each operation holds the Haxe runtime lock, so that it is atomic when goroutines run on many threads (Haxe -D gothreads),
+// otherwise Scheduler.lock() and Scheduler.unlock() compile to nothing.
 
 // *********** ignore: +build !race
 
@@ -52,146 +56,259 @@ import (
 //
 
 // SwapInt32 atomically stores new into *addr and returns the previous *addr value.
-func SwapInt32(addr *int32, new int32) (old int32) { old = *addr; *addr = new; return }
+func SwapInt32(addr *int32, new int32) (old int32) {
+	hx.Call("", "Scheduler.lock", 0)
+	old = *addr
+	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // SwapInt64 atomically stores new into *addr and returns the previous *addr value.
-func SwapInt64(addr *int64, new int64) (old int64) { old = *addr; *addr = new; return }
+func SwapInt64(addr *int64, new int64) (old int64) {
+	hx.Call("", "Scheduler.lock", 0)
+	old = *addr
+	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // SwapUint32 atomically stores new into *addr and returns the previous *addr value.
-func SwapUint32(addr *uint32, new uint32) (old uint32) { old = *addr; *addr = new; return }
+func SwapUint32(addr *uint32, new uint32) (old uint32) {
+	hx.Call("", "Scheduler.lock", 0)
+	old = *addr
+	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // SwapUint64 atomically stores new into *addr and returns the previous *addr value.
-func SwapUint64(addr *uint64, new uint64) (old uint64) { old = *addr; *addr = new; return }
+func SwapUint64(addr *uint64, new uint64) (old uint64) {
+	hx.Call("", "Scheduler.lock", 0)
+	old = *addr
+	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // SwapUintptr atomically stores new into *addr and returns the previous *addr value.
-func SwapUintptr(addr *uintptr, new uintptr) (old uintptr) { old = *addr; *addr = new; return }
+func SwapUintptr(addr *uintptr, new uintptr) (old uintptr) {
+	hx.Call("", "Scheduler.lock", 0)
+	old = *addr
+	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // SwapPointer atomically stores new into *addr and returns the previous *addr value.
 func SwapPointer(addr *unsafe.Pointer, new unsafe.Pointer) (old unsafe.Pointer) {
+	hx.Call("", "Scheduler.lock", 0)
 	old = *addr
 	*addr = new
+	hx.Call("", "Scheduler.unlock", 0)
 	return
 }
 
 // CompareAndSwapInt32 executes the compare-and-swap operation for an int32 value.
 func CompareAndSwapInt32(addr *int32, old, new int32) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if *addr == old {
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // CompareAndSwapInt64 executes the compare-and-swap operation for an int64 value.
 func CompareAndSwapInt64(addr *int64, old, new int64) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if *addr == old {
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // CompareAndSwapUint32 executes the compare-and-swap operation for a uint32 value.
 func CompareAndSwapUint32(addr *uint32, old, new uint32) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if *addr == old {
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // CompareAndSwapUint64 executes the compare-and-swap operation for a uint64 value.
 func CompareAndSwapUint64(addr *uint64, old, new uint64) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if *addr == old {
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // CompareAndSwapUintptr executes the compare-and-swap operation for a uintptr value.
 func CompareAndSwapUintptr(addr *uintptr, old, new uintptr) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if uint32(*addr) == uint32(old) { // in haxe uintptr could be anything
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // CompareAndSwapPointer executes the compare-and-swap operation for a unsafe.Pointer value.
 func CompareAndSwapPointer(addr *unsafe.Pointer, old, new unsafe.Pointer) (swapped bool) {
+	hx.Call("", "Scheduler.lock", 0)
 	if *addr == old {
 		*addr = new
-		return true
+		swapped = true
 	}
-	return false
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // AddInt32 atomically adds delta to *addr and returns the new value.
 func AddInt32(addr *int32, delta int32) (new int32) {
+	hx.Call("", "Scheduler.lock", 0)
 	*addr += delta
-	return *addr
+	new = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
-// AddUint32 atomically adds delta to *addr and returns the new value.
-func AddUint32(addr *uint32, delta uint32) (new uint32) {
+// AddInt64 atomically adds delta to *addr and returns the new value.
+func AddInt64(addr *int64, delta int64) (new int64) {
+	hx.Call("", "Scheduler.lock", 0)
 	*addr += delta
-	return *addr
+	new = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
-// AddInt64 atomically adds delta to *addr and returns the new value.
-func AddInt64(addr *int64, delta int64) (new int64) {
+// AddUint32 atomically adds delta to *addr and returns the new value.
+func AddUint32(addr *uint32, delta uint32) (new uint32) {
+	hx.Call("", "Scheduler.lock", 0)
 	*addr += delta
-	return *addr
+	new = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // AddUint64 atomically adds delta to *addr and returns the new value.
 func AddUint64(addr *uint64, delta uint64) (new uint64) {
+	hx.Call("", "Scheduler.lock", 0)
 	*addr += delta
-	return *addr
+	new = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // AddUintptr atomically adds delta to *addr and returns the new value.
 func AddUintptr(addr *uintptr, delta uintptr) (new uintptr) {
+	hx.Call("", "Scheduler.lock", 0)
 	*addr += delta
-	return *addr
+	new = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
 }
 
 // LoadInt32 atomically loads *addr.
-func LoadInt32(addr *int32) (val int32) { return *addr }
+func LoadInt32(addr *int32) (val int32) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // LoadInt64 atomically loads *addr.
-func LoadInt64(addr *int64) (val int64) { return *addr }
+func LoadInt64(addr *int64) (val int64) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // LoadUint32 atomically loads *addr.
-func LoadUint32(addr *uint32) (val uint32) { return *addr }
+func LoadUint32(addr *uint32) (val uint32) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // LoadUint64 atomically loads *addr.
-func LoadUint64(addr *uint64) (val uint64) { return *addr }
+func LoadUint64(addr *uint64) (val uint64) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // LoadUintptr atomically loads *addr.
-func LoadUintptr(addr *uintptr) (val uintptr) { return *addr }
+func LoadUintptr(addr *uintptr) (val uintptr) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // LoadPointer atomically loads *addr.
-func LoadPointer(addr *unsafe.Pointer) (val unsafe.Pointer) { return *addr }
+func LoadPointer(addr *unsafe.Pointer) (val unsafe.Pointer) {
+	hx.Call("", "Scheduler.lock", 0)
+	val = *addr
+	hx.Call("", "Scheduler.unlock", 0)
+	return
+}
 
 // StoreInt32 atomically stores val into *addr.
-func StoreInt32(addr *int32, val int32) { *addr = val }
+func StoreInt32(addr *int32, val int32) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // StoreInt64 atomically stores val into *addr.
-func StoreInt64(addr *int64, val int64) { *addr = val }
+func StoreInt64(addr *int64, val int64) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // StoreUint32 atomically stores val into *addr.
-func StoreUint32(addr *uint32, val uint32) { *addr = val }
+func StoreUint32(addr *uint32, val uint32) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // StoreUint64 atomically stores val into *addr.
-func StoreUint64(addr *uint64, val uint64) { *addr = val }
+func StoreUint64(addr *uint64, val uint64) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // StoreUintptr atomically stores val into *addr.
-func StoreUintptr(addr *uintptr, val uintptr) { *addr = val }
+func StoreUintptr(addr *uintptr, val uintptr) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // StorePointer atomically stores val into *addr.
-func StorePointer(addr *unsafe.Pointer, val unsafe.Pointer) { *addr = val }
+func StorePointer(addr *unsafe.Pointer, val unsafe.Pointer) {
+	hx.Call("", "Scheduler.lock", 0)
+	*addr = val
+	hx.Call("", "Scheduler.unlock", 0)
+}
 
 // this only for the SSA compiler, will not be code generated
 func init() {
@@ -10,6 +10,8 @@ import (
 	"runtime"
 	"sync/atomic"
 	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
 )
 
 // A Pool is a set of temporary objects that may be individually saved and
@@ -179,6 +181,9 @@ func (p *Pool) pinSlow() *poolLocal {
 	}
 	// If GOMAXPROCS changes between GCs, we re-allocate the array and lose the old one.
 	size := runtime.GOMAXPROCS(0)
+	if pid >= size { // in Haxe the "P" is the goroutine, see runtime_procPin()
+		size = pid + 1
+	}
 	local := make([]poolLocal, size)
 	atomic.StorePointer((*unsafe.Pointer)(&p.local), unsafe.Pointer(&local[0])) // store-release
 	atomic.StoreUintptr(&p.localSize, uintptr(size))                            // store-release
@@ -222,6 +227,11 @@ func indexLocal(l unsafe.Pointer, i int) *poolLocal {
 }
 
 // Implemented in runtime.
-func runtime_registerPoolCleanup(cleanup func()) {}           // TODO(haxe) review correct action here
-func runtime_procPin() int                       { return 0 } // TODO(haxe) review correct action here
-func runtime_procUnpin()                         {}           // TODO(haxe) review correct action here
+func runtime_registerPoolCleanup(cleanup func()) {} // TODO(haxe) review correct action here
+func runtime_procUnpin()                         {} // TODO(haxe) review correct action here
+
+// In Haxe the "P" is the goroutine, so that a poolLocal is only used by one goroutine at a time,
+// even when goroutines run on many threads (Haxe -D gothreads).
+func runtime_procPin() int {
+	return hx.GetInt("", "this._goroutine")
+}
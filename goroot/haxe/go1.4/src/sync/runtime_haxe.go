@@ -4,14 +4,18 @@
 
 // +build haxe
 
-// runtime functions rewritten for single-threaded Haxe
-// TODO this is all VERY simplistic, it needs to mesh-in with goroutine scheduling
+// runtime functions rewritten for Haxe,
+// the Scheduler does not run a goroutine waiting for a semaphore again until the semaphore is released,
+// and the Haxe runtime lock makes the semaphore operations atomic when goroutines run on many threads (Haxe -D gothreads)
 
 package sync
 
 import (
+	"haxegoruntime"
 	"runtime"
 	"unsafe"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
 )
 
 // defined in package runtime
@@ -20,10 +24,12 @@ import (
 // It is intended as a simple sleep primitive for use by the synchronization
 // library and should not be used directly.
 func runtime_Semacquire(s *uint32) {
+	hx.Call("", "Scheduler.lock", 0)
 	for *s < 1 {
-		runtime.Gosched()
+		haxegoruntime.Park("semacquire", 0, unsafe.Pointer(s)) // not run again until Semrelease(s)
 	}
 	*s -= 1
+	hx.Call("", "Scheduler.unlock", 0)
 }
 
 // Semrelease atomically increments *s and notifies a waiting goroutine
@@ -31,7 +37,10 @@ func runtime_Semacquire(s *uint32) {
 // It is intended as a simple wakeup primitive for use by the synchronization
 // library and should not be used directly.
 func runtime_Semrelease(s *uint32) {
+	hx.Call("", "Scheduler.lock", 0)
 	*s += 1
+	hx.Call("", "Scheduler.wakeAddr", 1, unsafe.Pointer(s))
+	hx.Call("", "Scheduler.unlock", 0)
 	runtime.Gosched()
 }
 
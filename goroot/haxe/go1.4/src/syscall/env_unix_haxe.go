@@ -8,7 +8,11 @@
 
 package syscall
 
-import "sync"
+import (
+	"sync"
+
+	"github.com/tardisgo/tardisgo/haxe/hx"
+)
 
 var (
 	// envOnce guards initialization by copyenv, which populates env.
@@ -26,12 +30,21 @@ var (
 	envs []string = runtime_envs()
 )
 
-func runtime_envs() []string { return []string{} } // in package runtime
+// runtime_envs returns the environment of the host, should be in package runtime
+// The host array is read by index, leaving it unchanged, as other Haxe code may share it.
+func runtime_envs() []string {
+	envs := []string{}
+	haxeEnv := hx.CallDynamic("", "Console.environment", 0)
+	n := hx.FgetInt("", haxeEnv, "", "length")
+	for i := 0; i < n; i++ {
+		envs = append(envs, hx.CodeString("", "_a.itemAddr(0).load().val[_a.itemAddr(1).load().val];", haxeEnv, i))
+	}
+	return envs
+}
 
-// setenv_c and unsetenv_c are provided by the runtime but are no-ops
-// if cgo isn't loaded.
-func setenv_c(k, v string) {}
-func unsetenv_c(k string)  {}
+// setenv_c and unsetenv_c pass changes on to the host environment, where the target allows it.
+func setenv_c(k, v string) { hx.Call("", "Console.putEnv", 2, k, v) }
+func unsetenv_c(k string)  { hx.Call("", "Console.unsetEnv", 1, k) }
 
 func copyenv() {
 	env = make(map[string]int)
@@ -11,6 +11,7 @@
 package syscall
 
 import (
+	"haxegoruntime"
 	"sync"
 	"sync/atomic"
 )
@@ -20,19 +21,21 @@ import (
 // Really for use by package time, but we cannot import time here.
 
 type runtimeTimer struct {
-	i      int
-	when   int64
-	period int64
-	f      func(interface{}, uintptr) // NOTE: must not be closure
-	arg    interface{}
-	seq    uintptr
+	i         int
+	when      int64
+	period    int64
+	f         func(interface{}, uintptr) // NOTE: must not be closure
+	arg       interface{}
+	seq       uintptr
+	haxeTimer *haxegoruntime.Timer // the entry in the runtime timer heap, an Haxe addition
 }
 
-func startTimer(*runtimeTimer) {
-	panic("syscall.startTimer()")
+func startTimer(rt *runtimeTimer) { // function body is an Haxe addition
+	rt.haxeTimer = &haxegoruntime.Timer{When: rt.when, Period: rt.period, F: rt.f, Arg: rt.arg, Seq: rt.seq}
+	haxegoruntime.AddTimer(rt.haxeTimer)
 }
-func stopTimer(*runtimeTimer) bool {
-	panic("syscall.stopTimer()")
+func stopTimer(rt *runtimeTimer) bool { // function body is an Haxe addition
+	return rt.haxeTimer != nil && haxegoruntime.DelTimer(rt.haxeTimer)
 }
 
 type timer struct {
@@ -46,7 +49,8 @@ func (t *timer) start(q *queue, deadline int64) {
 		return
 	}
 	t.q = q
-	t.r.when = deadline
+	sec, nsec := now()
+	t.r.when = deadline - (sec*1e9 + int64(nsec)) + haxegoruntime.Nanotime() // deadlines are wall-clock times, an Haxe addition
 	t.r.f = timerExpired
 	t.r.arg = t
 	startTimer(&t.r)
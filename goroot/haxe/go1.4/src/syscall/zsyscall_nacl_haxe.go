@@ -25,12 +25,7 @@ func Exit(code int) (err error) {
 	//if e1 != 0 {
 	//	err = e1
 	//}
-	hx.Call("(cpp || cs || java || macro || neko || php || python)", "Sys.exit", 1, code)
-	if code == 0 {
-		hx.Code("js", "untyped __js__('process.exit(0)');") // only works on Node
-	} else { // all non-zero values return as 1
-		hx.Code("js", "untyped __js__('process.exit(1)');") // only works on Node
-	}
+	hx.Call("", "Console.exit", 1, code) // only returns on targets that cannot exit
 	panic("syscall.Exit(" + hx.CallString("", "Std.string", 1, code) + ")")
 	return
 }
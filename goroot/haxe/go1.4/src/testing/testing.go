@@ -34,13 +34,6 @@ type TB interface {
 var _ TB = (*T)(nil)
 var _ TB = (*B)(nil)
 
-func header(s string) {
-	_, file, line, _ := runtime.Caller(2)
-	println(" ") // blank line
-	println(" ") // blank line
-	fmt.Println(s, file, line)
-}
-
 type T struct {
 	common
 }
@@ -64,51 +57,173 @@ type PB struct {
 
 func (pb *PB) Next() bool { return false }
 
-type common struct{}
+// common holds the state of a test, subtest or benchmark.
+type common struct {
+	name     string
+	depth    int // how many levels of subtest deep
+	failed   bool
+	skipped  bool
+	output   []string // the lines logged, printed after the result
+	start    time.Time
+	duration time.Duration
+}
+
+// log records a line of output, decorated with the position of the test code that called the method calling log.
+func (c *common) log(s string) {
+	_, file, line, ok := runtime.Caller(2)
+	if ok {
+		for i := len(file) - 1; i >= 0; i-- {
+			if file[i] == '/' {
+				file = file[i+1:]
+				break
+			}
+		}
+		s = file + ":" + itoa(line) + ": " + s
+	}
+	for len(s) > 0 && s[len(s)-1] == '\n' {
+		s = s[:len(s)-1]
+	}
+	c.output = append(c.output, s)
+}
+
+// sprint formats the arguments as the fmt_dummy package can, without the trailing space
+func sprint(args ...interface{}) string {
+	s := fmt.Sprint(args...)
+	if len(s) > 0 && s[len(s)-1] == ' ' {
+		s = s[:len(s)-1]
+	}
+	return s
+}
 
 func (c *common) Error(args ...interface{}) {
-	header("Error")
-	fmt.Println(args...)
-	runtime.Breakpoint()
-	hadError = true
+	c.log(sprint(args...))
+	c.Fail()
 }
 
 func (c *common) Errorf(format string, args ...interface{}) {
-	header("Errorf")
-	fmt.Printf(format, args...)
-	runtime.Breakpoint()
-	hadError = true
+	c.log(fmt.Sprintf(format, args...))
+	c.Fail()
 }
 func (c *common) Fatalf(format string, args ...interface{}) {
-	header("Fatalf")
-	fmt.Printf(format, args...)
-	runtime.Breakpoint()
-	badExit()
+	c.log(fmt.Sprintf(format, args...))
+	c.FailNow()
 }
 func (c *common) Logf(format string, args ...interface{}) {
-	header("Logf")
-	fmt.Printf(format, args...)
-	runtime.Breakpoint()
+	c.log(fmt.Sprintf(format, args...))
 }
-func (c *common) Fail()        { header("Fail"); runtime.Breakpoint(); badExit() }
-func (c *common) FailNow()     { header("FailNow"); runtime.Breakpoint(); badExit() }
-func (c *common) Failed() bool { return false }
+func (c *common) Fail()        { c.failed = true }
+func (c *common) FailNow()     { c.failed = true; runtime.Goexit() }
+func (c *common) Failed() bool { return c.failed }
 func (c *common) Fatal(args ...interface{}) {
-	header("Fatal")
-	fmt.Println(args...)
-	runtime.Breakpoint()
-	badExit()
-}
-func (c *common) Log(args ...interface{})  { header("Log"); fmt.Println(args...); runtime.Breakpoint() }
-func (t *common) Parallel()                {}
-func (c *common) Skip(args ...interface{}) { header("Skip"); fmt.Println(args...); runtime.Breakpoint() }
-func (c *common) SkipNow()                 { header("SkipNow"); runtime.Breakpoint() }
+	c.log(sprint(args...))
+	c.FailNow()
+}
+func (c *common) Log(args ...interface{}) { c.log(sprint(args...)) }
+func (t *common) Parallel()               {} // tests are always run one at a time
+func (c *common) Skip(args ...interface{}) {
+	c.log(sprint(args...))
+	c.SkipNow()
+}
+func (c *common) SkipNow() { c.skipped = true; runtime.Goexit() }
 func (c *common) Skipf(format string, args ...interface{}) {
-	header("Skipf")
-	fmt.Printf(format, args...)
-	runtime.Breakpoint()
+	c.log(fmt.Sprintf(format, args...))
+	c.SkipNow()
+}
+func (c *common) Skipped() bool { return c.skipped }
+
+// report prints the result of a test in the format of "go test -v", which "tardisgo test" reads.
+func (c *common) report() {
+	indent := ""
+	for i := 0; i < c.depth; i++ {
+		indent += "    "
+	}
+	status := "PASS"
+	switch {
+	case c.failed:
+		status = "FAIL"
+	case c.skipped:
+		status = "SKIP"
+	}
+	println(indent + "--- " + status + ": " + c.name + " (" + seconds(c.duration) + ")")
+	for _, s := range c.output {
+		println(indent + "\t" + s)
+	}
+}
+
+// run runs a test function, then reports its result.
+func (t *T) run(f func(*T)) {
+	println("=== RUN " + t.name)
+	t.start = time.Now()
+	t.call(f)
+	t.duration = time.Since(t.start)
+	t.report()
+}
+
+// call calls the test function in its own goroutine, as FailNow and SkipNow stop it with runtime.Goexit,
+// the test fails if it panics.
+func (t *T) call(f func(*T)) {
+	done := make(chan bool, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.failed = true
+				t.output = append(t.output, "panic: "+describe(r))
+			}
+			done <- true
+		}()
+		f(t)
+	}()
+	<-done
+}
+
+// Run runs f as a subtest of t called name, returning whether it passed.
+// The subtest runs in a new goroutine, and Run waits for it to finish before returning.
+func (t *T) Run(name string, f func(t *T)) bool {
+	sub := &T{common{name: t.name + "/" + name, depth: t.depth + 1}}
+	sub.run(f)
+	if sub.failed {
+		t.failed = true
+	}
+	return !sub.failed
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case string:
+		return v
+	}
+	return sprint(v)
+}
+
+func itoa(i int) string {
+	if i == 0 {
+		return "0"
+	}
+	neg := i < 0
+	if neg {
+		i = -i
+	}
+	s := ""
+	for ; i > 0; i /= 10 {
+		s = string(rune('0'+i%10)) + s
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
+
+// seconds formats a duration as "1.23s"
+func seconds(d time.Duration) string {
+	cs := int((d + 5*time.Millisecond) / (10 * time.Millisecond)) // hundredths of a second
+	frac := itoa(cs % 100)
+	if len(frac) < 2 {
+		frac = "0" + frac
+	}
+	return itoa(cs/100) + "." + frac + "s"
 }
-func (c *common) Skipped() bool { return false }
 
 func Short() bool   { return true }
 func Verbose() bool { return false }
@@ -144,36 +259,59 @@ func (r BenchmarkResult) String() string { return "" }
 
 // An internal function but exported because it is cross-package; part of the implementation
 // of the "go test" command.
+// The tests are run in name order, printing their results in the format of "go test -v",
+// then PASS or FAIL, with an exit code of 1 if any failed.
 func Main(matchString func(pat, str string) (bool, error), tests []InternalTest, benchmarks []InternalBenchmark, examples []InternalExample) {
-	//os.Exit(MainStart(matchString, tests, benchmarks, examples).Run())
-	fmt.Println("testing.Main")
 	runtime.UnzipTestFS()
-	var t T
+	selected := runtime.SelectedTests()
 	names := []string{}
 	for _, f := range tests {
-		names = append(names, f.Name)
+		if isSelected(selected, f.Name) {
+			names = append(names, f.Name)
+		}
+	}
+	if len(names) == 0 {
+		println("testing: warning: no tests to run")
 	}
 	sort.Strings(names)
+	ok := true
 	for _, n := range names {
 		for _, f := range tests {
 			if n == f.Name {
-				println("\nExecuting test:", n)
-				f.F(&t)
+				t := &T{common{name: n}}
+				t.run(f.F)
+				if t.failed {
+					ok = false
+				}
 			}
 		}
 	}
-	if hadError {
+	if !ok {
+		println("FAIL")
 		badExit()
 	}
-	hx.Call("(cpp || cs || java || macro || neko || php || python)", "Sys.exit", 1, 0)
-	hx.Code("js", "untyped __js__('process.exit(0)');") // only works on Node
+	println("PASS")
+	hx.Call("", "Console.exit", 1, 0)
 }
 
-var hadError = false
+// isSelected reports if the named test is in the newline-terminated list of selected tests, an empty list selects them all.
+func isSelected(selected, name string) bool {
+	if selected == "" {
+		return true
+	}
+	for start, i := 0, 0; i < len(selected); i++ {
+		if selected[i] == '\n' {
+			if selected[start:i] == name {
+				return true
+			}
+			start = i + 1
+		}
+	}
+	return false
+}
 
 func badExit() {
-	hx.Call("(cpp || cs || java || macro || neko || php || python)", "Sys.exit", 1, 1)
-	hx.Code("js", "untyped __js__('process.exit(1)');") // only works on Node
+	hx.Call("", "Console.exit", 1, 1)
 }
 
 func init() {
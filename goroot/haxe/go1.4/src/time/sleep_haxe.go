@@ -7,7 +7,8 @@
 package time
 
 import ( // import is an Haxe addition
-	"runtime"
+	"haxegoruntime"
+	"unsafe"
 
 	"github.com/tardisgo/tardisgo/haxe/hx"
 )
@@ -22,32 +23,30 @@ func Sleep(d Duration) { // function body is an Haxe addition
 	haxeWait(target, &sleeping)
 }
 
+// haxeWait blocks the goroutine until the time target, as from haxe.Timer.stamp(), unless *whileTrue is cleared.
 func haxeWait(target float64, whileTrue *bool) {
-	// TODO(haxe): optimize to use the Timer call-back methods for the targets - flash, flash8, java, js, python
-loop:
-	runtime.Gosched()
-	now := hx.CallFloat("", "haxe.Timer.stamp", 0)
-	//println("DEBUG haxeWait now, target, *whileTrue = ", now, target, *whileTrue)
-	if now < target && *whileTrue {
-		goto loop
+	for *whileTrue && hx.CallFloat("", "haxe.Timer.stamp", 0) < target {
+		hx.Call("", "Scheduler.lock", 0)
+		haxegoruntime.Park("sleep", target, unsafe.Pointer(whileTrue))
+		hx.Call("", "Scheduler.unlock", 0)
 	}
 }
 
 // runtimeNano returns the current value of the runtime clock in nanoseconds.
 func runtimeNano() int64 { // function body is an Haxe addition
-	return int64(hx.CallFloat("", "haxe.Timer.stamp", 0) * 1000000000)
+	return haxegoruntime.Nanotime()
 }
 
 // Interface to timers implemented in package runtime.
 // Must be in sync with ../runtime/runtime.h:/^struct.Timer$
 type runtimeTimer struct {
-	i          int
-	when       int64
-	period     int64
-	f          func(interface{}, uintptr) // NOTE: must not be closure
-	arg        interface{}
-	seq        uintptr
-	haxeRuning bool
+	i         int
+	when      int64
+	period    int64
+	f         func(interface{}, uintptr) // NOTE: must not be closure
+	arg       interface{}
+	seq       uintptr
+	haxeTimer *haxegoruntime.Timer // the entry in the runtime timer heap, an Haxe addition
 }
 
 // when is a helper function for setting the 'when' field of a runtimeTimer.
@@ -65,29 +64,12 @@ func when(d Duration) int64 {
 	return t
 }
 
-func haxeTimer(rt *runtimeTimer) {
-again:
-	haxeWait(float64(rt.when)/1000000000, &rt.haxeRuning) // rt.when is in nanoseconds, haxe works in seconds
-	if rt.haxeRuning {
-		rt.f(rt.arg, rt.seq)
-		rt.seq++
-		if rt.period > 0 {
-			rt.when += rt.period
-			goto again
-		}
-	}
-}
-
 func startTimer(rt *runtimeTimer) { // function body is an Haxe addition
-	rt.haxeRuning = true
-	go haxeTimer(rt)
+	rt.haxeTimer = &haxegoruntime.Timer{When: rt.when, Period: rt.period, F: rt.f, Arg: rt.arg, Seq: rt.seq}
+	haxegoruntime.AddTimer(rt.haxeTimer)
 }
 func stopTimer(rt *runtimeTimer) bool { // function body is an Haxe addition
-	if rt.haxeRuning {
-		rt.haxeRuning = false
-		return true
-	}
-	return false
+	return rt.haxeTimer != nil && haxegoruntime.DelTimer(rt.haxeTimer)
 }
 
 // The Timer type represents a single event.
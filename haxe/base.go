@@ -8,18 +8,46 @@ import (
 	"errors"
 	"fmt"
 	"go/token"
+	"os"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 
 	"github.com/tardisgo/tardisgo/pogo"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/types"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
-var haxeStdSizes = types.StdSizes{
+var haxeStdSizes = lockedSizes{StdSizes: types.StdSizes{
 	WordSize: 4, // word size in bytes - must be >= 4 (32bits)
 	MaxAlign: 8, // maximum alignment in bytes - must be >= 1
+}}
+
+// lockedSizes allows the sizes to be used by many goroutines at once, as types.StdSizes caches struct offsets in the types.
+type lockedSizes struct {
+	mu sync.Mutex
+	types.StdSizes
+}
+
+func (s *lockedSizes) Alignof(T types.Type) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.StdSizes.Alignof(T)
+}
+
+func (s *lockedSizes) Offsetsof(fields []*types.Var) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.StdSizes.Offsetsof(fields)
+}
+
+func (s *lockedSizes) Sizeof(T types.Type) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.StdSizes.Sizeof(T)
 }
 
 func fieldOffset(str *types.Struct, fldNum int) int64 {
@@ -50,21 +78,43 @@ func arrayOffsetCalc(ele types.Type) string {
 	return fmt.Sprintf("*%d", off)
 }
 
-func emitTrace(s string) string {
-	if pogo.TraceFlag {
+func (l *langType) emitTrace(s string) string {
+	if l.PogoComp.TraceFlag {
 		return `trace(this._functionName,this._latestBlock,"TRACE ` + s + ` "` /* + ` "+Scheduler.stackDump()` */ + ");\n"
 	}
 	return ""
 }
 
-type langType struct{} // to give us a type to work from when building the interface for pogo
-
-var langIdx int // which entry is this language in pogo.LanguageList
+// langType gives us a type to work from when building the interface for pogo,
+// it holds the state of the Haxe code generation for one pogo.Compilation.
+type langType struct {
+	PogoComp  *pogo.Compilation   // the compilation this code generation is part of
+	langEntry *pogo.LanguageEntry // this language's entry in PogoComp.LanguageList
+
+	useRegisterArray bool // should we use an array rather than individual register vars
+
+	nextReturnAddress       int           // what number is the next pseudo block return address?
+	hadReturn               bool          // has there been a return statement in this function?
+	hadBlockReturn          bool          // has there been a return in this block?
+	pseudoNextReturnAddress int           // what is the next pseudo block to emit/or limit of what's been emitted
+	pseudoBlockNext         int           // what is the next pseudo block we should have emitted?
+	currentfn               *ssa.Function // what we are currently working on
+	currentfnName           string        // the Haxe name of what we are currently working on
+	fnUsesGr                bool          // does the current function use Goroutines?
+
+	typesByID    []types.Type
+	pte          typeutil.Map
+	pteKeys      []types.Type
+	methodSetIDs map[int]bool // the IDs of the types with methods that MethodTypeInfo dispatches on
+}
 
 func init() {
 	var langVar langType
 	var langEntry pogo.LanguageEntry
-	langEntry.Language = langVar
+	langEntry.Language = &langVar
+	langEntry.InitLang = func(comp *pogo.Compilation, entry *pogo.LanguageEntry) pogo.Language {
+		return &langType{PogoComp: comp, langEntry: entry}
+	}
 
 	il := 1024 // 1024 is an internal C# limit (`lvregs_len < 1024')
 
@@ -74,16 +124,15 @@ func init() {
 	langEntry.HeaderConstVarName = "tardisgoHaxeHeader"
 	langEntry.Goruntime = "haxegoruntime" // a string containing the location of the core language runtime functions delivered in Go
 
-	langIdx = len(pogo.LanguageList)
 	pogo.LanguageList = append(pogo.LanguageList, langEntry)
 }
 
-func (langType) LanguageName() string   { return "haxe" }
-func (langType) FileTypeSuffix() string { return ".hx" }
+func (l *langType) LanguageName() string   { return "haxe" }
+func (l *langType) FileTypeSuffix() string { return ".hx" }
 
 // make a comment
-func (langType) Comment(c string) string {
-	if c != "" && pogo.DebugFlag { // only comment if something to say and in debug mode
+func (l *langType) Comment(c string) string {
+	if c != "" && l.PogoComp.DebugFlag { // only comment if something to say and in debug mode
 		return " // " + c
 	}
 	return ""
@@ -97,7 +146,7 @@ const tardisgoLicence = `// This code generated using the TARDIS Go tool, elemen
 // license that can be found in the LICENSE file at https://github.com/tardisgo/tardisgo
 `
 
-func (langType) FileStart(haxePackageName, headerText string) string {
+func (l *langType) FileStart(haxePackageName, headerText string) string {
 	if haxePackageName == "" {
 		haxePackageName = "tardis"
 	}
@@ -105,14 +154,14 @@ func (langType) FileStart(haxePackageName, headerText string) string {
 }
 
 // TODO rename
-func (langType) FileEnd() string {
-	return haxeruntime() // this deals with the individual runtime class files
+func (l *langType) FileEnd() string {
+	return l.haxeruntime() // this deals with the individual runtime class files
 }
 
 // RegisterName returns the name of an ssa.Value, a utility function in case it needs to be altered.
-func (langType) RegisterName(val ssa.Value) string {
+func (l *langType) RegisterName(val ssa.Value) string {
 	//NOTE the SSA code says that name() should not be relied on, so this code may need to alter
-	if useRegisterArray { // we must use a register array when there are too many registers declared at class level for C++/Java to handle
+	if l.useRegisterArray { // we must use a register array when there are too many registers declared at class level for C++/Java to handle
 		reg := val.Name()
 		if reg[0] != 't' {
 			panic("Register Name does not begin with t: " + reg)
@@ -123,28 +172,45 @@ func (langType) RegisterName(val ssa.Value) string {
 	}
 }
 
-var useRegisterArray bool // should we use an array rather than individual register vars
-
-var nextReturnAddress int       // what number is the next pseudo block return address?
-var hadReturn bool              // has there been a return statement in this function?
-var hadBlockReturn bool         // has there been a return in this block?
-var pseudoNextReturnAddress int // what is the next pseudo block to emit/or limit of what's been emitted
-var pseudoBlockNext int         // what is the next pseudo block we should have emitted?
-var currentfn *ssa.Function     // what we are currently working on
-var currentfnName string        // the Haxe name of what we are currently working on
-var fnUsesGr bool               // does the current function use Goroutines?
+// goFuncName gives the name of a function in the form the Go runtime uses, for runtime.FuncForPC and stack dumps,
+// for example "main.main", "main.main.func1" or "sync.(*Mutex).Lock".
+func goFuncName(fn *ssa.Function) string {
+	if par := fn.Parent(); par != nil { // an anonymous function, which ssa names $1, $2... after its parent
+		return goFuncName(par) + ".func" + strings.TrimPrefix(fn.Name(), par.Name()+"$")
+	}
+	if recv := fn.Signature.Recv(); recv != nil && fn.Synthetic == "" {
+		typ := recv.Type()
+		ptr, isPtr := typ.(*types.Pointer)
+		if isPtr {
+			typ = ptr.Elem()
+		}
+		if named, isNamed := typ.(*types.Named); isNamed && named.Obj().Pkg() != nil {
+			if isPtr {
+				return named.Obj().Pkg().Path() + ".(*" + named.Obj().Name() + ")." + fn.Name()
+			}
+			return named.Obj().Pkg().Path() + "." + named.Obj().Name() + "." + fn.Name()
+		}
+	}
+	name := fn.String()
+	if i := strings.LastIndex(name, ".init#"); i >= 0 { // ssa numbers the init functions of a package from 1, Go from 0
+		if n, err := strconv.Atoi(name[i+len(".init#"):]); err == nil {
+			return name[:i] + ".init." + strconv.Itoa(n-1)
+		}
+	}
+	return name
+}
 
-func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, position string, isPublic, trackPhi, usesGr bool, canOptMap map[string]bool) string {
+func (l *langType) FuncStart(packageName, objectName string, fn *ssa.Function, position string, isPublic, trackPhi, usesGr bool, canOptMap map[string]bool) string {
 
 	//fmt.Println("DEBUG: HAXE FuncStart: ", packageName, ".", objectName, usesGr)
 
-	nextReturnAddress = -1
-	hadReturn = false
-	hadBlockReturn = false
-	pseudoBlockNext = -1
-	currentfn = fn
-	currentfnName = "Go_" + l.LangName(packageName, objectName)
-	fnUsesGr = usesGr
+	l.nextReturnAddress = -1
+	l.hadReturn = false
+	l.hadBlockReturn = false
+	l.pseudoBlockNext = -1
+	l.currentfn = fn
+	l.currentfnName = "Go_" + l.LangName(packageName, objectName)
+	l.fnUsesGr = usesGr
 
 	ret := ""
 
@@ -156,7 +222,7 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 		//	ret += "#if (!php) private #end " // for some reason making classes private is a problem in php
 	}
 	ret += fmt.Sprintf("class %s extends StackFrameBasis implements StackFrame { %s\n",
-		currentfnName, l.Comment(position))
+		l.currentfnName, l.Comment(position))
 
 	//Create the stack frame variables
 	hadBlank := false
@@ -177,7 +243,8 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 		ret += ", "
 		ret += "p_" + pogo.MakeID(fn.Params[p].Name()) + " : " + l.LangType(fn.Params[p].Type() /*.Underlying()*/, false, fn.Params[p].Name()+position)
 	}
-	ret += ") {\nsuper(gr," + fmt.Sprintf("%d", pogo.LatestValidPosHash) + ",\"Go_" + l.LangName(packageName, objectName) + "\");\nthis._bds=_bds;\n"
+	ret += ") {\nsuper(gr," + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + "," +
+		l.haxeStringConst(strconv.Quote(goFuncName(fn)), position) + ");\nthis._bds=_bds;\n"
 	hadBlank = false
 	for p := range fn.Params {
 		prefix := "this.p_"
@@ -185,7 +252,7 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 			prefix += fmt.Sprintf("%d", p)
 		}
 		ret += prefix + pogo.MakeID(fn.Params[p].Name()) + "=p_" + pogo.MakeID(fn.Params[p].Name()) + ";\n"
-		if pogo.DebugFlag {
+		if l.PogoComp.DebugFlag {
 			ret += `this._debugVars.set("` + fn.Params[p].Name() + `",p_` + pogo.MakeID(fn.Params[p].Name()) + ");\n"
 		}
 		if fn.Params[p].Name() == "_" {
@@ -200,7 +267,7 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 			}
 		}
 	}
-	ret += emitTrace(`New:` + l.LangName(packageName, objectName))
+	ret += l.emitTrace(`New:` + l.LangName(packageName, objectName))
 	ret += "Scheduler.push(gr,this);\n}\n"
 
 	rTyp := ""
@@ -351,13 +418,13 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 
 	regCount := 0
 	regDefs := ""
-	useRegisterArray = false
+	l.useRegisterArray = false
 
-	pseudoNextReturnAddress = -1
+	l.pseudoNextReturnAddress = -1
 	for b := range fn.Blocks {
 		for i := range fn.Blocks[b].Instrs {
 			in := fn.Blocks[b].Instrs[i]
-			reg := l.Value(in, pogo.CodePosition(in.Pos()))
+			reg := l.Value(in, l.PogoComp.CodePosition(in.Pos()))
 
 			switch in.(type) {
 			case *ssa.Call:
@@ -366,32 +433,32 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 					//NoOp
 				default:
 					// Optimise here not to declare Stack Frames for pseudo-functions used when calling Haxe code direct
-					pp := getPackagePath(in.(*ssa.Call).Common())
+					pp := l.getPackagePath(in.(*ssa.Call).Common())
 					ppBits := strings.Split(pp, "/")
 					if ppBits[len(ppBits)-1] != "hx" && !strings.HasPrefix(ppBits[len(ppBits)-1], "_") {
 						//if usesGr {
 						//	ret += "private "
 						//}
-						ret += fmt.Sprintf("var _SF%d:StackFrame", -pseudoNextReturnAddress) //TODO set correct type, or let Haxe determine
+						ret += fmt.Sprintf("var _SF%d:StackFrame", -l.pseudoNextReturnAddress) //TODO set correct type, or let Haxe determine
 						if usesGr {
 							ret += " #if js =null #end ;\n"
 						} else {
 							ret += "=null;\n" // need to initalize when using the native stack for these vars
 						}
 					}
-					pseudoNextReturnAddress--
+					l.pseudoNextReturnAddress--
 				}
 			case *ssa.Send, *ssa.Select, *ssa.RunDefers, *ssa.Panic:
-				pseudoNextReturnAddress--
+				l.pseudoNextReturnAddress--
 			case *ssa.UnOp:
 				if in.(*ssa.UnOp).Op == token.ARROW {
-					pseudoNextReturnAddress--
+					l.pseudoNextReturnAddress--
 				}
 			case *ssa.Alloc:
 				if !in.(*ssa.Alloc).Heap { // allocate space on the stack if possible
 					//fmt.Println("DEBUG allocate stack space for", reg, "at", position)
 					if reg != "" {
-						ret += haxeVar(reg+"_stackalloc", "Object", "="+allocNewObject(in.(*ssa.Alloc).Type()), position, "FuncStart()") + "\n"
+						ret += l.haxeVar(reg+"_stackalloc", "Object", "="+allocNewObject(in.(*ssa.Alloc).Type()), position, "FuncStart()") + "\n"
 					}
 				}
 			}
@@ -426,7 +493,7 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 						//if usesGr {
 						//	ret += "private "
 						//}
-						hv := haxeVar(reg, typ, init, position, "FuncStart()") + "\n"
+						hv := l.haxeVar(reg, typ, init, position, "FuncStart()") + "\n"
 						//if usesGr {
 						//	if strings.Contains(hv, ":") {
 						//		hv = strings.Replace(hv, ":", "(null,null):", 1)
@@ -440,12 +507,12 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 		}
 	}
 
-	if regCount > pogo.LanguageList[langIdx].InstructionLimit { // should only affect very large init() fns
-		fmt.Println("DEBUG regCount", currentfnName, regCount)
-		useRegisterArray = true
+	if regCount > l.langEntry.InstructionLimit { // should only affect very large init() fns
+		fmt.Fprintln(os.Stderr, "DEBUG regCount", l.currentfnName, regCount) // not stdout, which may carry -json diagnostics
+		l.useRegisterArray = true
 		ret += "var _t=new Array<Dynamic>();\n"
 	} else {
-		useRegisterArray = false
+		l.useRegisterArray = false
 		ret += regDefs
 	}
 	//TODO optimise (again) for if only one block (as below) AND no calls (which create synthetic values for _Next)
@@ -469,21 +536,21 @@ func (l langType) FuncStart(packageName, objectName string, fn *ssa.Function, po
 	return ret
 }
 
-func (l langType) runFunctionCode(packageName, objectName, msg string) string {
+func (l *langType) runFunctionCode(packageName, objectName, msg string) string {
 	ret := "public function run():Go_" + l.LangName(packageName, objectName) + " { //" + msg + "\n"
-	ret += emitTrace(`Run: ` + l.LangName(packageName, objectName) + " " + msg)
+	ret += l.emitTrace(`Run: ` + l.LangName(packageName, objectName) + " " + msg)
 	return ret
 }
 
-func (l langType) whileCaseCode() string {
+func (l *langType) whileCaseCode() string {
 	// NOTE this rather odd arrangement improves JS V8 optimization
 	ret := "#if js\n"
-	ret += "\tvar retVal:" + currentfnName + "=null;\n"
+	ret += "\tvar retVal:" + l.currentfnName + "=null;\n"
 	ret += "\twhile(retVal==null) \n\t\tswitch(_Next){\n"
-	for b := range currentfn.Blocks {
+	for b := range l.currentfn.Blocks {
 		ret += fmt.Sprintf("\t\tcase %d: retVal=_Block%d();\n", b, b)
 	}
-	for p := -1; p > pseudoNextReturnAddress; p-- {
+	for p := -1; p > l.pseudoNextReturnAddress; p-- {
 		ret += fmt.Sprintf("\t\tcase %d: retVal=_Block_%d();\n", p, -p)
 	}
 	ret += "\t\tdefault: Scheduler.bbi();\n"
@@ -494,29 +561,29 @@ func (l langType) whileCaseCode() string {
 	return ret
 }
 
-func (l langType) RunEnd(fn *ssa.Function) string {
+func (l *langType) RunEnd(fn *ssa.Function) string {
 	// TODO reoptimize if blocks >0 and no calls that create synthetic block entries
 	/*
 		ret := ""
-		if len(fn.Blocks) == 1 && !hadReturn {
+		if len(fn.Blocks) == 1 && !l.hadReturn {
 			ret += l.Ret(nil, "") // required because sometimes the SSA code is not generated for this
 		}
 		return ret + `default: Scheduler.bbi();}}}`
 	*/
-	ret := emitUnseenPseudoBlocks()
+	ret := l.emitUnseenPseudoBlocks()
 	ret += l.whileCaseCode()
 	return ret + "\n}\n"
 }
-func (l langType) FuncEnd(fn *ssa.Function) string {
+func (l *langType) FuncEnd(fn *ssa.Function) string {
 	// actually, the end of the class for that Go function
-	pogo.WriteAsClass(currentfnName, "}\n")
+	l.PogoComp.WriteAsClass(l.currentfnName, "}\n")
 	return ``
 }
 
 // utiltiy to set-up a haxe variable
-func haxeVar(reg, typ, init, position, errorStart string) string {
+func (l *langType) haxeVar(reg, typ, init, position, errorStart string) string {
 	if typ == "" {
-		pogo.LogError(position, "Haxe", fmt.Errorf(errorStart+" unhandled initialisation for empty type"))
+		l.PogoComp.LogError(position, "Haxe", fmt.Errorf(errorStart+" unhandled initialisation for empty type"))
 		return ""
 	}
 	ret := "var " + reg + ":" + typ
@@ -526,12 +593,12 @@ func haxeVar(reg, typ, init, position, errorStart string) string {
 	return ret + ";"
 }
 
-func (l langType) SetPosHash() string {
-	return "this.setPH(" + fmt.Sprintf("%d", pogo.LatestValidPosHash) + ");"
+func (l *langType) SetPosHash() string {
+	return "this.setPH(" + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + ");"
 }
 
-func (l langType) BlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) string {
-	hadBlockReturn = false
+func (l *langType) BlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) string {
+	l.hadBlockReturn = false
 	// TODO optimise is only 1 block AND no calls
 	// TODO if len(block) > 1 { // no need for a case statement if only one block
 	ret := ""
@@ -540,35 +607,38 @@ func (l langType) BlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) str
 	}
 	ret += fmt.Sprintf("#if !js case %d: #end", num) + l.Comment(block[num].Comment) + "\n"
 	ret += fmt.Sprintf("#if js function _Block%d(){ #end\n", num)
-	ret += emitTrace(fmt.Sprintf("Function: %s Block:%d", block[num].Parent(), num))
-	if pogo.DebugFlag {
-		ret += "this.setLatest(" + fmt.Sprintf("%d", pogo.LatestValidPosHash) + "," + fmt.Sprintf("%d", num) + ");\n"
+	ret += l.emitTrace(fmt.Sprintf("Function: %s Block:%d", block[num].Parent(), num))
+	if l.PogoComp.DebugFlag {
+		ret += "this.setLatest(" + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + "," + fmt.Sprintf("%d", num) + ");\n"
 	}
 	return ret
 }
 
-func (l langType) BlockEnd(block []*ssa.BasicBlock, num int, emitPhi bool) string {
+func (l *langType) BlockEnd(block []*ssa.BasicBlock, num int, emitPhi, loopEnd bool) string {
 	ret := ""
 	if emitPhi {
 		ret += fmt.Sprintf(" _Phi=%d;\n", num)
 	}
-	if !hadBlockReturn {
+	if loopEnd {
+		ret += "if(Scheduler.preempt()) return this;\n" // _Next is already set, so we carry on from there
+	}
+	if !l.hadBlockReturn {
 		ret += "#if js return null; #end\n"
 	}
-	hadBlockReturn = true
+	l.hadBlockReturn = true
 	ret += "#if js } #end\n"
 	return ret
 }
 
-func (l langType) Jump(block int) string {
+func (l *langType) Jump(block int) string {
 	return fmt.Sprintf("_Next=%d;", block)
 }
 
-func (l langType) If(v interface{}, trueNext, falseNext int, errorInfo string) string {
+func (l *langType) If(v interface{}, trueNext, falseNext int, errorInfo string) string {
 	return fmt.Sprintf("_Next=%s ? %d : %d;", l.IndirectValue(v, errorInfo), trueNext, falseNext)
 }
 
-func (l langType) Phi(register string, phiEntries []int, valEntries []interface{}, defaultValue, errorInfo string) string {
+func (l *langType) Phi(register string, phiEntries []int, valEntries []interface{}, defaultValue, errorInfo string) string {
 	ret := register + "=("
 	for e := range phiEntries {
 		val := l.IndirectValue(valEntries[e], errorInfo)
@@ -577,13 +647,13 @@ func (l langType) Phi(register string, phiEntries []int, valEntries []interface{
 	return ret + defaultValue + ");"
 }
 
-func (l langType) LangName(p, o string) string {
+func (l *langType) LangName(p, o string) string {
 	return pogo.MakeID(p) + "_" + pogo.MakeID(o)
 }
 
 // Returns the textual version of Value, possibly emmitting an error
 // can't merge with indirectValue, as this is used by emit-func-setup to get register names
-func (l langType) Value(v interface{}, errorInfo string) string {
+func (l *langType) Value(v interface{}, errorInfo string) string {
 	val, ok := v.(ssa.Value)
 	if !ok {
 		return "" // if it is not a value, an empty string will be returned
@@ -592,9 +662,9 @@ func (l langType) Value(v interface{}, errorInfo string) string {
 	case *ssa.Global:
 		return "Go." + l.LangName(v.(*ssa.Global).Pkg.Object.Path() /* was .Name()*/, v.(*ssa.Global).Name())
 	case *ssa.Alloc, *ssa.MakeSlice:
-		return pogo.RegisterName(v.(ssa.Value))
+		return l.PogoComp.RegisterName(v.(ssa.Value))
 	case *ssa.FieldAddr, *ssa.IndexAddr:
-		return pogo.RegisterName(v.(ssa.Value))
+		return l.PogoComp.RegisterName(v.(ssa.Value))
 	case *ssa.Const:
 		ci := v.(*ssa.Const)
 		_, c := l.Const(*ci, errorInfo)
@@ -607,12 +677,12 @@ func (l langType) Value(v interface{}, errorInfo string) string {
 	//			return `_bds[` + fmt.Sprintf("%d", b) + `]`
 	//		}
 	//	}
-	//	pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Value(): *ssa.Capture name not found: %s", v.(*ssa.Capture).Name()))
+	//	l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Value(): *ssa.Capture name not found: %s", v.(*ssa.Capture).Name()))
 	//	return `_bds["_b` + "ERROR: Captured bound variable name not found" + `"]` // TODO proper error
 	case *ssa.FreeVar:
 		return `_bds.` + v.(*ssa.FreeVar).Name()
 	case *ssa.Function:
-		pk, _ := pogo.FuncPathName(v.(*ssa.Function))  //fmt.Sprintf("fn%d", v.(*ssa.Function).Pos())
+		pk, _ := l.PogoComp.FuncPathName(v.(*ssa.Function))  //fmt.Sprintf("fn%d", v.(*ssa.Function).Pos())
 		if v.(*ssa.Function).Signature.Recv() != nil { // it's a method
 			pn := v.(*ssa.Function).Signature.Recv().Pkg().Path() // was .Name()
 			pk = pn + "." + v.(*ssa.Function).Signature.Recv().Name()
@@ -623,25 +693,25 @@ func (l langType) Value(v interface{}, errorInfo string) string {
 				}
 			}
 		}
-		if len(v.(*ssa.Function).Blocks) > 0 { //the function actually exists
+		if l.PogoComp.HasCode(v.(*ssa.Function)) { //the function actually exists
 			return "new Closure(Go_" + l.LangName(pk, v.(*ssa.Function).Name()) + ".call,null)" //TODO will change for go instr
 		}
 		// function has no implementation
 		// TODO maybe put a list of over-loaded functions here and only error if not found
 		// NOTE the reflect package comes through this path TODO fix!
-		pogo.LogWarning(errorInfo, "Haxe", fmt.Errorf("haxe.Value(): *ssa.Function has no implementation: %s", v.(*ssa.Function).Name()))
+		l.PogoComp.LogWarning(errorInfo, "Haxe", fmt.Errorf("haxe.Value(): *ssa.Function has no implementation: %s", v.(*ssa.Function).Name()))
 		return "new Closure(null,null)" // Should fail at runtime if it is used...
 	case *ssa.UnOp:
-		return pogo.RegisterName(val)
+		return l.PogoComp.RegisterName(val)
 	case *ssa.BinOp:
-		return pogo.RegisterName(val)
+		return l.PogoComp.RegisterName(val)
 	case *ssa.MakeInterface:
-		return pogo.RegisterName(val)
+		return l.PogoComp.RegisterName(val)
 	default:
-		return pogo.RegisterName(val)
+		return l.PogoComp.RegisterName(val)
 	}
 }
-func (l langType) FieldAddr(register string, v interface{}, errorInfo string) string {
+func (l *langType) FieldAddr(register string, v interface{}, errorInfo string) string {
 	if register != "" {
 		ptr := l.IndirectValue(v.(*ssa.FieldAddr).X, errorInfo)
 		ptr = "Pointer.check(" + ptr + ")"
@@ -665,7 +735,7 @@ func wrapForce_toUInt(v string, k types.BasicKind) string {
 	return v
 }
 
-func (l langType) IndexAddr(register string, v interface{}, errorInfo string) string {
+func (l *langType) IndexAddr(register string, v interface{}, errorInfo string) string {
 	if register == "" {
 		return "" // we can't make an address if there is nowhere to put it...
 	}
@@ -689,16 +759,16 @@ func (l langType) IndexAddr(register string, v interface{}, errorInfo string) st
 	//		l.IndirectValue(v.(*ssa.IndexAddr).X, errorInfo),
 	//		idxString, arrayOffsetCalc(ele))
 	default:
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.IndirectValue():IndexAddr unknown operand type"))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.IndirectValue():IndexAddr unknown operand type"))
 		return ""
 	}
 }
 
-func (l langType) IndirectValue(v interface{}, errorInfo string) string {
+func (l *langType) IndirectValue(v interface{}, errorInfo string) string {
 	return l.Value(v, errorInfo)
 }
 
-func (l langType) intTypeCoersion(t types.Type, v, errorInfo string) string {
+func (l *langType) intTypeCoersion(t types.Type, v, errorInfo string) string {
 	switch t.Underlying().(type) {
 	case *types.Basic:
 		switch t.Underlying().(*types.Basic).Kind() {
@@ -719,24 +789,24 @@ func (l langType) intTypeCoersion(t types.Type, v, errorInfo string) string {
 		case types.Uint64:
 			return "Force.toUint64(" + v + ")"
 		case types.UntypedInt, types.UntypedRune:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion(): unhandled types.UntypedInt or types.UntypedRune"))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion(): unhandled types.UntypedInt or types.UntypedRune"))
 			return ""
 		case types.Float32:
 			return "Force.toFloat32(" + v + ")"
 		case types.Float64, types.Bool:
 			return v
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion():unhandled basic kind %s",
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion():unhandled basic kind %s",
 				t.Underlying().(*types.Basic).Kind()))
 			return v
 		}
 	default:
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion():unhandled type %T", t.Underlying()))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.intTypeCoersion():unhandled type %T", t.Underlying()))
 		return v
 	}
 }
 
-func (l langType) Store(v1, v2 interface{}, errorInfo string) string {
+func (l *langType) Store(v1, v2 interface{}, errorInfo string) string {
 	ptr := l.IndirectValue(v1, errorInfo)
 	ptr = "Pointer.check(" + ptr + ")"
 	return ptr + ".store" + loadStoreSuffix(v2.(ssa.Value).Type().Underlying(), true) +
@@ -744,53 +814,57 @@ func (l langType) Store(v1, v2 interface{}, errorInfo string) string {
 		" /* " + v2.(ssa.Value).Type().Underlying().String() + " */ "
 }
 
-func (l langType) Send(v1, v2 interface{}, errorInfo string) string {
-	ret := fmt.Sprintf("_Next=%d;\n", nextReturnAddress)
+func (l *langType) Send(v1, v2 interface{}, errorInfo string) string {
+	ret := fmt.Sprintf("_Next=%d;\n", l.nextReturnAddress)
 	ret += "return this;\n"
 	ret += "#if js } #end\n"
-	ret += emitUnseenPseudoBlocks()
-	ret += fmt.Sprintf("#if !js case %d: #end\n", nextReturnAddress)
-	ret += fmt.Sprintf("#if js function _Block_%d(){ #end\n", -nextReturnAddress)
-	if pogo.DebugFlag {
-		ret += "this.setLatest(" + fmt.Sprintf("%d", pogo.LatestValidPosHash) + "," + fmt.Sprintf("%d", nextReturnAddress) + ");\n"
+	ret += l.emitUnseenPseudoBlocks()
+	ret += fmt.Sprintf("#if !js case %d: #end\n", l.nextReturnAddress)
+	ret += fmt.Sprintf("#if js function _Block_%d(){ #end\n", -l.nextReturnAddress)
+	if l.PogoComp.DebugFlag {
+		ret += "this.setLatest(" + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + "," + fmt.Sprintf("%d", l.nextReturnAddress) + ");\n"
 	}
-	ret += emitTrace(fmt.Sprintf("Block:%d", nextReturnAddress))
+	ret += l.emitTrace(fmt.Sprintf("Block:%d", l.nextReturnAddress))
 	// TODO panic if the chanel is null
-	ret += "if(!" + l.IndirectValue(v1, errorInfo) + ".hasSpace())return this;\n" // go round the loop again and wait if not OK
-	ret += l.IndirectValue(v1, errorInfo) + ".send(" + l.IndirectValue(v2, errorInfo) + ");"
-	nextReturnAddress-- // decrement to set new return address for next code generation
-	hadBlockReturn = false
+	// the channel is tested and changed as one action, for -D gothreads
+	ret += "Scheduler.lock();\n"
+	// if the value can't be sent now, this goroutine is queued to send it, and waits to go round the loop again
+	ret += "if(!Channel.send(" + l.IndirectValue(v1, errorInfo) + ",this._goroutine," + l.IndirectValue(v2, errorInfo) + ")){"
+	ret += "Scheduler.wait(this._goroutine,\"chan send\"," + l.IndirectValue(v1, errorInfo) + ");Scheduler.unlock();return this;}\n"
+	ret += "Scheduler.unlock();"
+	l.nextReturnAddress-- // decrement to set new return address for next code generation
+	l.hadBlockReturn = false
 	return ret
 }
 
-func emitReturnHere() string {
+func (l *langType) emitReturnHere() string {
 	ret := ""
-	ret += fmt.Sprintf("_Next=%d;\n", nextReturnAddress)
+	ret += fmt.Sprintf("_Next=%d;\n", l.nextReturnAddress)
 	ret += "return this;\n"
 	ret += "#if js } #end\n"
-	ret += emitUnseenPseudoBlocks()
-	ret += fmt.Sprintf("#if !js case %d: #end\n", nextReturnAddress)
-	ret += fmt.Sprintf("#if js function _Block_%d(){ #end\n", -nextReturnAddress)
-	if pogo.DebugFlag {
-		ret += "this.setLatest(" + fmt.Sprintf("%d", pogo.LatestValidPosHash) + "," + fmt.Sprintf("%d", nextReturnAddress) + ");\n"
-	}
-	ret += emitTrace(fmt.Sprintf("Block:%d", nextReturnAddress))
-	hadBlockReturn = false
+	ret += l.emitUnseenPseudoBlocks()
+	ret += fmt.Sprintf("#if !js case %d: #end\n", l.nextReturnAddress)
+	ret += fmt.Sprintf("#if js function _Block_%d(){ #end\n", -l.nextReturnAddress)
+	if l.PogoComp.DebugFlag {
+		ret += "this.setLatest(" + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + "," + fmt.Sprintf("%d", l.nextReturnAddress) + ");\n"
+	}
+	ret += l.emitTrace(fmt.Sprintf("Block:%d", l.nextReturnAddress))
+	l.hadBlockReturn = false
 	return ret
 }
 
-func emitUnseenPseudoBlocks() string {
+func (l *langType) emitUnseenPseudoBlocks() string {
 	ret := ""
-	if nextReturnAddress == pseudoBlockNext {
-		pseudoBlockNext = nextReturnAddress - 1
+	if l.nextReturnAddress == l.pseudoBlockNext {
+		l.pseudoBlockNext = l.nextReturnAddress - 1
 		return ret
 	}
 	// we've missed some
-	for pseudoBlockNext > nextReturnAddress {
-		ret += fmt.Sprintf("#if js function _Block_%d():Dynamic{return null;} #end\n", -pseudoBlockNext)
-		pseudoBlockNext--
+	for l.pseudoBlockNext > l.nextReturnAddress {
+		ret += fmt.Sprintf("#if js function _Block_%d():Dynamic{return null;} #end\n", -l.pseudoBlockNext)
+		l.pseudoBlockNext--
 	}
-	pseudoBlockNext = nextReturnAddress - 1
+	l.pseudoBlockNext = l.nextReturnAddress - 1
 	return ret
 }
 
@@ -817,97 +891,139 @@ Note that the the receive index i is not the same as the state index index.
 The second component of the triple, recvOk, is a boolean whose value is true iff
 the selected operation was a receive and the receive successfully yielded a value.
 */
-func (l langType) Select(isSelect bool, register string, v interface{}, CommaOK bool, errorInfo string) string {
-	ret := emitReturnHere() // even if we are in a non-blocking select, we need to give the other goroutines a chance!
+func (l *langType) Select(isSelect bool, register string, v interface{}, CommaOK bool, errorInfo string) string {
+	ret := l.emitReturnHere() // even if we are in a non-blocking select, we need to give the other goroutines a chance!
 	if isSelect {
 		sel := v.(*ssa.Select)
 		if register == "" {
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has no register"))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has no register"))
 			return ""
 		}
 		ret += register + "=" + l.LangType(v.(ssa.Value).Type(), true, errorInfo) + ";\n" //initialize
 		ret += register + ".r0= -1;\n"                                                    // the returned index if nothing is found
+		ret += "Scheduler.lock();\n"                                                      // the channels are tested and changed as one action, for -D gothreads
 
 		if len(sel.States) > 0 { // only do the logic if there are states to choose between
 			// TODO a blocking select with no states could be further optimised to stop the goroutine
 
+			// if a partner completed one of the cases while this goroutine was queued on the channels, it is the one chosen
+			ret += "{ var _d=Scheduler.takeDone(this._goroutine);\n"
+			ret += fmt.Sprintf("if(_d!=null) { %s.r0=_d.idx; switch(_d.idx){", register)
+			rxIdx := 0
+			for s := range sel.States {
+				if sel.States[s].Dir == types.RecvOnly {
+					ret += fmt.Sprintf("case %d: %s.r%d= _d.val; %s.r1= _d.ok;\n", s, register, 2+rxIdx, register)
+					rxIdx++
+				}
+			}
+			ret += "default:\n}} else {\n"
+			ret += "Scheduler.dequeue(this._goroutine);\n"
+
 			// Spec requires a pseudo-random order to which item is processed
-			ret += fmt.Sprintf("{ var _states:Array<Bool> = new Array(); var _rnd=Std.random(%d);\n", len(sel.States))
+			ret += fmt.Sprintf("var _states:Array<Bool> = new Array(); var _rnd=Std.random(%d);\n", len(sel.States))
 			for s := range sel.States {
 				switch sel.States[s].Dir {
 				case types.SendOnly:
 					ch := l.IndirectValue(sel.States[s].Chan, errorInfo)
-					ret += fmt.Sprintf("_states[%d]=%s==null?false:%s.hasSpace();\n", s, ch, ch)
+					ret += fmt.Sprintf("_states[%d]=Channel.canSend(%s);\n", s, ch)
 				case types.RecvOnly:
 					ch := l.IndirectValue(sel.States[s].Chan, errorInfo)
-					ret += fmt.Sprintf("_states[%d]=%s==null?false:%s.hasContents();\n", s, ch, ch)
+					ret += fmt.Sprintf("_states[%d]=Channel.canReceive(%s);\n", s, ch)
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has invalid ChanDir"))
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has invalid ChanDir"))
 					return ""
 				}
 			}
 			ret += fmt.Sprintf("for(_s in 0...%d) {var _i=(_s+_rnd)%s%d; if(_states[_i]) {%s.r0=_i; break;};}\n",
 				len(sel.States), "%", len(sel.States), register)
 			ret += fmt.Sprintf("switch(%s.r0){", register)
-			rxIdx := 0
+			rxIdx = 0
 			for s := range sel.States {
 				ret += fmt.Sprintf("case %d:\n", s)
 				switch sel.States[s].Dir {
 				case types.SendOnly:
 					ch := l.IndirectValue(sel.States[s].Chan, errorInfo)
 					snd := l.IndirectValue(sel.States[s].Send, errorInfo)
-					ret += fmt.Sprintf("%s.send(%s);\n", ch, snd)
+					ret += fmt.Sprintf("Channel.send(%s,this._goroutine,%s);\n", ch, snd)
 				case types.RecvOnly:
 					ch := l.IndirectValue(sel.States[s].Chan, errorInfo)
-					ret += fmt.Sprintf("{ var _v=%s.receive(%s); ", ch,
+					ret += fmt.Sprintf("{ var _v=Channel.receive(%s,this._goroutine,%s); ", ch,
 						l.LangType(sel.States[s].Chan.(ssa.Value).Type().Underlying().(*types.Chan).Elem().Underlying(), true, errorInfo))
 					ret += fmt.Sprintf("%s.r%d= _v.r0; ", register, 2+rxIdx)
 					rxIdx++
 					ret += register + ".r1= _v.r1; }\n"
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has invalid ChanDir"))
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("select statement has invalid ChanDir"))
 					return ""
 				}
 			}
-			ret += "};}\n" // end switch; _states, _rnd scope
+			ret += "}\n" // end switch
+
+			if sel.Blocking { // if no case is ready, queue this goroutine on all the channels, for a partner to complete one of the cases
+				ret += fmt.Sprintf("if(%s.r0 == -1) {\n", register)
+				for s := range sel.States {
+					ch := l.IndirectValue(sel.States[s].Chan, errorInfo)
+					if sel.States[s].Dir == types.SendOnly {
+						ret += fmt.Sprintf("Channel.queueSend(%s,this._goroutine,%d,%s);\n", ch, s,
+							l.IndirectValue(sel.States[s].Send, errorInfo))
+					} else {
+						ret += fmt.Sprintf("Channel.queueReceive(%s,this._goroutine,%d);\n", ch, s)
+					}
+				}
+				ret += "}\n"
+			}
+			ret += "}}\n" // end if _d; _d scope
 
 		} // end only if len(sel.States)>0
 
 		if sel.Blocking {
-			ret += "if(" + register + ".r0 == -1) return this;\n"
+			why, on := "select (no cases)", "null" // blocked forever
+			if len(sel.States) > 0 {
+				chans := make([]string, len(sel.States))
+				for s := range sel.States {
+					chans[s] = l.IndirectValue(sel.States[s].Chan, errorInfo)
+				}
+				why, on = "select", "["+strings.Join(chans, ",")+"]"
+			}
+			ret += "if(" + register + ".r0 == -1) {Scheduler.wait(this._goroutine,\"" + why + "\"," + on + ");Scheduler.unlock();return this;}\n"
 		}
+		ret += "Scheduler.unlock();\n"
 
 	} else {
-		ret += "if(" + l.IndirectValue(v, errorInfo) + ".hasNoContents())return this;\n" // go round the loop again and wait if not OK
+		// the channel is tested and changed as one action, for -D gothreads
+		ret += "Scheduler.lock();\n"
+		// if there is nothing to receive now, this goroutine is queued to receive, and waits to go round the loop again
+		ret += "{ var _v=Channel.receive(" + l.IndirectValue(v, errorInfo) + ",this._goroutine,"
+		ret += l.LangType(v.(ssa.Value).Type().Underlying().(*types.Chan).Elem().Underlying(), true, errorInfo) + ");\n"
+		ret += "if(_v==null){Scheduler.wait(this._goroutine,\"chan receive\"," + l.IndirectValue(v, errorInfo) + ");Scheduler.unlock();return this;}\n"
 		if register != "" {
-			ret += register + "="
-		}
-		ret += l.IndirectValue(v, errorInfo) + ".receive("
-		ret += l.LangType(v.(ssa.Value).Type().Underlying().(*types.Chan).Elem().Underlying(), true, errorInfo) + ")" // put correct result into register
-		if !CommaOK {
-			ret += ".r0"
+			ret += register + "=_v" // put correct result into register
+			if !CommaOK {
+				ret += ".r0"
+			}
+			ret += ";"
 		}
-		ret += ";"
+		ret += "}\nScheduler.unlock();"
 	}
-	nextReturnAddress-- // decrement to set new return address for next code generation
+	l.nextReturnAddress-- // decrement to set new return address for next code generation
 	return ret
 }
-func (l langType) RegEq(r string) string {
+func (l *langType) RegEq(r string) string {
 	return r + "="
 }
 
-func (l langType) Ret(values []*ssa.Value, errorInfo string) string {
-	hadReturn = true
+func (l *langType) Ret(values []*ssa.Value, errorInfo string) string {
+	l.hadReturn = true
 	_BlockEnd := "this._incomplete=false;\nScheduler.pop(this._goroutine);\n"
-	hadBlockReturn = true
+	l.hadBlockReturn = true
 	_BlockEnd += "return this;\n"
 	switch len(values) {
 	case 0:
-		return emitTrace("Ret0") + _BlockEnd
+		return l.emitTrace("Ret0") + _BlockEnd
 	case 1:
-		return emitTrace("Ret1") + "_res= " + l.IndirectValue(*values[0], errorInfo) + ";\n" + _BlockEnd
+		return l.emitTrace("Ret1") + "_res= " + l.IndirectValue(*values[0], errorInfo) + ";\n" + _BlockEnd
 	default:
-		ret := emitTrace("RetN") + "_res= {"
+		ret := l.emitTrace("RetN") + "_res= {"
 		for r := range values {
 			if r != 0 {
 				ret += ","
@@ -922,17 +1038,17 @@ func (l langType) Ret(values []*ssa.Value, errorInfo string) string {
 	}
 }
 
-func (l langType) Panic(v1 interface{}, errorInfo string, usesGr bool) string {
+func (l *langType) Panic(v1 interface{}, errorInfo string, usesGr bool) string {
 	ret := l.doCall("", nil, "Scheduler.panic(this._goroutine,"+l.IndirectValue(v1, errorInfo)+");\n", usesGr)
 	ret += l.Ret(nil, errorInfo) // just in case we return to this point without _recoverNext being set & used
 	return ret
 }
 
-func getPackagePath(cc *ssa.CallCommon) string {
+func (l *langType) getPackagePath(cc *ssa.CallCommon) string {
 	// This code to find the package name
 	var pn string = "UNKNOWN" // package name
 	if cc.StaticCallee() != nil {
-		pn, _ = pogo.FuncPathName(cc.StaticCallee()) // was =fmt.Sprintf("fn%d", cc.StaticCallee().Pos())
+		pn, _ = l.PogoComp.FuncPathName(cc.StaticCallee()) // was =fmt.Sprintf("fn%d", cc.StaticCallee().Pos())
 	}
 	if cc != nil {
 		if cc.Method != nil {
@@ -956,7 +1072,7 @@ func getPackagePath(cc *ssa.CallCommon) string {
 	return pn
 }
 
-func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isBuiltin, isGo, isDefer, usesGr bool, fnToCall, errorInfo string) string {
+func (l *langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isBuiltin, isGo, isDefer, usesGr bool, fnToCall, errorInfo string) string {
 	isHaxeAPI := false
 	hashIf := ""  // #if  - only if required
 	hashEnd := "" // #end - ditto
@@ -990,21 +1106,21 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 				return register + "Force.toUTF8length(this._goroutine," + l.IndirectValue(args[0], errorInfo /*, false*/) + ");"
 			default: // TODO handle other types?
 				// TODO error on string?
-				pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Call() - unhandled len/cap type: %s",
+				l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Call() - unhandled len/cap type: %s",
 					reflect.TypeOf(args[0].Type().Underlying())))
 				return register + `null;`
 			}
 		case "print", "println":
 			ret += "Console." + fnToCall + "(["
 			/* DEBUG if we want to know where all the prints happen
-			ret	+= fmt.Sprintf("Go.CPos(%d)", pogo.LatestValidPosHash)
+			ret	+= fmt.Sprintf("Go.CPos(%d)", l.PogoComp.LatestValidPosHash)
 			if len(args) > 0 {                  // if there are more arguments to pass, add a comma
 				ret += ","
 			}
 			*/
 		case "delete":
 			return register + l.IndirectValue(args[0], errorInfo) + ".remove(" +
-				serializeKey(l.IndirectValue(args[1], errorInfo),
+				l.serializeKey(l.IndirectValue(args[1], errorInfo),
 					l.LangType(args[1].Type().Underlying(), false, errorInfo)) + ");"
 		case "append":
 			return register + l.append(args, errorInfo) + ";"
@@ -1023,7 +1139,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 		case "ssa:wrapnilchk":
 			return register + "Scheduler.wrapnilchk(" + l.IndirectValue(args[0], errorInfo) + ");"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Call() - Unhandled builtin function: %s", fnToCall))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Call() - Unhandled builtin function: %s", fnToCall))
 			ret = "MISSING_BUILTIN("
 		}
 	} else {
@@ -1033,14 +1149,24 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 		// Go library complex function rewriting
 		//
 		case "runtime_BBreakpoint":
-			nextReturnAddress-- //decrement to set new return address for next call generation
+			l.nextReturnAddress-- //decrement to set new return address for next call generation
 			return "this.breakpoint();"
 		case "runtime_UUnzipTTestFFSS":
-			nextReturnAddress-- //decrement to set new return address for next call generation
-			if pogo.LanguageList[langIdx].TestFS != "" {
-				return `Go_syscall_UUnzipFFSS.hx("` + pogo.LanguageList[langIdx].TestFS + `");`
+			l.nextReturnAddress-- //decrement to set new return address for next call generation
+			if l.langEntry.TestFS != "" {
+				return `Go_syscall_UUnzipFFSS.hx("` + l.langEntry.TestFS + `");`
 			}
 			return ""
+		case "runtime_SSelectedTTests":
+			l.nextReturnAddress-- //decrement to set new return address for next call generation
+			selected := ""
+			if l.langEntry.TestNames != nil {
+				selected = strings.Join(l.langEntry.TestNames, "\n") + "\n"
+			}
+			if register == "" {
+				return ""
+			}
+			return register + "=" + l.haxeStringConst(fmt.Sprintf("%q", selected), errorInfo) + ";"
 		//case "math_Inf":
 		//	nextReturnAddress-- //decrement to set new return address for next call generation
 		//	return register + "=(" + l.IndirectValue(args[0], errorInfo) + ">=0?Math.POSITIVE_INFINITY:Math.NEGATIVE_INFINITY);"
@@ -1050,14 +1176,14 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 			// haxe interface pseudo-function re-writing
 			//
 			if strings.HasPrefix(fnToCall, pseudoFnPrefix) {
-				nextReturnAddress-- //decrement to set new return address for next call generation
+				l.nextReturnAddress-- //decrement to set new return address for next call generation
 				if register != "" {
 					register += "="
 				}
 				return register + l.hxPseudoFuncs(fnToCall, args, errorInfo)
 			}
 
-			pn := getPackagePath(&cc)
+			pn := l.getPackagePath(&cc)
 			pnSplit := strings.Split(pn, "/")
 			pn = pnSplit[len(pnSplit)-1]
 			//fmt.Println("DEBUG package name", pn)
@@ -1083,7 +1209,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 				}
 				fnToCall = ftc // fnToCall does not now contain doubled uppercase chars
 
-				nextReturnAddress--                     // decrement to set new return address for next call generation
+				l.nextReturnAddress--                     // decrement to set new return address for next call generation
 				isBuiltin = true                        // pretend we are in a builtin function to avoid passing 1st param as bindings
 				isHaxeAPI = true                        // we are calling a Haxe native function
 				bits := strings.Split(fnToCall, "_47_") // split the parts of the string separated by /
@@ -1125,7 +1251,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 					}
 					fallthrough
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("call to function %s unknown Haxe API first letter %v of %v",
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("call to function %s unknown Haxe API first letter %v of %v",
 						fnToCall, bits[0][0:1], bits))
 				}
 				bits[0] = bits[0][1:] // discard the magic letter from the front of the function name
@@ -1219,7 +1345,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 			} else {
 				olv, ok := fnToVarOverloadMap[fnToCall]
 				if ok { // replace the function call with a variable
-					nextReturnAddress-- //decrement to set new return address for next call generation
+					l.nextReturnAddress-- //decrement to set new return address for next call generation
 					if register == "" {
 						return ""
 					}
@@ -1232,7 +1358,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 					olf, ok := builtinOverloadMap[fnToCall]
 					if ok { // replace a go function with a haxe one
 						targetFunc = olf
-						nextReturnAddress-- //decrement to set new return address for next call generation
+						l.nextReturnAddress-- //decrement to set new return address for next call generation
 						isBuiltin = true    // pretend we are in a builtin function to avoid passing 1st param as bindings or waiting for completion
 					} else {
 						// TODO at this point the package-level overloading could occur, but I cannot make it reliable, so code removed
@@ -1309,7 +1435,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 	}
 	if isBuiltin {
 		if isGo || isDefer {
-			pogo.LogError(errorInfo, "Haxe",
+			l.PogoComp.LogError(errorInfo, "Haxe",
 				fmt.Errorf("calling a builtin function (%s) via 'go' or 'defer' is not supported",
 					fnToCall))
 		}
@@ -1336,7 +1462,7 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 	}
 	if isGo {
 		if isDefer {
-			pogo.LogError(errorInfo, "Haxe",
+			l.PogoComp.LogError(errorInfo, "Haxe",
 				fmt.Errorf("calling a function (%s) using both 'go' and 'defer' is not supported",
 					fnToCall))
 		}
@@ -1348,42 +1474,42 @@ func (l langType) Call(register string, cc ssa.CallCommon, args []ssa.Value, isB
 	return l.doCall(register, cc.Signature().Results(), ret+";\n", usesGr)
 }
 
-func (l langType) RunDefers(usesGr bool) string {
+func (l *langType) RunDefers(usesGr bool) string {
 	return l.doCall("", nil, "this.runDefers();\n", usesGr)
 }
 
-func (l langType) doCall(register string, tuple *types.Tuple, callCode string, usesGr bool) string {
+func (l *langType) doCall(register string, tuple *types.Tuple, callCode string, usesGr bool) string {
 	ret := ""
 	if register != "" {
-		ret += fmt.Sprintf("_SF%d=", -nextReturnAddress)
+		ret += fmt.Sprintf("_SF%d=", -l.nextReturnAddress)
 	}
 	if usesGr {
 		ret += callCode
 		//await completion
-		ret += fmt.Sprintf("_Next = %d;\n", nextReturnAddress) // where to come back to
-		hadBlockReturn = false
+		ret += fmt.Sprintf("_Next = %d;\n", l.nextReturnAddress) // where to come back to
+		l.hadBlockReturn = false
 		ret += "return this;\n"
 		ret += "#if js } #end\n"
-		ret += emitUnseenPseudoBlocks()
-		ret += fmt.Sprintf("#if !js case %d: #end\n", nextReturnAddress) // emit code to come back to
+		ret += l.emitUnseenPseudoBlocks()
+		ret += fmt.Sprintf("#if !js case %d: #end\n", l.nextReturnAddress) // emit code to come back to
 		ret += fmt.Sprintf("#if js function _Block_%d(){ #end\n",
-			-nextReturnAddress) // optimize JS with closure to allow V8 to optimize big funcs
-		if pogo.DebugFlag {
-			ret += "this.setLatest(" + fmt.Sprintf("%d", pogo.LatestValidPosHash) + "," + fmt.Sprintf("%d", nextReturnAddress) + ");\n"
+			-l.nextReturnAddress) // optimize JS with closure to allow V8 to optimize big funcs
+		if l.PogoComp.DebugFlag {
+			ret += "this.setLatest(" + l.PogoComp.PosHashText(l.PogoComp.LatestValidPosHash) + "," + fmt.Sprintf("%d", l.nextReturnAddress) + ");\n"
 		}
-		ret += emitTrace(fmt.Sprintf("Block:%d", nextReturnAddress))
+		ret += l.emitTrace(fmt.Sprintf("Block:%d", l.nextReturnAddress))
 	} else {
 		callCode = strings.TrimSpace(callCode)
 		if register != "" {
 			ret += callCode
-			ret += emitTrace(`OPTIMIZED CALL (via stack frame)`)
-			ret += fmt.Sprintf("_SF%d.run();\n", -nextReturnAddress)
+			ret += l.emitTrace(`OPTIMIZED CALL (via stack frame)`)
+			ret += fmt.Sprintf("_SF%d.run();\n", -l.nextReturnAddress)
 		} else {
 			if strings.HasSuffix(callCode, ";") {
-				ret += emitTrace(`OPTIMIZED CALL (no stack frame)`)
+				ret += l.emitTrace(`OPTIMIZED CALL (no stack frame)`)
 				ret += fmt.Sprintf("%s.run();\n", strings.TrimSuffix(callCode, ";"))
 			} else {
-				ret += emitTrace(`OPTIMIZED CALL (via scheduler)`)
+				ret += l.emitTrace(`OPTIMIZED CALL (via scheduler)`)
 				ret += fmt.Sprintf("Scheduler.run1();\n")
 				//was: ret += "Scheduler.run1(this._goroutine);\n"
 			}
@@ -1402,10 +1528,10 @@ func (l langType) doCall(register string, tuple *types.Tuple, callCode string, u
 			//ret += fmt.Sprintf("%s=(_SF%d==null)?%s:_SF%d.res();\n", // goroutine of -1 => null closure
 			//	register, -nextReturnAddress, registerZero, -nextReturnAddress)
 			ret += fmt.Sprintf("%s=_SF%d.res();\n", // will fail if _SF is null
-				register, -nextReturnAddress)
+				register, -l.nextReturnAddress)
 		}
 	}
-	nextReturnAddress-- //decrement to set new return address for next call generation
+	l.nextReturnAddress-- //decrement to set new return address for next call generation
 	return ret
 }
 
@@ -1420,17 +1546,17 @@ func allocNewObject(t types.Type) string {
 		for so%ao != 0 {
 			so++
 		}
-		return fmt.Sprintf("new Object(%d) /* Array: %s */",
-			typ.(*types.Array).Len()*so, typ.String())
+		return fmt.Sprintf("%s /* Array: %s */",
+			newObject(fmt.Sprintf("%d", typ.(*types.Array).Len()*so), typ), typ.String())
 
 	default:
-		return fmt.Sprintf("new Object(%d) /* %s */",
-			haxeStdSizes.Sizeof(typ),
+		return fmt.Sprintf("%s /* %s */",
+			newObject(fmt.Sprintf("%d", haxeStdSizes.Sizeof(typ)), typ),
 			typ.String())
 	}
 }
 
-func (l langType) Alloc(reg string, heap bool, v interface{}, errorInfo string) string {
+func (l *langType) Alloc(reg string, heap bool, v interface{}, errorInfo string) string {
 	if reg == "" {
 		return "" // if the register is not used, don't emit the code!
 	}
@@ -1459,7 +1585,7 @@ func (l langType) Alloc(reg string, heap bool, v interface{}, errorInfo string)
 		case *types.Struct:
 			typ = typ.(*types.Struct).Underlying()
 		default:
-			pogo.LogError(errorInfo, "Haxe",
+			l.PogoComp.LogError(errorInfo, "Haxe",
 				fmt.Errorf("haxe.Alloc() - unhandled type: %v", reflect.TypeOf(typ)))
 			return ""
 		}
@@ -1472,21 +1598,21 @@ func (l langType) Alloc(reg string, heap bool, v interface{}, errorInfo string)
 	return fmt.Sprintf("%s=new Pointer(%s_stackalloc.clear());", reg, reg2)
 }
 
-func (l langType) MakeChan(reg string, v interface{}, errorInfo string) string {
+func (l *langType) MakeChan(reg string, v interface{}, errorInfo string) string {
 	//typeElem := l.LangType(v.(*ssa.MakeChan).Type().Underlying().(*types.Chan).Elem().Underlying(), false, errorInfo)
 	size := l.IndirectValue(v.(*ssa.MakeChan).Size, errorInfo)
 	return reg + "=new Channel(" + size + `);` // <" + typeElem + ">(" + size + `);`
 }
 
-func newSliceCode(typeElem, initElem, capacity, length, errorInfo, itemSize string) string {
+func newSliceCode(typeElem, initElem, capacity, length, errorInfo, itemSize string, elem types.Type) string {
 	//return "new Slice(new Pointer(new Make<" + typeElem + ">((" + capacity + ")*(" + itemSize + "))" +
 	//	".array(" + initElem + "," + capacity + ")" +
 	//	"),0," + length + "," + capacity + "," + itemSize + `)`
-	return "new Slice(new Pointer(new Object((" + capacity + ")*(" + itemSize + "))" +
+	return "new Slice(new Pointer(" + newObject("("+capacity+")*("+itemSize+")", elem) +
 		"),0," + length + "," + capacity + "," + itemSize + `)`
 }
 
-func (l langType) MakeSlice(reg string, v interface{}, errorInfo string) string {
+func (l *langType) MakeSlice(reg string, v interface{}, errorInfo string) string {
 	typeElem := l.LangType(v.(*ssa.MakeSlice).Type().Underlying().(*types.Slice).Elem().Underlying(), false, errorInfo)
 	initElem := l.LangType(v.(*ssa.MakeSlice).Type().Underlying().(*types.Slice).Elem().Underlying(), true, errorInfo)
 	length := wrapForce_toUInt(l.IndirectValue(v.(*ssa.MakeSlice).Len, errorInfo),
@@ -1494,12 +1620,13 @@ func (l langType) MakeSlice(reg string, v interface{}, errorInfo string) string
 	capacity := wrapForce_toUInt(l.IndirectValue(v.(*ssa.MakeSlice).Cap, errorInfo),
 		v.(*ssa.MakeSlice).Cap.Type().Underlying().(*types.Basic).Kind()) // capacities can't be 64 bit
 	itemSize := "1" + arrayOffsetCalc(v.(*ssa.MakeSlice).Type().Underlying().(*types.Slice).Elem().Underlying())
-	return reg + "=" + newSliceCode(typeElem, initElem, capacity, length, errorInfo, itemSize) + `;`
+	return reg + "=" + newSliceCode(typeElem, initElem, capacity, length, errorInfo, itemSize,
+		v.(*ssa.MakeSlice).Type().Underlying().(*types.Slice).Elem()) + `;`
 }
 
 // TODO see http://tip.golang.org/doc/go1.2#three_index
 // TODO add third parameter when SSA code provides it to enable slice instructions to specify a capacity
-func (l langType) Slice(register string, x, lv, hv interface{}, errorInfo string) string {
+func (l *langType) Slice(register string, x, lv, hv interface{}, errorInfo string) string {
 	xString := l.IndirectValue(x, errorInfo) // the target must be an array
 	if xString == "" {
 		xString = l.IndirectValue(x, errorInfo)
@@ -1528,13 +1655,13 @@ func (l langType) Slice(register string, x, lv, hv interface{}, errorInfo string
 		}
 		return register + "= (" + xString + ").substr(" + lvString + "," + hvString + "-" + lvString + ") ;"
 	default:
-		pogo.LogError(errorInfo, "Haxe",
+		l.PogoComp.LogError(errorInfo, "Haxe",
 			fmt.Errorf("haxe.Slice() - unhandled type: %v", reflect.TypeOf(x.(ssa.Value).Type().Underlying())))
 		return ""
 	}
 }
 
-func (l langType) Index(register string, v1, v2 interface{}, errorInfo string) string {
+func (l *langType) Index(register string, v1, v2 interface{}, errorInfo string) string {
 	keyString := wrapForce_toUInt(l.IndirectValue(v2, errorInfo),
 		v2.(ssa.Value).Type().Underlying().(*types.Basic).Kind())
 	typ := v1.(ssa.Value).Type().Underlying().(*types.Array).Elem().Underlying()
@@ -1547,11 +1674,11 @@ func (l langType) Index(register string, v1, v2 interface{}, errorInfo string) s
 }
 
 //TODO review parameters required
-func (l langType) codeField(v interface{}, fNum int, fName, errorInfo string, isFunctionName bool) string {
+func (l *langType) codeField(v interface{}, fNum int, fName, errorInfo string, isFunctionName bool) string {
 	//iv := l.IndirectValue(v, errorInfo)
 	//r := fmt.Sprintf("%s[%d] /* %s */ ", iv, fNum, fixKeyWds(fName))
 	str := v.(ssa.Value).Type().Underlying().(*types.Struct)
-	//if pogo.DebugFlag {
+	//if l.PogoComp.DebugFlag {
 	//	r = "{if(" + iv + "==null) { Scheduler.ioor(); null; } else " + r + ";}"
 	//}
 	//return fmt.Sprintf(" /* %d */ ", fieldOffset(str, fNum)) +
@@ -1562,7 +1689,7 @@ func (l langType) codeField(v interface{}, fNum int, fName, errorInfo string, is
 }
 
 //TODO review parameters required
-func (l langType) Field(register string, v interface{}, fNum int, fName, errorInfo string, isFunctionName bool) string {
+func (l *langType) Field(register string, v interface{}, fNum int, fName, errorInfo string, isFunctionName bool) string {
 	if register != "" {
 		return register + "=" + l.codeField(v, fNum, fName, errorInfo, isFunctionName) + ";"
 	}
@@ -1570,7 +1697,7 @@ func (l langType) Field(register string, v interface{}, fNum int, fName, errorIn
 }
 
 // TODO error on 64-bit indexes
-func (l langType) RangeCheck(x, i interface{}, length int, errorInfo string) string {
+func (l *langType) RangeCheck(x, i interface{}, length int, errorInfo string) string {
 	iStr := l.IndirectValue(i, errorInfo)
 	if length <= 0 { // length unknown at compile time
 		xStr := l.IndirectValue(x, errorInfo)
@@ -1594,26 +1721,26 @@ func (l langType) RangeCheck(x, i interface{}, length int, errorInfo string) str
 	return fmt.Sprintf("Scheduler.wraprangechk(%s,%d);", iStr, length)
 }
 
-func (l langType) MakeMap(reg string, v interface{}, errorInfo string) string {
+func (l *langType) MakeMap(reg string, v interface{}, errorInfo string) string {
 	if reg == "" {
 		return ""
 	}
 	return reg + "=" + l.LangType(v.(*ssa.MakeMap).Type().Underlying(), true, errorInfo) + `;`
 }
 
-func serializeKey(val, haxeTyp string) string { // can the key be serialized?
+func (l *langType) serializeKey(val, haxeTyp string) string { // can the key be serialized?
 	switch haxeTyp {
 	case "String", "Int", "Float", "Bool",
 		"Pointer", "Object", "GOint64", "Complex", "Interface", "Channel", "Slice":
 		return val
 	default:
-		pogo.LogError("serializeKey", "haxe", errors.New("unsupported map key type: "+haxeTyp))
+		l.PogoComp.LogError("serializeKey", "haxe", errors.New("unsupported map key type: "+haxeTyp))
 		return ""
 	}
 }
 
-func (l langType) MapUpdate(Map, Key, Value interface{}, errorInfo string) string {
-	skey := serializeKey(l.IndirectValue(Key, errorInfo),
+func (l *langType) MapUpdate(Map, Key, Value interface{}, errorInfo string) string {
+	skey := l.serializeKey(l.IndirectValue(Key, errorInfo),
 		l.LangType(Key.(ssa.Value).Type().Underlying(), false, errorInfo))
 	ret := l.IndirectValue(Map, errorInfo) + ".set("
 	ret += skey + "," //+ l.IndirectValue(Key, errorInfo) + ","
@@ -1621,7 +1748,7 @@ func (l langType) MapUpdate(Map, Key, Value interface{}, errorInfo string) strin
 	return ret
 }
 
-func (l langType) Lookup(reg string, Map, Key interface{}, commaOk bool, errorInfo string) string {
+func (l *langType) Lookup(reg string, Map, Key interface{}, commaOk bool, errorInfo string) string {
 	if reg == "" {
 		return ""
 	}
@@ -1640,7 +1767,7 @@ func (l langType) Lookup(reg string, Map, Key interface{}, commaOk bool, errorIn
 		//	"{Scheduler.ioor();0;}:Std.int(" + valueCode + ");"
 	}
 	// assume it is a Map
-	keyString = serializeKey(keyString, l.LangType(Key.(ssa.Value).Type().Underlying(), false, errorInfo))
+	keyString = l.serializeKey(keyString, l.LangType(Key.(ssa.Value).Type().Underlying(), false, errorInfo))
 
 	isNull := l.IndirectValue(Map, errorInfo) + "==null?"
 
@@ -1661,15 +1788,15 @@ func (l langType) Lookup(reg string, Map, Key interface{}, commaOk bool, errorIn
 	return reg + "=" + isNull + li + ":" + returnValue + ";" // the .get will check for existance and return the zero value if not
 }
 
-func (l langType) Extract(reg string, tuple interface{}, index int, errorInfo string) string {
+func (l *langType) Extract(reg string, tuple interface{}, index int, errorInfo string) string {
 	tp := l.IndirectValue(tuple, errorInfo)
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		tp = "Force.checkTuple(" + tp + ")"
 	}
 	return reg + "=" + tp + ".r" + fmt.Sprintf("%d", index) + ";"
 }
 
-func (l langType) Range(reg string, v interface{}, errorInfo string) string {
+func (l *langType) Range(reg string, v interface{}, errorInfo string) string {
 
 	switch l.LangType(v.(ssa.Value).Type().Underlying(), false, errorInfo) {
 	case "String":
@@ -1699,7 +1826,7 @@ func (l langType) Range(reg string, v interface{}, errorInfo string) string {
 		*/
 	}
 }
-func (l langType) Next(register string, v interface{}, isString bool, errorInfo string) string {
+func (l *langType) Next(register string, v interface{}, isString bool, errorInfo string) string {
 	if isString {
 		return register + "=cast(" + l.IndirectValue(v, errorInfo) + ",GOstringRange).next();"
 		/*
@@ -1724,7 +1851,7 @@ func (l langType) Next(register string, v interface{}, isString bool, errorInfo
 	*/
 }
 
-func (l langType) MakeClosure(reg string, v interface{}, errorInfo string) string {
+func (l *langType) MakeClosure(reg string, v interface{}, errorInfo string) string {
 	// use a closure type
 	ret := reg + "= new Closure(" + l.IndirectValue(v.(*ssa.MakeClosure).Fn, errorInfo) + ",{"
 	for b := range v.(*ssa.MakeClosure).Bindings {
@@ -1740,17 +1867,17 @@ func (l langType) MakeClosure(reg string, v interface{}, errorInfo string) strin
 	//as in: return reg + "=" + l.IndirectValue(v.(*ssa.MakeClosure).Fn, errorInfo) + ";"
 }
 
-func (l langType) EmitInvoke(register string, isGo, isDefer, usesGr bool, callCommon interface{}, errorInfo string) string {
+func (l *langType) EmitInvoke(register string, isGo, isDefer, usesGr bool, callCommon interface{}, errorInfo string) string {
 	val := callCommon.(ssa.CallCommon).Value
 	meth := callCommon.(ssa.CallCommon).Method.Name()
 	ret := ""
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		ret += l.IndirectValue(val, errorInfo) + "==null?Scheduler.unt():"
 	}
 	ret += "Interface.invoke(" + l.IndirectValue(val, errorInfo) + `,"` + meth + `",[`
 	if isGo {
 		if isDefer {
-			pogo.LogError(errorInfo, "Haxe",
+			l.PogoComp.LogError(errorInfo, "Haxe",
 				fmt.Errorf("calling a method (%s) using both 'go' and 'defer' is not supported",
 					meth))
 		}
@@ -1782,27 +1909,27 @@ func (l langType) EmitInvoke(register string, isGo, isDefer, usesGr bool, callCo
 	return l.doCall(register, cc.Signature().Results(), ret+"]);", usesGr)
 }
 
-func (l langType) SubFnStart(id int, mustSplitCode bool) string {
+func (l *langType) SubFnStart(id int, mustSplitCode bool) string {
 	if !mustSplitCode {
 		return "try {"
 	}
 	return fmt.Sprintf("private "+"function SubFn%d():Void { try {", id)
 }
 
-func (l langType) SubFnEnd(id, pos int, mustSplitCode bool) string {
-	ret := fmt.Sprintf("} catch (c:Dynamic) {Scheduler.htc(c,%d);}", pos)
+func (l *langType) SubFnEnd(id, pos int, mustSplitCode bool) string {
+	ret := "} catch (c:Dynamic) {Scheduler.htc(c," + l.PogoComp.PosHashText(pogo.PosHash(pos)) + ");}"
 	if mustSplitCode {
 		ret += ";}"
 	}
 	return ret
 }
 
-func (l langType) SubFnCall(id int) string {
+func (l *langType) SubFnCall(id int) string {
 	return fmt.Sprintf("SubFn%d();", id)
 }
 
-func (l langType) DeclareTempVar(v ssa.Value) string {
-	if useRegisterArray {
+func (l *langType) DeclareTempVar(v ssa.Value) string {
+	if l.useRegisterArray {
 		return ""
 	}
 	typ := l.LangType(v.Type(), false, "temp var declaration")
@@ -6,7 +6,7 @@ package haxe
 
 import "golang.org/x/tools/go/ssa"
 
-func (l langType) append(args []ssa.Value, errorInfo string) string {
+func (l *langType) append(args []ssa.Value, errorInfo string) string {
 	source := l.IndirectValue(args[1], errorInfo)
 	if l.LangType(args[1].Type().Underlying(), false, errorInfo) == "String" {
 		source = "Force.toUTF8slice(this._goroutine," + source + ")" // if we have a string, we must convert it to a slice
@@ -18,7 +18,7 @@ func (l langType) append(args []ssa.Value, errorInfo string) string {
 	return ret
 }
 
-func (l langType) copy(register string, args []ssa.Value, errorInfo string) string {
+func (l *langType) copy(register string, args []ssa.Value, errorInfo string) string {
 	ret := ""
 	if register != "" {
 		ret += register
@@ -31,6 +31,6 @@ func (l langType) copy(register string, args []ssa.Value, errorInfo string) stri
 	return ret + code
 }
 
-func (l langType) DebugRef(userName string, val interface{}, errorInfo string) string {
+func (l *langType) DebugRef(userName string, val interface{}, errorInfo string) string {
 	return `this._debugVars.set("` + userName + `",` + l.IndirectValue(val, errorInfo) + ");"
 }
@@ -19,7 +19,7 @@ import (
 )
 
 // Start the main Go class in haxe
-func (langType) GoClassStart() string {
+func (l *langType) GoClassStart() string {
 	// the code below makes the Go class globally visible in JS as window.Go in the browser or exports.Go in nodejs
 	//TODO consider how to make Go/Haxe libs available across all platforms
 	return `
@@ -55,11 +55,14 @@ class Go
 }
 
 // end the main Go class
-func (l langType) GoClassEnd(pkg *ssa.Package) string {
+func (l *langType) GoClassEnd(pkg *ssa.Package) string {
 	// init function
 	main := "public static var doneInit:Bool=false;\n"                                                          // flag to run this routine only once
 	main += "\npublic static function init() : Void {\ndoneInit=true;\nvar gr:Int=Scheduler.makeGoroutine();\n" // first goroutine number is always 0
 	main += `if(gr!=0) throw "non-zero goroutine number in init";` + "\n"                                       // first goroutine number is always 0, NOTE using throw as panic not setup
+	if l.PogoComp.PreemptLoops > 0 || l.PogoComp.PreemptMillis > 0 {
+		main += fmt.Sprintf("Scheduler.setPreempt(%d,%d);\n", l.PogoComp.PreemptLoops, l.PogoComp.PreemptMillis)
+	}
 
 	main += "var _sfgr=new Go_haxegoruntime_init(gr,[]).run();\n" //haxegoruntime.init() NOTE can't use .hx() to call from Haxe as that would call this fn
 	main += `Go.haxegoruntime_ZZiLLen.store_uint32('字'.length);`  // value required by haxegoruntime to know what type of strings we have
@@ -71,34 +74,36 @@ func (l langType) GoClassEnd(pkg *ssa.Package) string {
 	main += "}\n"
 	// Haxe main function, only called in a go-only environment
 	main += "\npublic static function main() : Void {\n"
+	main += "Scheduler.detectDeadlock=true;\n" // only Go code can wake a blocked goroutine
 	main += "Go_" + l.LangName(pkg.Object.Path(), "main") + `.hx();` + "\n"
+	main += "#if gothreads\nConsole.exit(0);\n#end\n" // as in Go, the program ends when main.main() returns, even if goroutines are running on other threads
 	main += "}\n"
 
 	pos := "public static function CPos(pos:Int):String {\nvar prefix:String=\"\";\n"
 	pos += fmt.Sprintf(`if (pos==%d) return "(pogo.NoPosHash)";`, pogo.NoPosHash) + "\n"
 	pos += "if (pos<0) { pos = -pos; prefix= \"near \";}\n"
-	for p := len(pogo.PosHashFileList) - 1; p >= 0; p-- {
-		if p != len(pogo.PosHashFileList)-1 {
+	for p := len(l.PogoComp.PosHashFileList) - 1; p >= 0; p-- {
+		if p != len(l.PogoComp.PosHashFileList)-1 {
 			pos += "else "
 		}
 		pos += fmt.Sprintf(`if(pos>%d) return prefix+"%s:"+Std.string(pos-%d);`,
-			pogo.PosHashFileList[p].BasePosHash,
-			strings.Replace(pogo.PosHashFileList[p].FileName, "\\", "\\\\", -1),
-			pogo.PosHashFileList[p].BasePosHash) + "\n"
+			l.PogoComp.PosHashFileList[p].BasePosHash,
+			strings.Replace(l.PogoComp.PosHashFileList[p].FileName, "\\", "\\\\", -1),
+			l.PogoComp.PosHashFileList[p].BasePosHash) + "\n"
 	}
 	pos += "else return \"(invalid pogo.PosHash:\"+Std.string(pos)+\")\";\n}\n"
 
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		pos += "\npublic static function getStartCPos(s:String):Int {\n"
-		for p := len(pogo.PosHashFileList) - 1; p >= 0; p-- {
+		for p := len(l.PogoComp.PosHashFileList) - 1; p >= 0; p-- {
 			pos += "\t" + fmt.Sprintf(`if("%s".indexOf(s)!=-1) return %d;`,
-				strings.Replace(pogo.PosHashFileList[p].FileName, "\\", "\\\\", -1),
-				pogo.PosHashFileList[p].BasePosHash) + "\n"
+				strings.Replace(l.PogoComp.PosHashFileList[p].FileName, "\\", "\\\\", -1),
+				l.PogoComp.PosHashFileList[p].BasePosHash) + "\n"
 		}
 		pos += "\treturn -1;\n}\n"
 
 		pos += "\npublic static function getGlobal(s:String):String {\n"
-		globs := pogo.GlobalList()
+		globs := l.PogoComp.GlobalList()
 		for _, g := range globs {
 			goName := strings.Replace(g.Package+"."+g.Member, "\\", "\\\\", -1)
 			pos += "\t" + fmt.Sprintf(`if("%s".indexOf(s)!=-1) return "%s = "+%s.toString();`,
@@ -111,10 +116,10 @@ func (l langType) GoClassEnd(pkg *ssa.Package) string {
 	return main + pos + "} // end Go class"
 }
 
-func haxeStringConst(sconst string, position string) string {
+func (l *langType) haxeStringConst(sconst string, position string) string {
 	s, err := strconv.Unquote(sconst)
 	if err != nil {
-		pogo.LogError(position, "Haxe", errors.New(err.Error()+" : "+sconst))
+		l.PogoComp.LogError(position, "Haxe", errors.New(err.Error()+" : "+sconst))
 		return ""
 	}
 	ret0 := ""
@@ -163,7 +168,7 @@ func haxeStringConst(sconst string, position string) string {
 	return ` #if (cpp || neko || php) ` + ret0 + ` #else ` + ret + " #end "
 }
 
-func constFloat64(lit ssa.Const, bits int, position string) string {
+func (l *langType) constFloat64(lit ssa.Const, bits int, position string) string {
 	var f float64
 	var f32 float32
 	//sigBits := uint(53)
@@ -175,7 +180,7 @@ func constFloat64(lit ssa.Const, bits int, position string) string {
 	if bits == 32 {
 		f = float64(f32)
 	}
-	haxeVal := pogo.FloatVal(lit.Value, bits, position)
+	haxeVal := l.PogoComp.FloatVal(lit.Value, bits, position)
 	switch {
 	case math.IsInf(f, +1):
 		haxeVal = "Math.POSITIVE_INFINITY"
@@ -209,8 +214,8 @@ func constFloat64(lit ssa.Const, bits int, position string) string {
 			res := float64(n64i) * math.Pow(2, float64(exp)) / float64(d64i)
 			if !math.IsNaN(res) && !math.IsInf(res, +1) && !math.IsInf(res, -1) { //drop through
 				if nok && dok {
-					nh, nl := pogo.IntVal(num, position)
-					dh, dl := pogo.IntVal(den, position)
+					nh, nl := l.PogoComp.IntVal(num, position)
+					dh, dl := l.PogoComp.IntVal(den, position)
 					n := fmt.Sprintf("%d", nl)
 					if n64i < 0 {
 						n = "(" + n + ")"
@@ -246,13 +251,13 @@ func constFloat64(lit ssa.Const, bits int, position string) string {
 	/*
 		bits64 := *(*uint64)(unsafe.Pointer(&f))
 		bitVal := exact.MakeUint64(bits64)
-		h, l := pogo.IntVal(bitVal, position)
+		h, l := l.PogoComp.IntVal(bitVal, position)
 		bitStr := fmt.Sprintf("GOint64.make(0x%x,0x%x)", uint32(h), uint32(l))
 		return "Force.float64const(" + bitStr + "," + haxeVal + ")"
 	*/
 }
 
-func (langType) Const(lit ssa.Const, position string) (typ, val string) {
+func (l *langType) Const(lit ssa.Const, position string) (typ, val string) {
 	if lit.Value == nil {
 		return "Dynamic", "null"
 	}
@@ -264,78 +269,78 @@ func (langType) Const(lit ssa.Const, position string) (typ, val string) {
 		// TODO check if conversion of some string constant declarations are required
 		switch lit.Type().Underlying().(type) {
 		case *types.Basic:
-			return "String", haxeStringConst(lit.Value.String(), position)
+			return "String", l.haxeStringConst(lit.Value.String(), position)
 		case *types.Slice:
-			return "Slice", "Force.toUTF8slice(this._goroutine," + haxeStringConst(lit.Value.String(), position) + ")"
+			return "Slice", "Force.toUTF8slice(this._goroutine," + l.haxeStringConst(lit.Value.String(), position) + ")"
 		default:
-			pogo.LogError(position, "Haxe", fmt.Errorf("haxe.Const() internal error, unknown string type"))
+			l.PogoComp.LogError(position, "Haxe", fmt.Errorf("haxe.Const() internal error, unknown string type"))
 		}
 	case exact.Float:
 		switch lit.Type().Underlying().(*types.Basic).Kind() {
 		case types.Float32:
-			return "Float", constFloat64(lit, 32, position)
+			return "Float", l.constFloat64(lit, 32, position)
 		case types.Float64, types.UntypedFloat:
-			return "Float", constFloat64(lit, 64, position)
+			return "Float", l.constFloat64(lit, 64, position)
 		case types.Complex64:
-			return "Complex", fmt.Sprintf("new Complex(%s,0)", pogo.FloatVal(lit.Value, 32, position))
+			return "Complex", fmt.Sprintf("new Complex(%s,0)", l.PogoComp.FloatVal(lit.Value, 32, position))
 		case types.Complex128:
-			return "Complex", fmt.Sprintf("new Complex(%s,0)", pogo.FloatVal(lit.Value, 64, position))
+			return "Complex", fmt.Sprintf("new Complex(%s,0)", l.PogoComp.FloatVal(lit.Value, 64, position))
 		}
 	case exact.Int:
-		h, l := pogo.IntVal(lit.Value, position)
+		h, lo := l.PogoComp.IntVal(lit.Value, position)
 		switch lit.Type().Underlying().(*types.Basic).Kind() {
 		case types.Int64:
-			return "GOint64", fmt.Sprintf("Force.toInt64(GOint64.make(0x%x,0x%x))", uint32(h), uint32(l))
+			return "GOint64", fmt.Sprintf("Force.toInt64(GOint64.make(0x%x,0x%x))", uint32(h), uint32(lo))
 		case types.Uint64:
-			return "GOint64", fmt.Sprintf("Force.toUint64(GOint64.make(0x%x,0x%x))", uint32(h), uint32(l))
+			return "GOint64", fmt.Sprintf("Force.toUint64(GOint64.make(0x%x,0x%x))", uint32(h), uint32(lo))
 		case types.Float32:
-			return "Float", constFloat64(lit, 32, position)
+			return "Float", l.constFloat64(lit, 32, position)
 		case types.Float64, types.UntypedFloat:
-			return "Float", constFloat64(lit, 64, position)
+			return "Float", l.constFloat64(lit, 64, position)
 		case types.Complex64:
-			return "Complex", fmt.Sprintf("new Complex(%s,0)", pogo.FloatVal(lit.Value, 32, position))
+			return "Complex", fmt.Sprintf("new Complex(%s,0)", l.PogoComp.FloatVal(lit.Value, 32, position))
 		case types.Complex128:
-			return "Complex", fmt.Sprintf("new Complex(%s,0)", pogo.FloatVal(lit.Value, 64, position))
+			return "Complex", fmt.Sprintf("new Complex(%s,0)", l.PogoComp.FloatVal(lit.Value, 64, position))
 		default:
 			if h != 0 && h != -1 {
-				pogo.LogWarning(position, "Haxe", fmt.Errorf("integer constant value > 32 bits : %v", lit.Value))
+				l.PogoComp.LogWarning(position, "Haxe", fmt.Errorf("integer constant value > 32 bits : %v", lit.Value))
 			}
 			ret := ""
 			switch lit.Type().Underlying().(*types.Basic).Kind() {
 			case types.Uint, types.Uint32, types.Uintptr:
-				q := uint32(l)
+				q := uint32(lo)
 				ret = fmt.Sprintf(
 					" #if js untyped __js__(\"0x%x\") #elseif php untyped __php__(\"0x%x\") #else 0x%x #end ",
 					q, q, q)
 			case types.Uint16:
-				q := uint16(l)
+				q := uint16(lo)
 				ret = fmt.Sprintf(" 0x%x ", q)
 			case types.Uint8: // types.Byte
-				q := uint8(l)
+				q := uint8(lo)
 				ret = fmt.Sprintf(" 0x%x ", q)
 			case types.Int, types.Int32, types.UntypedRune, types.UntypedInt: // types.Rune
-				if l < 0 {
-					ret = fmt.Sprintf("(%d)", int32(l))
+				if lo < 0 {
+					ret = fmt.Sprintf("(%d)", int32(lo))
 				} else {
-					ret = fmt.Sprintf("%d", int32(l))
+					ret = fmt.Sprintf("%d", int32(lo))
 				}
 			case types.Int16:
-				if l < 0 {
-					ret = fmt.Sprintf("(%d)", int16(l))
+				if lo < 0 {
+					ret = fmt.Sprintf("(%d)", int16(lo))
 				} else {
-					ret = fmt.Sprintf("%d", int16(l))
+					ret = fmt.Sprintf("%d", int16(lo))
 				}
 			case types.Int8:
-				if l < 0 {
-					ret = fmt.Sprintf("(%d)", int8(l))
+				if lo < 0 {
+					ret = fmt.Sprintf("(%d)", int8(lo))
 				} else {
-					ret = fmt.Sprintf("%d", int8(l))
+					ret = fmt.Sprintf("%d", int8(lo))
 				}
 			case types.UnsafePointer:
-				if l == 0 {
+				if lo == 0 {
 					return "Pointer", "null"
 				}
-				pogo.LogError(position, "Haxe", fmt.Errorf("unsafe pointers cannot be initialized in TARDISgo/Haxe to a non-zero value: %v", l))
+				l.PogoComp.LogError(position, "Haxe", fmt.Errorf("unsafe pointers cannot be initialized in TARDISgo/Haxe to a non-zero value: %v", lo))
 			default:
 				panic("haxe.Const() unhandled integer constant for: " +
 					lit.Type().Underlying().(*types.Basic).String())
@@ -354,18 +359,18 @@ func (langType) Const(lit ssa.Const, position string) (typ, val string) {
 			return "Complex", fmt.Sprintf("new Complex(%g,%g)", realV, imagV)
 		}
 	}
-	pogo.LogError(position, "Haxe", fmt.Errorf("haxe.Const() internal error, unknown constant type: %v", lit.Value.Kind()))
+	l.PogoComp.LogError(position, "Haxe", fmt.Errorf("haxe.Const() internal error, unknown constant type: %v", lit.Value.Kind()))
 	return "", ""
 }
 
 // only public Literals are created here, so that they can be used by Haxe callers of the Go code
-func (l langType) NamedConst(packageName, objectName string, lit ssa.Const, position string) string {
+func (l *langType) NamedConst(packageName, objectName string, lit ssa.Const, position string) string {
 	typ, rhs := l.Const(lit, position+":"+packageName+"."+objectName)
 	return fmt.Sprintf("public static var %s:%s = %s;%s",
 		l.LangName(packageName, objectName), typ, rhs, l.Comment(position))
 }
 
-func (l langType) Global(packageName, objectName string, glob ssa.Global, position string, isPublic bool) string {
+func (l *langType) Global(packageName, objectName string, glob ssa.Global, position string, isPublic bool) string {
 	pub := "public " // all globals have to be public in Haxe terms
 	//gTyp := glob.Type().Underlying().(*types.Pointer).Elem().Underlying() // globals are always pointers to an underlying element
 	/*
@@ -393,7 +398,7 @@ func (l langType) Global(packageName, objectName string, glob ssa.Global, positi
 		init := "new " + ptrTyp + "(" + ltInit + ")" // initialize basic types only
 	*/
 	//return fmt.Sprintf("%sstatic %s %s",
-	//	pub, haxeVar(l.LangName(packageName, objectName), ptrTyp, init, position, "Global()"),
+	//	pub, l.haxeVar(l.LangName(packageName, objectName), ptrTyp, init, position, "Global()"),
 	//	l.Comment(position))
 	obj := allocNewObject(glob.Type().Underlying().(*types.Pointer))
 	return fmt.Sprintf("%sstatic var %s:Pointer=new Pointer(%s); %s",
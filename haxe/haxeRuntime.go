@@ -4,8 +4,6 @@
 
 package haxe
 
-import "github.com/tardisgo/tardisgo/pogo"
-
 // Runtime Haxe code for Go, which may eventually become a haxe library when the system settles down.
 // TODO All runtime class names are currently carried through if the haxe code uses "import tardis.Go;" and some are too generic,
 // others, like Int64, will overload the Haxe standard library version for some platforms, which may cause other problems.
@@ -14,9 +12,9 @@ import "github.com/tardisgo/tardisgo/pogo"
 // However, there are references to Go->Haxe generated classes, like "Go", that would need to be managed somehow.
 // TODO consider merging and possibly renaming the Deep and Force classes as they both hold general utility code
 
-func haxeruntime() string {
+func (l *langType) haxeruntime() string {
 
-	pogo.WriteAsClass("Console", `
+	l.PogoComp.WriteAsClass("Console", `
 
 class Console {
 	public static inline function naclWrite(v:String){
@@ -50,6 +48,60 @@ class Console {
 		}
 		return s;
 	}
+	public static function args():Array<String> { // the command-line arguments, not including the program name
+		#if ( cpp || cs || java || neko || php || python )
+			return Sys.args();
+		#elseif js
+			untyped __js__("if(typeof process!='undefined' && process.argv) return process.argv.slice(2);"); // only works on Node
+			return [];
+		#else
+			return [];
+		#end
+	}
+	public static function programPath():String { // the path of the program being run, or "" if it is not known
+		#if ( cpp || cs || java || neko || php || python )
+			try {
+				var p = Sys.programPath();
+				if(p!=null) return p;
+			} catch (e:Dynamic) {} // not all targets give it
+			return "";
+		#elseif js
+			untyped __js__("if(typeof process!='undefined' && process.argv && process.argv.length>1) return process.argv[1];"); // only works on Node
+			return "";
+		#else
+			return "";
+		#end
+	}
+	public static function environment():Array<String> { // the environment variables, as "key=value"
+		var env = new Array<String>();
+		#if ( cpp || cs || java || neko || php || python )
+			var m = Sys.environment();
+			for (k in m.keys())
+				env.push(k + "=" + m.get(k));
+		#elseif js
+			untyped __js__("if(typeof process!='undefined' && process.env) for(var k in process.env) env.push(k+'='+process.env[k]);"); // only works on Node
+		#end
+		return env;
+	}
+	public static function putEnv(k:String, v:String) { // so that Haxe code also sees environment variables set by Go
+		#if ( cpp || cs || java || neko || php || python )
+			try Sys.putEnv(k, v) catch (e:Dynamic) {} // not all targets allow it
+		#elseif js
+			untyped __js__("if(typeof process!='undefined' && process.env) process.env[k]=v;");
+		#end
+	}
+	public static function unsetEnv(k:String) {
+		#if js
+			untyped __js__("if(typeof process!='undefined' && process.env) delete process.env[k];");
+		#end // Haxe has no way to remove an environment variable on sys targets
+	}
+	public static function exit(code:Int) { // end the program with the exit code, where the target allows it
+		#if ( cpp || cs || java || neko || php || python )
+			Sys.exit(code);
+		#elseif js
+			untyped __js__("if(typeof process!='undefined' && process.exit) process.exit(code);"); // only works on Node
+		#end
+	}
 	public static function readln():Null<String> {
 		#if (cpp || cs || java || neko || php )
 			var s:String="";
@@ -77,7 +129,7 @@ class Console {
 }
 
 `)
-	pogo.WriteAsClass("Force", `
+	l.PogoComp.WriteAsClass("Force", `
 // TODO: consider putting these go-compatibiliy classes into a separate library for general Haxe use when calling Go
 
 class Force { // TODO maybe this should not be a separate haxe class, as no non-Go code needs access to it
@@ -169,7 +221,9 @@ class Force { // TODO maybe this should not be a separate haxe class, as no non-
 		static private var f32dView = new js.html.DataView(new js.html.ArrayBuffer(8),0,8); 
 	#end
 	public static function toFloat32(v:Float):Float {
-		#if (cpp || neko)
+		#if gothreads // the buffers above are shared, and the Go code below may only be called from goroutine 0
+			return haxe.io.FPHelper.i32ToFloat(haxe.io.FPHelper.floatToI32(v));
+		#elseif (cpp || neko)
 			f64byts.setFloat(0,v);
 			return f64byts.getFloat(0);
 		#elseif js 
@@ -390,8 +444,8 @@ class Force { // TODO maybe this should not be a separate haxe class, as no non-
 				//if(v.charCodeAt(i)>0xff) return v; // probably already encoded as UTF-16
 				sli.itemAddr(i).store_uint8(v.charCodeAt(i));
 			}
-			var slr = Go_haxegoruntime_UUTTFF8toRRunes.callFromRT(0,sli);
-			var slo = Go_haxegoruntime_RRunesTToUUTTFF16.callFromRT(0,slr);
+			var slr = Go_haxegoruntime_UUTTFF8toRRunes.callFromRT(Scheduler.runtimeGR(),sli);
+			var slo = Go_haxegoruntime_RRunesTToUUTTFF16.callFromRT(Scheduler.runtimeGR(),slr);
 			v="";
 			for(i in 0...slo.len()) {
 				v += String.fromCharCode( slo.itemAddr(i).load_uint16() );
@@ -408,8 +462,8 @@ class Force { // TODO maybe this should not be a separate haxe class, as no non-
 			for(i in 0...v.length){
 				sli.itemAddr(i).store_uint16(v.charCodeAt(i));
 			}
-			var slr = Go_haxegoruntime_UUTTFF16toRRunes.callFromRT(0,sli);
-			var slo = Go_haxegoruntime_RRunesTToUUTTFF8.callFromRT(0,slr);
+			var slr = Go_haxegoruntime_UUTTFF16toRRunes.callFromRT(Scheduler.runtimeGR(),sli);
+			var slo = Go_haxegoruntime_RRunesTToUUTTFF8.callFromRT(Scheduler.runtimeGR(),slr);
 			v="";
 			for(i in 0...slo.len()) {
 				v += String.fromCharCode( slo.itemAddr(i).load_uint8() );
@@ -426,6 +480,9 @@ class Force { // TODO maybe this should not be a separate haxe class, as no non-
 	static var i2f = new Object(8);
 	public static function float64const(i:GOint64,f:Float):Float{
 		if(Object.nativeFloats) {
+			#if gothreads
+				var i2f = new Object(8); // as the shared one may be in use by another thread
+			#end
 			i2f.set_int64(0,i);
 			var r=i2f.get_float64(0);
 			//trace("i2f",r);
@@ -482,34 +539,34 @@ class Force { // TODO maybe this should not be a separate haxe class, as no non-
 
 // Object code
 // a single type of Go object
+// The scalar values (bools, numbers and the bits of a uintptr) are held little-endian in bytes, even in structs of only scalars,
+// while the values held as Haxe objects (strings, pointers, interfaces, maps...) are kept separately, on 4-byte boundaries.
+// The separate storage is only made for the Go types that may need it, as decided at compile time, 
+// or when an unsafe pointer stores a Haxe object, so byte slices and scalar-only structs take about as much memory as in Go.
 @:keep
-class Object { // this implementation will improve with typed array access
-	#if ((js || cpp || neko) && fullunsafe) 
-		public static var nativeFloats:Bool=true; 
-	#else
-		public static var nativeFloats:Bool=false; 	
-	#end
+class Object { 
+	public static var nativeFloats:Bool=true; // floats are always held as bytes, so unsafe pointers see their bits 
 
-	private var dVec4:haxe.ds.Vector<Dynamic>; // on 4-byte boundaries 
+	private var dVec4:haxe.ds.Vector<Dynamic>; // on 4-byte boundaries, or null if none have been needed
 	#if (js && fullunsafe) // native memory access (nearly)
 		private var arrayBuffer:js.html.ArrayBuffer;
 		private var dView:js.html.DataView;
-	#elseif !fullunsafe	// Simple! 1 address per byte, non-Int types are always on 4-byte
-		private var iVec:haxe.ds.Vector<Int>; 
-	#else // fullunsafe position is to allow unsafe pointers, and therefore run slowly...
+	#else
 		private var byts:haxe.io.Bytes;
 	#end
 	public var length:Int;
 	public var uniqueRef:Int; // to give pointers a unique numerical value
 
 	private static var uniqueCount:Int=0;
+	public static var allocBytes:Float=0; // all the bytes and Objects ever made, for runtime.ReadMemStats(), as Floats so that they do not overflow
+	public static var allocObjects:Float=0;
 	#if godebug
 		public static var memory = new Map<Int,Object>();
 	#end
 
-	public function new(byteSize:Int,?bytes:haxe.io.Bytes){ // size is in bytes
-		dVec4 = new haxe.ds.Vector<Dynamic>(1+(byteSize>>2)); // +1 to make sure non-zero
+	public function new(byteSize:Int,?bytes:haxe.io.Bytes,refs:Bool=false){ // size is in bytes, refs is set if the type may hold Haxe objects
 		if(bytes!=null) byteSize = bytes.length;
+		dVec4 = refs ? newRefs(byteSize) : null;
 		#if (js && fullunsafe)
 			arrayBuffer = new js.html.ArrayBuffer(byteSize);
 			if(byteSize>0)
@@ -517,89 +574,71 @@ class Object { // this implementation will improve with typed array access
 			if(bytes!=null)
 				for(i in 0 ... byteSize) 
 					set_uint8(i, bytes.get(i));
-		#elseif !fullunsafe
-			iVec = new haxe.ds.Vector<Int>(byteSize);
-			if(bytes!=null)
-				for(i in 0 ... byteSize) 
-					iVec[i] = bytes.get(i);
 		#else
 			if(bytes==null)	{
 				byts = haxe.io.Bytes.alloc(byteSize);
-				//byts.fill(0,byteSize,0); 
+				byts.fill(0,byteSize,0); // not all targets give zeroed memory
 			} else byts = bytes;
 		#end
 		length = byteSize;
+		Scheduler.lock();
 		uniqueCount += 1;
 		uniqueRef = uniqueCount;
+		allocBytes += byteSize;
+		allocObjects += 1;
+		if(MemProfile.rate!=null) 
+			MemProfile.sample(byteSize);
+		Scheduler.unlock();
 		#if godebug
 			memory.set(uniqueRef,this);
 		#end
 	}
+	private static inline function newRefs(byteSize:Int):haxe.ds.Vector<Dynamic> {
+		return new haxe.ds.Vector<Dynamic>(1+(byteSize>>2)); // +1 to make sure non-zero
+	}
+	public inline function hasRefs():Bool { // does the object have storage for Haxe objects
+		return dVec4!=null;
+	}
 	public function getBytes():haxe.io.Bytes {
 		#if (js && fullunsafe)
 			var byts = haxe.io.Bytes.alloc(length);
 			for(i in 0 ... length) 
 				byts.set(i,get_uint8(i));
-		#elseif !fullunsafe
-			var byts = haxe.io.Bytes.alloc(length);
-			for(i in 0 ... length) 
-				byts.set(i,iVec[i]);
 		#else
 			// the byts field already exists
 		#end
 		return byts;
 	}
 	public function clear():Object {
-		for(i in 0...this.length){
-			set_uint8(i,0);
-			if(i&3==0) set(i,null);
-		}
+		#if (js && fullunsafe)
+			for(i in 0...this.length)
+				set_uint8(i,0);
+		#else
+			byts.fill(0,length,0);
+		#end
+		if(dVec4!=null)
+			for(i in 0...dVec4.length)
+				dVec4[i]=null;
 		return this; // to allow use without a temp var
 	}
 	public function isEqual(off:Int,target:Object,tgtOff:Int):Bool { // TODO check if correct, used by interface{} value comparison
 		if((this.length-off)!=(target.length-tgtOff)) return false;
+		var refs=(this.dVec4!=null || target.dVec4!=null);
 		for(i in 0...(this.length-off)) {
-			if((i+off)&3==0){
+			if(refs && (i+off)&3==0){
 				var a:Dynamic=this.get(i+off);
 				var b:Dynamic=target.get(i+tgtOff);
 				if(!Force.isEqualDynamic(a,b)) return false;
-				/* code like that below all now in isEqualDynamic
-				if(a!=b){
-					if(Reflect.isObject(a)&&Reflect.isObject(b)) { // also deals with one being null
-						if(Std.is(a,Pointer)&&Std.is(b,Pointer)) {
-							if(!Pointer.isEqual(a,b) )
-								return false;
-						} else {
-							if(Std.is(a,Interface)&&Std.is(b,Interface)){
-								if(!Interface.isEqual(a,b))
-									return false;
-							} else {
-								if(Std.is(a,GOmap)||Std.is(b,GOmap))
-									return false; //maps are never equal
-								else
-									if(GOint64.compare(a,b)!=0) // Assume Goint64
-										return false;
-							}
-						}
-					} else
-						return false;
-				}
-				*/
 			}
- 			#if fullunsafe
-				if(this.get_uint8(i+off)!=target.get_uint8(i+tgtOff))
-					return false;
-			#else
-				if(this.get_uint32(i+off)!=target.get_uint32(i+tgtOff))
-					return false;
-			#end
+			if(this.get_uint8(i+off)!=target.get_uint8(i+tgtOff))
+				return false;
 		}
 		return true;
 	}
 	private static function objBlit(src:Object,srcPos:Int,dest:Object,destPos:Int,size:Int):Void{
 		if(size==0) return;
 `
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		objClass += `
 		if(!Std.is(src,Object)) { 
 			Scheduler.panicFromHaxe("Object.objBlt() src parameter is not an Object - Value: "+Std.string(src)+" Type: "+Type.typeof(src));
@@ -633,14 +672,13 @@ class Object { // this implementation will improve with typed array access
 		`*/
 	}
 	objClass += `
-		#if fullunsafe //(js && fullunsafe)
+		#if (js && fullunsafe)
 			if((size&3==0)&&(srcPos&3==0)&&(destPos&3==0)) {
 				var i:Int=0;
 				var s:Int=srcPos;
 				var d:Int=destPos;
 				while(i<size){
 					dest.set_uint32(d,src.get_uint32(s)); 
-					dest.set(d,src.get(s));
 					i+=4;
 					s+=4;
 					d+=4;
@@ -651,36 +689,27 @@ class Object { // this implementation will improve with typed array access
 				var d:Int=destPos;
 				for(i in 0...size) {
 					dest.set_uint8(d,src.get_uint8(s));
-					if(((size-i)>3)&&(s&3)==0){ 
-						dest.set(d,src.get(s));
-					}
 					s+=1;
 					d+=1;
 				}
 			}
-		#else //if !fullunsafe
-			if((size>>2)>0)
-				haxe.ds.Vector.blit(src.dVec4,srcPos>>2, dest.dVec4, destPos>>2, size>>2); 
-			haxe.ds.Vector.blit(src.iVec,srcPos, dest.iVec, destPos, size); 
-			/*{
-				var s:Int=srcPos;
-				var d:Int=destPos;
-				for(i in 0...size) {
-					dest.set_int32(d,src.get_int32(s));
-					if((s&3)==0){ 
-						dest.set(d,src.get(s));
-					}
-					s+=1;
-					d+=1;
-				}
-			}*/
-		//#else
-		//	haxe.ds.Vector.blit(src.dVec4,srcPos>>2, dest.dVec4, destPos>>2, 1+(size>>2)); 
-		//	dest.byts.blit(destPos,src.byts,srcPos,size);
+		#else
+			dest.byts.blit(destPos,src.byts,srcPos,size);
 		#end
+		if((size>>2)>0) {
+			if(src.dVec4!=null) {
+				if(dest.dVec4==null) 
+					dest.dVec4=newRefs(dest.length);
+				haxe.ds.Vector.blit(src.dVec4,srcPos>>2, dest.dVec4, destPos>>2, size>>2); 
+			} else if(dest.dVec4!=null) { // nothing to copy, but any Haxe objects in the destination are overwritten
+				var d:Int=destPos>>2;
+				for(i in 0...(size>>2))
+					dest.dVec4[d+i]=null;
+			}
+		}
 	}
 	public function get_object(size:Int,from:Int):Object { // TODO SubObj class that is effectively a pointer?
-		var so:Object = new Object(size);
+		var so:Object = new Object(size,null,dVec4!=null);
 		objBlit(this,from, so, 0, size); 
 		return so;
 	}
@@ -697,18 +726,11 @@ class Object { // this implementation will improve with typed array access
 		return this.get_object(length,0);
 	}
 	public inline function get(i:Int):Dynamic {
-			return dVec4[i>>2];
+		return dVec4==null?null:dVec4[i>>2];
 	}
 	public inline function get_bool(i:Int):Bool { 
 		#if (js && fullunsafe)
 			return dView.getUint8(i)==0?false:true;
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) 
-				return r==null?false:(r==0?false:true); 
-			#else 
-				return r==0?false:true; 
-			#end
 		#else
 			return byts.get(i)==0?false:true;
 		#end
@@ -716,9 +738,6 @@ class Object { // this implementation will improve with typed array access
 	public inline function get_int8(i:Int):Int { 
 		#if (js && fullunsafe)
 			return dView.getInt8(i);
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else
 			return Force.toInt8(byts.get(i));
 		#end
@@ -726,116 +745,81 @@ class Object { // this implementation will improve with typed array access
 	public inline function get_int16(i:Int):Int { 
 		#if (js && fullunsafe)
 			return dView.getInt16(i,true); // little-endian
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else
-			return Force.toInt16((get_uint8(i+1)<<8)|get_uint8(i)); // little end 1st
+			return Force.toInt16(byts.getUInt16(i)); 
 		#end
 	}
 	public inline function get_int32(i:Int):Int {
 		#if (js && fullunsafe)
 			return dView.getInt32(i,true); // little-endian
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else
-			return Force.toInt32((get_uint16(i+2)<<16)|get_uint16(i)); // little end 1st			
+			return Force.toInt32(byts.getInt32(i));
 		#end
 	}
 	public inline function get_int64(i:Int):GOint64 {
-		#if !fullunsafe
-			var r:GOint64=get(i); 
-			return r==null?GOint64.ofInt(0):r;			
-		#else
-			return Force.toInt64(GOint64.make(get_uint32(i+4),get_uint32(i)));
-		#end
+		return Force.toInt64(GOint64.make(get_uint32(i+4),get_uint32(i)));
 	} 
 	public inline function get_uint8(i:Int):Int { 
 		#if (js && fullunsafe)
 			return dView.getUint8(i);
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else 
-			return Force.toUint8(byts.get(i));
+			return byts.get(i);
 		#end
 	}
 	public inline function get_uint16(i:Int):Int {
 		#if (js && fullunsafe)
 			return dView.getUint16(i,true); // little-endian
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else
-			return Force.toUint16((get_uint8(i+1)<<8)|get_uint8(i)); // little end 1st
+			return byts.getUInt16(i);
 		#end
 	}
 	public inline function get_uint32(i:Int):Int {
 		#if (js && fullunsafe)
 			return dView.getUint32(i,true); // little-endian
-		#elseif !fullunsafe
-			var r:Int=iVec[i]; 
-			#if (js || php || neko ) return r==null?0:0|r; #else return r; #end
 		#else
-			return Force.toUint32((get_uint16(i+2)<<16)|get_uint16(i)); // little end 1st
+			return Force.toUint32(byts.getInt32(i));
 		#end
 	}
 	public inline function get_uint64(i:Int):GOint64 { 
-		#if !fullunsafe
-			var r:GOint64=get(i); 
-			return r==null?GOint64.ofInt(0):r;			
-		#else
-			return Force.toUint64(GOint64.make(get_uint32(i+4),get_uint32(i)));
-		#end
+		return Force.toUint64(GOint64.make(get_uint32(i+4),get_uint32(i)));
 	} 
 	public inline function get_uintptr(i:Int):Dynamic { // uintptr holds Haxe objects
 		// TODO consider some type of read-from-mem if Dynamic type is Int 
 		return get(i); 
 	} 
-	public function get_float32(i:Int):Float { 
+	public inline function get_float32(i:Int):Float { 
 		#if (js && fullunsafe)
 			return dView.getFloat32(i,true); // little-endian
-		#elseif !fullunsafe
-			return get(i)==null?0.0:get(i); 
 		#else 
-			return byts.getFloat(i); // Go_haxegoruntime_FFloat32frombits.callFromRT(0,get_uint32(i)); 
+			return byts.getFloat(i);
 		#end
 	}
-	public function get_float64(i:Int):Float { 
+	public inline function get_float64(i:Int):Float { 
 		#if (js && fullunsafe)
 			return dView.getFloat64(i,true); // little-endian
-		#elseif !fullunsafe
-			return get(i)==null?0.0:get(i); 
 		#else
-			return byts.getDouble(i); // Go_haxegoruntime_FFloat64frombits.callFromRT(0,get_uint64(i)); 		
+			return byts.getDouble(i);
 		#end
 	}
 	public inline function get_complex64(i:Int):Complex {
-		// TODO optimize for dataview & unsafe
-		var r:Complex=get(i); 
-		return r==null?new Complex(0.0,0.0):r;			
+		return new Complex(get_float32(i),get_float32(i+4));
 	}
 	public inline function get_complex128(i:Int):Complex { 
-		// TODO optimize for dataview & unsafe
-		var r:Complex=get(i); 
-		return r==null?new Complex(0.0,0.0):r;			
+		return new Complex(get_float64(i),get_float64(i+8));
 	}
 	public inline function get_string(i:Int):String { 
 		var r=get(i); 
 		return r==null?"":Std.string(r);
 	}
 	public inline function set(i:Int,v:Dynamic):Void { 
-		dVec4[i>>2]=v;
+		if(dVec4==null && v!=null) // an unsafe pointer is storing a Haxe object where the type has none
+			dVec4=newRefs(length);
+		if(dVec4!=null)
+			dVec4[i>>2]=v;
 	}
 	public inline function set_bool(i:Int,v:Bool):Void { 
 		#if (js && fullunsafe)
 			dView.setUint8(i,v?1:0);
-		#elseif !fullunsafe
-			iVec[i]=v?1:0;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
 			byts.set(i,v?1:0); 
 		#end
@@ -843,11 +827,6 @@ class Object { // this implementation will improve with typed array access
 	public inline function set_int8(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setInt8(i,v);
-		#elseif !fullunsafe
-			iVec[i]=v;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
 			byts.set(i,v&0xff); 
 		#end
@@ -855,50 +834,24 @@ class Object { // this implementation will improve with typed array access
 	public inline function set_int16(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setInt16(i,v,true); // little-endian
-		#elseif !fullunsafe
-			iVec[i]=v;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
-			set_int8(i,v);
-			set_int8(i+1,v>>8);
+			byts.setUInt16(i,v&0xffff);
 		#end
 	}
 	public inline function set_int32(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setInt32(i,v,true); // little-endian
-		#elseif !fullunsafe
-			iVec[i]=v;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
-			set_int16(i,v);
-			set_int16(i+2,v>>16); 
+			byts.setInt32(i,v);
 		#end
 	}
 	public inline function set_int64(i:Int,v:GOint64):Void { 
-		#if !fullunsafe
-			#if (js || php || neko ) 
-				if(GOint64.isZero(v)) 	set(i,null);
-				else					set(i,v);  
-			#else
-				set(i,v);
-			#end
-		#else
-			set_uint32(i,GOint64.getLow(v));
-			set_uint32(i+4,GOint64.getHigh(v));
-		#end
+		set_uint32(i,GOint64.getLow(v));
+		set_uint32(i+4,GOint64.getHigh(v));
 	} 
 	public inline function set_uint8(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setUint8(i,v);
-		#elseif !fullunsafe
-			iVec[i]=v&0xff;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
 			byts.set(i,v&0xff);
 		#end
@@ -906,41 +859,20 @@ class Object { // this implementation will improve with typed array access
 	public inline function set_uint16(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setUint16(i,v,true); // little-endian
-		#elseif !fullunsafe
-			iVec[i]=v&0xffff;
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
-			set_uint8(i,v); 
-			set_uint8(i+1,v>>8); 
+			byts.setUInt16(i,v&0xffff);
 		#end
 	}
 	public inline function set_uint32(i:Int,v:Int):Void { 
 		#if (js && fullunsafe)
 			dView.setUint32(i,v,true); // little-endian
-		#elseif !fullunsafe
-			iVec[i]=Force.toUint32(v);
-			#if (js || php || neko ) 
-				if(iVec[i]==0) iVec[i]=null; 
-			#end
 		#else
-			set_uint16(i,v);
-			set_uint16(i+2,v>>16); 
+			byts.setInt32(i,v);
 		#end
 	}
 	public inline function set_uint64(i:Int,v:GOint64):Void { 
-		#if !fullunsafe
-			#if (js || php || neko ) 
-				if(GOint64.isZero(v)) 	set(i,null);
-				else					set(i,v);  
-			#else
-				set(i,v);
-			#end
-		#else
-			set_uint32(i,GOint64.getLow(v));
-			set_uint32(i+4,GOint64.getHigh(v));
-		#end
+		set_uint32(i,GOint64.getLow(v));
+		set_uint32(i+4,GOint64.getHigh(v));
 	} 
 	public inline function set_uintptr(i:Int,v:Dynamic):Void { 
 		if(Std.is(v,Int)) {
@@ -951,59 +883,27 @@ class Object { // this implementation will improve with typed array access
 		set(i,v);
 		set_uint32(i,0); // value overwritten
 	}
-	public static var MinFloat64:Float = -1.797693134862315708145274237317043567981e+308; // 2**1023 * (2**53 - 1) / 2**52
 	public inline function set_float32(i:Int,v:Float):Void {
 		#if (js && fullunsafe)
 			dView.setFloat32(i,v,true); // little-endian
-		#elseif !fullunsafe
-			#if (js || php || neko ) 
-				if(v==0.0) {
-					#if !php
-					var t:Float=1/v; // result is +/- infinity
-					if(t>MinFloat64) // ie not -0
-					#end
-						v=null; 
-				}
-			#end
-			set(i,Force.toFloat32(v));
 		#else 
-			#if (cpp||neko)
-				byts.setFloat(i,v);
-			#else
-				set_uint32(i,Go_haxegoruntime_FFloat32bits.callFromRT(0,v));
-			#end 
+			byts.setFloat(i,v);
 		#end	
 	}
 	public inline function set_float64(i:Int,v:Float):Void {
 	 	#if (js && fullunsafe)
 			dView.setFloat64(i,v,true); // little-endian
-		#elseif !fullunsafe
-			#if (js || php || neko ) 
-				if(v==0.0) {
-					#if !php
-					var t:Float=1/v; // result is +/- infinity
-					if(t>MinFloat64) // ie not -0
-					#end
-						v=null;
-				} 
-			#end
-			set(i,v);
 		#else
-			#if (cpp||neko)
-				byts.setDouble(i,v);
-			#else
-				set_uint64(i,Go_haxegoruntime_FFloat64bits.callFromRT(0,v));
-			#end 
+			byts.setDouble(i,v);
 		#end	
 	}
-	
 	public inline function set_complex64(i:Int,v:Complex):Void { 
-		if(v.real==0 && v.imag==0) set(i,null);
-		else set(i,v); // TODO review
+		set_float32(i,v.real);
+		set_float32(i+4,v.imag);
 	} 
 	public inline function set_complex128(i:Int,v:Complex):Void { 
-		if(v.real==0 && v.imag==0) set(i,null);
-		else set(i,v); // TODO review
+		set_float64(i,v.real);
+		set_float64(i+8,v.imag);
 	} 
 	public inline function set_string(i:Int,v:String):Void { 
 		if(v=="") set(i,null);
@@ -1027,7 +927,77 @@ class Object { // this implementation will improve with typed array access
 	}
 }
 `
-	pogo.WriteAsClass("Object", objClass)
+	l.PogoComp.WriteAsClass("Object", objClass)
+
+	l.PogoComp.WriteAsClass("MemProfile", `
+// MemProfile code
+// the allocation sites for runtime.MemProfile(), sampled by the Object constructor, which makes all Go memory. 
+// As in gc, a sample is taken about every runtime.MemProfileRate bytes, or at every allocation if it is 1, 
+// but each sample is given all the bytes and Objects allocated since the one before, so the sites add up to the totals in Object.
+// Slices are not counted, as they share the Objects of their underlying arrays.
+// The Haxe garbage collector does not tell us what it frees, so all that has been allocated is reported as in use.
+@:keep
+class MemProfile {
+	public static var rate:Pointer=null; // to runtime.MemProfileRate, set once package runtime is initialized, until then there are no samples
+	static var sinceBytes:Float=0; // allocated since the last sample
+	static var sinceObjects:Float=0;
+	static var sites:Map<String,Int>=new Map<String,Int>(); // the index of each site in the arrays below, keyed by its stack
+	static var siteStack:Array<Array<Int>>=new Array<Array<Int>>(); // the program counters of the sampled stack, innermost first 
+	static var siteBytes:Array<Float>=new Array<Float>();
+	static var siteObjects:Array<Float>=new Array<Float>();
+
+	public static function setRate(p:Pointer) {
+		Scheduler.lock();
+		rate=p;
+		Scheduler.unlock();
+	}
+	public static function sample(bytes:Int) { // called by the Object constructor, which holds the runtime lock
+		sinceBytes += bytes;
+		sinceObjects += 1;
+		var r=rate.load_int32();
+		if(r<=0 || sinceBytes<r) 
+			return;
+		var stack=Scheduler.currentCallers(32);
+		var key=stack.join(",");
+		var i=sites.get(key);
+		if(i==null) {
+			i=siteStack.length;
+			sites.set(key,i);
+			siteStack.push(stack);
+			siteBytes.push(0);
+			siteObjects.push(0);
+		}
+		siteBytes[i] += sinceBytes;
+		siteObjects[i] += sinceObjects;
+		sinceBytes=0;
+		sinceObjects=0;
+	}
+	public static function numSites():Int {
+		Scheduler.lock();
+		var n=siteStack.length;
+		Scheduler.unlock();
+		return n;
+	}
+	public static function bytes(i:Int):Float {
+		Scheduler.lock();
+		var b=siteBytes[i];
+		Scheduler.unlock();
+		return b;
+	}
+	public static function objects(i:Int):Float {
+		Scheduler.lock();
+		var o=siteObjects[i];
+		Scheduler.unlock();
+		return o;
+	}
+	public static function depth(i:Int):Int {
+		return siteStack[i].length; // the stack of a site never changes
+	}
+	public static function pc(i:Int,x:Int):Int {
+		return siteStack[i][x];
+	}
+}
+`)
 
 	ptrClass := `
 @:keep
@@ -1035,7 +1005,7 @@ class Pointer {
 	private var obj:Object; // reference to the object holding the value
 	private var off:Int; // the offset into the object, if any 
 `
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		ptrClass += `
 	public function new(from:Object){
 		if(from==null) Scheduler.panicFromHaxe("attempt to make a new Pointer from a nil object");
@@ -1060,7 +1030,7 @@ class Pointer {
 		return r;
 	}
 `
-	if pogo.DebugFlag {
+	if l.PogoComp.DebugFlag {
 		ptrClass += `	public static function check(p:Dynamic):Pointer {
 		if(p==null) {
 			Scheduler.panicFromHaxe("nil pointer de-reference");
@@ -1082,7 +1052,7 @@ class Pointer {
 		return p; 
 	}`
 	}
-	pogo.WriteAsClass("Pointer", ptrClass+
+	l.PogoComp.WriteAsClass("Pointer", ptrClass+
 		`	public static function isEqual(p1:Pointer,p2:Pointer):Bool {
 		if(p1==p2) return true; // simple case of being the same haxe object
 		if(p1==null || p2==null) return false; // one of them is null (if above handles both null)
@@ -1304,7 +1274,7 @@ class Slice {
 		return capacity-start;
 	}
 `
-	if pogo.DebugFlag { // TODO test could be removed in some future NoChecking mode maybe?
+	if l.PogoComp.DebugFlag { // TODO test could be removed in some future NoChecking mode maybe?
 		sliceClass += `
 	public function itemAddr(idx:Int):Pointer {
 		if (idx<0 || idx>=len()) Scheduler.panicFromHaxe("Slice index out of range");
@@ -1328,8 +1298,8 @@ class Slice {
 	}
 }
 `
-	pogo.WriteAsClass("Slice", sliceClass)
-	pogo.WriteAsClass("Closure", `
+	l.PogoComp.WriteAsClass("Slice", sliceClass)
+	l.PogoComp.WriteAsClass("Closure", `
 
 @:keep
 class Closure { // "closure" is a keyword in PHP but solved using compiler flag  --php-prefix go  //TODO tidy names
@@ -1374,6 +1344,34 @@ class Closure { // "closure" is a keyword in PHP but solved using compiler flag
 		}
 		return Reflect.callMethod(null, cl.fn, params);
 	}
+	// withArgs returns a Closure taking no parameters, which calls cl with the given arguments, used by reflect.Value.Call.
+	public static function withArgs(cl:Closure,args:Array<Dynamic>):Closure {
+		return new Closure(function(gr:Int,bds:Dynamic):Dynamic {
+			var params:Array<Dynamic>=[gr,cl==null?null:cl.bds];
+			return callFn(cl,params.concat(args));
+		},null);
+	}
+	// varArgs returns a Closure that may be called with any parameters, which calls inner
+	// with those parameters in a single Array, used by reflect.MakeFunc.
+	public static function varArgs(inner:Closure):Closure {
+		return new Closure(Reflect.makeVarArgs(function(params:Array<Dynamic>):Dynamic {
+			return callFn(inner,[params[0],inner.bds,params.slice(2)]);
+		}),null);
+	}
+	// results returns the values returned by a function in the form used by the generated code,
+	// used by reflect.MakeFunc.
+	public static function results(vals:Array<Dynamic>):Dynamic {
+		switch(vals.length) {
+		case 0:
+			return null;
+		case 1:
+			return vals[0];
+		default:
+			var ret:Dynamic={};
+			for(i in 0...vals.length) Reflect.setField(ret,"r"+i,vals[i]);
+			return ret;
+		}
+	}
 	// This technique is used to create callback functions
 	public function buildCallbackFn():Dynamic { 
 		//trace("buildCallbackFn");
@@ -1390,7 +1388,7 @@ class Closure { // "closure" is a keyword in PHP but solved using compiler flag
 	}
 }
 `)
-	pogo.WriteAsClass("Interface", `
+	l.PogoComp.WriteAsClass("Interface", `
 
 class Interface { // "interface" is a keyword in PHP but solved using compiler flag  --php-prefix tgo //TODO tidy names 
 	public var typ:Int; // the possibly interface type that has been cast to
@@ -1475,7 +1473,6 @@ class Interface { // "interface" is a keyword in PHP but solved using compiler f
 		the operation will fail iff the operand is nil. (Contrast with ChangeInterface, which performs no nil-check.)
 	*/
 	public static function assert(assTyp:Int,ifce:Interface):Dynamic{
-		// TODO add code to deal with overloaded types? i.e. those created by reflect
 		if(ifce==null) {
 			Scheduler.panicFromHaxe( "Interface.assert null Interface");
 		} else {
@@ -1515,9 +1512,62 @@ class Interface { // "interface" is a keyword in PHP but solved using compiler f
 	}
 }
 `)
-	pogo.WriteAsClass("Channel", `
+	l.PogoComp.WriteAsClass("CreatedTypeInfo", `
+
+class CreatedTypeInfo { // the types created at run time by reflect, numbered from TypeInfo.firstCreatedID, which TypeInfo defers to
+	static var zeros = new Map<Int,Dynamic>();
+	static var methods = new Map<Int,Map<String,Dynamic>>();
+	static var assertable = new Map<Int,Bool>(); // keyed as TypeInfo.assertableTo, (v<<16)|t
+
+	public static function add(t:Int,zero:Dynamic) {
+		Scheduler.lock();
+		zeros.set(t,zero);
+		methods.set(t,new Map<String,Dynamic>());
+		Scheduler.unlock();
+	}
+	public static function addMethod(t:Int,name:String,fn:Dynamic) { // fn is called as those given by MethodTypeInfo.method
+		Scheduler.lock();
+		methods.get(t).set(name,fn);
+		Scheduler.unlock();
+	}
+	public static function addAssertable(v:Int,t:Int) {
+		Scheduler.lock();
+		assertable.set((v<<16)|t,true);
+		Scheduler.unlock();
+	}
+	public static function zeroValue(t:Int):Dynamic {
+		Scheduler.lock();
+		var z:Dynamic=zeros.get(t);
+		Scheduler.unlock();
+		if(Std.is(z,Object)) 
+			return cast(z,Object).copy(); // each struct or array zero value must be a new Object
+		return z;
+	}
+	public static function assertableTo(v:Int,t:Int):Bool {
+		Scheduler.lock();
+		var ret=assertable.exists((v<<16)|t);
+		Scheduler.unlock();
+		return ret;
+	}
+	public static function method(t:Int,m:String):Dynamic {
+		Scheduler.lock();
+		var ms=methods.get(t);
+		var fn:Dynamic=(ms==null)?null:ms.get(m);
+		Scheduler.unlock();
+		if(fn==null) 
+			Scheduler.panicFromHaxe( "no method found for created type "+TypeInfo.getName(t)+": "+m); 
+		return fn;
+	}
+}
+`)
+	l.PogoComp.WriteAsClass("Channel", `
 
 class Channel { //TODO check close & rangeing over a channel
+// NOTE with -D gothreads, the generated code for send, receive and select holds the Scheduler lock while it uses a channel
+// A goroutine that can't send or receive straight away is queued on the channel, with the value it sends, 
+// so that a partner can complete its operation: this is how an unbuffered channel hands a value from sender to receiver,
+// and how a select that is blocked on many channels is completed by just one of them.
+// The static functions below are given the goroutine running them, and a nil channel, which is never ready
 var entries:Array<Dynamic>;
 var max_entries:Int;
 var num_entries:Int;
@@ -1525,6 +1575,9 @@ var oldest_entry:Int;
 var closed:Bool;
 var capa:Int;
 var uniqueId:Int;
+var waiters:Array<Int>=null; // the goroutines blocked on this channel
+var sendq:Array<{gr:Int,idx:Int,val:Dynamic}>; // the goroutines waiting to send, with the select case index (or 0) and the value
+var recvq:Array<{gr:Int,idx:Int,val:Dynamic}>; // the goroutines waiting to receive
 
 static var nextId:Int=0;
 
@@ -1537,49 +1590,101 @@ public function new(how_many_entries:Int) {
 	oldest_entry = 0;
 	num_entries = 0;
 	closed = false;
+	sendq = [];
+	recvq = [];
+	Scheduler.lock();
 	uniqueId = nextId;
 	nextId++;
-}
-public function hasSpace():Bool {
-	if(this==null) return false; // non-existant channels never have space
-	if(closed) return false; // closed channels don't have space
-	return num_entries < max_entries;
-}
-public function send(source:Dynamic):Bool {
-	if(closed) Scheduler.panicFromHaxe( "attempt to send to closed channel"); 
-	var next_element:Int;
-	if (this.hasSpace()) {
-		next_element = (oldest_entry + num_entries) % max_entries;
-		num_entries++;
-		entries[next_element]=source;  
-		return true;
-	} else
+	Scheduler.unlock();
+}
+// can a value be sent now (a send on a closed channel is ready, in order to panic)
+public static function canSend(c:Channel):Bool {
+	if(c==null) return false; // non-existant channels never have space
+	return c.closed || c.recvq.length>0 || c.num_entries<c.capa;
+}
+// can a value be received now
+public static function canReceive(c:Channel):Bool {
+	if(c==null) return false; // spec: "Receiving from a nil channel blocks forever."
+	return c.closed || c.num_entries>0 || c.sendq.length>0; // spec: "Receiving from a closed channel always succeeds..."
+}
+// send the value, returning false if the goroutine must wait, in which case it has been queued to send it
+public static function send(c:Channel,gr:Int,source:Dynamic):Bool {
+	if(Scheduler.takeDone(gr)!=null) 
+		return true; // a receiver took the value while we were waiting
+	Scheduler.dequeue(gr);
+	if(c==null) 
+		return false; // blocks forever
+	if(c.closed) Scheduler.panicFromHaxe( "send on closed channel"); 
+	if(c.recvq.length>0) { // hand over the value to a waiting receiver
+		var r=c.recvq.shift();
+		Scheduler.complete(r.gr,r.idx,source,true);
+	} else if(c.num_entries<c.capa) {
+		var next_element = (c.oldest_entry + c.num_entries) % c.max_entries;
+		c.num_entries++;
+		c.entries[next_element]=source;  
+	} else {
+		queueSend(c,gr,0,source);
 		return false;
+	}
+	c.wakeWaiters();
+	return true;
 }
-public function hasNoContents():Bool { // used by channel read
-	if (this==null) return true; // spec: "Receiving from a nil channel blocks forever."
-	if (closed) return false; // spec: "Receiving from a closed channel always succeeds..."
-	else return num_entries == 0;
+// receive a value, returning null if the goroutine must wait, in which case it has been queued to receive one
+public static function receive(c:Channel,gr:Int,zero:Dynamic):{r0:Dynamic ,r1:Bool} {
+	var d=Scheduler.takeDone(gr);
+	if(d!=null) 
+		return {r0:d.val,r1:d.ok}; // a sender gave us a value, or the channel was closed, while we were waiting
+	Scheduler.dequeue(gr);
+	if(c==null) 
+		return null; // spec: "Receiving from a nil channel blocks forever."
+	var ret:Dynamic=zero;
+	if (c.num_entries > 0) {
+		ret=c.entries[c.oldest_entry];
+		c.entries[c.oldest_entry]=null;
+		c.oldest_entry = (c.oldest_entry + 1) % c.max_entries;
+		c.num_entries--;
+		if(c.sendq.length>0) { // there is now space for the value of the first waiting sender
+			var s=c.sendq.shift();
+			c.entries[(c.oldest_entry + c.num_entries) % c.max_entries]=s.val;
+			c.num_entries++;
+			Scheduler.complete(s.gr,s.idx,null,false);
+		}
+	} else if(c.sendq.length>0) { // take the value straight from a waiting sender
+		var s=c.sendq.shift();
+		ret=s.val;
+		Scheduler.complete(s.gr,s.idx,null,false);
+	} else if(c.closed) {
+		return {r0:ret,r1:false}; // spec: "Receiving from a closed channel always succeeds, immediately returning the element type's zero value."
+	} else {
+		queueReceive(c,gr,0);
+		return null;
+	}
+	c.wakeWaiters();
+	return {r0:ret,r1:true};
 }
-public function hasContents():Bool { // used by select
-	if (this==null) return false; // spec: "Receiving from a nil channel blocks forever."
-	if (closed) return true; // spec: "Receiving from a closed channel always succeeds..."
-	return num_entries != 0;
+// queue a goroutine that is blocked in a select, or by send() or receive() above
+public static function queueSend(c:Channel,gr:Int,idx:Int,source:Dynamic) {
+	if(c==null) return;
+	c.sendq.push({gr:gr,idx:idx,val:source});
+	Scheduler.queued(gr,c);
 }
-public function receive(zero:Dynamic):{r0:Dynamic ,r1:Bool} {
-	var ret:Dynamic=zero;
-	if (num_entries > 0) {
-		ret=entries[oldest_entry];
-		oldest_entry = (oldest_entry + 1) % max_entries;
-		num_entries--;
-		return {r0:ret,r1:true};
-	} else
-		if(closed)
-			return {r0:ret,r1:false}; // spec: "Receiving from a closed channel always succeeds, immediately returning the element type's zero value."
-		else {
-			Scheduler.panicFromHaxe( "channel receive unreachable code!"); 
-			return {r0:ret,r1:false}; //dummy value as we have hit the panic button
-		}
+public static function queueReceive(c:Channel,gr:Int,idx:Int) {
+	if(c==null) return;
+	c.recvq.push({gr:gr,idx:idx,val:null});
+	Scheduler.queued(gr,c);
+}
+// remove the goroutine from the queues, called by Scheduler.dequeue()
+public function dequeue(gr:Int) {
+	var i=sendq.length;
+	while(i>0) {
+		i--;
+		if(sendq[i].gr==gr) sendq.splice(i,1);
+	}
+	i=recvq.length;
+	while(i>0) {
+		i--;
+		if(recvq[i].gr==gr) recvq.splice(i,1);
+	}
 }
 public inline function len():Int { 
 	return num_entries; 
@@ -1587,16 +1692,38 @@ public inline function len():Int {
 public inline function cap():Int { 
 	return capa; // give back the cap we were told
 }
-public inline function close() {
-	if(this==null) Scheduler.panicFromHaxe( "attempt to close a nil channel" ); 
+public function close() {
+	if(this==null) Scheduler.panicFromHaxe( "close of nil channel" ); 
+	Scheduler.lock();
+	if(closed) Scheduler.panicFromHaxe( "close of closed channel" ); 
 	closed = true;
+	sendq = []; // the waiting senders panic when they run again, while the receivers get the zero value
+	recvq = [];
+	wakeWaiters();
+	Scheduler.unlock();
+}
+public function addWaiter(gr:Int) {
+	if(waiters==null)
+		waiters=[gr];
+	else if(waiters.indexOf(gr)==-1)
+		waiters.push(gr);
+}
+function wakeWaiters() { // any change to a channel may unblock the goroutines waiting on it
+	if(waiters!=null) {
+		var q=waiters;
+		waiters=null;
+		Scheduler.wake(q);
+	}
+}
+public inline function id():Int { // unique to this channel, used by GOmap.hash()
+	return uniqueId;
 }
 public function toString():String{
 	return "<ChanId:"+Std.string(uniqueId)+">";
 }
 }
 `)
-	pogo.WriteAsClass("Complex", `
+	l.PogoComp.WriteAsClass("Complex", `
 
 class Complex {
 	public var real:Float;
@@ -1639,7 +1766,7 @@ public static function toString(x:Complex):String {
 }
 
 `)
-	pogo.WriteAsClass("GOint64", `
+	l.PogoComp.WriteAsClass("GOint64", `
 
 // TODO optimize to use cs and java base i64 types, as with cpp below
 //#if ( cpp ) // TODO revert to native type when fixed for Haxe 3.2.0
@@ -2199,7 +2326,7 @@ class Int64 {
 //**************** END REWRITE of haxe.Int64 for php and to correct errors
 
 `)
-	pogo.WriteAsClass("StackFrameBasis", `
+	l.PogoComp.WriteAsClass("StackFrameBasis", `
 
 // GoRoutine 
 class StackFrameBasis
@@ -2406,7 +2533,7 @@ public function runDefers(){
 
 }
 `)
-	pogo.WriteAsClass("StackFrame", `
+	l.PogoComp.WriteAsClass("StackFrame", `
 
 interface StackFrame
 {
@@ -2425,18 +2552,107 @@ function run():StackFrame; // function state machine (set up by each Go function
 function res():Dynamic; // function result (set up by each Go function Haxe class)
 }
 `)
-	pogo.WriteAsClass("Scheduler", `
+	l.PogoComp.WriteAsClass("Scheduler", `
+
+#if (gothreads && !(cpp || java || cs))
+	#error "-D gothreads requires the cpp, java or cs target"
+#end
+#if (gothreads && haxe_ver < 4)
+	#error "-D gothreads requires Haxe 4 or later, for sys.thread"
+#end
 
-class Scheduler { // NOTE this code requires a single-thread, as there is no locking TODO detect deadlocks
+// NOTE by default this code runs on a single thread, so the locking compiles to nothing;
+// with -D gothreads goroutine zero runs on the main thread, and the others on runtime.GOMAXPROCS() worker threads,
+// while all of the state shared between goroutines (here, in channels, maps, semaphores and atomics) is guarded by a single runtime lock
+class Scheduler { 
 // public
 public static var doneInit:Bool=false; // flag to limit go-routines to 1 during the init() processing phase
+public static var detectDeadlock:Bool=false; // set when running a Go program, as otherwise Haxe code may wake a goroutine 
 // private
 static var grStacks:Array<List<StackFrame>>=new Array<List<StackFrame>>(); 
 static var grInPanic:Array<Bool>=new Array<Bool>();
 static var grPanicMsg:Array<Interface>=new Array<Interface>();
-static var panicStackDump:String="";
+static var grGoexit:Array<Bool>=new Array<Bool>(); // set while runtime.Goexit() runs the deferred calls of a goroutine, as if in a panic that can't be recovered
+static var mainGoexit:Bool=false; // set when runtime.Goexit() has ended goroutine zero, which runs main.main()
+// a blocked goroutine is not run again until it is woken, by a change to what it waits on, or by its wake-up time passing
+static var grWaiting:Array<String>=new Array<String>(); // what each goroutine is blocked on, or null
+static var grWaitFrame:Array<StackFrame>=new Array<StackFrame>(); // the stack frame that blocked, as Haxe may call Go code in the same goroutine
+static var grWakeAt:Array<Float>=new Array<Float>(); // when a sleeping goroutine wakes, as from haxe.Timer.stamp(), or 0
+// a goroutine blocked on channels is queued on them, and the partner that completes its send, receive or select records it here
+static var grDone:Array<{idx:Int,val:Dynamic,ok:Bool}>=new Array<{idx:Int,val:Dynamic,ok:Bool}>(); // the select case done, and any value received
+static var grQueued:Array<Array<Channel>>=new Array<Array<Channel>>(); // the channels the goroutine is queued on, or null
+static var addrWaiting:Map<String,Array<Int>>=new Map<String,Array<Int>>(); // the wait queues of semaphores and timers, by address
+static var preemptLoops:Int=0; // if non-zero, how many loop iterations a goroutine may run before it gives up control (tardisgo -preempt)
+static var preemptMillis:Int=0; // if non-zero, for how many milliseconds a goroutine may run loops before it gives up control
+static var pcFuncName:Map<Int,String>=new Map<Int,String>(); // the function, and its entry, for each program counter (position) seen by getCallerX(), guarded by the runtime lock
+static var pcFuncEntry:Map<Int,Int>=new Map<Int,Int>();
+static var panicTrace:String=""; // the frames of the goroutine when it started to panic, see goroutineTrace()
 static var entryCount:Int=0; // this to be able to monitor the re-entrys into this routine for debug
+#if gothreads
+static var runtimeLock:sys.thread.Mutex=new sys.thread.Mutex(); // the runtime lock, which may be re-acquired by the thread that holds it
+static var wakeSignal:sys.thread.Lock=new sys.thread.Lock(); // released to wake the idle threads when a goroutine may be able to run
+static var idlers:Int=0; // how many threads are waiting for wakeSignal
+static var grRunning:Array<Bool>=new Array<Bool>(); // is a worker thread running the goroutine
+static var grNew:Array<Bool>=new Array<Bool>(); // has the goroutine been made, but not yet given its first stack frame
+static var maxProcs:Int=defaultProcs(); // the number of worker threads that may run goroutines, as set by runtime.GOMAXPROCS()
+static var workers:Int=0; // how many worker threads have been started
+static var nextGR:Int=1; // where the worker threads look next for a goroutine to run, so that they take turns
+static var tlsGR:sys.thread.Tls<Null<Int>>=new sys.thread.Tls<Null<Int>>();
+static var currentGR(get,set):Int; // the current goroutine of this thread, used by Scheduler.panicFromHaxe()
+static function get_currentGR():Int {
+	var gr=tlsGR.value;
+	return gr==null ? 0 : gr;
+}
+static function set_currentGR(gr:Int):Int {
+	tlsGR.value=gr;
+	return gr;
+}
+static var tlsLoopsRun:sys.thread.Tls<Null<Int>>=new sys.thread.Tls<Null<Int>>();
+static var loopsRun(get,set):Int; // the loop iterations run since this thread's goroutine was given control
+static function get_loopsRun():Int {
+	var n=tlsLoopsRun.value;
+	return n==null ? 0 : n;
+}
+static function set_loopsRun(n:Int):Int {
+	tlsLoopsRun.value=n;
+	return n;
+}
+static var tlsRunStart:sys.thread.Tls<Null<Float>>=new sys.thread.Tls<Null<Float>>();
+static var runStart(get,set):Float; // when this thread's goroutine was given control, if preemptMillis is set
+static function get_runStart():Float {
+	var t=tlsRunStart.value;
+	return t==null ? 0 : t;
+}
+static function set_runStart(t:Float):Float {
+	tlsRunStart.value=t;
+	return t;
+}
+#else
 static var currentGR:Int=0; // the current goroutine, used by Scheduler.panicFromHaxe(), NOTE this requires a single thread
+static var loopsRun:Int=0; // the loop iterations run since the current goroutine was given control
+static var runStart:Float=0; // when the current goroutine was given control, if preemptMillis is set
+#end
+
+// the runtime lock guards the state shared between goroutines when they run on many threads (-D gothreads), 
+// it must never be held while a goroutine gives up control
+public static inline function lock() {
+	#if gothreads
+		runtimeLock.acquire();
+	#end
+}
+public static inline function unlock() {
+	#if gothreads
+		runtimeLock.release();
+	#end
+}
+// the goroutine to use when the runtime calls Go code, via callFromRT()
+public static inline function runtimeGR():Int {
+	#if gothreads
+		return currentGR;
+	#else
+		return 0;
+	#end
+}
 
 // if the scheduler is being run from a timer, this is where it comes to
 public static var runLimit:Int=0;
@@ -2491,19 +2707,37 @@ public static function runAll() { // this must be re-entrant, in order to allow
 		throw "Scheduler.runAll() entryCount exceeded - "+stackDump();
 	}
 
+#if gothreads
+	// only goroutine zero runs here, on the main thread, the worker threads run the others
+	lock();
+	var empty=grStacks[0].isEmpty();
+	var run=!empty && runnable(0);
+	unlock();
+	if(run)
+		runOne(0,entryCount);
+	else if(empty && !mainGoexit)
+		throw "Scheduler: goroutine zero has an empty stack\n"+stackDump();		
+	else
+		idle(); // until goroutine zero is woken, or if it has ended in runtime.Goexit(), until the other goroutines end
+	if(doneInit && entryCount==1 && workers==0) // as above, don't run extra goroutines until we have finished initialisation
+		setMaxProcs(0); // start the worker threads
+#else
 	// special handling for goroutine 0, which is used in the initialisation phase and re-entrantly, where only one goroutine may operate		
 	if(grStacks[0].isEmpty()) { // check if there is ever likley to be anything to do
 		if(grStacks.length<=1) { 
+			if(mainGoexit) 
+				deadlock();
 			throw "Scheduler: there is only one goroutine and its stack is empty\n"+stackDump();		
 		}
 	} else { // run goroutine zero
-		runOne(0,entryCount);
+		if(runnable(0))
+			runOne(0,entryCount);
 	}
 
 	if(doneInit  && entryCount==1 ) {	 // don't run extra goroutines when we are re-entrant or have not finished initialistion
 									     // NOTE this means that Haxe->Go->Haxe->Go code cannot run goroutines 
 		for(cg in 1...grStacks.length) { // length may grow during a run through, NOTE goroutine 0 not run again
-			if(!grStacks[cg].isEmpty()) {
+			if(!grStacks[cg].isEmpty() && runnable(cg)) {
 				runOne(cg,entryCount);
 			}
 		}
@@ -2514,9 +2748,260 @@ public static function runAll() { // this must be re-entrant, in order to allow
 			else
 				break;
 		}
+		idle();
 	}
+#end
 	entryCount--;
 }
+#if gothreads
+static function startWorker(id:Int) {
+	sys.thread.Thread.create(function() { worker(id); });
+}
+// each worker thread runs the goroutines other than zero in turn, as long as there are no more than maxProcs workers
+static function worker(id:Int) {
+	while(true) {
+		var gr:Int=-1;
+		lock();
+		if(id<maxProcs) {
+			var n=grStacks.length;
+			for(i in 1...n) {
+				var g=nextGR;
+				nextGR++;
+				if(nextGR>=n) 
+					nextGR=1;
+				if(!grRunning[g] && !grNew[g] && !grStacks[g].isEmpty() && runnable(g)) {
+					grRunning[g]=true;
+					gr=g;
+					break;
+				}
+			}
+		}
+		unlock();
+		if(gr==-1) {
+			idle();
+		} else {
+			runOne(gr,1);
+			lock();
+			grRunning[gr]=false;
+			unlock();
+		}
+	}
+}
+// wake the idle threads, called with the lock held when a goroutine may be able to run
+static function signal() {
+	for(i in 0...idlers) // any of them, including the main thread, may be waiting for it
+		wakeSignal.release();
+}
+static function defaultProcs():Int {
+	var env=Sys.getEnv("GOMAXPROCS");
+	if(env!=null) {
+		var n=Std.parseInt(env);
+		if(n!=null && n>0)
+			return n;
+	}
+	return numCPU();
+}
+#end
+// the number of logical CPUs, for runtime.NumCPU()
+public static function numCPU():Int {
+	#if (gothreads && java)
+		return untyped __java__("java.lang.Runtime.getRuntime().availableProcessors()");
+	#elseif (gothreads && cs)
+		return untyped __cs__("System.Environment.ProcessorCount");
+	#else
+		return 1;
+	#end
+}
+// for runtime.GOMAXPROCS(), sets the number of threads that may run goroutines if n>0, returning the previous setting;
+// without -D gothreads there is only ever one
+public static function setMaxProcs(n:Int):Int {
+	#if gothreads
+		lock();
+		var old=maxProcs;
+		if(n>0) 
+			maxProcs=n;
+		if(doneInit)
+			while(workers<maxProcs) {
+				startWorker(workers);
+				workers++;
+			}
+		signal(); // so that any extra workers can stop
+		unlock();
+		return old;
+	#else
+		return 1;
+	#end
+}
+static function runnable(gr:Int):Bool {
+	if(grWaiting[gr]==null || grWaitFrame[gr]!=grStacks[gr].first()) 
+		return true;
+	return grWakeAt[gr]>0 && haxe.Timer.stamp()>=grWakeAt[gr];
+}
+// if no goroutine can run, wait for the first to wake up, or if none are sleeping none of them can ever run again
+static function idle() {
+#if gothreads
+	// the thread calling this has nothing to run, so it waits for a wake-up, but only for a while, in case it missed one
+	lock();
+	var next:Float=0;
+	var active=false;
+	for(gr in 0...grStacks.length) {
+		if(grRunning[gr] || grNew[gr]) {
+			active=true;
+		} else if(!grStacks[gr].isEmpty()) {
+			if(runnable(gr))
+				active=true;
+			else if(grWakeAt[gr]>0 && (next==0 || grWakeAt[gr]<next))
+				next=grWakeAt[gr];
+		}
+	}
+	if(!active && next==0 && detectDeadlock)
+		deadlock();
+	idlers++;
+	unlock();
+	var secs=0.01;
+	if(next>0 && next-haxe.Timer.stamp()<secs) 
+		secs=next-haxe.Timer.stamp();
+	if(secs>0)
+		wakeSignal.wait(secs);
+	lock();
+	idlers--;
+	unlock();
+#else
+	var next:Float=0; 
+	for(gr in 0...grStacks.length)
+		if(!grStacks[gr].isEmpty()) {
+			if(runnable(gr))
+				return;
+			if(grWakeAt[gr]>0 && (next==0 || grWakeAt[gr]<next))
+				next=grWakeAt[gr];
+		}
+	if(next==0) {
+		if(detectDeadlock)
+			deadlock();
+		return;
+	}
+	#if ( cpp || cs || java || neko || php || python )
+		var secs=next-haxe.Timer.stamp();
+		if(secs>0) 
+			Sys.sleep(secs);
+	#end // otherwise run the Scheduler again, or if it is run by timerEventHandler() there is nothing to do until then
+#end
+}
+static function deadlock() {
+	var dump="";
+	for(gr in 0...grStacks.length)
+		if(!grStacks[gr].isEmpty()) {
+			dump += "\ngoroutine "+gr+" ["+grWaiting[gr]+"]:\n";
+			for(ent in grStacks[gr]) 
+				dump += frameDump(ent);
+		}
+	if(dump=="" && mainGoexit)
+		dump="fatal error: no goroutines (main called runtime.Goexit) - deadlock!\n";
+	else
+		dump="fatal error: all goroutines are asleep - deadlock!\n"+dump;
+	Console.naclWrite(dump);
+	Console.exit(2);
+	throw "Go deadlock"; // only reached on targets that cannot exit
+}
+// called by a goroutine just before it gives up control because it is blocked, why is the wait reason shown by Go, e.g. "chan send",
+// and on is what will wake it: a Channel, an Array of them for a select, the Pointer to a semaphore, or null to block forever
+public static function wait(gr:Int,why:String,on:Dynamic) {
+	lock();
+	grWaiting[gr]=why;
+	grWaitFrame[gr]=grStacks[gr].first();
+	grWakeAt[gr]=0;
+	if(Std.is(on,Channel)) 
+		cast(on,Channel).addWaiter(gr);
+	else if(Std.is(on,Array)) {
+		var chans:Array<Channel>=on;
+		for(c in chans)
+			if(c!=null) 
+				c.addWaiter(gr);
+	} else if(Std.is(on,Pointer))
+		addWaiter(cast(on,Pointer).toUniqueVal(),gr);
+	unlock();
+}
+// called by a goroutine just before it gives up control to sleep until the time given by haxe.Timer.stamp(), 
+// unless woken earlier by wakeAddr(on)
+public static function sleep(gr:Int,until:Float,on:Pointer) {
+	lock();
+	wait(gr,"sleep",on);
+	grWakeAt[gr]=until;
+	unlock();
+}
+static function addWaiter(addr:String,gr:Int) {
+	var q=addrWaiting.get(addr);
+	if(q==null)
+		addrWaiting.set(addr,[gr]);
+	else if(q.indexOf(gr)==-1)
+		q.push(gr);
+}
+// wake the goroutines waiting on the given address, called when a semaphore is released or a timer stopped
+public static function wakeAddr(on:Pointer) {
+	var addr=on.toUniqueVal();
+	lock();
+	var q=addrWaiting.get(addr);
+	if(q!=null) {
+		addrWaiting.remove(addr);
+		wake(q);
+	}
+	unlock();
+}
+// wake the goroutines in a wait queue, they may find that they are still blocked, and wait again
+public static function wake(q:Array<Int>) {
+	lock();
+	for(gr in q) 
+		if(gr<grWaiting.length)
+			grWaiting[gr]=null;
+	#if gothreads
+		signal();
+	#end
+	unlock();
+}
+// called by Channel when it has completed the operation of a queued goroutine, idx is the select case, or 0,
+// val and ok what it received, then the goroutine is taken off all the channels it is queued on, and woken
+public static function complete(gr:Int,idx:Int,val:Dynamic,ok:Bool) {
+	dequeue(gr);
+	grDone[gr]={idx:idx,val:val,ok:ok};
+	wake([gr]);
+}
+// return, and forget, the operation completed while the goroutine was blocked, or null if there was none
+public static function takeDone(gr:Int):{idx:Int,val:Dynamic,ok:Bool} {
+	var d=grDone[gr];
+	if(d!=null) 
+		grDone[gr]=null;
+	return d;
+}
+// record that the goroutine is queued on a channel
+public static function queued(gr:Int,c:Channel) {
+	if(grQueued[gr]==null)
+		grQueued[gr]=[c];
+	else
+		grQueued[gr].push(c);
+}
+// take the goroutine off all the channels it is queued on
+public static function dequeue(gr:Int) {
+	var q=grQueued[gr];
+	if(q!=null) {
+		grQueued[gr]=null;
+		for(c in q)
+			c.dequeue(gr);
+	}
+}
+public static function setPreempt(loops:Int,millis:Int) {
+	preemptLoops=loops;
+	preemptMillis=millis;
+}
+// called at the end of each loop iteration in goroutine-capable functions, if set up by setPreempt(), 
+// it returns true when the goroutine has used up its budget and should give up control
+public static function preempt():Bool {
+	loopsRun++;
+	if(preemptLoops>0 && loopsRun>=preemptLoops) 
+		return true;
+	if(preemptMillis>0 && (loopsRun&63)==0 && (haxe.Timer.stamp()-runStart)*1000>=preemptMillis) // only look at the clock every 64 iterations
+		return true;
+	return false;
+}
 static inline function runOne(gr:Int,entryCount:Int){ // called from above to call individual goroutines TODO: Review for multi-threading
 	if(grInPanic[gr]) {
 		if(entryCount!=1) { // we are in re-entrant code, so we can't panic again, as this may be part of the panic handling...
@@ -2524,9 +3009,16 @@ static inline function runOne(gr:Int,entryCount:Int){ // called from above to ca
 				run1(gr);
 		} else {
 			while(grInPanic[gr]){
-				if(grStacks[gr].isEmpty()){
-					 Console.naclWrite("Panic in goroutine "+gr+"\n"+panicStackDump); // use stored stack dump
-					 throw "Go panic";
+				if(grStacks[gr].isEmpty() && grGoexit[gr]){ // runtime.Goexit() has run all the deferred calls, so the goroutine ends
+					lock();
+					grInPanic[gr]=false;
+					grGoexit[gr]=false;
+					if(gr==0) 
+						mainGoexit=true;
+					unlock();
+				} else if(grStacks[gr].isEmpty()){
+					 exitPanic(gr,Force.toHaxeString(Go_haxegoruntime_PPanicMMessage.callFromRT(gr,grPanicMsg[gr])));
+					 throw "Go panic"; // only reached on targets that cannot exit
 				} else {
 					var sf:StackFrame=grStacks[gr].pop();
 					while(!sf._deferStack.isEmpty() && grInPanic[gr]) { 
@@ -2537,7 +3029,10 @@ static inline function runOne(gr:Int,entryCount:Int){ // called from above to ca
 						//trace("DEBUG runOne panic defer:",def._functionName);
 						Scheduler.push(gr,def);
 						while(def._incomplete) 
-							runAll(); // with entryCount >1, so run as above 
+							if(gr==0)
+								runAll(); // with entryCount >1, so run as above 
+							else
+								run1(gr); // as runAll() only runs goroutine zero when re-entrant
 					}
 					if(!grInPanic[gr]){
 					 	//trace("DEBUG runOne panic - recovered");
@@ -2559,23 +3054,43 @@ public static inline function run1(gr:Int){ // used by callFromRT() for every go
 			throw "Panic:"+grPanicMsg+"\nScheduler: null stack entry for goroutine "+gr+"\n"+stackDump();
 		} else {
 			currentGR=gr;
+			lock();
+			grWaiting[gr]=null;
+			unlock();
+			loopsRun=0;
+			if(preemptMillis>0) runStart=haxe.Timer.stamp();
 			grStacks[gr].first().run(); // run() may call haxe which calls these routines recursively 
 		}	
 }
 public static function makeGoroutine():Int {
+	lock();
 	for (r in 1 ... grStacks.length) // goroutine zero is reserved for init activities, main.main() and Haxe call-backs
-		if(grStacks[r].isEmpty())
+		if(grStacks[r].isEmpty() #if gothreads && !grRunning[r] && !grNew[r] #end)
 		{
-			grInPanic[r]=false;
-			grPanicMsg[r]=null;
+			resetGoroutine(r);
+			unlock();
 			return r;	// reuse a previous goroutine number if possible
 		}
 	var l:Int=grStacks.length;
 	grStacks[l]=new List<StackFrame>();
-	grInPanic[l]=false;
-	grPanicMsg[l]=null;
+	resetGoroutine(l);
+	unlock();
 	return l;
 }
+static function resetGoroutine(r:Int) {
+	grInPanic[r]=false;
+	grPanicMsg[r]=null;
+	grGoexit[r]=false;
+	grWaiting[r]=null;
+	grWaitFrame[r]=null;
+	grWakeAt[r]=0;
+	grDone[r]=null;
+	grQueued[r]=null;
+	#if gothreads
+		grRunning[r]=false;
+		grNew[r]=(r!=0); // until push() gives it a stack frame, so that it is neither reused nor overlooked by idle()
+	#end
+}
 public static function pop(gr:Int):StackFrame {
 	if(gr>=grStacks.length||gr<0)
 		throw "Scheduler.pop() invalid goroutine";
@@ -2585,6 +3100,14 @@ public static function push(gr:Int,sf:StackFrame){
 	if(gr>=grStacks.length||gr<0)
 		throw "Scheduler.push() invalid goroutine";
 	grStacks[gr].push(sf);
+	#if gothreads
+		if(grNew[gr]) {
+			lock();
+			grNew[gr]=false;
+			signal();
+			unlock();
+		}
+	#end
 }
 public static inline function NumGoroutine():Int {
 	return grStacks.length;
@@ -2605,24 +3128,34 @@ public static function stackDump():String {
 			ret += "Stack has " +grStacks[gr].length+ " entries:\n";
 			var it=grStacks[gr].iterator();
 			while(it.hasNext()) {
-				var ent=it.next();
-				if(ent==null) {
-					ret += "\tStack entry is null\n";
-				} else {
-					ret += "\t"+ent._functionName+" starting at "+Go.CPos(ent._functionPH);
-					ret += " latest position "+Go.CPos(ent._latestPH);
-					ret += " latest block "+ent._latestBlock+"\n";
-					if(ent._debugVars!=null){
-						for(k in ent._debugVars.keys()) {
-							if(k.indexOf(".")==-1){ // not a global assignment, so showing only locals
-								var t:Dynamic=ent._debugVars.get(k);
-								if(t==null) t="nil";
-								if(Std.is(t,Pointer)) t=t.toUniqueVal();
-								ret += "\t\tvar "+k+" = "+t+"\n";
-							}
-						}
-					}
-				}
+				ret += frameDump(it.next());
+			}
+		}
+	}
+	return ret;
+}
+// the frames of a goroutine, innermost first, each as a line giving the function then a line giving its file and line, as in a Go panic
+// the line is the latest reached in -debug mode, otherwise that of the start of the function
+static function goroutineTrace(gr:Int):String {
+	var ret = "";
+	for(ent in grStacks[gr])
+		if(ent!=null)
+			ret += ent._functionName+"(...)\n\t"+Go.CPos(ent._latestPH!=0 ? ent._latestPH : ent._functionPH)+"\n";
+	return ret;
+}
+static function frameDump(ent:StackFrame):String {
+	if(ent==null) 
+		return "\tStack entry is null\n";
+	var ret = "\t"+ent._functionName+" starting at "+Go.CPos(ent._functionPH);
+	ret += " latest position "+Go.CPos(ent._latestPH);
+	ret += " latest block "+ent._latestBlock+"\n";
+	if(ent._debugVars!=null){
+		for(k in ent._debugVars.keys()) {
+			if(k.indexOf(".")==-1){ // not a global assignment, so showing only locals
+				var t:Dynamic=ent._debugVars.get(k);
+				if(t==null) t="nil";
+				if(Std.is(t,Pointer)) t=t.toUniqueVal();
+				ret += "\t\tvar "+k+" = "+t+"\n";
 			}
 		}
 	}
@@ -2637,6 +3170,26 @@ public static function getNumCallers(gr:Int):Int {
 	}
 }
 
+// the program counters of up to max frames of the current goroutine, innermost first, for MemProfile.sample()
+public static function currentCallers(max:Int):Array<Int> {
+	var ret=new Array<Int>();
+	var gr=currentGR;
+	if(gr<0 || gr>=grStacks.length || grStacks[gr]==null) 
+		return ret;
+	for(ent in grStacks[gr]) {
+		if(ret.length>=max) 
+			break;
+		if(ent!=null) {
+			lock();
+			pcFuncName.set(ent._latestPH,ent._functionName);
+			pcFuncEntry.set(ent._latestPH,ent._functionPH);
+			unlock();
+			ret.push(ent._latestPH);
+		}
+	}
+	return ret;
+}
+
 public static function getCallerX(gr:Int,x:Int):Int {
 	if(grStacks[gr].isEmpty()) {
 		return 0; // error
@@ -2648,6 +3201,10 @@ public static function getCallerX(gr:Int,x:Int):Int {
 				if(ent==null) {
 					return 0; // this is an error 
 				} else {
+					lock();
+					pcFuncName.set(ent._latestPH,ent._functionName);
+					pcFuncEntry.set(ent._latestPH,ent._functionPH);
+					unlock();
 					return ent._latestPH;
 				}
 			}
@@ -2657,6 +3214,20 @@ public static function getCallerX(gr:Int,x:Int):Int {
 	return 0; // error
 }
 
+// the name of the function containing a program counter returned by getCallerX(), or "" if unknown, for runtime.FuncForPC()
+public static function funcName(pc:Int):String {
+	lock();
+	var name=pcFuncName.get(pc);
+	unlock();
+	return name==null ? "" : name;
+}
+public static function funcEntry(pc:Int):Int {
+	lock();
+	var entry=pcFuncEntry.get(pc);
+	unlock();
+	return entry==null ? 0 : entry;
+}
+
 public static function traceStackDump() {trace(stackDump());}
 
 public static function panic(gr:Int,err:Interface){
@@ -2665,9 +3236,11 @@ public static function panic(gr:Int,err:Interface){
 	if(grInPanic[gr]) { // if we are already in a panic, not much we can do...
 		//trace("Scheduler.panic() panic within panic for goroutine "+Std.string(gr)+" message: "+err.toString());		
 	}else{
+		lock();
 		grInPanic[gr]=true;
 		grPanicMsg[gr]=err;
-		panicStackDump=stackDump();
+		panicTrace=goroutineTrace(gr);
+		unlock();
 		#if godebug
 			trace("GODEBUG: panic in goroutine "+Std.string(gr)+" message: "+err.toString());
 			var top = grStacks[gr].first();
@@ -2679,7 +3252,7 @@ public static function panic(gr:Int,err:Interface){
 public static function recover(gr:Int):Interface{
 	if(gr>=grStacks.length||gr<0)
 		throw "Scheduler.recover() invalid goroutine";
-	if(grInPanic[gr]==false)
+	if(grInPanic[gr]==false || grGoexit[gr]) // runtime.Goexit() can't be recovered
 		return null;
 	#if godebug
 		trace("GODEBUG: recover in goroutine "+Std.string(gr)+" message: "+grPanicMsg[gr]);
@@ -2687,19 +3260,38 @@ public static function recover(gr:Int):Interface{
 		if(top!=null)
 			cast(top,StackFrameBasis).breakpoint();
 	#end
+	lock();
 	grInPanic[gr]=false;
 	var t = grPanicMsg[gr];
 	grPanicMsg[gr]=null;
+	unlock();
 	return t;
 }
+// an unrecovered panic prints the panic message and the frames of the goroutine when it started, then exits with status 2, as in Go
+static function exitPanic(gr:Int,msg:String) {
+	Console.naclWrite("panic: "+msg+"\n\ngoroutine "+gr+" [running]:\n"+panicTrace);
+	Console.exit(2);
+}
+// called by runtime.Goexit(), which then gives up control so that runOne() runs the deferred calls of the goroutine and ends it
+public static function goexit(gr:Int) {
+	if(gr>=grStacks.length||gr<0)
+		throw "Scheduler.goexit() invalid goroutine";
+	lock();
+	grInPanic[gr]=true;
+	grPanicMsg[gr]=null;
+	grGoexit[gr]=true;
+	unlock();
+}
 public static function panicFromHaxe(err:String) { 
-	if(currentGR>=grStacks.length||currentGR<0) 
+	var gr=currentGR;
+	if(gr>=grStacks.length||gr<0) {
 		// if current goroutine is -ve, or out of range, always panics in goroutine 0
-		panic(0,new Interface(TypeInfo.getId("string"),"Runtime panic, unknown goroutine, "+err+" "));
-	else
-		panic(currentGR,new Interface(TypeInfo.getId("string"),"Runtime panic, "+err+" "));
-	Console.naclWrite(panicStackDump); 
-	throw "Haxe panic"; // NOTE can't be recovered!
+		gr=0;
+		panic(gr,new Interface(TypeInfo.getId("string"),"Runtime panic, unknown goroutine, "+err+" "));
+	} else
+		panic(gr,new Interface(TypeInfo.getId("string"),"Runtime panic, "+err+" "));
+	exitPanic(gr,"runtime error: "+err);
+	throw "Haxe panic"; // NOTE can't be recovered! Only reached on targets that cannot exit
 }
 public static function bbi() {
 	panicFromHaxe("bad block ID (internal phi error)");
@@ -2727,125 +3319,267 @@ public static inline function wrapnilchk(p:Pointer):Pointer {
 }
 }
 `)
-	pogo.WriteAsClass("GOmap", `
+	l.PogoComp.WriteAsClass("GOmap", `
 
 class GOmap {
-	// TODO a more sophisticated (and hopefully faster) version of this code 
-	// TODO in Haxe, the keys can be Int, String or "object" (by reference)
-	// TODO there is also a very sophisticated go implementation in runtime
+	// the entries are held in the Haxe map that suits the type of key: an IntMap for the Go integer types held as a Haxe Int, 
+	// a StringMap for strings, and otherwise an IntMap of buckets, found by hash() and searched using keyEqual(),
+	// where a key that is (or holds) a NaN is never equal to another, so each one set is a new entry, as in Go;
+	// the entries are also kept in the order they were added, so that range() works the same way on every target
+	public static inline var KeyOther:Int=0; // the kinds of key, given by the compiler when the map is made
+	public static inline var KeyInt:Int=1;
+	public static inline var KeyString:Int=2;
+	public static inline var KeyFloat:Int=3;
 
-	public var baseMap:Map<String,{key:Dynamic,val:Dynamic}>;
 	public var kz:Dynamic;
 	public var vz:Dynamic;
+	var kind:Int;
+	var intMap:haxe.ds.IntMap<GOmapEntry>;
+	var strMap:haxe.ds.StringMap<GOmapEntry>;
+	var hashMap:haxe.ds.IntMap<Array<GOmapEntry>>;
+	var order:Array<GOmapEntry>; // the entries in the order they were added, including those with a NaN key, which can only be found by range()
+	var removed:Int=0; // how many entries in order have been removed from the map
+	var count:Int=0; // so that len() does not have to count the entries
+	#if gothreads
+		var mu:sys.thread.Mutex=new sys.thread.Mutex(); // so that the Haxe map is not corrupted when it is used from many threads
+	#end
 
-	public function new (kDef:Dynamic,vDef:Dynamic) {
+	public inline function lock() {
+		#if gothreads
+			mu.acquire();
+		#end
+	}
+	public inline function unlock() {
+		#if gothreads
+			mu.release();
+		#end
+	}
+
+	public function new (kDef:Dynamic,vDef:Dynamic,kindOfKey:Int=KeyOther) {
 		//trace("DEBUG new",kDef,vDef);
-		baseMap = new Map<String,{key:Dynamic,val:Dynamic}>();
 		kz = kDef;
 		vz = vDef;
+		kind = kindOfKey;
+		order = new Array<GOmapEntry>();
+		switch(kind) {
+		case KeyInt:
+			intMap = new haxe.ds.IntMap<GOmapEntry>();
+		case KeyString:
+			strMap = new haxe.ds.StringMap<GOmapEntry>();
+		default:
+			hashMap = new haxe.ds.IntMap<Array<GOmapEntry>>();
+		}
+	}
+
+	// hash gives the same value for keys that are equal, as tested by keyEqual()
+	public static function hash(a:Dynamic):Int {
+		if(a==null) return 0;
+		if(Std.is(a,Int)) return a;
+		if(Std.is(a,Float)) return floatHash(a);
+		if(Std.is(a,Bool)) return a ? 1 : 0;
+		if(Std.is(a,String)) {
+			var s:String=a;
+			var h=s.length;
+			for(i in 0...s.length) 
+				h = (h*31 + s.charCodeAt(i)) | 0;
+			return h;
+		}
+		if(Std.is(a,Pointer)) return cast(a,Pointer).hashInt();
+		if(Std.is(a,Interface)) return hash(cast(a,Interface).val); // the type is ignored, as equal values of different types are rare
+		if(Std.is(a,Complex)) return (floatHash(a.real)*31 + floatHash(a.imag)) | 0;
+		if(Std.is(a,Channel)) return cast(a,Channel).id();
+		if(Std.is(a,Object)) {
+			var o:Object=a;
+			var h=o.length;
+			for(i in 0...o.length) {
+				if(i&3==0) 
+					h = (h*31 + hash(o.get(i))) | 0; // the part of the value not held as bytes
+				h = (h*31 + o.get_uint8(i)) | 0;
+			}
+			return h;
+		}
+		if(Std.is(a,GOmap)||Std.is(a,Closure)||Std.is(a,Slice)) {
+			Scheduler.panicFromHaxe("hash of unhashable type");
+			return 0;
+		}
+		// assume GOint64 - Std.is() does not work for abstract types
+		return GOint64.getLow(a) ^ GOint64.getHigh(a);
+	}
+	static function floatHash(f:Float):Int {
+		if(f==0) return 0; // +0 and -0 are equal
+		if(f>=-2147483648.0 && f<=2147483647.0 && Math.ffloor(f)==f) return Std.int(f); // the same as the Int, for targets where they are the same type
+		var i=haxe.io.FPHelper.doubleToI64(f);
+		return i.high ^ i.low;
+	}
+	static function keyEqual(a:Dynamic,b:Dynamic):Bool {
+		if(Std.is(a,Channel)||Std.is(b,Channel)) return a==b;
+		return Force.isEqualDynamic(a,b);
+	}
+	static function hasNaN(a:Dynamic):Bool {
+		if(a==null) return false;
+		if(Std.is(a,Float)) return Math.isNaN(a);
+		if(Std.is(a,Complex)) return Math.isNaN(a.real) || Math.isNaN(a.imag);
+		if(Std.is(a,Interface)) return hasNaN(cast(a,Interface).val);
+		return false;
 	}
 
-	public static function makeKey(a:Dynamic):String{
-		//trace("DEBUG makeKey",a);
-		if(a==null) return "<<<<NULL>>>>"; // TODO how can this be more unique?
-		if(Reflect.isObject(a)){
-			if(Std.is(a,String))
-				return a;
-			if(Std.is(a,Pointer))
-				return cast(a,Pointer).toUniqueVal();    
-			if(Std.is(a,Object)){
-				//trace("DEBUG makeKey Object found");
-				var r=cast(a,Object).toString(); 
-				//trace("DEBUG makeKey Object="+r);
-				return r;
-			}
-			if(Std.is(a,Complex)){
-				return Complex.toString(a);
-			}
-			if(Std.is(a,Interface))
-				return cast(a,Interface).toString();
-			if(Std.is(a,Slice)) 
-				return a.toString();
-			if(Std.is(a,Channel))
-				return a.toString();
-			if(Std.is(a,GOmap)||Std.is(a,Closure)) {
-				Scheduler.panicFromHaxe("haxeruntime.GOmap.makeKey() unsupported haxe type: "+a);
-				return "";
-			}
-			return a.toString(); // must be an Int64
+	function find(rKey:Dynamic):GOmapEntry {
+		switch(kind) {
+		case KeyInt:
+			return intMap.get(rKey);
+		case KeyString:
+			return strMap.get(rKey);
+		default:
+			if(hasNaN(rKey)) return null; // never equal to any key
+			var b=hashMap.get(hash(rKey));
+			if(b!=null) 
+				for(e in b) 
+					if(keyEqual(e.key,rKey)) 
+						return e;
+			return null;
 		}
-		return Std.string(a);
 	}
 
 	public function set(realKey:Dynamic,value:Dynamic){
-		var sKey = makeKey(realKey);
-		//trace("DEBUG set",sKey,realKey);
-		if(baseMap.exists(sKey)){
-			if(!Force.isEqualDynamic(baseMap.get(sKey).key,realKey))
-				Scheduler.panicFromHaxe("haxeruntime.GOmap non-unique key for: "+sKey);
+		lock();
+		var e = find(realKey);
+		if(e!=null) {
+			e.val = value;
+		} else {
+			e = new GOmapEntry(realKey,value);
+			switch(kind) {
+			case KeyInt:
+				intMap.set(realKey,e);
+			case KeyString:
+				strMap.set(realKey,e);
+			default:
+				if(!hasNaN(realKey)) {
+					var h=hash(realKey);
+					var b=hashMap.get(h);
+					if(b==null) 
+						hashMap.set(h,[e]);
+					else 
+						b.push(e);
+				}
+			}
+			order.push(e);
+			count++;
 		}
-		baseMap.set(sKey,{key:realKey,val:value});
+		unlock();
 	}
 
 	public function get(rKey:Dynamic):Dynamic {
-		var sKey = makeKey(rKey);
-		//trace("DEBUG get",sKey,rKey);		
-		if(baseMap.exists(sKey))	return baseMap.get(sKey).val;
-		else 						return vz; // the zero value
+		lock();
+		var e = find(rKey);
+		unlock();
+		if(e!=null)	return e.val;
+		else 		return vz; // the zero value
 	}
 
 	public function exists(rKey:Dynamic):Bool {
-		var sKey = makeKey(rKey);
-		//trace("DEBUG exists",sKey,rKey);		
-		return baseMap.exists(sKey);
+		lock();
+		var ret = find(rKey)!=null;
+		unlock();
+		return ret;
 	}
 
 	public function remove(r:Dynamic){
-		var s = makeKey(r);
-		//trace("DEBUG remove",s,r);		
-		baseMap.remove(s);
+		lock();
+		var e = find(r);
+		if(e!=null) {
+			switch(kind) {
+			case KeyInt:
+				intMap.remove(r);
+			case KeyString:
+				strMap.remove(r);
+			default:
+				var h=hash(r);
+				var b=hashMap.get(h);
+				b.remove(e);
+				if(b.length==0) 
+					hashMap.remove(h);
+			}
+			e.live = false; // so that range() does not return it
+			count--;
+			removed++;
+			if(removed>16 && removed>count) { // compact into a new Array, as a GOmapRange may still be using the old one
+				var live = new Array<GOmapEntry>();
+				for(e in order) 
+					if(e.live) 
+						live.push(e);
+				order = live;
+				removed = 0;
+			}
+		}
+		unlock();
 	}
 
-	public function len():Int {
-		var _l:Int=0;
-		var _it=baseMap.iterator();
-		while(_it.hasNext()) {_l++; _it.next();};
-		//trace("DEBUG len",_l);		
-		return _l;
+	public inline function len():Int {
+		return count;
 	}
 
 	public function range():GOmapRange {
-		return new GOmapRange(baseMap.keys(),this);
+		lock();
+		var r = new GOmapRange(order,order.length,this);
+		unlock();
+		return r;
 	}
 
 }
 `)
-	pogo.WriteAsClass("GOmapRange", `
+	l.PogoComp.WriteAsClass("GOmapEntry", `
 
+class GOmapEntry {
+	public var key:Dynamic;
+	public var val:Dynamic;
+	public var live:Bool=true; // false once it has been removed from its map
+
+	public function new(k:Dynamic,v:Dynamic) {
+		key=k;
+		val=v;
+	}
+}
+`)
+	l.PogoComp.WriteAsClass("GOmapRange", `
+
+// As in Go, the entries of a map are returned starting from a random one, so that code does not come to depend on their order;
+// the Haxe flag -D gomaporder returns them in the order they were added instead, for repeatable tests.
+// Entries removed before they are reached are not returned, and those added after the range starts are never returned, which Go allows.
 class GOmapRange {
-	private var k:Iterator<String>;
+	private var ents:Array<GOmapEntry>; // the entries of the map in the order they were added, only ever appended to while in use 
+	private var num:Int; // how many there were when the range started
+	private var start:Int;
+	private var pos:Int=0;
 	private var m:GOmap;
 
-	public function new(kv:Iterator<String>, mv:GOmap){
-		k=kv;
+	public function new(ev:Array<GOmapEntry>, nv:Int, mv:GOmap){
+		ents=ev;
+		num=nv;
+		#if gomaporder
+			start=0;
+		#else
+			start=(num>0) ? Std.random(num) : 0;
+		#end
 		m=mv;
 	}
 
 	public function next():{r0:Bool,r1:Dynamic,r2:Dynamic} {
-		var _hn:Bool=k.hasNext();
-		if(_hn){
-			var _nxt=k.next();
-			if(m.baseMap.exists(_nxt))
-				return {r0:true,r1:m.baseMap.get(_nxt).key,r2:m.baseMap.get(_nxt).val};
-			else
-				return next(); // recurse if this key is not found (deleted in-between?)
-		}else{
-			return {r0:false,r1:m.kz,r2:m.vz};
+		m.lock();
+		var r:{r0:Bool,r1:Dynamic,r2:Dynamic} = {r0:false,r1:m.kz,r2:m.vz};
+		while(pos<num){
+			var ent=ents[(start+pos)%num];
+			pos++;
+			if(ent.live){ // skip the entries removed in-between
+				r = {r0:true,r1:ent.key,r2:ent.val};
+				break;
+			}
 		}
+		m.unlock();
+		return r;
 	}
 }
 `)
-	pogo.WriteAsClass("GOstringRange", `
+	l.PogoComp.WriteAsClass("GOstringRange", `
 
 class GOstringRange {
 	private var g:Int;
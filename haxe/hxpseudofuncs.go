@@ -5,13 +5,12 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/tardisgo/tardisgo/pogo"
 	"golang.org/x/tools/go/ssa"
 )
 
 const pseudoFnPrefix = "github_dot_com_47_tardisgo_47_tardisgo_47_haxe_47_hx_"
 
-func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo string) string {
+func (l *langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo string) string {
 	//fmt.Println("DEBUG l.hxPseudoFuncs()", fnToCall, args, errorInfo)
 	fnToCall = strings.TrimPrefix(fnToCall, pseudoFnPrefix)
 
@@ -45,11 +44,11 @@ func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo str
 				}
 				con, ok := (*(goMI.Operands(nil)[0])).(*ssa.Const)
 				if ok {
-					return "new Interface(-1," + tgoString(l.IndirectValue(con, errorInfo), errorInfo) + ");"
+					return "new Interface(-1," + l.tgoString(l.IndirectValue(con, errorInfo), errorInfo) + ");"
 				}
 			}
 		}
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("hx.Func() argument is not a function constant"))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("hx.Func() argument is not a function constant"))
 		return ""
 	}
 
@@ -60,7 +59,7 @@ func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo str
 
 	ifLogic := l.IndirectValue(args[0], errorInfo)
 	//fmt.Println("DEBUG:ifLogic=", ifLogic, "AT", errorInfo)
-	ifLogic = tgoString(ifLogic, errorInfo)
+	ifLogic = l.tgoString(ifLogic, errorInfo)
 	if len(ifLogic) > 0 {
 		wrapStart = " #if (" + ifLogic + ") "
 		defVal := "null"
@@ -98,7 +97,7 @@ func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo str
 		strings.HasPrefix(fnToCall, "MMeth") || strings.HasPrefix(fnToCall, "NNew") {
 		argOff++
 		if strings.HasPrefix(fnToCall, "MMeth") {
-			haxeType := tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo)
+			haxeType := l.tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo)
 			if len(haxeType) > 0 {
 				code = "cast(" + code + "," + haxeType + ")"
 			}
@@ -137,17 +136,17 @@ func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo str
 	if strings.HasPrefix(fnToCall, "FFget") {
 		argOff++
 		if l.IndirectValue(args[argOff], errorInfo) != `""` {
-			code = "cast(" + code + "," + tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo) + ")"
+			code = "cast(" + code + "," + l.tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo) + ")"
 		}
-		code += "." + tgoString(l.IndirectValue(args[argOff+1], errorInfo), errorInfo) + "; "
+		code += "." + l.tgoString(l.IndirectValue(args[argOff+1], errorInfo), errorInfo) + "; "
 		usesArgs = false
 	}
 	if strings.HasPrefix(fnToCall, "FFset") {
 		argOff++
 		if l.IndirectValue(args[argOff], errorInfo) != `""` {
-			code = "cast(" + code + "," + tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo) + ")"
+			code = "cast(" + code + "," + l.tgoString(l.IndirectValue(args[argOff], errorInfo), errorInfo) + ")"
 		}
-		code += "." + tgoString(l.IndirectValue(args[argOff+1], errorInfo), errorInfo) +
+		code += "." + l.tgoString(l.IndirectValue(args[argOff+1], errorInfo), errorInfo) +
 			"=Force.toHaxeParam(" + l.IndirectValue(args[argOff+2], errorInfo) + "); "
 		usesArgs = false
 	}
@@ -159,10 +158,10 @@ func (l langType) hxPseudoFuncs(fnToCall string, args []ssa.Value, errorInfo str
 	return ret + wrapStart + code + wrapEnd + " }"
 }
 
-func tgoString(s, errorInfo string) string {
+func (l *langType) tgoString(s, errorInfo string) string {
 	bits := strings.Split(s, `"`)
 	if len(bits) < 2 {
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("hx.() argument is not a usable string constant"))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("hx.() argument is not a usable string constant"))
 		return ""
 	}
 	return bits[1]
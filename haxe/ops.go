@@ -7,12 +7,11 @@ package haxe
 import (
 	"fmt"
 
-	"github.com/tardisgo/tardisgo/pogo"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/types"
 )
 
-func (l langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK bool, errorInfo string) string {
+func (l *langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK bool, errorInfo string) string {
 	useInt64 := false
 	lt := l.LangType(v.(ssa.Value).Type().Underlying(), false, errorInfo)
 	if lt == "GOint64" {
@@ -20,7 +19,7 @@ func (l langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK
 	}
 	rt := l.LangType(regTyp.Underlying(), false, errorInfo)
 	if lt != rt && op != "<-" && op != "*" {
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): result type %s != source type %s", rt, lt))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): result type %s != source type %s", rt, lt))
 	}
 
 	// neko target platform requires special handling because in makes whole-number Float into Int without asking
@@ -28,7 +27,7 @@ func (l langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK
 
 	switch op {
 	case "<-":
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): impossible to reach <- code"))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): impossible to reach <- code"))
 		return ""
 	case "*":
 		goTyp := v.(ssa.Value).Type().Underlying().(*types.Pointer).Elem().Underlying()
@@ -60,7 +59,7 @@ func (l langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK
 				return l.intTypeCoersion(v.(ssa.Value).Type().Underlying(),
 					"GOint64.xor("+l.IndirectValue(v, errorInfo)+",GOint64.make(-1,-1))", errorInfo)
 			default:
-				pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): unhandled Int64 un-op: %s", op))
+				l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeUnOp(): unhandled Int64 un-op: %s", op))
 				return ""
 			}
 		} else {
@@ -89,26 +88,26 @@ func (l langType) codeUnOp(regTyp types.Type, op string, v interface{}, CommaOK
 	}
 }
 
-func (l langType) UnOp(register string, regTyp types.Type, op string, v interface{}, CommaOK bool, errorInfo string) string {
+func (l *langType) UnOp(register string, regTyp types.Type, op string, v interface{}, CommaOK bool, errorInfo string) string {
 	if op == "<-" { // wait for a channel to be ready
 		return l.Select(false, register, v, CommaOK, errorInfo)
 	}
 	return register + "=" + l.codeUnOp(regTyp, op, v, CommaOK, errorInfo) + ";"
 }
 
-func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, errorInfo string) string {
+func (l *langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, errorInfo string) string {
 	ret := ""
 	useInt64 := false
 	v1LangType := l.LangType(v1.(ssa.Value).Type().Underlying(), false, errorInfo)
 	v2LangType := l.LangType(v2.(ssa.Value).Type().Underlying(), false, errorInfo)
 	if v1LangType != v2LangType && !(v1LangType == "Int" && v2LangType == "GOint64") && !(op == "<<" || op == ">>") {
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): haxe types not equal: %s %s %s",
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): haxe types not equal: %s %s %s",
 			v1LangType, op, v2LangType))
 		return ""
 	}
 	rt := l.LangType(regTyp.Underlying(), false, errorInfo)
 	if v1LangType != rt && rt != "Bool" {
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): result type %s != 1st operand type %s",
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): result type %s != 1st operand type %s",
 			rt, v1LangType))
 	}
 
@@ -148,7 +147,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 		case "!=":
 			return "Complex.neq(" + v1string + "," + v2string + ")"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Complex op: %s", op))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Complex op: %s", op))
 			return ""
 		}
 
@@ -168,7 +167,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 		case "!=":
 			return "!Interface.isEqual(" + v1string + "," + v2string + ")"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Interface op: %s", op))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Interface op: %s", op))
 			return ""
 		}
 
@@ -179,7 +178,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 		case "!=":
 			return "!Pointer.isEqual(" + v1string + "," + v2string + ")"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Pointer op: %s", op))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Pointer op: %s", op))
 			return ""
 		}
 
@@ -190,7 +189,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 		case "!=":
 			return "!(" + v1string + ".isEqual(0," + v2string + ",0))"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Object op: %s", op))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled Object op: %s", op))
 			return ""
 		}
 
@@ -252,7 +251,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 				}
 				ret = "(" + compFunc + v1string + "," + v2string + ")" + op + "0)"
 			default:
-				pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled 64-bit op: %s", op))
+				l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled 64-bit op: %s", op))
 				return ""
 			}
 
@@ -326,7 +325,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 				case types.UntypedFloat, types.Float32, types.Float64:
 					ret = "Force.floatDiv(" + v1string + "," + v2string + ")"
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
 					ret = "(ERROR)"
 				}
 			case "%":
@@ -342,7 +341,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 				case types.UntypedFloat, types.Float32, types.Float64:
 					ret = "Force.floatMod(" + v1string + "," + v2string + ")"
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
 					ret = "(ERROR)"
 				}
 
@@ -359,7 +358,7 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 				case types.UntypedFloat, types.Float32, types.Float64:
 					ret = "(" + v1string + "*" + v2string + ")"
 				default:
-					pogo.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
+					l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("codeBinOp(): unhandled divide type"))
 					ret = "(ERROR)"
 				}
 
@@ -387,6 +386,6 @@ func (l langType) codeBinOp(regTyp types.Type, op string, v1, v2 interface{}, er
 	}
 }
 
-func (l langType) BinOp(register string, regTyp types.Type, op string, v1, v2 interface{}, errorInfo string) string {
+func (l *langType) BinOp(register string, regTyp types.Type, op string, v1, v2 interface{}, errorInfo string) string {
 	return register + "=" + l.codeBinOp(regTyp, op, v1, v2, errorInfo) + ";"
 }
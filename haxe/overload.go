@@ -9,7 +9,6 @@ import (
 
 	"golang.org/x/tools/go/ssa"
 
-	"github.com/tardisgo/tardisgo/pogo"
 )
 
 var builtinOverloadMap = map[string]string{
@@ -67,7 +66,7 @@ var fnToVarOverloadMap = map[string]string{
 //"math_NaN": "Math.NaN",
 }
 
-func (l langType) FunctionOverloaded(pkg, fun string) bool {
+func (l *langType) FunctionOverloaded(pkg, fun string) bool {
 	//fmt.Printf("DEBUG fn ov :%s:%s:\n", pkg, fun)
 	_, ok := fnOverloadMap[pkg+"_"+fun]
 	if ok {
@@ -81,12 +80,12 @@ func (l langType) FunctionOverloaded(pkg, fun string) bool {
 	return ok
 }
 
-func (l langType) FuncName(fnx *ssa.Function) string {
+func (l *langType) FuncName(fnx *ssa.Function) string {
 	pn := ""
 	if fnx.Signature.Recv() != nil {
 		pn = fnx.Signature.Recv().Type().String() // NOTE no use of underlying here
 	} else {
-		pn, _ = pogo.FuncPathName(fnx) //fmt.Sprintf("fn%d", fnx.Pos())
+		pn, _ = l.PogoComp.FuncPathName(fnx) //fmt.Sprintf("fn%d", fnx.Pos())
 		fn := ssa.EnclosingFunction(fnx.Package(), []ast.Node{fnx.Syntax()})
 		if fn == nil {
 			fn = fnx
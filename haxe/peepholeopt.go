@@ -15,7 +15,7 @@ import (
 type phiEntry struct{ reg, val string }
 
 // PeepholeOpt implements the optimisations spotted by pogo.peephole
-func (l langType) PeepholeOpt(opt, register string, code []ssa.Instruction, errorInfo string) string {
+func (l *langType) PeepholeOpt(opt, register string, code []ssa.Instruction, errorInfo string) string {
 	ret := ""
 	switch opt {
 	case "loadObject":
@@ -83,7 +83,7 @@ func (l langType) PeepholeOpt(opt, register string, code []ssa.Instruction, erro
 			}
 			for _, ent := range opt {
 				rn := "_" + ent.reg
-				if useRegisterArray {
+				if l.useRegisterArray {
 					rn = rn[:2] + "[" + rn[2:] + "]"
 				}
 				ret += fmt.Sprintf("\t\t%s=tmp_%s;\n", rn, ent.reg)
@@ -6,10 +6,10 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/tardisgo/tardisgo/pogo"
 	"golang.org/x/tools/go/types"
 	//"golang.org/x/tools/go/types/typeutil"
 
-	"github.com/tardisgo/tardisgo/pogo"
 )
 
 const ( // from reflect package
@@ -33,21 +33,21 @@ func synthTypesFor(t types.Type) {
 	/*
 	// synthesize a pointer to the type
 	np := (*types.Pointer)(nil)
-	for _, tt := range typesByID {
+	for _, tt := range l.typesByID {
 		if tp, ok := tt.(*types.Pointer); ok {
-			if pte.At(tp.Elem()).(int) == pte.At(t).(int) {
+			if l.pte.At(tp.Elem()).(int) == l.pte.At(t).(int) {
 				np = tp
 				break
 			}
 		}
 	}
 	if np == nil {
-		pogo.LogTypeUse(types.NewPointer(t))
+		l.PogoComp.LogTypeUse(types.NewPointer(t))
 	}
 
 	// if an array, synthesize a slice
 	if arr, isArr := t.(*types.Array); isArr {
-		pogo.LogTypeUse(types.NewSlice(arr.Elem()))
+		l.PogoComp.LogTypeUse(types.NewSlice(arr.Elem()))
 	}
 	*/
 }
@@ -153,27 +153,32 @@ func GetTypeInfo(t types.Type, tname string) (kind reflect.Kind, name string) {
 	return
 }
 
-func BuildTypeHaxe() string {
+func (l *langType) BuildTypeHaxe() string {
 
-	buildTBI()
-	for i, t := range typesByID {
+	l.buildTBI()
+	for i, t := range l.typesByID {
 		if i > 0 {
 			synthTypesFor(t)
 		}
 	}
-	buildTBI()
+	l.buildTBI()
+
+	l.methodSetIDs = make(map[int]bool)
+	for _, t := range l.PogoComp.TypesWithMethodSets() {
+		l.methodSetIDs[l.pte.At(t).(int)] = true
+	}
 
 	ret := "class Tgotypes {\n"
 
-	for i, t := range typesByID {
+	for i, t := range l.typesByID {
 		if i > 0 {
-			ret += typeBuild(i, t)
+			ret += l.typeBuild(i, t)
 		}
 	}
 
 	ret += "public static function setup() {\nvar a=Go.haxegoruntime_TTypeTTable.load();\n"
 
-	for i := range typesByID {
+	for i := range l.typesByID {
 		if i > 0 {
 			//fmt.Println("DEBUG setup",i,t)
 			ret += fmt.Sprintf(
@@ -184,14 +189,14 @@ func BuildTypeHaxe() string {
 
 	ret += "}\n" + "}\n"
 
-	pogo.WriteAsClass("Tgotypes", ret)
+	l.PogoComp.WriteAsClass("Tgotypes", ret)
 
 	//fmt.Println("DEBUG generated Haxe code:", ret)
 
 	return ret
 }
 
-func typeBuild(i int, t types.Type) string {
+func (l *langType) typeBuild(i int, t types.Type) string {
 	sizes := &haxeStdSizes
 	ret := fmt.Sprintf( // sizeof largest struct (funcType) is 76
 		"private static var type%dptr:Pointer=null; // %s\npublic static function type%d():Pointer { if(type%dptr==null) { type%dptr=new Pointer(new Object(80));",
@@ -202,7 +207,7 @@ func typeBuild(i int, t types.Type) string {
 	if namedT, named := t.(*types.Named); named {
 		name = namedT.Obj().Name()
 	}
-	rtype, kind := rtypeBuild(i, sizes, t, name)
+	rtype, kind := l.rtypeBuild(i, sizes, t, name)
 
 	switch t.(type) {
 	case *types.Named:
@@ -217,25 +222,25 @@ func typeBuild(i int, t types.Type) string {
 
 	case reflect.Ptr:
 		ret += fmt.Sprintf("Go_haxegoruntime_fillPPtrTType.callFromRT(0,type%dptr,\n/*rtype:*/ ", i) + rtype + ",\n"
-		if pte.At(t.(*types.Pointer).Elem()) == nil {
+		if l.pte.At(t.(*types.Pointer).Elem()) == nil {
 			ret += fmt.Sprintf("/*elem:*/ nil,\n")
 		} else {
 			ret += fmt.Sprintf("/*elem:*/ type%d()\n",
-				pte.At(t.(*types.Pointer).Elem()).(int))
+				l.pte.At(t.(*types.Pointer).Elem()).(int))
 		}
 		ret += ")"
 
 	case reflect.Array:
 		ret += fmt.Sprintf("Go_haxegoruntime_fillAArrayTType.callFromRT(0,type%dptr,\n/*rtype:*/ ", i) + rtype + ",\n"
 		ret += fmt.Sprintf("/*elem:*/ type%d(),\n",
-			pte.At(t.(*types.Array).Elem()).(int))
+			l.pte.At(t.(*types.Array).Elem()).(int))
 		asl := "null" // slice type
-		for _, tt := range pte.Keys() {
+		for _, tt := range l.pte.Keys() {
 			slt, isSlice := tt.(*types.Slice)
 			if isSlice {
-				if pte.At(slt.Elem()) == pte.At(t.(*types.Array).Elem()) {
+				if l.pte.At(slt.Elem()) == l.pte.At(t.(*types.Array).Elem()) {
 					asl = fmt.Sprintf("type%d()",
-						pte.At(slt).(int))
+						l.pte.At(slt).(int))
 					break
 				}
 			}
@@ -247,7 +252,7 @@ func typeBuild(i int, t types.Type) string {
 
 	case reflect.Slice:
 		ret += fmt.Sprintf("Go_haxegoruntime_fillSSliceTType.callFromRT(0,type%dptr,\n/*rtype:*/ ", i) + rtype + ",\n"
-		ret += fmt.Sprintf("/*elem:*/ type%d()\n", pte.At(t.(*types.Slice).Elem()).(int))
+		ret += fmt.Sprintf("/*elem:*/ type%d()\n", l.pte.At(t.(*types.Slice).Elem()).(int))
 		ret += ")"
 
 	case reflect.Struct:
@@ -268,15 +273,17 @@ func typeBuild(i int, t types.Type) string {
 			//if fldInfo.IsField() {
 			name := fldInfo.Name()
 			path := ""
-			rune1, _ := utf8.DecodeRune([]byte(name))
-			if unicode.IsLower(rune1) {
+			if !fldInfo.Exported() { // as gc, this includes blank fields
 				path = fldInfo.Pkg().Path()
 			}
+			if fldInfo.Anonymous() { // as gc, embedded fields have no name, reflect uses that of the type
+				name = ""
+			}
 
 			fret = "\tGo_haxegoruntime_addSStructFFieldSSlice.callFromRT(0," + fret + ","
-			fret += "\n\t\t/*name:*/ \"" + fldInfo.Name() + "\",\n"
+			fret += "\n\t\t/*name:*/ \"" + name + "\",\n"
 			fret += "\t\t/*pkgPath:*/ \"" + path + "\",\n"
-			fret += fmt.Sprintf("\t\t/*typ:*/ type%d(),// %s\n", pte.At(fldInfo.Type()), fldInfo.Type().String())
+			fret += fmt.Sprintf("\t\t/*typ:*/ type%d(),// %s\n", l.pte.At(fldInfo.Type()), fldInfo.Type().String())
 			fret += "\t\t/*tag:*/ \"" + escapedTypeString(t.(*types.Struct).Tag(fld)) + "\", // "+t.(*types.Struct).Tag(fld)+"\n"
 			fret += fmt.Sprintf("\t\t/*offset:*/ %d\n", offs[fld])
 
@@ -299,7 +306,7 @@ func typeBuild(i int, t types.Type) string {
 			}
 			mret += "\t\t/*pkgPath:*/ " + path + ",\n"
 			typ := "null"
-			iface := pte.At(meth.Type())
+			iface := l.pte.At(meth.Type())
 			if iface != interface{}(nil) {
 				typ = fmt.Sprintf("type%d()", iface.(int))
 			}
@@ -311,9 +318,9 @@ func typeBuild(i int, t types.Type) string {
 	case reflect.Map:
 		ret += fmt.Sprintf("Go_haxegoruntime_fillMMapTType.callFromRT(0,type%dptr,\n/*rtype:*/ ", i) + rtype + ",\n"
 		ret += fmt.Sprintf("/*key:*/ type%d(),\n",
-			pte.At(t.(*types.Map).Key()).(int))
+			l.pte.At(t.(*types.Map).Key()).(int))
 		ret += fmt.Sprintf("/*elem:*/ type%d()\n",
-			pte.At(t.(*types.Map).Elem()).(int))
+			l.pte.At(t.(*types.Map).Elem()).(int))
 		ret += ")"
 
 	case reflect.Func:
@@ -323,20 +330,20 @@ func typeBuild(i int, t types.Type) string {
 		iret := "Go_haxegoruntime_newPPtrTToRRtypeSSlice.callFromRT(0)"
 		for i := 0; i < t.(*types.Signature).Params().Len(); i++ {
 			iret = fmt.Sprintf("Go_haxegoruntime_addPPtrTToRRtypeSSlice.callFromRT(0,%s,\n\ttype%d())", iret,
-				pte.At((t.(*types.Signature).Params().At(i).Type())).(int))
+				l.pte.At((t.(*types.Signature).Params().At(i).Type())).(int))
 		}
 		ret += iret + ",\n/*out:*/  "
 		oret := "Go_haxegoruntime_newPPtrTToRRtypeSSlice.callFromRT(0)"
 		for o := 0; o < t.(*types.Signature).Results().Len(); o++ {
 			oret = fmt.Sprintf("Go_haxegoruntime_addPPtrTToRRtypeSSlice.callFromRT(0,%s,\n\ttype%d())", oret,
-				pte.At((t.(*types.Signature).Results().At(o).Type())).(int))
+				l.pte.At((t.(*types.Signature).Results().At(o).Type())).(int))
 		}
 		ret += oret + " )\n"
 
 	case reflect.Chan:
 		ret += fmt.Sprintf("Go_haxegoruntime_fillCChanTType.callFromRT(0,type%dptr,\n/*rtype:*/ ", i) + rtype + ",\n"
 		ret += fmt.Sprintf("/*elem:*/ type%d(),\n",
-			pte.At(t.(*types.Chan).Elem()).(int))
+			l.pte.At(t.(*types.Chan).Elem()).(int))
 		reflectDir := reflect.ChanDir(0)
 		switch t.(*types.Chan).Dir() {
 		case types.SendRecv:
@@ -357,7 +364,7 @@ func typeBuild(i int, t types.Type) string {
 	ret += fmt.Sprintf("}; return type%dptr; }\n", i)
 	return ret
 }
-func rtypeBuild(i int, sizes types.Sizes, t types.Type, name string) (string, reflect.Kind) {
+func (l *langType) rtypeBuild(i int, sizes types.Sizes, t types.Type, name string) (string, reflect.Kind) {
 	var kind reflect.Kind
 	kind, name = GetTypeInfo(t, name)
 	sof := int64(4)
@@ -373,12 +380,12 @@ func rtypeBuild(i int, sizes types.Sizes, t types.Type, name string) (string, re
 	ret += fmt.Sprintf("\t/*fieldAlign:*/ %d,\n", aof) // TODO check correct for fieldAlign
 	ret += fmt.Sprintf("\t/*kind:*/ %d,\n", kind)
 	ret += fmt.Sprintf("\t/*string:*/ \"%s\", // %s\n", escapedTypeString(t.String()),t.String())
-	ret += fmt.Sprintf("\t/*uncommonType:*/ %s,\n", uncommonBuild(i, sizes, name, t))
+	ret += fmt.Sprintf("\t/*uncommonType:*/ %s,\n", l.uncommonBuild(i, sizes, name, t))
 	ptt := "null"
-	for pti, pt := range typesByID {
+	for pti, pt := range l.typesByID {
 		_, isPtr := pt.(*types.Pointer)
 		if isPtr {
-			ele := pte.At(pt.(*types.Pointer).Elem())
+			ele := l.pte.At(pt.(*types.Pointer).Elem())
 			if ele != nil {
 				if i == ele.(int) {
 					ptt = fmt.Sprintf("type%d()", pti)
@@ -391,7 +398,7 @@ func rtypeBuild(i int, sizes types.Sizes, t types.Type, name string) (string, re
 	return ret, kind
 }
 
-func uncommonBuild(i int, sizes types.Sizes, name string, t types.Type) string {
+func (l *langType) uncommonBuild(i int, sizes types.Sizes, name string, t types.Type) string {
 	pkgPath := ""
 	tt := t
 	switch tt.(type) {
@@ -419,12 +426,26 @@ func uncommonBuild(i int, sizes types.Sizes, name string, t types.Type) string {
 		ret += "\t\t/*pkgPath:*/ \"" + pkgPath + "\",\n"
 		ret += "\t\t/*methods:*/ "
 		meths := "Go_haxegoruntime_newMMethodSSlice.callFromRT(0)"
+		_, isIface := t.Underlying().(*types.Interface)
 		for m := 0; m < methods.Len(); m++ {
 			sel := methods.At(m)
-			fn := "null"
-			fid, haveFn := pte.At(sel.Obj().Type()).(int)
+			mtyp := "null"
+			fid, haveFn := l.pte.At(sel.Obj().Type()).(int)
 			if haveFn {
-				fn = fmt.Sprintf("type%d()", fid)
+				mtyp = fmt.Sprintf("type%d()", fid)
+			}
+			typ, fn := mtyp, "null"
+			if !isIface {
+				typ = "null"
+				if rid, ok := l.pte.At(pogo.MethodFuncType(t, sel)).(int); ok {
+					typ = fmt.Sprintf("type%d()", rid)
+				}
+				// only the methods of types that may be held in an interface are sure to have been generated
+				if l.methodSetIDs[i] {
+					if f := l.methodFn(t, sel); f != "" {
+						fn = "new Closure(" + f + ",null)"
+					}
+				}
 			}
 			meths = "Go_haxegoruntime_addMMethod.callFromRT(0," + meths + ",\n"
 			meths += fmt.Sprintf("\n\t\t\t/*name:*/ \"%s\", // %s\n", sel.Obj().Name(), sel.String())
@@ -435,11 +456,10 @@ func uncommonBuild(i int, sizes types.Sizes, name string, t types.Type) string {
 				path = sel.Obj().Pkg().Path()
 			}
 			meths += fmt.Sprintf("\t\t\t/*pkgPath:*/ \"%s\",\n", path)
-			// TODO should the two lines below be different?
-			meths += fmt.Sprintf("\t\t\t/*mtyp:*/ %s,\n", fn)
-			meths += fmt.Sprintf("\t\t\t/*typ:*/ %s,\n", fn)
-			// TODO add links to the functions ... may be haxe specific
-			meths += "\t\t\tnull, null )"
+			meths += fmt.Sprintf("\t\t\t/*mtyp:*/ %s,\n", mtyp)
+			meths += fmt.Sprintf("\t\t\t/*typ:*/ %s,\n", typ)
+			meths += fmt.Sprintf("\t\t\t/*ifn:*/ %s,\n", fn) // in Haxe the receiver is always passed in the same way
+			meths += fmt.Sprintf("\t\t\t/*tfn:*/ %s )", fn)
 		}
 		ret += meths
 		//ret += "\t\t},\n"
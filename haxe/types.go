@@ -15,11 +15,10 @@ import (
 	"github.com/tardisgo/tardisgo/pogo"
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/types"
-	"golang.org/x/tools/go/types/typeutil"
 )
 
-func (l langType) LangType(t types.Type, retInitVal bool, errorInfo string) string {
-	if pogo.IsValidInPogo(t, errorInfo) {
+func (l *langType) LangType(t types.Type, retInitVal bool, errorInfo string) string {
+	if l.PogoComp.IsValidInPogo(t, errorInfo) {
 		switch t.(type) {
 		case *types.Basic:
 			switch t.(*types.Basic).Kind() {
@@ -70,7 +69,7 @@ func (l langType) LangType(t types.Type, retInitVal bool, errorInfo string) stri
 				}
 				return "Dynamic"
 			default:
-				pogo.LogWarning(errorInfo, "Haxe", fmt.Errorf("haxe.LangType() unrecognised basic type, Dynamic assumed"))
+				l.PogoComp.LogWarning(errorInfo, "Haxe", fmt.Errorf("haxe.LangType() unrecognised basic type, Dynamic assumed"))
 				if retInitVal {
 					return "null"
 				}
@@ -98,9 +97,7 @@ func (l langType) LangType(t types.Type, retInitVal bool, errorInfo string) stri
 			return "Channel" //was: <" + l.LangType(t.(*types.Chan).Elem(), false, errorInfo) + ">"
 		case *types.Map:
 			if retInitVal {
-				return "new GOmap(" +
-					l.LangType(t.(*types.Map).Key(), true, errorInfo) + "," +
-					l.LangType(t.(*types.Map).Elem(), true, errorInfo) + ")"
+				return l.newMap(t.(*types.Map), nil, errorInfo)
 			}
 			return "GOmap"
 		case *types.Slice:
@@ -112,12 +109,12 @@ func (l langType) LangType(t types.Type, retInitVal bool, errorInfo string) stri
 			return "Slice"
 		case *types.Array:
 			if retInitVal {
-				return fmt.Sprintf("new Object(%d)", haxeStdSizes.Sizeof(t))
+				return newObject(fmt.Sprintf("%d", haxeStdSizes.Sizeof(t)), t)
 			}
 			return "Object"
 		case *types.Struct:
 			if retInitVal {
-				return fmt.Sprintf("new Object(%d)", haxeStdSizes.Sizeof(t.(*types.Struct).Underlying()))
+				return newObject(fmt.Sprintf("%d", haxeStdSizes.Sizeof(t.(*types.Struct).Underlying())), t)
 			}
 			return "Object"
 		case *types.Tuple: // what is returned by a call and some other instructions, not in the Go language spec!
@@ -164,14 +161,14 @@ func (l langType) LangType(t types.Type, retInitVal bool, errorInfo string) stri
 				}
 				return "Dynamic"
 			}
-			pogo.LogError(errorInfo, "Haxe",
+			l.PogoComp.LogError(errorInfo, "Haxe",
 				fmt.Errorf("haxe.LangType() internal error, unhandled non-basic type: %s", rTyp))
 		}
 	}
 	return "UNKNOWN_LANGTYPE" // this should generate a Haxe compiler error
 }
 
-func (l langType) Convert(register, langType string, destType types.Type, v interface{}, errorInfo string) string {
+func (l *langType) Convert(register, langType string, destType types.Type, v interface{}, errorInfo string) string {
 	srcTyp := l.LangType(v.(ssa.Value).Type().Underlying(), false, errorInfo)
 	if srcTyp == langType && langType != "Float" && langType != "Int" { // no cast required because the Haxe type is the same
 		return register + "=" + l.IndirectValue(v, errorInfo) + ";"
@@ -193,7 +190,7 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 		if srcTyp == "Dynamic" {
 			return register + "=" + l.IndirectValue(v, errorInfo) + "==null?null:Pointer.check(" + l.IndirectValue(v, errorInfo) + ");"
 		}
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - can only convert uintptr to unsafe.Pointer"))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - can only convert uintptr to unsafe.Pointer"))
 		return ""
 	case "String":
 		switch srcTyp {
@@ -206,7 +203,7 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 			case types.Byte: // []byte
 				return register + "=Force.toRawString(this._goroutine," + l.IndirectValue(v, errorInfo) + ");"
 			default:
-				pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected slice type to convert to String"))
+				l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected slice type to convert to String"))
 				return ""
 			}
 		case "Int": // make a string from a single rune
@@ -220,12 +217,12 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 		case "Dynamic":
 			return register + "=cast(" + l.IndirectValue(v, errorInfo) + ",String);"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected type to convert to String: %s", srcTyp))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected type to convert to String: %s", srcTyp))
 			return ""
 		}
 	case "Slice": // []rune or []byte
 		if srcTyp != "String" {
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected type to convert to %s ([]rune or []byte): %s",
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected type to convert to %s ([]rune or []byte): %s",
 				langType, srcTyp))
 			return ""
 		}
@@ -244,7 +241,7 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 		case types.Byte:
 			return register + "=Force.toUTF8slice(this._goroutine," + l.IndirectValue(v, errorInfo) + ");"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected slice elementto convert to %s ([]rune/[]byte): %s",
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unexpected slice elementto convert to %s ([]rune/[]byte): %s",
 				langType, srcTyp))
 			return ""
 		}
@@ -260,7 +257,7 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 		case "Dynamic":
 			vInt = "Force.toInt(" + l.IndirectValue(v, errorInfo) + ")" // Dynamic == uintptr
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to u/int from: %s", srcTyp))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to u/int from: %s", srcTyp))
 			return ""
 		}
 		return register + "=" + l.intTypeCoersion(destType, vInt, errorInfo) + ";"
@@ -279,7 +276,7 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 		case "Dynamic": // uintptr
 			return register + "=GOint64.ofUInt(Force.toInt(" + l.IndirectValue(v, errorInfo) + "));" // let Haxe work out how to do the cast
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to u/int64 from: %s", srcTyp))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to u/int64 from: %s", srcTyp))
 			return ""
 		}
 	case "Float":
@@ -303,25 +300,25 @@ func (l langType) Convert(register, langType string, destType types.Type, v inte
 			}
 			return register + "=Force.toFloat(" + l.IndirectValue(v, errorInfo) + ");"
 		default:
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to float from: %s", srcTyp))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - unhandled convert to float from: %s", srcTyp))
 			return ""
 		}
 	case "UnsafePointer":
-		//pogo.LogWarning(errorInfo, "Haxe", fmt.Errorf("converting a pointer to an Unsafe Pointer"))
+		//l.PogoComp.LogWarning(errorInfo, "Haxe", fmt.Errorf("converting a pointer to an Unsafe Pointer"))
 		return register + "=" + l.IndirectValue(v, errorInfo) + ";" // ALL Pointers are unsafe ?
 	default:
 		if strings.HasPrefix(srcTyp, "Array<") {
-			pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - No way to convert to %s from %s ", langType, srcTyp))
+			l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - No way to convert to %s from %s ", langType, srcTyp))
 			return ""
 		}
-		pogo.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unhandled convert to %s from %s ", langType, srcTyp))
+		l.PogoComp.LogError(errorInfo, "Haxe", fmt.Errorf("haxe.Convert() - Unhandled convert to %s from %s ", langType, srcTyp))
 		//return register + "=cast(" + l.IndirectValue(v, errorInfo) + "," + langType + ");"
 		return ""
 	}
 }
 
-func (l langType) MakeInterface(register string, regTyp types.Type, v interface{}, errorInfo string) string {
-	ret := `new Interface(` + pogo.LogTypeUse(v.(ssa.Value).Type() /*NOT underlying()*/) + `,` +
+func (l *langType) MakeInterface(register string, regTyp types.Type, v interface{}, errorInfo string) string {
+	ret := `new Interface(` + l.PogoComp.LogTypeUse(v.(ssa.Value).Type() /*NOT underlying()*/) + `,` +
 		l.IndirectValue(v, errorInfo) + ")"
 	if getHaxeClass(regTyp.String()) != "" {
 		ret = "Force.toHaxeParam(" + ret + ")" // as interfaces are not native to haxe, so need to convert
@@ -330,9 +327,9 @@ func (l langType) MakeInterface(register string, regTyp types.Type, v interface{
 	return register + `=` + ret + ";"
 }
 
-func (l langType) ChangeInterface(register string, regTyp types.Type, v interface{}, errorInfo string) string {
-	pogo.LogTypeUse(regTyp) // make sure it is in the DB
-	return register + `=Interface.change(` + pogo.LogTypeUse(v.(ssa.Value).Type() /*NOT underlying()*/) + `,` +
+func (l *langType) ChangeInterface(register string, regTyp types.Type, v interface{}, errorInfo string) string {
+	l.PogoComp.LogTypeUse(regTyp) // make sure it is in the DB
+	return register + `=Interface.change(` + l.PogoComp.LogTypeUse(v.(ssa.Value).Type() /*NOT underlying()*/) + `,` +
 		l.IndirectValue(v, errorInfo) + ");"
 }
 
@@ -348,7 +345,7 @@ Type changes are permitted:
 - from a bidirectional channel to a read- or write-channel,
   optionally adding/removing a name.
 */
-func (l langType) ChangeType(register string, regTyp interface{}, v interface{}, errorInfo string) string {
+func (l *langType) ChangeType(register string, regTyp interface{}, v interface{}, errorInfo string) string {
 	//fmt.Printf("DEBUG CHANGE TYPE: %v -- %v\n", regTyp, v)
 	switch v.(ssa.Value).(type) {
 	case *ssa.Function:
@@ -366,7 +363,7 @@ func (l langType) ChangeType(register string, regTyp interface{}, v interface{},
 				"new Closure(Go_" + l.LangName(pf, v.(*ssa.Function).Name()) + ".call,[]);"
 		*/
 		return register + "=" +
-			"new Closure(Go_" + l.LangName(pogo.FuncPathName(v.(*ssa.Function))) + ".call,[]);"
+			"new Closure(Go_" + l.LangName(l.PogoComp.FuncPathName(v.(*ssa.Function))) + ".call,[]);"
 	default:
 		hType := getHaxeClass(regTyp.(types.Type).String())
 		if hType != "" {
@@ -393,14 +390,52 @@ func (l langType) ChangeType(register string, regTyp interface{}, v interface{},
 
 }
 
-func (l langType) TypeAssert(register string, v ssa.Value, AssertedType types.Type, CommaOk bool, errorInfo string) string {
+func (l *langType) TypeAssert(register string, v ssa.Value, AssertedType types.Type, CommaOk bool, errorInfo string) string {
 	if register == "" {
 		return ""
 	}
 	if CommaOk {
-		return register + `=Interface.assertOk(` + pogo.LogTypeUse(AssertedType) + `,` + l.IndirectValue(v, errorInfo) + ");"
+		return register + `=Interface.assertOk(` + l.PogoComp.LogTypeUse(AssertedType) + `,` + l.IndirectValue(v, errorInfo) + ");"
 	}
-	return register + `=Interface.assert(` + pogo.LogTypeUse(AssertedType) + `,` + l.IndirectValue(v, errorInfo) + ");"
+	return register + `=Interface.assert(` + l.PogoComp.LogTypeUse(AssertedType) + `,` + l.IndirectValue(v, errorInfo) + ");"
+}
+
+// newMap returns the Haxe code to make an empty map of type m, within the maps being made for its element zero values.
+// The element zero value of a map that is already being made is null, a nil map, as for "type recursiveMap map[string]recursiveMap".
+func (l *langType) newMap(m *types.Map, making []*types.Map, errorInfo string) string {
+	kind := "" // the default, GOmap.KeyOther
+	switch l.LangType(m.Key(), false, errorInfo) {
+	case "Int":
+		kind = ",GOmap.KeyInt"
+	case "String":
+		kind = ",GOmap.KeyString"
+	case "Float":
+		kind = ",GOmap.KeyFloat"
+	}
+	making = append(making, m)
+	var elem string
+	em, isMap := m.Elem().Underlying().(*types.Map)
+	if named, isNamed := m.Elem().(*types.Named); isNamed && getHaxeClass(named.String()) != "" {
+		isMap = false
+	}
+	switch {
+	case !isMap:
+		elem = l.LangType(m.Elem(), true, errorInfo)
+	case mapIn(em, making):
+		elem = "null"
+	default:
+		elem = l.newMap(em, making, errorInfo)
+	}
+	return "new GOmap(" + l.LangType(m.Key(), true, errorInfo) + "," + elem + kind + ")"
+}
+
+func mapIn(m *types.Map, maps []*types.Map) bool {
+	for _, mm := range maps {
+		if mm == m {
+			return true
+		}
+	}
+	return false
 }
 
 func getHaxeClass(fullname string) string { // NOTE capital letter de-doubling not handled here
@@ -474,74 +509,70 @@ func notInterface(t types.Type) bool {
 	return false
 }
 
-var typesByID []types.Type
-var pte typeutil.Map
-var pteKeys []types.Type
-
-func buildTBI() {
-	pte = pogo.TypesEncountered
-	pteKeys = pogo.TypesEncountered.Keys()
-	sort.Sort(pogo.TypeSorter(pteKeys))
-	typesByID = make([]types.Type, pogo.NextTypeID)
-	for k := range pteKeys {
-		v := pte.At(pteKeys[k]).(int)
-		typesByID[v] = pteKeys[k]
+func (l *langType) buildTBI() {
+	l.pte = l.PogoComp.TypesEncountered
+	l.pteKeys = l.PogoComp.TypesEncountered.Keys()
+	sort.Sort(pogo.TypeSorter(l.pteKeys))
+	l.typesByID = make([]types.Type, l.PogoComp.NextTypeID)
+	for k := range l.pteKeys {
+		v := l.pte.At(l.pteKeys[k]).(int)
+		l.typesByID[v] = l.pteKeys[k]
 	}
 }
 
-func (l langType) EmitTypeInfo() string {
+func (l *langType) EmitTypeInfo() string {
 
-	BuildTypeHaxe() // generate the code to emulate compiler reflect data output
+	l.BuildTypeHaxe() // generate the code to emulate compiler reflect data output
 
 	var ret string = ""
 
-	/*if false { // was: pogo.UsingPackage("reflect")
+	/*if false { // was: l.PogoComp.UsingPackage("reflect")
 
 		ret += "class PtrTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var ptrByID:Map<Int,Int> = [ 0=> 0,"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
 			}
 			switch t.(type) {
 			case *types.Pointer:
-				ret += fmt.Sprintf("\t%d=>%s,\n", id, pogo.LogTypeUse(t.(*types.Pointer).Elem()))
+				ret += fmt.Sprintf("\t%d=>%s,\n", id, l.PogoComp.LogTypeUse(t.(*types.Pointer).Elem()))
 			}
 		}
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("PtrTypeInfo", ret)
+		l.PogoComp.WriteAsClass("PtrTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass SliceTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var sliceByID:Map<Int,Int> = [ 0=> 0,\n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
 			}
 			switch t.(type) {
 			case *types.Slice:
-				ret += fmt.Sprintf("\t%d=>%s,\n", id, pogo.LogTypeUse(t.(*types.Slice).Elem()))
+				ret += fmt.Sprintf("\t%d=>%s,\n", id, l.PogoComp.LogTypeUse(t.(*types.Slice).Elem()))
 			}
 		}
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("SliceTypeInfo", ret)
+		l.PogoComp.WriteAsClass("SliceTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass StructTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "// mirrors reflect.structType / reflect.structField\n"
 		ret += "//[0] name    *string // nil for embedded fields\n"
@@ -551,7 +582,7 @@ func (l langType) EmitTypeInfo() string {
 		ret += "//[4] offset  uintptr // byte offset of field within struct\n"
 		ret += "public static var structByID:Array<{id:Int,flds:Array<Array<Dynamic>>}> = "
 		ret += "haxe.Json.parse(\"[{ \\\"id\\\":0, \\\"flds\\\":[] }"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
@@ -583,10 +614,10 @@ func (l langType) EmitTypeInfo() string {
 							ret += ","
 						}
 						ret += "[ "
-						ret += "\\\"" + strings.Trim(haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
-						ret += "\\\"" + strings.Trim(haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
-						ret += fmt.Sprintf("%s, ", pogo.LogTypeUse(fldInfo.Type()))
-						ret += "\\\"" + strings.Trim(haxeStringConst(`"`+t.(*types.Struct).Tag(fld)+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
+						ret += "\\\"" + strings.Trim(l.haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
+						ret += "\\\"" + strings.Trim(l.haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
+						ret += fmt.Sprintf("%s, ", l.PogoComp.LogTypeUse(fldInfo.Type()))
+						ret += "\\\"" + strings.Trim(l.haxeStringConst(`"`+t.(*types.Struct).Tag(fld)+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
 						ret += fmt.Sprintf("%d ", offs[fld])
 						ret += "]"
 					}
@@ -597,19 +628,19 @@ func (l langType) EmitTypeInfo() string {
 		ret += "]\");\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("StructTypeInfo", ret)
+		l.PogoComp.WriteAsClass("StructTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass IfaceTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var ifaceByID:Map<Int,Array<Array<Dynamic>>> = [ 0=> [],\n"
 		ret += "// mirrors reflect.interfaceType / reflect.imethod\n"
 		ret += "//[0] name    *string // name of method\n"
 		ret += "//[1] pkgPath *string // nil for exported Names; otherwise import path\n"
 		ret += "//[2] typ     *rtype  //  .(*FuncType) underneath\n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
@@ -626,9 +657,9 @@ func (l langType) EmitTypeInfo() string {
 						path = methInfo.Pkg().Path()
 					}
 					ret += "\t\t[ "
-					ret += haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()") + ", "
-					ret += haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()") + ", "
-					ret += fmt.Sprintf("%s, ", pogo.LogTypeUse(methInfo.Type()))
+					ret += l.haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()") + ", "
+					ret += l.haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()") + ", "
+					ret += fmt.Sprintf("%s, ", l.PogoComp.LogTypeUse(methInfo.Type()))
 					ret += "],\n"
 				}
 				ret += "\t],\n"
@@ -637,12 +668,12 @@ func (l langType) EmitTypeInfo() string {
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("IfaceTypeInfo", ret)
+		l.PogoComp.WriteAsClass("IfaceTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass MethTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "// mirrors reflect.ucommonType / reflect.method\n"
 		ret += "public static var methByID:Array<{\n" // using a map makes it too big for Java
@@ -655,7 +686,7 @@ func (l langType) EmitTypeInfo() string {
 		//ret += "\ttfn:Dynamic,     // fn used for normal method call\n"
 		ret += "\n}> = haxe.Json.parse(\"[ "
 		firstTime := true
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			ms := types.NewMethodSet(t)
 			if ms.Len() > 0 {
 				for mth := 0; mth < ms.Len(); mth++ {
@@ -673,10 +704,10 @@ func (l langType) EmitTypeInfo() string {
 					}
 					ret += "{ "
 					ret += fmt.Sprintf("\\\"id\\\": %d, ", id)
-					ret += "\\\"name\\\": \\\"" + strings.Trim(haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
-					ret += "\\\"pkgPath\\\": \\\"" + strings.Trim(haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
-					ret += fmt.Sprintf("\\\"mtyp\\\": %s, ", pogo.LogTypeUse(methInfo.Type())) // TODO should be without Receiver???
-					ret += fmt.Sprintf("\\\"typ\\\": %s ", pogo.LogTypeUse(methInfo.Type()))
+					ret += "\\\"name\\\": \\\"" + strings.Trim(l.haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
+					ret += "\\\"pkgPath\\\": \\\"" + strings.Trim(l.haxeStringConst(`"`+path+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`) + "\\\", "
+					ret += fmt.Sprintf("\\\"mtyp\\\": %s, ", l.PogoComp.LogTypeUse(methInfo.Type())) // TODO should be without Receiver???
+					ret += fmt.Sprintf("\\\"typ\\\": %s ", l.PogoComp.LogTypeUse(methInfo.Type()))
 					//if notInterface(t) {
 					//	fnToCall := `Go_` + l.LangName(
 					//		methInfo.Obj().Pkg().String()+":"+methInfo.Recv().String(),
@@ -693,15 +724,15 @@ func (l langType) EmitTypeInfo() string {
 		ret += "]\");\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("MethTypeInfo", ret)
+		l.PogoComp.WriteAsClass("MethTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass MapTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var mapByID:Map<Int,{key:Int,elem:Int}> = [ 0=>{key:0,elem:0}, \n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
@@ -709,22 +740,22 @@ func (l langType) EmitTypeInfo() string {
 			switch t.(type) {
 			case *types.Map:
 				ret += fmt.Sprintf("\t%d=>{key:%s,elem:%s},\n", id,
-					pogo.LogTypeUse(t.(*types.Map).Key()),
-					pogo.LogTypeUse(t.(*types.Map).Elem()))
+					l.PogoComp.LogTypeUse(t.(*types.Map).Key()),
+					l.PogoComp.LogTypeUse(t.(*types.Map).Elem()))
 			}
 		}
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("MapTypeInfo", ret)
+		l.PogoComp.WriteAsClass("MapTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass ChanTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var chanByID:Map<Int,{elem:Int,dir:Int}> = [ 0=>{elem:0,dir:0}, \n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
@@ -741,22 +772,22 @@ func (l langType) EmitTypeInfo() string {
 					reflectDir = reflect.RecvDir
 				}
 				ret += fmt.Sprintf("\t%d=>{elem:%s,dir:%d},\n", id,
-					pogo.LogTypeUse(t.(*types.Chan).Elem()),
+					l.PogoComp.LogTypeUse(t.(*types.Chan).Elem()),
 					reflectDir)
 			}
 		}
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("ChanTypeInfo", ret)
+		l.PogoComp.WriteAsClass("ChanTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass FuncTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var funcByID:Map<Int,{ddd:Bool,pin:Array<Int>,pout:Array<Int>}> = [ 0=>{ddd:false,pin:[],pout:[]}, \n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			switch t.(type) {
 			case *types.Named:
 				t = t.(*types.Named).Underlying()
@@ -765,11 +796,11 @@ func (l langType) EmitTypeInfo() string {
 			case *types.Signature:
 				ret += fmt.Sprintf("\t%d=>{ddd:%v,pin:[", id, t.(*types.Signature).Variadic())
 				for i := 0; i < t.(*types.Signature).Params().Len(); i++ {
-					ret += fmt.Sprintf("%s,", pogo.LogTypeUse(t.(*types.Signature).Params().At(i).Type()))
+					ret += fmt.Sprintf("%s,", l.PogoComp.LogTypeUse(t.(*types.Signature).Params().At(i).Type()))
 				}
 				ret += "],pout:["
 				for o := 0; o < t.(*types.Signature).Results().Len(); o++ {
-					ret += fmt.Sprintf("%s,", pogo.LogTypeUse(t.(*types.Signature).Results().At(o).Type()))
+					ret += fmt.Sprintf("%s,", l.PogoComp.LogTypeUse(t.(*types.Signature).Results().At(o).Type()))
 				}
 				ret += fmt.Sprintf("]}, // %s \n", t.(*types.Signature).String())
 			}
@@ -777,15 +808,15 @@ func (l langType) EmitTypeInfo() string {
 		ret += "];\n"
 		ret += "}\n"
 
-		pogo.WriteAsClass("FuncTypeInfo", ret)
+		l.PogoComp.WriteAsClass("FuncTypeInfo", ret)
 		ret = ""
 
 		ret += "\nclass ArrayTypeInfo{\n"
 
-		buildTBI()
+		l.buildTBI()
 
 		ret += "public static var arrayByID:Map<Int,{elem:Int,slice:Int,len:Int}> = [ 0=>{elem:0,slice:0,len:0}, \n"
-		for id, t := range typesByID {
+		for id, t := range l.typesByID {
 			var err error
 			switch t.(type) {
 			case *types.Named:
@@ -794,23 +825,23 @@ func (l langType) EmitTypeInfo() string {
 			switch t.(type) {
 			case *types.Array:
 				slT := 0
-				for ids, ts := range typesByID {
+				for ids, ts := range l.typesByID {
 					switch ts.(type) {
 					case *types.Slice:
-						if pogo.LogTypeUse(ts.(*types.Slice).Elem()) == pogo.LogTypeUse(t.(*types.Array).Elem()) {
+						if l.PogoComp.LogTypeUse(ts.(*types.Slice).Elem()) == l.PogoComp.LogTypeUse(t.(*types.Array).Elem()) {
 							slT = ids
 							goto slTfound
 						}
 					}
 				}
 				//println("DEBUG making new slice for ", t.String())
-				slT, err = strconv.Atoi(pogo.LogTypeUse(types.NewSlice(t.(*types.Array).Elem())))
+				slT, err = strconv.Atoi(l.PogoComp.LogTypeUse(types.NewSlice(t.(*types.Array).Elem())))
 				if err != nil {
 					panic("haxe.EmitTypeInfo() correct slice type not created for array:" + t.String())
 				}
 			slTfound:
 				ret += fmt.Sprintf("\t%d=>{elem:%s,slice:%d,len:%d},\n", id,
-					pogo.LogTypeUse(t.(*types.Array).Elem()),
+					l.PogoComp.LogTypeUse(t.(*types.Array).Elem()),
 					slT, t.(*types.Array).Len())
 			}
 		}
@@ -818,13 +849,13 @@ func (l langType) EmitTypeInfo() string {
 
 		ret += "}\n"
 
-		pogo.WriteAsClass("ArrayTypeInfo", ret)
+		l.PogoComp.WriteAsClass("ArrayTypeInfo", ret)
 		ret = ""
 
 	}*/ // end of Reflect-releated type info
 
 	/*if false {
-		buildTBI()
+		l.buildTBI()
 
 		ret += "\nclass TypeInfoIDs{\n\n"
 
@@ -840,16 +871,16 @@ func (l langType) EmitTypeInfo() string {
 		ret += "//\t[8] = pkgPath:String,\n"
 		ret += "//\t[9] = numMethods:Int,\n"
 		ret += "public static var typesByID:Array<Array<Dynamic>>=haxe.Json.parse(\" [ "
-		for _, t := range typesByID {
+		for _, t := range l.typesByID {
 			kind, name := getTypeInfo(t, "")
 			if t == nil || kind == reflect.Invalid {
 				ret += "[false,0,0,0,0,\\\"\\\",\\\"\\\",0,\\\"\\\",0] " // the first one
 			} else {
 				ptrT := 0
-				for idp, tp := range typesByID {
+				for idp, tp := range l.typesByID {
 					switch tp.(type) {
 					case *types.Pointer:
-						if pogo.LogTypeUse(tp.(*types.Pointer).Elem()) == pogo.LogTypeUse(t) {
+						if l.PogoComp.LogTypeUse(tp.(*types.Pointer).Elem()) == l.PogoComp.LogTypeUse(t) {
 							ptrT = idp
 							goto ptrTfound
 						}
@@ -885,30 +916,30 @@ func (l langType) EmitTypeInfo() string {
 				ret += fmt.Sprintf(" %d,", haxeStdSizes.Alignof(t)) // TODO check correct for fieldAlign
 				ret += fmt.Sprintf(" %d,", kind)
 				ret += fmt.Sprintf(" \\\"%s\\\",",
-					strings.Trim(haxeStringConst(`"`+preprocessTypeName(t.String())+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
+					strings.Trim(l.haxeStringConst(`"`+preprocessTypeName(t.String())+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
 				ret += fmt.Sprintf(" \\\"%s\\\",",
-					strings.Trim(haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
+					strings.Trim(l.haxeStringConst(`"`+name+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
 				ret += fmt.Sprintf(" %d,", ptrT)
 				ret += fmt.Sprintf(" \\\"%s\\\",",
-					strings.Trim(haxeStringConst(`"`+pkgPath+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
+					strings.Trim(l.haxeStringConst(`"`+pkgPath+`"`, "CompilerInternal:haxe.EmitTypeInfo()"), `"`))
 				ret += fmt.Sprintf(" %d", numMethods)
 				ret += fmt.Sprintf("]")
 			}
 		}
 		ret += "]\");\n}\n"
 
-		pogo.WriteAsClass("TypeInfoIDs", ret)
+		l.PogoComp.WriteAsClass("TypeInfoIDs", ret)
 		ret = ""
 
-		buildTBI()
+		l.buildTBI()
 	}*/
 	ret += "\nclass TypeInfo{\n\n"
 
 	// TODO review if this is required
 	ret += "public static function isHaxeClass(id:Int):Bool {\nswitch(id){" + "\n"
-	for k := range pteKeys {
-		v := pte.At(pteKeys[k])
-		goType := pteKeys[k].String()
+	for k := range l.pteKeys {
+		v := l.pte.At(l.pteKeys[k])
+		goType := l.pteKeys[k].String()
 		//fmt.Println("DEBUG full goType", goType)
 		haxeClass := getHaxeClass(goType)
 		if haxeClass != "" {
@@ -921,7 +952,7 @@ func (l langType) EmitTypeInfo() string {
 	ret += "\tif(id<0||id>=nextTypeID)return \"reflect.CREATED\"+Std.string(id);\n"
 	//for k, v := range typesByID {
 	//	ret += "case " + fmt.Sprintf("%d", k) + `: return ` +
-	//		haxeStringConst(`"`+preprocessTypeName(v.String())+`"`, "CompilerInternal:haxe.EmitTypeInfo()") +
+	//		l.haxeStringConst(`"`+preprocessTypeName(v.String())+`"`, "CompilerInternal:haxe.EmitTypeInfo()") +
 	//		`;` + "\n"
 	//}
 	ret += "\tif(id==0)return \"(haxeTypeID=0)\";" + "\n"
@@ -932,9 +963,9 @@ func (l langType) EmitTypeInfo() string {
 
 	ret += "static var typIDs:Map<String,Int> = ["
 	deDup := make(map[string]bool)
-	for k := range pteKeys {
-		v := pte.At(pteKeys[k])
-		nam := haxeStringConst("`"+preprocessTypeName(pteKeys[k].String())+"`", "CompilerInternal:haxe.EmitTypeInfo()")
+	for k := range l.pteKeys {
+		v := l.pte.At(l.pteKeys[k])
+		nam := l.haxeStringConst("`"+preprocessTypeName(l.pteKeys[k].String())+"`", "CompilerInternal:haxe.EmitTypeInfo()")
 		if len(nam) != 0 {
 			if deDup[nam] { // have one already!!
 				nam = fmt.Sprintf("%s (duplicate type name! this id=%d)\"", nam[:len(nam)-1], v)
@@ -957,11 +988,11 @@ func (l langType) EmitTypeInfo() string {
 	ret += "\treturn false;\n}\n"
 
 	ret += "static var isAsssignableToArray:Array<Int> = ["
-	for V := range pteKeys {
-		v := pte.At(pteKeys[V])
-		for T := range pteKeys {
-			t := pte.At(pteKeys[T])
-			if v != t && types.AssignableTo(pteKeys[V], pteKeys[T]) {
+	for V := range l.pteKeys {
+		v := l.pte.At(l.pteKeys[V])
+		for T := range l.pteKeys {
+			t := l.pte.At(l.pteKeys[T])
+			if v != t && types.AssignableTo(l.pteKeys[V], l.pteKeys[T]) {
 				//ret0 += "\t" + `case ` + fmt.Sprintf("%d", t) + `: return true;` + "\n"
 				ret += fmt.Sprintf("%d,", v.(int)<<16|t.(int))
 			}
@@ -978,12 +1009,12 @@ func (l langType) EmitTypeInfo() string {
 
 	//emulation of: func IsIdentical(x, y Type) bool
 	ret += "public static function isIdentical(v:Int,t:Int):Bool {\nif(v==t) return true;\nswitch(v){" + "\n"
-	for V := range pteKeys {
-		v := pte.At(pteKeys[V])
+	for V := range l.pteKeys {
+		v := l.pte.At(l.pteKeys[V])
 		ret0 := ""
-		for T := range pteKeys {
-			t := pte.At(pteKeys[T])
-			if v != t && types.Identical(pteKeys[V], pteKeys[T]) {
+		for T := range l.pteKeys {
+			t := l.pte.At(l.pteKeys[T])
+			if v != t && types.Identical(l.pteKeys[V], l.pteKeys[T]) {
 				ret0 += `case ` + fmt.Sprintf("%d", t) + `: return true;` + "\n"
 			}
 		}
@@ -998,14 +1029,15 @@ func (l langType) EmitTypeInfo() string {
 	//emulation of: func type.AsertableTo(V *Interface, T Type) bool
 	ret += "public static function assertableTo(v:Int,t:Int):Bool {\n"
 	//ret += "trace(\"DEBUG assertableTo()\",v,t);\n"
+	ret += "\tif(v>=firstCreatedID) return CreatedTypeInfo.assertableTo(v,t); // a type created at run time by reflect\n"
 	ret += "\treturn isAssertableToMap[(v<<16)|t];\n"
 	ret += "}\n"
 	//	ret += "if(v==t) return true;\nswitch(v){" + "\n"
 	ret += "static var isAssertableToMap:Map<Int,Bool> = [ 0 => false, "
-	for tid, typ := range typesByID {
+	for tid, typ := range l.typesByID {
 		ret0 := ""
 		if typ != nil {
-			for iid, ityp := range typesByID {
+			for iid, ityp := range l.typesByID {
 				named, isNamed := ityp.(*types.Named)
 				if isNamed {
 					iface, isIface := named.Underlying().(*types.Interface)
@@ -1031,9 +1063,9 @@ func (l langType) EmitTypeInfo() string {
 
 	//function to answer the question is the type a concrete value?
 	ret += "public static function isConcrete(t:Int):Bool {\nswitch(t){" + "\n"
-	for T := range pteKeys {
-		t := pte.At(pteKeys[T])
-		switch pteKeys[T].Underlying().(type) {
+	for T := range l.pteKeys {
+		t := l.pte.At(l.pteKeys[T])
+		switch l.pteKeys[T].Underlying().(type) {
 		case *types.Interface:
 			ret += `case ` + fmt.Sprintf("%d", t) + `: return false;` + "\n"
 		default:
@@ -1044,9 +1076,9 @@ func (l langType) EmitTypeInfo() string {
 
 	// function to give the zero value for each type
 	ret += "public static function zeroValue(t:Int):Dynamic {\nswitch(t){" + "\n"
-	for T := range pteKeys {
-		t := pte.At(pteKeys[T])
-		z := l.LangType(pteKeys[T], true, "EmitTypeInfo()")
+	for T := range l.pteKeys {
+		t := l.pte.At(l.pteKeys[T])
+		z := l.LangType(l.pteKeys[T], true, "EmitTypeInfo()")
 		if z == "" {
 			z = "null"
 		}
@@ -1055,22 +1087,24 @@ func (l langType) EmitTypeInfo() string {
 			ret += z + ";\n"
 		}
 	}
-	ret += "default: return null;}}\n"
+	ret += "default: return t>=firstCreatedID ? CreatedTypeInfo.zeroValue(t) : null;}}\n"
 
-	ret += fmt.Sprintf("public static var nextTypeID=%d;\n", pogo.NextTypeID) // must be last as will change during processing
+	// the types created at run time by reflect are numbered from firstCreatedID, nextTypeID is then moved on
+	ret += fmt.Sprintf("public static inline var firstCreatedID=%d;\n", l.PogoComp.NextTypeID)
+	ret += fmt.Sprintf("public static var nextTypeID=%d;\n", l.PogoComp.NextTypeID) // must be last as will change during processing
 
 	ret += "}\n"
 
-	pogo.WriteAsClass("TypeInfo", ret)
+	l.PogoComp.WriteAsClass("TypeInfo", ret)
 
 	ret = "class MethodTypeInfo {"
 
 	ret += "public static function method(t:Int,m:String):Dynamic {\nswitch(t){" + "\n"
 
-	tta := pogo.TypesWithMethodSets() //[]types.Type
+	tta := l.PogoComp.TypesWithMethodSets() //[]types.Type
 	sort.Sort(pogo.TypeSorter(tta))
 	for T := range tta {
-		t := pte.At(tta[T])
+		t := l.pte.At(tta[T])
 		if t != nil { // it is used?
 			ret += `case ` + fmt.Sprintf("%d", t) + `: switch(m){` + "\n"
 			ms := types.NewMethodSet(tta[T])
@@ -1111,10 +1145,7 @@ func (l langType) EmitTypeInfo() string {
 											line += "// Duplicate unused: "
 										}
 										line += `case "` + funcObj.Name() + `": return `
-										fnToCall := l.LangName(
-											ms.At(m).Obj().Pkg().Name()+":"+ms.At(m).Recv().String(),
-											funcObj.Name())
-										line += `Go_` + fnToCall + `.call` + "; "
+										line += l.methodFn(tta[T], ms.At(m)) + "; "
 									}
 								}
 								ret += line
@@ -1134,15 +1165,28 @@ func (l langType) EmitTypeInfo() string {
 		}
 	}
 
-	// TODO look for overloaded types at this point
+	ret += "default: if(t>=TypeInfo.firstCreatedID) return CreatedTypeInfo.method(t,m); // a type created at run time by reflect\n"
+	ret += "}\n Scheduler.panicFromHaxe( " + `"no method found!"` + "); return null;}\n" // TODO improve error
 
-	ret += "default:}\n Scheduler.panicFromHaxe( " + `"no method found!"` + "); return null;}\n" // TODO improve error
-
-	pogo.WriteAsClass("MethodTypeInfo", ret+"}\n")
+	l.PogoComp.WriteAsClass("MethodTypeInfo", ret+"}\n")
 
 	return ""
 }
 
+// methodFn returns the Haxe function implementing the method sel in the method set of type T,
+// or "" if there is none, as for the methods of Haxe API types.
+func (l *langType) methodFn(T types.Type, sel *types.Selection) string {
+	funcObj, ok := sel.Obj().(*types.Func)
+	if !ok || funcObj.Pkg() == nil || sel.Recv() != T {
+		return ""
+	}
+	ss := strings.Split(funcObj.Pkg().Name(), "/")
+	if strings.HasPrefix(ss[len(ss)-1], "_") { // exclude functions in haxe for now
+		return ""
+	}
+	return "Go_" + l.LangName(funcObj.Pkg().Name()+":"+sel.Recv().String(), funcObj.Name()) + ".call"
+}
+
 func fixKeyWds(w string) string {
 	switch w {
 	case "new":
@@ -1193,9 +1237,40 @@ func loadStoreSuffix(T types.Type, hasParameters bool) string {
 	return "(" // no suffix, so some dynamic type
 }
 
+// hasRefs reports if values of type T may hold Haxe objects, rather than only the scalars that an Object holds as bytes,
+// so that Objects for the types that do not are made without the storage for them.
+func hasRefs(T types.Type) bool {
+	switch t := T.Underlying().(type) {
+	case *types.Basic:
+		switch t.Kind() {
+		case types.String, types.Uintptr, types.UnsafePointer:
+			return true
+		}
+		return false
+	case *types.Array:
+		return t.Len() > 0 && hasRefs(t.Elem())
+	case *types.Struct:
+		for f := 0; f < t.NumFields(); f++ {
+			if hasRefs(t.Field(f).Type()) {
+				return true
+			}
+		}
+		return false
+	}
+	return true // pointers, slices, maps, channels, functions and interfaces
+}
+
+// newObject returns the Haxe code to make an Object of the given size for values of type T.
+func newObject(size string, T types.Type) string {
+	if hasRefs(T) {
+		return "new Object(" + size + ",null,true)"
+	}
+	return "new Object(" + size + ")"
+}
+
 // Type definitions are only carried through to Haxe to allow access to objects as if they were native Haxe classes.
 // TODO consider renaming
-func (l langType) TypeStart(nt *types.Named, err string) string {
+func (l *langType) TypeStart(nt *types.Named, err string) string {
 	typName := "GoType" + l.LangName("", nt.String())
 	hxTyp := l.LangType(nt.Obj().Type(), false, nt.String())
 	ret := ""
@@ -1209,7 +1284,7 @@ func (l langType) TypeStart(nt *types.Named, err string) string {
 	switch nt.Underlying().(type) {
 	case *types.Struct:
 		str := nt.Underlying().(*types.Struct)
-		ret += "inline public function new(){ super new(" + strconv.Itoa(int(haxeStdSizes.Sizeof(nt.Obj().Type()))) + "); }\n"
+		ret += "inline public function new(){ super " + newObject(strconv.Itoa(int(haxeStdSizes.Sizeof(nt.Obj().Type()))), nt) + "; }\n"
 		flds := []string{}
 		for f := 0; f < str.NumFields(); f++ {
 			fName := str.Field(f).Name()
@@ -1236,7 +1311,7 @@ func (l langType) TypeStart(nt *types.Named, err string) string {
 			}
 		}
 	case *types.Array:
-		ret += "inline public function new(){ super new(" + strconv.Itoa(int(haxeStdSizes.Sizeof(nt.Obj().Type()))) + "); }\n"
+		ret += "inline public function new(){ super " + newObject(strconv.Itoa(int(haxeStdSizes.Sizeof(nt.Obj().Type()))), nt) + "; }\n"
 	default: // TODO not yet sure how to handle named types that are not structs
 		ret += "inline public function new(v:" + hxTyp + ") { this = v; }\n"
 	}
@@ -1296,11 +1371,11 @@ func (l langType) TypeStart(nt *types.Named, err string) string {
 		}
 	}
 
-	pogo.WriteAsClass(typName, ret+"}\n")
+	l.PogoComp.WriteAsClass(typName, ret+"}\n")
 
 	return "" //ret
 }
 
-//func (langType) TypeEnd(nt *types.Named, err string) string {
+//func (l *langType) TypeEnd(nt *types.Named, err string) string {
 //	return "" //"}"
 //}
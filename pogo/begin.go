@@ -13,68 +13,146 @@ import (
 
 	"golang.org/x/tools/go/exact"
 	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
-// global variables to save having to pass them about (TODO these, and other, status vars should be in a structure)
-var rootProgram *ssa.Program // pointer to the root datastructure TODO make this state non-global
-var mainPackage *ssa.Package // pointer to the "main" package TODO make this state non-global
+// Compilation holds the state of one transpilation of a Go program,
+// so that many programs may be compiled, one after another or concurrently, in the same process.
+type Compilation struct {
+	rootProgram *ssa.Program // pointer to the root datastructure
+	mainPackage *ssa.Package // pointer to the "main" package
 
-// DebugFlag is used to signal if we are emitting debug information
-var DebugFlag bool
+	DebugFlag     bool   // DebugFlag is used to signal if we are emitting debug information
+	TraceFlag     bool   // TraceFlag is used to signal if we are emitting trace information (big)
+	TargetPackage string // if set, overrides any special package constant in the Go code, otherwise set from that constant
+	OutputDir     string // the directory into which WriteFiles() puts the target language files, created if required
 
-// TraceFlag is used to signal if we are emitting trace information (big)
-var TraceFlag bool
+	TargetLang   int             // which entry in LanguageList is being targeted
+	LanguageList []LanguageEntry // the language entries for this compilation, copied from the package-level LanguageList
 
-// EntryPoint provides the entry point for the pogo package, called from ssadump_copy.
-func EntryPoint(mainPkg *ssa.Package) error {
-	mainPackage = mainPkg
-	rootProgram = mainPkg.Prog
+	hadErrors     bool
+	stopOnError   bool
+	warnings      []string        // Warnings are collected up and added to the end of the output code.
+	messagesGiven map[string]bool // This map de-dups error messages
+	diagnostics   []Diagnostic    // every error and warning logged, in order
 
-	for k, v := range LanguageList {
-		//fmt.Println("DEBUG Language List[", k, "]=", v.LanguageName())
-		if v.LanguageName() == "haxe" {
-			TargetLang = k
-			//fmt.Println("DEBUG Target Language =", k)
-			break
+	// DiagnosticHandler, if set, is called with each error or warning as it is logged,
+	// and errors are no longer written to stderr.
+	DiagnosticHandler func(Diagnostic)
+
+	PosHashFileList    []PosHashFileStruct // PosHashFileList holds the list of input go files with their posHash information
+	LatestValidPosHash PosHash             // LatestValidPosHash holds the latest valid PosHash value seen, for use when an invalid one requires a "near" reference.
+	latestValidPos     token.Pos           // the code position of LatestValidPosHash
+
+	TypesEncountered         typeutil.Map // TypesEncountered keeps track of the types we encounter using the excellent go.tools/go/types/typesmap package.
+	NextTypeID               int          // NextTypeID is used to give each type we come across its own ID - entry zero is invalid
+	catchReferencedTypesSeen map[string]bool
+
+	fnMap, grMap map[*ssa.Function]bool // which functions are used and if the functions use goroutines/channels
+
+	Cache        *BuildCache         // if set, the build cache, see cache.go
+	PackageFiles map[string][]string // the Go source files of each package, by package path, required for the package to be cached
+	trial        bool                // set for the trial compilation of presetTypeIDs(), which does not write to the build cache
+	cache        *cacheState
+
+	Reproducible bool              // if set, the output does not depend on where the files are or the order types are used, see reproducible.go
+	fileNames    map[string]string // the name used in the generated code for each Go file, if Reproducible
+
+	// If either is set, goroutine-capable functions give up control at the end of each loop iteration
+	// once the goroutine has run that many loop iterations or milliseconds, see loopEnds() in function.go.
+	PreemptLoops  int
+	PreemptMillis int
+
+	Jobs   int       // how many functions may be generated at once, see parallel.go
+	forked *funcCode // non-nil in a copy of the Compilation made to generate the code for one function
+
+	hxPkgName, headerText string
+	LibListNoDCE          []string // packages to keep in their entirety, from the special tardisgoLibList constant
+	previousErrorInfo     string   // used to give some indication of the error's location, even if it is not given
+}
+
+// NewCompilation sets up the state to compile the given main package (or test main package) into the named target language.
+func NewCompilation(mainPkg *ssa.Package, langName string) (*Compilation, error) {
+	targetLang, err := FindTargetLang(langName)
+	if err != nil {
+		return nil, err
+	}
+	comp := &Compilation{
+		mainPackage:              mainPkg,
+		rootProgram:              mainPkg.Prog,
+		OutputDir:                "tardis",
+		TargetLang:               targetLang,
+		LanguageList:             make([]LanguageEntry, len(LanguageList)),
+		stopOnError:              true, // TODO make this soft and default true
+		warnings:                 make([]string, 0),
+		messagesGiven:            make(map[string]bool),
+		PosHashFileList:          make([]PosHashFileStruct, 0),
+		LatestValidPosHash:       NoPosHash,
+		NextTypeID:               1, // entry zero is invalid
+		catchReferencedTypesSeen: make(map[string]bool),
+		LibListNoDCE:             []string{},
+		Jobs:                     1,
+	}
+	copy(comp.LanguageList, LanguageList)
+	for k := range comp.LanguageList {
+		if comp.LanguageList[k].InitLang != nil {
+			comp.LanguageList[k].Language = comp.LanguageList[k].InitLang(comp, &comp.LanguageList[k])
 		}
+		comp.LanguageList[k].buffer.posHash = &comp.LatestValidPosHash
 	}
+	return comp, nil
+}
 
-	setupPosHash()
-	loadSpecialConsts()
-	emitFileStart()
-	emitFunctions()
-	emitGoClass(mainPackage)
-	emitTypeInfo()
-	emitFileEnd()
-	if hadErrors && stopOnError {
+// Compile generates the target language code for the main package, holding the output files in memory, see Files().
+// If it returns ErrStaleCache, the program must be loaded again before it can be compiled, see BuildCache.
+func (comp *Compilation) Compile() error {
+	if comp.Reproducible && !comp.trial {
+		if err := comp.presetTypeIDs(); err != nil {
+			return err
+		}
+	}
+	comp.setupFileNames()
+	comp.setupPosHash()
+	comp.loadSpecialConsts()
+	if err := comp.loadCache(); err != nil {
+		return err
+	}
+	comp.emitFileStart()
+	if err := comp.emitFunctions(); err != nil {
+		return err
+	}
+	comp.emitGoClass(comp.mainPackage)
+	comp.emitTypeInfo()
+	comp.emitFileEnd()
+	if comp.hadErrors && comp.stopOnError {
 		err := fmt.Errorf("no output files generated")
-		LogError("", "pogo", err)
+		comp.LogError("", "pogo", err)
 		return err
 	}
-	writeFiles()
+	comp.finishFiles()
 	return nil
 }
 
 // The main Go class contains those elements that don't fit in functions
-func emitGoClass(mainPkg *ssa.Package) {
-	emitGoClassStart()
-	emitNamedConstants()
-	emitGlobals()
-	emitGoClassEnd(mainPkg)
-	WriteAsClass("Go", "")
+func (comp *Compilation) emitGoClass(mainPkg *ssa.Package) {
+	comp.emitGoClassStart()
+	comp.emitNamedConstants()
+	comp.emitGlobals()
+	comp.emitGoClassEnd(mainPkg)
+	comp.WriteAsClass("Go", "")
 }
 
 // special constant name used in TARDIS Go to put text in the header of files
 const pogoHeader = "tardisgoHeader"
 const pogoLibList = "tardisgoLibList"
 
-func loadSpecialConsts() {
+func (comp *Compilation) loadSpecialConsts() {
 	hxPkg := ""
-	l := TargetLang
-	ph := LanguageList[l].HeaderConstVarName
-	targetPackage := LanguageList[l].PackageConstVarName
+	l := comp.TargetLang
+	ph := comp.LanguageList[l].HeaderConstVarName
+	targetPackage := comp.LanguageList[l].PackageConstVarName
 	header := ""
-	allPack := rootProgram.AllPackages()
+	allPack := comp.rootProgram.AllPackages()
 	sort.Sort(PackageSorter(allPack))
 	for _, pkg := range allPack {
 		allMem := MemberNamesSorted(pkg)
@@ -88,7 +166,7 @@ func loadSpecialConsts() {
 					case exact.String:
 						h, err := strconv.Unquote(lit.Value.String())
 						if err != nil {
-							LogError(CodePosition(lit.Pos())+"Special pogo header constant "+ph+" or "+pogoHeader,
+							comp.LogErrorAt(mem.Pos(), comp.CodePosition(lit.Pos())+"Special pogo header constant "+ph+" or "+pogoHeader,
 								"pogo", err)
 						} else {
 							header += h + "\n"
@@ -100,11 +178,11 @@ func loadSpecialConsts() {
 					case exact.String:
 						hp, err := strconv.Unquote(lit.Value.String())
 						if err != nil {
-							LogError(CodePosition(lit.Pos())+"Special targetPackage constant ", "pogo", err)
+							comp.LogErrorAt(mem.Pos(), comp.CodePosition(lit.Pos())+"Special targetPackage constant ", "pogo", err)
 						}
 						hxPkg = hp
 					default:
-						LogError(CodePosition(lit.Pos()), "pogo",
+						comp.LogErrorAt(mem.Pos(), comp.CodePosition(lit.Pos()), "pogo",
 							fmt.Errorf("special targetPackage constant not a string"))
 					}
 				case pogoLibList:
@@ -113,64 +191,66 @@ func loadSpecialConsts() {
 					case exact.String:
 						lrp, err := strconv.Unquote(lit.Value.String())
 						if err != nil {
-							LogError(CodePosition(lit.Pos())+"Special "+pogoLibList+" constant ", "pogo", err)
+							comp.LogErrorAt(mem.Pos(), comp.CodePosition(lit.Pos())+"Special "+pogoLibList+" constant ", "pogo", err)
 						}
-						LibListNoDCE = strings.Split(lrp, ",")
-						for lib := range LibListNoDCE {
-							LibListNoDCE[lib] = strings.TrimSpace(LibListNoDCE[lib])
+						comp.LibListNoDCE = strings.Split(lrp, ",")
+						for lib := range comp.LibListNoDCE {
+							comp.LibListNoDCE[lib] = strings.TrimSpace(comp.LibListNoDCE[lib])
 						}
 					default:
-						LogError(CodePosition(lit.Pos()), "pogo",
+						comp.LogErrorAt(mem.Pos(), comp.CodePosition(lit.Pos()), "pogo",
 							fmt.Errorf("special targetPackage constant not a string"))
 					}
 				}
 			}
 		}
 	}
-	hxPkgName = hxPkg
-	headerText = header
+	if comp.TargetPackage == "" {
+		comp.TargetPackage = hxPkg
+	}
+	comp.hxPkgName = comp.TargetPackage
+	comp.headerText = header
 }
 
-var hxPkgName, headerText string
-var LibListNoDCE = []string{}
-
 // emit the standard file header for target language
-func emitFileStart() {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].FileStart(hxPkgName, headerText))
+func (comp *Compilation) emitFileStart() {
+	l := comp.TargetLang
+	comp.LanguageList[l].buffer.noPos = true // the header does not come from any Go code
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].FileStart(comp.hxPkgName, comp.headerText))
+	comp.LanguageList[l].buffer.noPos = false
 }
 
 // emit the tail of the required language file
-func emitFileEnd() {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].FileEnd())
-	for w := range warnings {
-		emitComment(warnings[w])
+func (comp *Compilation) emitFileEnd() {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].FileEnd())
+	for w := range comp.warnings {
+		comp.emitComment(comp.warnings[w])
 	}
-	emitComment("Package List:")
-	allPack := rootProgram.AllPackages()
+	comp.emitComment("Package List:")
+	allPack := comp.rootProgram.AllPackages()
 	sort.Sort(PackageSorter(allPack))
 	for pkgIdx := range allPack {
-		emitComment(" " + allPack[pkgIdx].String())
+		comp.emitComment(" " + allPack[pkgIdx].String())
 	}
 }
 
 // emit the start of the top level type definition for each language
-func emitGoClassStart() {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].GoClassStart())
+func (comp *Compilation) emitGoClassStart() {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].GoClassStart())
 }
 
 // emit the end of the top level type definition for each language file
-func emitGoClassEnd(pak *ssa.Package) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].GoClassEnd(pak))
+func (comp *Compilation) emitGoClassEnd(pak *ssa.Package) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].GoClassEnd(pak))
 }
 
-func UsingPackage(pkgName string) bool {
+func (comp *Compilation) UsingPackage(pkgName string) bool {
 	//println("DEBUG UsingPackage() looking for: ", pkgName)
 	pkgName = "package " + pkgName
-	pkgs := rootProgram.AllPackages()
+	pkgs := comp.rootProgram.AllPackages()
 	for p := range pkgs {
 		//println("DEBUG UsingPackage() considering pkg: ", pkgs[p].String())
 		if pkgs[p].String() == pkgName {
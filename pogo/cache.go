@@ -0,0 +1,1098 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pogo
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/tardisgo/tardisgo/tgossa"
+
+	"golang.org/x/tools/go/importer"
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types"
+)
+
+// The build cache holds the code generated for the functions of each package, so that a later compilation
+// in which the package is unchanged need not type-check the bodies of its functions, build their SSA form, or generate their code.
+//
+// The entry of a package is found by a key made from its path and the content of its Go source files,
+// so that BuildCache.Skip() can tell the loader which packages need their function bodies type-checked, before they are loaded.
+// The entry is then only used if the export data of the package, and of every package it depends on, is unchanged.
+//
+// As the code of a package that is not built cannot be visited, the entry records the Visit of each of its functions,
+// so that tgossa.VisitedFunctions() finds the functions used, and which of them use goroutines, as if it had been built.
+// Functions that only exist once the package is built, such as function literals and thunks, are recorded with the package.
+// The code generated for a function refers to others by name, and depends on whether they use goroutines and have code,
+// so these are recorded for each function it refers to, and checked once the program has been visited.
+//
+// The code is cached with the placeholders for type IDs and PosHash values made by the copy of the Compilation that generated it,
+// see parallel.go, which are given their values as it is merged, so the code does not depend on the rest of the program.
+// The types that the code uses are recorded as the export data of a package declaring a variable of each type.
+//
+// If the entry of a package that was not built turns out not to be usable, Compile() returns ErrStaleCache,
+// and the program must be loaded again, so that the package is type-checked and built.
+
+// cacheVersion must be changed whenever the generated code or the entries change, to invalidate existing build caches.
+const cacheVersion = "tardisgo-cache-3"
+
+// ErrStaleCache is returned by Compile() if the build cache entry of a package that was not type-checked or built cannot be used.
+// The program should then be loaded again, with the same BuildCache, which no longer skips that package.
+var ErrStaleCache = errors.New("the build cache is out of date")
+
+// BuildCache is a build cache directory, as used by the compilations of one program.
+type BuildCache struct {
+	Dir     string // the directory of the build cache, created if required
+	Options string // the options that the loaded program depends on, such as the build tags, part of the key of each entry
+
+	mu      sync.Mutex
+	keys    map[string]string        // the key of each package, by path
+	entries map[string]*packageEntry // the entry of each package that is not type-checked or built, by path
+	stale   map[string]bool          // the packages whose entries could not be used
+	errs    []error                  // problems reading the build cache, to be given as warnings
+}
+
+// NewBuildCache returns the BuildCache in the directory.
+func NewBuildCache(dir, options string) *BuildCache {
+	return &BuildCache{
+		Dir:     dir,
+		Options: options,
+		keys:    make(map[string]string),
+		entries: make(map[string]*packageEntry),
+		stale:   make(map[string]bool),
+	}
+}
+
+// Skip returns true if a package has an entry in the build cache, so its function bodies need not be type-checked or built.
+// The files are the Go source files of the package, or nil if they are not known.
+// It is called as each package is loaded, see loader.Config.TypeCheckFuncBodies.
+func (bc *BuildCache) Skip(path string, files []string) bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	delete(bc.keys, path)
+	delete(bc.entries, path)
+	if files == nil {
+		return false
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\npackage %s\n", cacheVersion, bc.Options, path)
+	for _, name := range files {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return false // the loader will report the error
+		}
+		fmt.Fprintf(h, "file %s %d\n", name, len(data))
+		h.Write(data)
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+	bc.keys[path] = key
+	if bc.stale[path] {
+		return false
+	}
+	entry, err := bc.read(key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			bc.errs = append(bc.errs, fmt.Errorf("unable to read from the build cache: %s", err))
+		}
+		return false
+	}
+	bc.entries[path] = entry
+	return true
+}
+
+// Skipped returns true if a package was not to be type-checked or built, because it has an entry in the build cache.
+func (bc *BuildCache) Skipped(path string) bool {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.entries[path] != nil
+}
+
+func (bc *BuildCache) key(path string) string {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return bc.keys[path]
+}
+
+// skipped returns the entries of the packages that were not type-checked or built.
+func (bc *BuildCache) skipped() map[string]*packageEntry {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	entries := make(map[string]*packageEntry, len(bc.entries))
+	for path, entry := range bc.entries {
+		entries[path] = entry
+	}
+	return entries
+}
+
+// markStale stops the entry of a package from being used again.
+func (bc *BuildCache) markStale(path string) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if bc.entries[path] == nil { // not expected, but loading the program again must make progress
+		for p := range bc.entries {
+			bc.stale[p] = true
+		}
+	}
+	bc.stale[path] = true
+}
+
+func (bc *BuildCache) takeErrors() []error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	errs := bc.errs
+	bc.errs = nil
+	return errs
+}
+
+func (bc *BuildCache) fileName(key string) string {
+	return filepath.Join(bc.Dir, key+".gob")
+}
+
+func (bc *BuildCache) read(key string) (*packageEntry, error) {
+	f, err := os.Open(bc.fileName(key))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entry := &packageEntry{}
+	if err := gob.NewDecoder(f).Decode(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// write writes via a temporary file, so that concurrent compilations never see part of an entry.
+func (bc *BuildCache) write(key string, entry *packageEntry) error {
+	if err := os.MkdirAll(bc.Dir, os.ModePerm); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(bc.Dir, key+".tmp")
+	if err != nil {
+		return err
+	}
+	err = gob.NewEncoder(f).Encode(entry)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), bc.fileName(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// packageEntry is what is stored in the build cache for a package.
+type packageEntry struct {
+	Files        []string          // the Go source files of the package
+	Export       string            // the hash of the export data of the package
+	Imports      map[string]string // the hash of the export data of every package it depends on, by path
+	Options      string            // the options of the compilation that the code depends on, see codeOptions()
+	Types        []byte            // the types used, see encodeTypes()
+	Tuples       []bool            // which of the types are tuples
+	RuntimeTypes []int             // the types whose method sets the code of the package requires
+	Funcs        []*cachedFunc     // the functions of the package, and those without a package that its code refers to
+}
+
+// cachedFunc is a function in a build cache entry, types are given by their index in the entry's Types.
+type cachedFunc struct {
+	Name       string // as given by ssa.Function.String()
+	Class      string // the package and name parts of the class name, to avoid emitting a duplicate thunk
+	Overloaded bool
+	HasCode    bool
+	Steps      []int       // the Steps of its Visit, the index in Refs of the function referred to, or -1 for the use of goroutines
+	Refs       []cachedRef // the functions referred to
+	UsesGR     bool
+	Object     *cachedRef  // for a function without a package or receiver, the object that its class name is made from
+	Pos        string      // the position of that object
+	Code       *cachedCode // nil if the code cannot be cached
+}
+
+// cachedRef refers to a function: a package member or a declared method, which exist without building its package,
+// or a Local function, only known by its Name in the same build cache entry.
+type cachedRef struct {
+	Name    string
+	Local   bool
+	Pkg     string // the package path
+	Recv    string // the name of the receiver type of a method
+	Fn      string
+	Path    string // the FuncPathName() path that the generated code names the function by
+	UsesGR  bool
+	HasCode bool
+}
+
+// cachedCode is the code generated for a function, with the type IDs and PosHash values as placeholders.
+type cachedCode struct {
+	Files        []FileOutput
+	TypeUses     []int          // the types used, in the order first used
+	PosFiles     map[int]string // the name of each file, by the index given in the PosHash placeholders
+	Messages     []message
+	EndFile      string // the position state at the end of the function, EndFile is "" if there is none
+	EndLine      int
+	EndOffset    int
+	EndErrorInfo string
+}
+
+// cachedPackage is the entry of a package that was not built, in use by a Compilation.
+type cachedPackage struct {
+	path         string
+	entry        *packageEntry
+	types        []types.Type
+	runtimeTypes []types.Type
+	funcs        map[string]*cachedFunc // by Name
+	visits       map[*cachedFunc]*tgossa.Visit
+}
+
+// cachedVisit is a function visited using the build cache.
+type cachedVisit struct {
+	pkg     *cachedPackage
+	cf      *cachedFunc
+	fn      *ssa.Function   // nil for a Local function
+	refs    []*ssa.Function // the functions in cf.Refs that are not Local
+	visited bool
+	usesGR  bool
+}
+
+// cacheState is the state of the build cache in a Compilation, it implements tgossa.Cache.
+type cacheState struct {
+	prog     *ssa.Program
+	packages map[string]*cachedPackage       // the packages that were not built, by path
+	byPath   map[string]*ssa.Package         // every package in the program, by path
+	exports  map[*types.Package]string       // the hash of the export data of each package
+	bases    map[string]int                  // the BasePosHash of each file, by name
+	files    map[string]*token.File          // each file, by name
+	visits   map[*ssa.Function]*tgossa.Visit // the Visit of each function visited
+	cached   map[*tgossa.Visit]*cachedVisit  // the Visits made from the build cache
+	funcs    map[*ssa.Function]*cachedVisit  // those of functions in the program
+	stale    map[string]bool                 // the packages whose entries turned out to be out of date as they were visited
+}
+
+// codeOptions describes the options of the compilation that the generated code depends on.
+func (comp *Compilation) codeOptions() string {
+	l := comp.TargetLang
+	return fmt.Sprintf("%s debug=%v trace=%v reproducible=%v preempt=%d,%dms package=%q header=%q test=%q %q",
+		comp.LanguageList[l].LanguageName(), comp.DebugFlag, comp.TraceFlag, comp.Reproducible, comp.PreemptLoops, comp.PreemptMillis,
+		comp.hxPkgName, comp.headerText, comp.LanguageList[l].TestFS, comp.LanguageList[l].TestNames)
+}
+
+// loadCache imports the types of the build cache entries of the packages that were not built, checking that they can be used.
+func (comp *Compilation) loadCache() error {
+	if comp.Cache == nil {
+		return nil
+	}
+	if !comp.trial {
+		for _, err := range comp.Cache.takeErrors() {
+			comp.LogWarning(comp.Cache.Dir, "pogo", err)
+		}
+	}
+	cs := &cacheState{
+		prog:     comp.rootProgram,
+		packages: make(map[string]*cachedPackage),
+		byPath:   make(map[string]*ssa.Package),
+		exports:  make(map[*types.Package]string),
+		bases:    make(map[string]int),
+		files:    make(map[string]*token.File),
+		visits:   make(map[*ssa.Function]*tgossa.Visit),
+		cached:   make(map[*tgossa.Visit]*cachedVisit),
+		funcs:    make(map[*ssa.Function]*cachedVisit),
+		stale:    make(map[string]bool),
+	}
+	comp.cache = cs
+	for _, pkg := range comp.rootProgram.AllPackages() {
+		cs.byPath[pkg.Object.Path()] = pkg
+	}
+	for _, f := range comp.PosHashFileList {
+		cs.bases[f.FileName] = f.BasePosHash
+	}
+	comp.rootProgram.Fset.Iterate(func(f *token.File) bool {
+		cs.files[comp.fileName(f.Name())] = f
+		return true
+	})
+	for path, entry := range comp.Cache.skipped() {
+		cp := &cachedPackage{
+			path:   path,
+			entry:  entry,
+			funcs:  make(map[string]*cachedFunc),
+			visits: make(map[*cachedFunc]*tgossa.Visit),
+		}
+		if !comp.usableEntry(cp) {
+			cs.stale[path] = true
+			continue
+		}
+		for _, cf := range entry.Funcs {
+			cp.funcs[cf.Name] = cf
+		}
+		cs.packages[path] = cp
+	}
+	return comp.staleCache()
+}
+
+// usableEntry checks that the entry of a package describes it as it is now, and imports its types.
+func (comp *Compilation) usableEntry(cp *cachedPackage) bool {
+	cs := comp.cache
+	entry := cp.entry
+	pkg := cs.byPath[cp.path]
+	if pkg == nil || !stringsEqual(entry.Files, comp.PackageFiles[cp.path]) || entry.Options != comp.codeOptions() ||
+		entry.Export != cs.exportHash(pkg.Object) {
+		return false
+	}
+	imports := cs.dependencyHashes(pkg.Object)
+	if imports == nil || len(imports) != len(entry.Imports) {
+		return false
+	}
+	for path, hash := range imports {
+		if entry.Imports[path] != hash {
+			return false
+		}
+	}
+	cp.types = comp.decodeTypes(entry.Types, entry.Tuples)
+	if cp.types == nil {
+		return false
+	}
+	for _, t := range entry.RuntimeTypes {
+		cp.runtimeTypes = append(cp.runtimeTypes, cp.types[t])
+	}
+	return true
+}
+
+// staleCache stops the entries of the packages found to be out of date from being used, returning ErrStaleCache if there are any.
+func (comp *Compilation) staleCache() error {
+	if len(comp.cache.stale) == 0 {
+		return nil
+	}
+	for path := range comp.cache.stale {
+		comp.Cache.markStale(path)
+	}
+	return ErrStaleCache
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// exportHash returns the hash of the export data of a package, or "" if the export data cannot describe it.
+func (cs *cacheState) exportHash(pkg *types.Package) (hash string) {
+	if h, done := cs.exports[pkg]; done {
+		return h
+	}
+	if pkg == types.Unsafe {
+		return "unsafe" // it cannot be exported, but never changes
+	}
+	defer func() {
+		if recover() != nil {
+			hash = ""
+		}
+		cs.exports[pkg] = hash
+	}()
+	sum := sha256.Sum256(importer.ExportData(pkg))
+	return hex.EncodeToString(sum[:])
+}
+
+// dependencyHashes returns the hash of the export data of every package that a package depends on, by path,
+// or nil if there is one that the export data cannot describe.
+func (cs *cacheState) dependencyHashes(pkg *types.Package) map[string]string {
+	hashes := make(map[string]string)
+	var add func(p *types.Package) bool
+	add = func(p *types.Package) bool {
+		for _, imp := range p.Imports() {
+			if _, done := hashes[imp.Path()]; done {
+				continue
+			}
+			hash := cs.exportHash(imp)
+			if hash == "" {
+				return false
+			}
+			hashes[imp.Path()] = hash
+			if !add(imp) {
+				return false
+			}
+		}
+		return true
+	}
+	if !add(pkg) {
+		return nil
+	}
+	return hashes
+}
+
+// cacheTypesPath is the path of the package that the types used by the code of a package are exported as.
+const cacheTypesPath = "tardisgo-cache-types"
+
+// encodeTypes returns the export data of a package that declares a variable of each type, Ti for the i'th type,
+// a tuple is declared as the results of a function type.
+func encodeTypes(typs []types.Type) (data []byte, tuples []bool) {
+	pkg := types.NewPackage(cacheTypesPath, "types")
+	tuples = make([]bool, len(typs))
+	for i, t := range typs {
+		if tuple, ok := t.(*types.Tuple); ok {
+			t = types.NewSignature(nil, nil, nil, tuple, false)
+			tuples[i] = true
+		}
+		pkg.Scope().Insert(types.NewVar(token.NoPos, pkg, fmt.Sprintf("T%d", i), t))
+	}
+	return importer.ExportData(pkg), tuples
+}
+
+// decodeTypes imports the types given by encodeTypes(), returning nil if they are not all types of the program.
+func (comp *Compilation) decodeTypes(data []byte, tuples []bool) (typs []types.Type) {
+	imports := map[string]*types.Package{"unsafe": types.Unsafe}
+	scopeLens := make(map[*types.Package]int)
+	for _, pkg := range comp.rootProgram.AllPackages() {
+		imports[pkg.Object.Path()] = pkg.Object
+		scopeLens[pkg.Object] = pkg.Object.Scope().Len()
+	}
+	numImports := len(imports)
+	defer func() {
+		if recover() != nil {
+			typs = nil
+		}
+	}()
+	_, pkg, err := importer.ImportData(imports, data)
+	if err != nil || len(imports) != numImports+1 {
+		return nil // a package of the types is not in the program
+	}
+	for p, n := range scopeLens {
+		if p.Scope().Len() != n {
+			return nil // a named type is not in the program, so the importer declared it
+		}
+	}
+	typs = make([]types.Type, len(tuples))
+	for i := range typs {
+		v, ok := pkg.Scope().Lookup(fmt.Sprintf("T%d", i)).(*types.Var)
+		if !ok {
+			return nil
+		}
+		typs[i] = v.Type()
+		if tuples[i] {
+			typs[i] = typs[i].(*types.Signature).Results()
+		}
+	}
+	return typs
+}
+
+// exportable returns true if the export data can describe a type, so that it can be imported into a later program.
+func exportable(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Basic:
+		return t == types.Typ[t.Kind()] && t.Kind() != types.Invalid || t == types.UniverseByte || t == types.UniverseRune
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() == nil {
+			return obj == types.Universe.Lookup(obj.Name()) // error
+		}
+		return obj.Parent() == obj.Pkg().Scope() // so not declared in a function
+	case *types.Pointer:
+		return exportable(t.Elem())
+	case *types.Slice:
+		return exportable(t.Elem())
+	case *types.Array:
+		return exportable(t.Elem())
+	case *types.Chan:
+		return exportable(t.Elem())
+	case *types.Map:
+		return exportable(t.Key()) && exportable(t.Elem())
+	case *types.Struct:
+		for i := 0; i < t.NumFields(); i++ {
+			if !exportable(t.Field(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Tuple:
+		for i := 0; i < t.Len(); i++ {
+			if !exportable(t.At(i).Type()) {
+				return false
+			}
+		}
+		return true
+	case *types.Signature:
+		if recv := t.Recv(); recv != nil && !exportable(recv.Type()) {
+			return false
+		}
+		return exportable(t.Params()) && exportable(t.Results())
+	case *types.Interface:
+		for i := 0; i < t.NumEmbeddeds(); i++ {
+			if !exportable(t.Embedded(i)) {
+				return false
+			}
+		}
+		for i := 0; i < t.NumExplicitMethods(); i++ {
+			sig := t.ExplicitMethod(i).Type().(*types.Signature) // the receiver is not exported
+			if !exportable(sig.Params()) || !exportable(sig.Results()) {
+				return false
+			}
+		}
+		return true
+	}
+	return false // for example the type of map iterators
+}
+
+// typeTable numbers the types used by the code of a package, for its build cache entry.
+// Types are only shared if they have the same name, as well as being identical, as the name may be in the generated code.
+type typeTable struct {
+	typs   []types.Type
+	byName map[string][]int
+}
+
+func (tt *typeTable) add(t types.Type) (int, bool) {
+	name := t.String()
+	for _, i := range tt.byName[name] {
+		if types.Identical(tt.typs[i], t) {
+			return i, true
+		}
+	}
+	if !exportable(t) {
+		return 0, false
+	}
+	i := len(tt.typs)
+	tt.typs = append(tt.typs, t)
+	tt.byName[name] = append(tt.byName[name], i)
+	return i, true
+}
+
+// Visit implements tgossa.Cache, giving the recorded Visit of a function of a package that was not built.
+func (cs *cacheState) Visit(fn *ssa.Function) *tgossa.Visit {
+	if fn.Pkg == nil {
+		return nil
+	}
+	cp := cs.packages[fn.Pkg.Object.Path()]
+	if cp == nil {
+		return nil // it really has no code
+	}
+	cf := cp.funcs[fn.String()]
+	if cf == nil {
+		cs.stale[cp.path] = true // not used when the entry was made
+		return nil
+	}
+	return cs.visit(cp, cf, fn)
+}
+
+func (cs *cacheState) visit(cp *cachedPackage, cf *cachedFunc, fn *ssa.Function) *tgossa.Visit {
+	if v := cp.visits[cf]; v != nil {
+		return v
+	}
+	v := &tgossa.Visit{Overloaded: cf.Overloaded, External: !cf.HasCode}
+	cv := &cachedVisit{pkg: cp, cf: cf, fn: fn, refs: make([]*ssa.Function, len(cf.Refs))}
+	cp.visits[cf] = v // before the Visits of the functions it refers to, which may refer back to it
+	cs.cached[v] = cv
+	if fn != nil {
+		cs.funcs[fn] = cv
+	}
+	locals := make([]*tgossa.Visit, len(cf.Refs))
+	for i, r := range cf.Refs {
+		if r.Local {
+			if lf := cp.funcs[r.Name]; lf != nil {
+				locals[i] = cs.visit(cp, lf, nil)
+			}
+		} else {
+			cv.refs[i] = cs.refFunc(&cf.Refs[i])
+		}
+		if locals[i] == nil && cv.refs[i] == nil {
+			cs.stale[cp.path] = true
+		}
+	}
+	for _, s := range cf.Steps {
+		switch {
+		case s < 0:
+			v.Steps = append(v.Steps, tgossa.Step{})
+		case locals[s] != nil:
+			v.Steps = append(v.Steps, tgossa.Step{Local: locals[s]})
+		case cv.refs[s] != nil:
+			v.Steps = append(v.Steps, tgossa.Step{Fn: cv.refs[s]})
+		}
+	}
+	return v
+}
+
+// Visited implements tgossa.Cache, noting the Visit of each function,
+// and whether those visited using the build cache use goroutines.
+func (cs *cacheState) Visited(fn *ssa.Function, v *tgossa.Visit, usesGR bool) {
+	if fn != nil {
+		cs.visits[fn] = v
+	}
+	if cv := cs.cached[v]; cv != nil {
+		cv.visited = true
+		cv.usesGR = usesGR
+	}
+}
+
+// RuntimeTypes implements tgossa.Cache, giving the types whose method sets the code of the packages that were not built requires.
+func (cs *cacheState) RuntimeTypes() []types.Type {
+	paths := make([]string, 0, len(cs.packages))
+	for path := range cs.packages {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	typs := []types.Type{}
+	for _, path := range paths {
+		typs = append(typs, cs.packages[path].runtimeTypes...)
+	}
+	return typs
+}
+
+// refFunc finds the function that a cachedRef, which is not Local, refers to.
+func (cs *cacheState) refFunc(r *cachedRef) *ssa.Function {
+	pkg := cs.byPath[r.Pkg]
+	if pkg == nil {
+		return nil
+	}
+	if r.Recv == "" {
+		return pkg.Func(r.Fn)
+	}
+	if f, ok := cs.lookupObject(r).(*types.Func); ok {
+		return cs.prog.FuncValue(f)
+	}
+	return nil
+}
+
+// lookupObject finds the package-level object, or method, that a cachedRef refers to.
+func (cs *cacheState) lookupObject(r *cachedRef) types.Object {
+	pkg := cs.byPath[r.Pkg]
+	if pkg == nil {
+		return nil
+	}
+	obj := pkg.Object.Scope().Lookup(r.Recv)
+	if r.Recv == "" {
+		return pkg.Object.Scope().Lookup(r.Fn)
+	}
+	tn, ok := obj.(*types.TypeName)
+	if !ok {
+		return nil
+	}
+	named, ok := tn.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+	if iface, ok := named.Underlying().(*types.Interface); ok {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if m := iface.Method(i); m.Name() == r.Fn {
+				return m
+			}
+		}
+		return nil
+	}
+	for i := 0; i < named.NumMethods(); i++ {
+		if m := named.Method(i); m.Name() == r.Fn {
+			return m
+		}
+	}
+	return nil
+}
+
+// HasCode returns true if a function has code, which may come from the build cache.
+func (comp *Compilation) HasCode(fn *ssa.Function) bool {
+	if len(fn.Blocks) > 0 {
+		return true
+	}
+	if comp.cache != nil {
+		if cv := comp.cache.funcs[fn]; cv != nil {
+			return cv.cf.HasCode
+		}
+	}
+	return false
+}
+
+// checkCachedVisits checks that the cached code of the functions visited can be used,
+// now that it is known which functions use goroutines, returning ErrStaleCache if not.
+func (comp *Compilation) checkCachedVisits() error {
+	if comp.cache == nil {
+		return nil
+	}
+	for _, cv := range comp.cache.cached {
+		if cv.visited && !comp.usableCode(cv) {
+			comp.cache.stale[cv.pkg.path] = true
+		}
+	}
+	return comp.staleCache()
+}
+
+func (comp *Compilation) usableCode(cv *cachedVisit) bool {
+	cs := comp.cache
+	cf := cv.cf
+	if cf.Code == nil || cv.usesGR != cf.UsesGR {
+		return false
+	}
+	for i, r := range cf.Refs {
+		if r.Local {
+			lv := cs.cached[cv.pkg.visits[cv.pkg.funcs[r.Name]]]
+			if lv == nil || lv.usesGR != r.UsesGR {
+				return false
+			}
+			continue
+		}
+		fn := cv.refs[i]
+		if fn == nil {
+			return false
+		}
+		if path, _ := comp.FuncPathName(fn); path != r.Path || comp.grMap[fn] != r.UsesGR || comp.HasCode(fn) != r.HasCode {
+			return false
+		}
+	}
+	if cf.Object != nil {
+		obj := cs.lookupObject(cf.Object)
+		if obj == nil || comp.position(obj.Pos()).String() != cf.Pos {
+			return false
+		}
+	}
+	for _, name := range cf.Code.PosFiles {
+		if _, found := cs.bases[name]; !found {
+			return false
+		}
+	}
+	if cf.Code.EndFile != "" {
+		f := cs.files[cf.Code.EndFile]
+		if _, found := cs.bases[cf.Code.EndFile]; !found || f == nil || cf.Code.EndOffset > f.Size() {
+			return false
+		}
+	}
+	return true
+}
+
+// funcItem is a function to emit, with its code if that comes from the build cache.
+type funcItem struct {
+	name string
+	fn   *ssa.Function // nil for a function only known from the build cache
+	pkg  *cachedPackage
+	code *cachedCode
+}
+
+type funcItemSorter []funcItem
+
+func (a funcItemSorter) Len() int           { return len(a) }
+func (a funcItemSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a funcItemSorter) Less(i, j int) bool { return a[i].name < a[j].name }
+
+// funcItems lists the functions to emit in name order, using the build cache for those of the packages that were not built,
+// and adding the functions only known from the build cache, unless a function of the same class is already listed.
+func (comp *Compilation) funcItems(fns []*ssa.Function) []funcItem {
+	items := make([]funcItem, 0, len(fns))
+	for _, fn := range fns {
+		items = append(items, funcItem{name: fn.String(), fn: fn})
+	}
+	if comp.cache == nil {
+		return items
+	}
+	classes := make(map[string]bool)
+	for i, it := range items {
+		if cv := comp.cache.funcs[it.fn]; cv != nil {
+			items[i].pkg, items[i].code = cv.pkg, cv.cf.Code
+		}
+		p, n := comp.GetFnNameParts(it.fn)
+		classes[p+"."+n] = true
+	}
+	locals := []funcItem{}
+	for _, cv := range comp.cache.cached {
+		if cv.fn == nil && cv.visited {
+			locals = append(locals, funcItem{name: cv.cf.Name, pkg: cv.pkg, code: cv.cf.Code})
+		}
+	}
+	sort.Sort(funcItemSorter(locals))
+	for _, it := range locals {
+		if class := it.pkg.funcs[it.name].Class; !classes[class] {
+			classes[class] = true
+			items = append(items, it)
+		}
+	}
+	sort.Sort(funcItemSorter(items))
+	return items
+}
+
+// useCachedCode adds the cached code of a function to the Compilation, as mergeFunc() does for the code generated.
+func (comp *Compilation) useCachedCode(cp *cachedPackage, code *cachedCode) {
+	l := comp.TargetLang
+	cs := comp.cache
+	ids := make([]string, len(code.TypeUses))
+	for i, t := range code.TypeUses {
+		ids[i] = comp.LogTypeUse(cp.types[t])
+	}
+	posHash := func(file, line int) int {
+		return cs.bases[code.PosFiles[file]] + line
+	}
+	for _, fo := range code.Files {
+		comp.LanguageList[l].files = append(comp.LanguageList[l].files,
+			FileOutput{fo.Filename, relocate(fo.Data, ids, posHash)})
+	}
+	for _, m := range code.Messages {
+		comp.addMessage(m)
+	}
+	comp.LatestValidPosHash = NoPosHash
+	comp.latestValidPos = token.NoPos
+	if code.EndFile != "" {
+		comp.LatestValidPosHash = PosHash(cs.bases[code.EndFile] + code.EndLine)
+		comp.latestValidPos = cs.files[code.EndFile].Pos(code.EndOffset)
+	}
+	comp.previousErrorInfo = code.EndErrorInfo
+}
+
+// storeCache writes the build cache entries of the packages that were built.
+func (comp *Compilation) storeCache(generated map[*ssa.Function]*funcCode) {
+	if comp.Cache == nil || comp.trial {
+		return
+	}
+	byClass := make(map[string]*funcCode) // for the thunks not emitted, as they duplicate another
+	pkgFuncs := make(map[*ssa.Package][]*ssa.Function)
+	for fn := range comp.cache.visits {
+		if fn.Pkg != nil {
+			pkgFuncs[fn.Pkg] = append(pkgFuncs[fn.Pkg], fn)
+		} else if code := generated[fn]; code != nil {
+			p, n := comp.GetFnNameParts(fn)
+			byClass[p+"."+n] = code
+		}
+	}
+	for _, pkg := range comp.rootProgram.AllPackages() {
+		path := pkg.Object.Path()
+		key := comp.Cache.key(path)
+		if key == "" || comp.Cache.Skipped(path) || comp.rootProgram.ImportedPackage(path) != pkg || comp.PackageFiles[path] == nil {
+			continue
+		}
+		entry := comp.packageEntry(pkg, pkgFuncs[pkg], generated, byClass)
+		if entry == nil {
+			continue
+		}
+		if err := comp.Cache.write(key, entry); err != nil {
+			comp.LogWarning(comp.Cache.Dir, "pogo", fmt.Errorf("unable to write to the build cache: %s", err))
+			return
+		}
+	}
+}
+
+// packageEntry makes the build cache entry of a package that was built, or returns nil if it cannot be cached.
+// It includes the functions of any earlier entry for the same package that were not used by this program.
+func (comp *Compilation) packageEntry(pkg *ssa.Package, fns []*ssa.Function,
+	generated map[*ssa.Function]*funcCode, byClass map[string]*funcCode) *packageEntry {
+	cs := comp.cache
+	path := pkg.Object.Path()
+	entry := &packageEntry{
+		Files:   comp.PackageFiles[path],
+		Export:  cs.exportHash(pkg.Object),
+		Imports: cs.dependencyHashes(pkg.Object),
+		Options: comp.codeOptions(),
+	}
+	if entry.Export == "" || entry.Imports == nil {
+		return nil
+	}
+	tt := &typeTable{byName: make(map[string][]int)}
+	for _, t := range pkg.TypesWithMethodSets() {
+		i, ok := tt.add(t)
+		if !ok {
+			return nil
+		}
+		entry.RuntimeTypes = append(entry.RuntimeTypes, i)
+	}
+	sort.Sort(fnMapSorter(fns))
+	added := make(map[*ssa.Function]bool)
+	for _, fn := range fns {
+		added[fn] = true
+	}
+	for len(fns) > 0 {
+		fn := fns[0]
+		fns = fns[1:]
+		code := generated[fn]
+		if code == nil && fn.Pkg == nil {
+			p, n := comp.GetFnNameParts(fn)
+			code = byClass[p+"."+n]
+		}
+		cf, locals := comp.cachedFunc(fn, pkg, code, tt)
+		if cf == nil {
+			return nil
+		}
+		for _, lf := range locals {
+			if !added[lf] {
+				added[lf] = true
+				fns = append(fns, lf)
+			}
+		}
+		entry.Funcs = append(entry.Funcs, cf)
+	}
+	if old, err := comp.Cache.read(comp.Cache.key(path)); err == nil && old.Export == entry.Export &&
+		old.Options == entry.Options && stringsEqual(old.Files, entry.Files) {
+		comp.mergeEntry(entry, old, tt)
+	}
+	entry.Types, entry.Tuples = encodeTypes(tt.typs)
+	return entry
+}
+
+// mergeEntry adds the functions of an earlier entry for the same package that are not in the entry.
+func (comp *Compilation) mergeEntry(entry, old *packageEntry, tt *typeTable) {
+	if len(old.Imports) != len(entry.Imports) {
+		return
+	}
+	for path, hash := range old.Imports {
+		if entry.Imports[path] != hash {
+			return
+		}
+	}
+	oldTypes := comp.decodeTypes(old.Types, old.Tuples)
+	if oldTypes == nil {
+		return
+	}
+	ids := make([]int, len(oldTypes))
+	for i, t := range oldTypes {
+		id, ok := tt.add(t)
+		if !ok {
+			return
+		}
+		ids[i] = id
+	}
+	names := make(map[string]bool)
+	for _, cf := range entry.Funcs {
+		names[cf.Name] = true
+	}
+	for _, cf := range old.Funcs {
+		if names[cf.Name] {
+			continue
+		}
+		if cf.Code != nil {
+			for i, t := range cf.Code.TypeUses {
+				cf.Code.TypeUses[i] = ids[t]
+			}
+		}
+		entry.Funcs = append(entry.Funcs, cf)
+	}
+}
+
+// cachedFunc describes a function that was visited and emitted, for the build cache entry of a package,
+// also returning the functions only known by the code of that package which it refers to.
+func (comp *Compilation) cachedFunc(fn *ssa.Function, pkg *ssa.Package, code *funcCode,
+	tt *typeTable) (*cachedFunc, []*ssa.Function) {
+	v := comp.cache.visits[fn]
+	if v == nil {
+		return nil, nil
+	}
+	p, n := comp.GetFnNameParts(fn)
+	cf := &cachedFunc{
+		Name:       fn.String(),
+		Class:      p + "." + n,
+		Overloaded: v.Overloaded,
+		HasCode:    len(fn.Blocks) > 0,
+		UsesGR:     comp.grMap[fn],
+	}
+	locals := []*ssa.Function{}
+	refIndex := make(map[*ssa.Function]int)
+	for _, s := range v.Steps {
+		if s.Local != nil {
+			return nil, nil // not expected, as the package was built
+		}
+		if s.Fn == nil {
+			cf.Steps = append(cf.Steps, -1)
+			continue
+		}
+		i, seen := refIndex[s.Fn]
+		if !seen {
+			r, ok := comp.cachedRef(s.Fn, pkg)
+			if !ok {
+				return nil, nil
+			}
+			if r.Local {
+				locals = append(locals, s.Fn)
+			}
+			i = len(cf.Refs)
+			refIndex[s.Fn] = i
+			cf.Refs = append(cf.Refs, r)
+		}
+		cf.Steps = append(cf.Steps, i)
+	}
+	codeOK := true
+	if fn.Pkg == nil && fn.Signature.Recv() == nil { // its class name is made from the position of its object
+		r, ok := comp.objectRef(fn.Object())
+		cf.Object, cf.Pos = &r, comp.position(fn.Pos()).String()
+		codeOK = ok
+	}
+	if codeOK {
+		cf.Code = comp.cachedCode(code, tt)
+	}
+	return cf, locals
+}
+
+// cachedRef describes a function referred to by the code of a package.
+func (comp *Compilation) cachedRef(fn *ssa.Function, pkg *ssa.Package) (cachedRef, bool) {
+	var r cachedRef
+	ok := true
+	switch {
+	case fn.Pkg != nil && fn.Signature.Recv() == nil && fn.Pkg.Func(fn.Name()) == fn:
+		r.Pkg, r.Fn = fn.Pkg.Object.Path(), fn.Name()
+	case fn.Synthetic == "" && fn.Signature.Recv() != nil && fn.Object() != nil:
+		r, ok = comp.objectRef(fn.Object())
+	case fn.Pkg == pkg || fn.Pkg == nil:
+		r.Local, r.Name = true, fn.String()
+	default:
+		ok = false
+	}
+	if !ok || (!r.Local && comp.cache.refFunc(&r) != fn) {
+		return r, false
+	}
+	r.Path, _ = comp.FuncPathName(fn)
+	r.UsesGR = comp.grMap[fn]
+	r.HasCode = comp.HasCode(fn)
+	return r, true
+}
+
+// objectRef describes a package-level function, or a method, by name.
+func (comp *Compilation) objectRef(obj types.Object) (cachedRef, bool) {
+	var r cachedRef
+	f, ok := obj.(*types.Func)
+	if !ok || f.Pkg() == nil {
+		return r, false
+	}
+	r.Pkg, r.Fn = f.Pkg().Path(), f.Name()
+	if recv := f.Type().(*types.Signature).Recv(); recv != nil {
+		t := recv.Type()
+		if ptr, isPtr := t.(*types.Pointer); isPtr {
+			t = ptr.Elem()
+		}
+		named, isNamed := t.(*types.Named)
+		if !isNamed || named.Obj().Pkg() == nil {
+			return r, false
+		}
+		r.Pkg, r.Recv = named.Obj().Pkg().Path(), named.Obj().Name()
+	}
+	return r, comp.cache.lookupObject(&r) == obj
+}
+
+// cachedCode gives the code generated for a function as it is cached, or nil if it cannot be.
+func (comp *Compilation) cachedCode(code *funcCode, tt *typeTable) *cachedCode {
+	if code == nil || code.hadErrors || len(code.leftover) > 0 {
+		return nil // only cache whole classes, generated without error
+	}
+	cc := &cachedCode{
+		Files:        code.files,
+		PosFiles:     make(map[int]string),
+		Messages:     code.messages,
+		EndErrorInfo: code.endErrorInfo,
+	}
+	for _, t := range code.typeUses {
+		i, ok := tt.add(t)
+		if !ok {
+			return nil
+		}
+		cc.TypeUses = append(cc.TypeUses, i)
+	}
+	for f := range code.posFiles {
+		cc.PosFiles[f] = comp.PosHashFileList[f].FileName
+	}
+	if code.endPosHash != NoPosHash {
+		posn := comp.position(code.endPos)
+		cc.EndFile, cc.EndLine, cc.EndOffset = posn.Filename, posn.Line, posn.Offset
+	}
+	return cc
+}
@@ -2,5 +2,9 @@
 // Use of this source code is governed by an MIT-style
 // license that can be found in the LICENSE file.
 
-// Package pogo provides the generic components of a tool for transforming the go.tools/go/ssa form of Go programs to other languages
+// Package pogo provides the generic components of a tool for transforming the go.tools/go/ssa form of Go programs to other languages.
+//
+// Each transpilation is held in a Compilation, created by NewCompilation(), so many programs may be transpiled in one process.
+// Compile() generates the target language files in memory, which are then available from Files() or may be written out by WriteFiles().
+// The code for each function is generated separately, so set Jobs to generate many functions at once.
 package pogo
@@ -11,43 +11,127 @@ import (
 	"sort"
 )
 
-// TODO remove all global scope
+// Severity says how serious a Diagnostic is.
+type Severity string
 
-var hadErrors = false
+// The severities of Diagnostic messages.
+const (
+	SeverityError   Severity = "error"   // the compilation will fail
+	SeverityWarning Severity = "warning" // the compilation can still succeed
+)
 
-var stopOnError = true // TODO make this soft and default true
+// Diagnostic is a machine-readable message about the Go code being compiled.
+type Diagnostic struct {
+	Severity  Severity `json:"severity"`
+	Filename  string   `json:"file,omitempty"`   // the Go source position, if known
+	Line      int      `json:"line,omitempty"`   // line number, from 1
+	Column    int      `json:"column,omitempty"` // column number, from 1
+	Subsystem string   `json:"subsystem"`        // which part of the compiler raised the message, for example "pogo" or "Haxe"
+	Message   string   `json:"message"`
+	Location  string   `json:"location,omitempty"` // the free-text location description given by the subsystem
+}
 
-var warnings = make([]string, 0) // Warnings are collected up and added to the end of the output code.
+// String gives the Diagnostic in the text format written to stderr.
+func (d Diagnostic) String() string {
+	level := "Error"
+	if d.Severity == SeverityWarning {
+		level = "Warning"
+	}
+	return fmt.Sprintf("%s : %s (%s) %s", level, d.Location, d.Subsystem, d.Message)
+}
 
-var messagesGiven = make(map[string]bool) // This map de-dups error messages
+// make a Diagnostic, taking the Go source position from pos if it is valid,
+// otherwise from the latest position seen by MakePosHash()
+func (comp *Compilation) makeDiagnostic(sev Severity, pos token.Pos, loc, lang string, err error) Diagnostic {
+	d := Diagnostic{Severity: sev, Subsystem: lang, Message: fmt.Sprint(err), Location: loc}
+	if !pos.IsValid() {
+		pos = comp.latestValidPos
+	}
+	if pos.IsValid() {
+		posn := comp.position(pos)
+		d.Filename, d.Line, d.Column = posn.Filename, posn.Line, posn.Column
+	}
+	return d
+}
+
+// record a Diagnostic, and pass it to any DiagnosticHandler
+func (comp *Compilation) addDiagnostic(d Diagnostic) {
+	comp.diagnostics = append(comp.diagnostics, d)
+	if comp.DiagnosticHandler != nil {
+		comp.DiagnosticHandler(d)
+	}
+}
+
+// Diagnostics returns the errors and warnings logged so far, in the order they were logged.
+func (comp *Compilation) Diagnostics() []Diagnostic {
+	return comp.diagnostics
+}
+
+// A message is an error or warning, as text for stderr or the end of the code, and as a Diagnostic.
+type message struct {
+	Text       string
+	Diagnostic Diagnostic
+}
 
 // Utility message handler for errors
-func logMessage(level, loc, lang string, err error) {
-	msg := fmt.Sprintf("%s : %s (%s) %v \n", level, loc, lang, err)
+func (comp *Compilation) logMessage(level string, pos token.Pos, loc, lang string, err error) {
+	comp.addMessage(message{
+		fmt.Sprintf("%s : %s (%s) %v \n", level, loc, lang, err),
+		comp.makeDiagnostic(SeverityError, pos, loc, lang, err)})
+}
+
+// LogWarning but a warning does not stop the compiler from claiming success.
+// The Diagnostic is given the latest code position seen by MakePosHash().
+func (comp *Compilation) LogWarning(loc, lang string, err error) {
+	comp.LogWarningAt(token.NoPos, loc, lang, err)
+}
+
+// LogWarningAt is LogWarning for a known code position.
+func (comp *Compilation) LogWarningAt(pos token.Pos, loc, lang string, err error) {
+	comp.addMessage(message{
+		fmt.Sprintf("Warning: %s (%s) %v", loc, lang, err),
+		comp.makeDiagnostic(SeverityWarning, pos, loc, lang, err)})
+}
+
+func (comp *Compilation) addMessage(m message) {
+	if comp.forked != nil { // the message is added to the parent Compilation when the code is merged, see parallel.go
+		comp.forked.messages = append(comp.forked.messages, m)
+		return
+	}
+	if m.Diagnostic.Severity == SeverityWarning {
+		comp.warnings = append(comp.warnings, m.Text)
+		comp.addDiagnostic(m.Diagnostic)
+		return
+	}
+	comp.hadErrors = true
 	// don't emit duplicate messages
-	_, hadIt := messagesGiven[msg]
+	_, hadIt := comp.messagesGiven[m.Text]
 	if !hadIt {
-		fmt.Fprintf(os.Stderr, "%s", msg)
-		messagesGiven[msg] = true
+		comp.addDiagnostic(m.Diagnostic)
+		if comp.DiagnosticHandler == nil {
+			fmt.Fprintf(os.Stderr, "%s", m.Text)
+		}
+		comp.messagesGiven[m.Text] = true
 	}
 }
 
-// LogWarning but a warning does not stop the compiler from claiming success.
-func LogWarning(loc, lang string, err error) {
-	warnings = append(warnings, fmt.Sprintf("Warning: %s (%s) %v", loc, lang, err))
+// LogError and potentially stop the compilation process.
+// The Diagnostic is given the latest code position seen by MakePosHash().
+func (comp *Compilation) LogError(loc, lang string, err error) {
+	comp.LogErrorAt(token.NoPos, loc, lang, err)
 }
 
-// LogError and potentially stop the compilation process.
-func LogError(loc, lang string, err error) {
-	logMessage("Error", loc, lang, err)
-	hadErrors = true
+// LogErrorAt is LogError for a known code position.
+func (comp *Compilation) LogErrorAt(pos token.Pos, loc, lang string, err error) {
+	comp.logMessage("Error", pos, loc, lang, err)
+	comp.hadErrors = true
 }
 
 // CodePosition is a utility to provide a string version of token.Pos.
 // this string should be used for documentation & debug only.
-func CodePosition(pos token.Pos) string {
+func (comp *Compilation) CodePosition(pos token.Pos) string {
 
-	p := rootProgram.Fset.Position(pos).String()
+	p := comp.position(pos).String()
 	if p == "-" {
 		return ""
 	}
@@ -73,43 +157,41 @@ func (a posHashFileSorter) Len() int           { return len(a) }
 func (a posHashFileSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a posHashFileSorter) Less(i, j int) bool { return a[i].FileName < a[j].FileName }
 
-// PosHashFileList holds the list of input go files with their posHash information
-var PosHashFileList = make([]PosHashFileStruct, 0)
-
 // Create the PosHashFileList to enable poshash values to be emitted
-func setupPosHash() {
-	rootProgram.Fset.Iterate(func(fRef *token.File) bool {
-		PosHashFileList = append(PosHashFileList, PosHashFileStruct{FileName: fRef.Name(), LineCount: fRef.LineCount()})
+func (comp *Compilation) setupPosHash() {
+	comp.rootProgram.Fset.Iterate(func(fRef *token.File) bool {
+		comp.PosHashFileList = append(comp.PosHashFileList, PosHashFileStruct{FileName: comp.fileName(fRef.Name()), LineCount: fRef.LineCount()})
 		return true
 	})
-	sort.Sort(posHashFileSorter(PosHashFileList))
-	for f := range PosHashFileList {
+	sort.Sort(posHashFileSorter(comp.PosHashFileList))
+	for f := range comp.PosHashFileList {
 		if f > 0 {
-			PosHashFileList[f].BasePosHash = PosHashFileList[f-1].BasePosHash + PosHashFileList[f-1].LineCount
+			comp.PosHashFileList[f].BasePosHash = comp.PosHashFileList[f-1].BasePosHash + comp.PosHashFileList[f-1].LineCount
 		}
 	}
 }
 
-// LatestValidPosHash holds the latest valid PosHash value seen, for use when an invalid one requires a "near" reference.
-// TODO like all other global values in the pogo module, this should not be a global state.
-var LatestValidPosHash = NoPosHash
-
 // MakePosHash keeps track of references put into the code for later extraction in a runtime debug function.
 // It returns the PosHash integer to be used for exception handling that was passed in.
-func MakePosHash(pos token.Pos) PosHash {
+func (comp *Compilation) MakePosHash(pos token.Pos) PosHash {
 	if pos.IsValid() {
-		fname := rootProgram.Fset.Position(pos).Filename
-		for f := range PosHashFileList {
-			if PosHashFileList[f].FileName == fname {
-				LatestValidPosHash = PosHash(PosHashFileList[f].BasePosHash + rootProgram.Fset.Position(pos).Line)
-				return LatestValidPosHash
+		posn := comp.position(pos)
+		fname := posn.Filename
+		for f := range comp.PosHashFileList {
+			if comp.PosHashFileList[f].FileName == fname {
+				comp.latestValidPos = pos
+				if comp.forked != nil {
+					comp.forked.posFiles[f] = true
+				}
+				comp.LatestValidPosHash = PosHash(comp.PosHashFileList[f].BasePosHash + posn.Line)
+				return comp.LatestValidPosHash
 			}
 		}
 		panic(fmt.Errorf("pogo.MakePosHash() Cant find file: %s", fname))
 	} else {
-		if LatestValidPosHash == NoPosHash {
+		if comp.LatestValidPosHash == NoPosHash {
 			return NoPosHash
 		}
-		return -LatestValidPosHash // -ve value => nearby reference
+		return -comp.LatestValidPosHash // -ve value => nearby reference
 	}
 }
@@ -17,35 +17,36 @@ import (
 	"golang.org/x/tools/go/types"
 )
 
-var fnMap, grMap map[*ssa.Function]bool // which functions are used and if the functions use goroutines/channels
-
-var Tardisgotypes *ssa.Package
-
 // For every function, maybe emit the code...
-func emitFunctions() {
+func (comp *Compilation) emitFunctions() error {
 	//fnMap := ssautil.AllFunctions(rootProgram)
-	//fmt.Println("DEBUG Tardisgotypes:",Tardisgotypes)
 	dceList := []*ssa.Package{
-		mainPackage,
-		rootProgram.ImportedPackage(LanguageList[TargetLang].Goruntime),
-		Tardisgotypes}
+		comp.mainPackage,
+		comp.rootProgram.ImportedPackage(comp.LanguageList[comp.TargetLang].Goruntime)}
 	dceExceptions := []string{}
-	if LanguageList[TargetLang].TestFS != "" { // need to load file system
+	if comp.LanguageList[comp.TargetLang].TestFS != "" { // need to load file system
 		dceExceptions = append(dceExceptions, "syscall") // so that we keep UnzipFS()
 	}
-	dceExceptions = append(dceExceptions, LibListNoDCE...)
+	dceExceptions = append(dceExceptions, comp.LibListNoDCE...)
 	for _, ex := range dceExceptions {
-		exip := rootProgram.ImportedPackage(ex)
+		exip := comp.rootProgram.ImportedPackage(ex)
 		if exip != nil {
 			dceList = append(dceList, exip)
 		} else {
 			//fmt.Println("DEBUG exip nil for package: ",ex)
 		}
 	}
-	fnMap, grMap = tgossa.VisitedFunctions(rootProgram, dceList, IsOverloaded)
+	var cache tgossa.Cache
+	if comp.cache != nil {
+		cache = comp.cache
+	}
+	comp.fnMap, comp.grMap = tgossa.VisitedFunctions(comp.rootProgram, dceList, comp.IsOverloaded, cache)
+	if err := comp.checkCachedVisits(); err != nil {
+		return err
+	}
 	/*
 		fmt.Println("DEBUG funcs not requiring goroutines:")
-		for df, db := range grMap {
+		for df, db := range comp.grMap {
 			if !db {
 				fmt.Println(df)
 			}
@@ -53,11 +54,11 @@ func emitFunctions() {
 	*/
 	/*
 		fmt.Println("DEBUG functions removed by Dead Code Eliminaiton:")
-		for _,pkg := range rootProgram.AllPackages() {
+		for _,pkg := range comp.rootProgram.AllPackages() {
 			for _,mem := range pkg.Members {
 				fn,ok := mem.(*ssa.Function)
 				if ok {
-					_,found := fnMap[fn]
+					_,found := comp.fnMap[fn]
 					if !found {
 						println(fn.String())
 					}
@@ -67,9 +68,16 @@ func emitFunctions() {
 	*/
 
 	var dupCheck = make(map[string]*ssa.Function)
-	for f := range fnMap {
-		p, n := GetFnNameParts(f)
+	for f := range comp.fnMap {
+		p, n := comp.GetFnNameParts(f)
 		first, exists := dupCheck[p+"."+n]
+		if exists && strings.HasPrefix(f.Synthetic, "thunk") && strings.HasPrefix(first.Synthetic, "thunk") &&
+			types.Identical(f.Signature, first.Signature) {
+			// ssa makes a new thunk for each T.Method expression with a different, but identical, receiver type,
+			// they all have the same name and code, so only one is emitted
+			delete(comp.fnMap, f)
+			continue
+		}
 		if exists {
 			panic(fmt.Sprintf(
 				"duplicate function name: %s.%s\nparent orig %v new %v\n",
@@ -78,17 +86,20 @@ func emitFunctions() {
 		dupCheck[p+"."+n] = f
 	}
 
-	for _, f := range fnMapSorted() {
-		if !IsOverloaded(f) {
-			if err := tgossa.CheckNames(f); err != nil {
-				panic(err)
-			}
-			emitFunc(f)
+	comp.storeCache(comp.emitFuncList(comp.fnMapSorted()))
+	return nil
+}
+
+func (comp *Compilation) emitFuncIfRequired(f *ssa.Function) {
+	if !comp.IsOverloaded(f) {
+		if err := tgossa.CheckNames(f); err != nil {
+			panic(err)
 		}
+		comp.emitFunc(f)
 	}
 }
 
-func IsOverloaded(f *ssa.Function) bool {
+func (comp *Compilation) IsOverloaded(f *ssa.Function) bool {
 	pn := "unknown" // Defensive, as some synthetic or other edge-case functions may not have a valid package name
 	rx := f.Signature.Recv()
 	if rx == nil { // ordinary function
@@ -121,7 +132,7 @@ func IsOverloaded(f *ssa.Function) bool {
 	ts := tss[len(tss)-1]         // take the last part of the path
 	pn = ts                       // TODO this is incorrect, but not currently a problem as there is no function overloading
 	//println("DEBUG package name: " + pn)
-	if LanguageList[TargetLang].FunctionOverloaded(pn, f.Name()) ||
+	if comp.LanguageList[comp.TargetLang].FunctionOverloaded(pn, f.Name()) ||
 		strings.HasPrefix(pn, "_") { // the package is not in the target language, signaled by a leading underscore and
 		return true
 	}
@@ -142,7 +153,7 @@ type subFnInstrs struct {
 }
 
 // Emit a particular function.
-func emitFunc(fn *ssa.Function) {
+func (comp *Compilation) emitFunc(fn *ssa.Function) {
 
 	/* TODO research if the ssautil.Switches() function can be incorporated to provide any run-time improvement to the code
 	sw := ssautil.Switches(fn)
@@ -155,7 +166,7 @@ func emitFunc(fn *ssa.Function) {
 	canOptMap := make(map[string]bool) // TODO review use of this mechanism
 
 	//println("DEBUG processing function: ", fn.Name())
-	MakePosHash(fn.Pos()) // mark that we have entered a function
+	comp.MakePosHash(fn.Pos()) // mark that we have entered a function
 	trackPhi := true
 	switch len(fn.Blocks) {
 	case 0: // NoOp - only output a function if it has a body... so ignore pure definitions (target language may generate an error, if truely undef)
@@ -182,7 +193,7 @@ func emitFunc(fn *ssa.Function) {
 			instrCount += len(fn.Blocks[b].Instrs)
 		}
 		mustSplitCode := false
-		if instrCount > LanguageList[TargetLang].InstructionLimit {
+		if instrCount > comp.LanguageList[comp.TargetLang].InstructionLimit {
 			//println("DEBUG mustSplitCode => large function length:", instrCount, " in ", fn.Name())
 			mustSplitCode = true
 		}
@@ -213,7 +224,7 @@ func emitFunc(fn *ssa.Function) {
 				}
 				if canPutInSubFn {
 					if inSubFn {
-						if instrsEmitted > LanguageList[TargetLang].SubFnInstructionLimit {
+						if instrsEmitted > comp.LanguageList[comp.TargetLang].SubFnInstructionLimit {
 							subFnList[len(subFnList)-1].end = i
 							subFnList = append(subFnList, subFnInstrs{b, i, 0})
 							instrsEmitted = 0
@@ -268,11 +279,12 @@ func emitFunc(fn *ssa.Function) {
 			}
 		}
 
-		emitFuncStart(fn, trackPhi, canOptMap, mustSplitCode)
+		loopEnds := comp.loopEnds(fn)
+		comp.emitFuncStart(fn, trackPhi, canOptMap, mustSplitCode)
 		thisSubFn := 0
 		for b := range fn.Blocks {
 			emitPhi := trackPhi
-			emitBlockStart(fn.Blocks, b, emitPhi)
+			comp.emitBlockStart(fn.Blocks, b, emitPhi)
 			inSubFn := false
 			for i := 0; i < len(fn.Blocks[b].Instrs); i++ {
 				if thisSubFn >= 0 && thisSubFn < len(subFnList) { // not at the end of the list
@@ -290,11 +302,11 @@ func emitFunc(fn *ssa.Function) {
 					if b == subFnList[thisSubFn].block {
 						if i == subFnList[thisSubFn].start {
 							inSubFn = true
-							l := TargetLang
+							l := comp.TargetLang
 							if mustSplitCode {
-								fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].SubFnCall(thisSubFn))
+								fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].SubFnCall(thisSubFn))
 							} else {
-								emitSubFn(fn, subFnList, thisSubFn, mustSplitCode, canOptMap)
+								comp.emitSubFn(fn, subFnList, thisSubFn, mustSplitCode, canOptMap)
 							}
 						}
 					}
@@ -313,10 +325,10 @@ func emitFunc(fn *ssa.Function) {
 						}
 					}
 					if phiList > 1 {
-						peephole(fn.Blocks[b].Instrs[i : i+phiList])
+						comp.peephole(fn.Blocks[b].Instrs[i : i+phiList])
 						i += phiList - 1
 					} else {
-						emitPhi = emitInstruction(fn.Blocks[b].Instrs[i],
+						emitPhi = comp.emitInstruction(fn.Blocks[b].Instrs[i],
 							fn.Blocks[b].Instrs[i].Operands(make([]*ssa.Value, 0)))
 					}
 				}
@@ -331,37 +343,54 @@ func emitFunc(fn *ssa.Function) {
 					}
 				}
 			}
-			emitBlockEnd(fn.Blocks, b, emitPhi && trackPhi)
+			comp.emitBlockEnd(fn.Blocks, b, emitPhi && trackPhi, loopEnds[fn.Blocks[b]])
 		}
-		emitRunEnd(fn)
+		comp.emitRunEnd(fn)
 		if mustSplitCode {
 			for sf := range subFnList {
-				emitSubFn(fn, subFnList, sf, mustSplitCode, canOptMap)
+				comp.emitSubFn(fn, subFnList, sf, mustSplitCode, canOptMap)
 			}
 		}
-		emitFuncEnd(fn)
+		comp.emitFuncEnd(fn)
 	}
 }
 
-func emitSubFn(fn *ssa.Function, subFnList []subFnInstrs, sf int, mustSplitCode bool, canOptMap map[string]bool) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].SubFnStart(sf, mustSplitCode))
+// loopEnds finds the blocks at the end of a loop, that jump back to a block which dominates them,
+// if the function is goroutine-capable and the goroutine should give up control in long-running loops.
+func (comp *Compilation) loopEnds(fn *ssa.Function) map[*ssa.BasicBlock]bool {
+	ends := make(map[*ssa.BasicBlock]bool)
+	if (comp.PreemptLoops <= 0 && comp.PreemptMillis <= 0) || !comp.grMap[fn] {
+		return ends
+	}
+	for _, b := range fn.Blocks {
+		for _, s := range b.Succs {
+			if s.Dominates(b) {
+				ends[b] = true
+			}
+		}
+	}
+	return ends
+}
+
+func (comp *Compilation) emitSubFn(fn *ssa.Function, subFnList []subFnInstrs, sf int, mustSplitCode bool, canOptMap map[string]bool) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].SubFnStart(sf, mustSplitCode))
 	for i := subFnList[sf].start; i < subFnList[sf].end; i++ {
 		instrVal, hasVal := fn.Blocks[subFnList[sf].block].Instrs[i].(ssa.Value)
 		if hasVal {
 			if canOptMap[instrVal.Name()] == true {
-				l := TargetLang
-				fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].DeclareTempVar(instrVal))
+				l := comp.TargetLang
+				fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].DeclareTempVar(instrVal))
 			}
 		}
 	}
-	peephole(fn.Blocks[subFnList[sf].block].Instrs[subFnList[sf].start:subFnList[sf].end])
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].SubFnEnd(sf, int(LatestValidPosHash), mustSplitCode))
+	comp.peephole(fn.Blocks[subFnList[sf].block].Instrs[subFnList[sf].start:subFnList[sf].end])
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].SubFnEnd(sf, int(comp.LatestValidPosHash), mustSplitCode))
 }
 
-func GetFnNameParts(fn *ssa.Function) (pack, nam string) {
+func (comp *Compilation) GetFnNameParts(fn *ssa.Function) (pack, nam string) {
 	mName := fn.Name()
-	pName, _ := FuncPathName(fn) //fmt.Sprintf("fn%d", fn.Pos()) //uintptr(unsafe.Pointer(fn)))
+	pName, _ := comp.FuncPathName(fn) //fmt.Sprintf("fn%d", fn.Pos()) //uintptr(unsafe.Pointer(fn)))
 	if fn.Pkg != nil {
 		if fn.Pkg.Object != nil {
 			pName = fn.Pkg.Object.Path() // was .Name()
@@ -375,50 +404,52 @@ func GetFnNameParts(fn *ssa.Function) (pack, nam string) {
 }
 
 // Emit the start of a function.
-func emitFuncStart(fn *ssa.Function, trackPhi bool, canOptMap map[string]bool, mustSplitCode bool) {
-	l := TargetLang
-	posStr := CodePosition(fn.Pos())
-	pName, mName := GetFnNameParts(fn)
+func (comp *Compilation) emitFuncStart(fn *ssa.Function, trackPhi bool, canOptMap map[string]bool, mustSplitCode bool) {
+	l := comp.TargetLang
+	posStr := comp.CodePosition(fn.Pos())
+	pName, mName := comp.GetFnNameParts(fn)
 	isPublic := unicode.IsUpper(rune(mName[0])) // TODO check rules for non-ASCII 1st characters and fix
-	fmt.Fprintln(&LanguageList[l].buffer,
-		LanguageList[l].FuncStart(pName, mName, fn, posStr, isPublic, trackPhi, grMap[fn] || mustSplitCode , canOptMap))
+	fmt.Fprintln(&comp.LanguageList[l].buffer,
+		comp.LanguageList[l].FuncStart(pName, mName, fn, posStr, isPublic, trackPhi, comp.grMap[fn] || mustSplitCode, canOptMap))
 }
 
 // Emit the end of a function.
-func emitFuncEnd(fn *ssa.Function) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].FuncEnd(fn))
+func (comp *Compilation) emitFuncEnd(fn *ssa.Function) {
+	l := comp.TargetLang
+	if end := comp.LanguageList[l].FuncEnd(fn); end != "" { // for Haxe, FuncEnd() writes the class itself
+		fmt.Fprintln(&comp.LanguageList[l].buffer, end)
+	}
 }
 
 // Emit code for after the end of all the case statements for a functions _Next phi switch, but before the sub-functions.
-func emitRunEnd(fn *ssa.Function) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].RunEnd(fn))
+func (comp *Compilation) emitRunEnd(fn *ssa.Function) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].RunEnd(fn))
 }
 
 // Emit the start of the code to handle a particular SSA code block,
 // for Haxe this handles a particular _Next value (in phi or -ve if synthetic because of call or channel Rx/Tx).
-func emitBlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].BlockStart(block, num, emitPhi))
+func (comp *Compilation) emitBlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].BlockStart(block, num, emitPhi))
 }
 
-// Emit the end of the SSA code block
-func emitBlockEnd(block []*ssa.BasicBlock, num int, emitPhi bool) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].BlockEnd(block, num, emitPhi))
+// Emit the end of the SSA code block, where the goroutine may give up control if the block ends a loop.
+func (comp *Compilation) emitBlockEnd(block []*ssa.BasicBlock, num int, emitPhi, loopEnd bool) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].BlockEnd(block, num, emitPhi, loopEnd))
 }
 
 // Emit the code for a call to a function or builtin, which could be deferred.
-func emitCall(isBuiltin, isGo, isDefer, usesGr bool, register string, callInfo ssa.CallCommon, errorInfo, comment string) {
+func (comp *Compilation) emitCall(isBuiltin, isGo, isDefer, usesGr bool, register string, callInfo ssa.CallCommon, errorInfo, comment string) {
 	// usesGr gives the default position
-	l := TargetLang
+	l := comp.TargetLang
 	fnToCall := ""
 	if isBuiltin {
 		fnToCall = callInfo.Value.(*ssa.Builtin).Name()
 		usesGr = false
 	} else if callInfo.StaticCallee() != nil {
-		pName, _ := FuncPathName(callInfo.StaticCallee()) //fmt.Sprintf("fn%d", callInfo.StaticCallee().Pos())
+		pName, _ := comp.FuncPathName(callInfo.StaticCallee()) //fmt.Sprintf("fn%d", callInfo.StaticCallee().Pos())
 		if callInfo.Signature().Recv() != nil {
 			pName = callInfo.Signature().Recv().Pkg().Name() + ":" + callInfo.Signature().Recv().Type().String() // no use of Underlying() here
 		} else {
@@ -427,33 +458,33 @@ func emitCall(isBuiltin, isGo, isDefer, usesGr bool, register string, callInfo s
 				pName = pkg.Object.Path() // was .Name()
 			}
 		}
-		fnToCall = LanguageList[l].LangName(pName, callInfo.StaticCallee().Name())
-		usesGr = grMap[callInfo.StaticCallee()]
+		fnToCall = comp.LanguageList[l].LangName(pName, callInfo.StaticCallee().Name())
+		usesGr = comp.grMap[callInfo.StaticCallee()]
 	} else { // Dynamic call (take the default on usesGr)
-		fnToCall = LanguageList[l].Value(callInfo.Value, errorInfo)
+		fnToCall = comp.LanguageList[l].Value(callInfo.Value, errorInfo)
 	}
 
 	if isBuiltin {
 		switch fnToCall {
 		case "len", "cap", "append", "real", "imag", "complex": //  "copy" may have the results unused
 			if register == "" {
-				LogError(errorInfo, "pogo", fmt.Errorf("the result from a built-in function is not used"))
+				comp.LogError(errorInfo, "pogo", fmt.Errorf("the result from a built-in function is not used"))
 			}
 		default:
 		}
 	} else {
 		if callInfo.Signature().Results().Len() > 0 {
 			if register == "" {
-				LogWarning(errorInfo, "pogo", fmt.Errorf("the result from a function call is not used")) //TODO is this needed?
+				comp.LogWarning(errorInfo, "pogo", fmt.Errorf("the result from a function call is not used")) //TODO is this needed?
 			}
 		}
 	}
 	// target language code must do builtin emulation
-	text := LanguageList[l].Call(register, callInfo, callInfo.Args, isBuiltin, isGo, isDefer, usesGr, fnToCall, errorInfo)
-	fmt.Fprintln(&LanguageList[l].buffer, text+LanguageList[l].Comment(comment))
+	text := comp.LanguageList[l].Call(register, callInfo, callInfo.Args, isBuiltin, isGo, isDefer, usesGr, fnToCall, errorInfo)
+	fmt.Fprintln(&comp.LanguageList[l].buffer, text+comp.LanguageList[l].Comment(comment))
 }
 
 // FuncValue is a utility function to avoid publishing rootProgram from this package.
-func FuncValue(obj *types.Func) ssa.Value {
-	return rootProgram.FuncValue(obj)
+func (comp *Compilation) FuncValue(obj *types.Func) ssa.Value {
+	return comp.rootProgram.FuncValue(obj)
 }
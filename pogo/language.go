@@ -5,7 +5,6 @@
 package pogo
 
 import (
-	"bytes"
 	"fmt"
 	"unicode"
 
@@ -15,7 +14,7 @@ import (
 
 // The Language interface enables multiple target languages for TARDIS Go.
 type Language interface {
-	RegisterName(val ssa.Value) string 
+	RegisterName(val ssa.Value) string
 	DeclareTempVar(ssa.Value) string
 	LanguageName() string
 	FileTypeSuffix() string // e.g. ".go" ".js" ".hx"
@@ -40,7 +39,7 @@ type Language interface {
 	RunEnd(fn *ssa.Function) string
 	FuncEnd(fn *ssa.Function) string
 	BlockStart(block []*ssa.BasicBlock, num int, emitPhi bool) string
-	BlockEnd(block []*ssa.BasicBlock, num int, emitPhi bool) string
+	BlockEnd(block []*ssa.BasicBlock, num int, emitPhi, loopEnd bool) string
 	Jump(int) string
 	If(v interface{}, trueNext, falseNext int, errorInfo string) string
 	Phi(register string, phiEntries []int, valEntries []interface{}, defaultValue, errorInfo string) string
@@ -85,32 +84,43 @@ type Language interface {
 
 // LanguageEntry holds the static infomation about each of the languages, expect this list to extend as more languages are added.
 type LanguageEntry struct {
-	Language                           // All of the interface functions.
-	buffer                bytes.Buffer // Where the output is collected.
-	InstructionLimit      int          // How many instructions in a function before we need to split it up.
-	SubFnInstructionLimit int          // When we split up a function, how large can each sub-function be?
-	PackageConstVarName   string       // The special constant name to specify a Package/Module name in the target language.
-	HeaderConstVarName    string       // The special constant name for a target-specific header.
-	Goruntime             string       // The location of the core implementation go runtime code for this target language.
-	TestFS                string       // the location of the test zipped file system, if present
-	files                 []FileOutput // files to write if no errors in compilation
+	Language                                                          // All of the interface functions.
+	InitLang              func(*Compilation, *LanguageEntry) Language // Creates the Language for a new compilation, so that it can hold per-compilation state.
+	buffer                codeBuffer                                  // Where the output is collected.
+	InstructionLimit      int                                         // How many instructions in a function before we need to split it up.
+	SubFnInstructionLimit int                                         // When we split up a function, how large can each sub-function be?
+	PackageConstVarName   string                                      // The special constant name to specify a Package/Module name in the target language.
+	HeaderConstVarName    string                                      // The special constant name for a target-specific header.
+	Goruntime             string                                      // The location of the core implementation go runtime code for this target language.
+	TestFS                string                                      // the location of the test zipped file system, if present
+	TestNames             []string                                    // the names of the only tests to run, if not nil
+	files                 []FileOutput                                // files to write if no errors in compilation
 }
 
+// FileOutput is a generated file, named relative to the output directory.
 type FileOutput struct {
-	filename string
-	data     []byte
+	Filename string
+	Data     []byte
 }
 
-// LanguageList holds the languages that can be targeted.
+// LanguageList holds the languages that can be targeted, each registered by the init() function of its package;
+// every Compilation takes its own copy of these entries.
 var LanguageList = make([]LanguageEntry, 0, 1)
 
-// TargetLang holds the language currently being targeted, default is the first on the list, initially haxe.
-var TargetLang = 0
+// FindTargetLang returns the position in LanguageList of the named language.
+func FindTargetLang(langName string) (int, error) {
+	for k, v := range LanguageList {
+		if v.LanguageName() == langName {
+			return k, nil
+		}
+	}
+	return -1, fmt.Errorf("target language %q not available", langName)
+}
 
 // Utility comment emitter function.
-func emitComment(cmt string) {
-	l := TargetLang
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].Comment(cmt))
+func (comp *Compilation) emitComment(cmt string) {
+	l := comp.TargetLang
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].Comment(cmt))
 }
 
 // MakeID cleans-up Go names to replace characters outside (_,0-9,a-z,A-Z) with a decimal value surrounded by underlines, with special handling of '.' and '*'.
@@ -140,9 +150,9 @@ func MakeID(s string) (r string) {
 
 // is there more than one package with this name?
 // TODO consider using this function in pogo.emitFunctions()
-func isDupPkg(pn string) bool {
+func (comp *Compilation) isDupPkg(pn string) bool {
 	pnCount := 0
-	ap := rootProgram.AllPackages()
+	ap := comp.rootProgram.AllPackages()
 	for p := range ap {
 		if pn == ap[p].Object.Name() {
 			pnCount++
@@ -153,10 +163,10 @@ func isDupPkg(pn string) bool {
 
 // FunctionName returns a unique function path and name.
 // TODO refactor this code and everywhere it is called to remove duplication.
-func FuncPathName(fn *ssa.Function) (path, name string) {
+func (comp *Compilation) FuncPathName(fn *ssa.Function) (path, name string) {
 	rx := fn.Signature.Recv()
-	pf := MakeID(rootProgram.Fset.Position(fn.Pos()).String()) //fmt.Sprintf("fn%d", fn.Pos())
-	if rx != nil {                                             // it is not the name of a normal function, but that of a method, so append the method description
+	pf := MakeID(comp.position(fn.Pos()).String()) //fmt.Sprintf("fn%d", fn.Pos())
+	if rx != nil {                                 // it is not the name of a normal function, but that of a method, so append the method description
 		pf = rx.Type().String() // NOTE no underlying()
 	} else {
 		if fn.Pkg != nil {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 )
 
 func writeIfChanged(filename string, data []byte) error {
@@ -18,52 +19,60 @@ func writeIfChanged(filename string, data []byte) error {
 	return ioutil.WriteFile(filename, data, 0666)
 }
 
-// WriteAsClass writes the contents of the buffer as a given class file name.
+// WriteAsClass writes the contents of the buffer as a given class file name, followed by its source map.
 // For haxe this name must begin with an upper-case letter and match the underlying class name.
-func WriteAsClass(name, code string) {
-	l := TargetLang
-	if LanguageList[l].files == nil {
-		LanguageList[l].files = make([]FileOutput, 0, 100)
+func (comp *Compilation) WriteAsClass(name, code string) {
+	l := comp.TargetLang
+	if comp.LanguageList[l].files == nil {
+		comp.LanguageList[l].files = make([]FileOutput, 0, 100)
 	}
-	LanguageList[l].buffer.WriteString(code)
-	var data = make([]byte, LanguageList[l].buffer.Len())
-	copy(data, LanguageList[l].buffer.Bytes())
-	LanguageList[l].files = append(LanguageList[l].files, FileOutput{name, data})
-	LanguageList[l].buffer.Reset()
-	emitFileStart()
+	comp.LanguageList[l].buffer.WriteString(code)
+	var data = make([]byte, comp.LanguageList[l].buffer.Len())
+	copy(data, comp.LanguageList[l].buffer.Bytes())
+	fileName := name + comp.LanguageList[l].FileTypeSuffix() // Ubuntu requires the first letter of the haxe file to be uppercase
+	comp.LanguageList[l].files = append(comp.LanguageList[l].files,
+		FileOutput{fileName, data},
+		FileOutput{fileName + SourceMapSuffix, comp.sourceMap(&comp.LanguageList[l].buffer)})
+	comp.LanguageList[l].buffer.Reset()
+	comp.emitFileStart()
 }
 
-const tgtDir = "tardis" // TODO move to the correct directory based on a command line argument
-
-func targetDir() error {
-	if err := os.Mkdir(tgtDir, os.ModePerm); err != nil {
-		if !os.IsExist(err) { // no problem if it already exists
-			LogError("Unable to create tardis output directory", "pogo", err)
-			return err
-		}
+func (comp *Compilation) targetDir() error {
+	if err := os.MkdirAll(comp.OutputDir, os.ModePerm); err != nil {
+		comp.LogError("Unable to create output directory "+comp.OutputDir, "pogo", err)
+		return err
 	}
 	return nil
 }
 
-// Write out the target language file
+// Put any remaining output into its own file
 // TODO consider writing multiple output files, if this would be better/required for some target languages.
-func writeFiles() {
-	l := TargetLang
-	if LanguageList[l].buffer.Len() > 0 {
-		WriteAsClass("Remnants", "")
+func (comp *Compilation) finishFiles() {
+	l := comp.TargetLang
+	if comp.LanguageList[l].buffer.Len() > 0 {
+		comp.WriteAsClass("Remnants", "")
 	}
-	err := targetDir()
+}
+
+// Files returns the target language files generated in memory by Compile().
+func (comp *Compilation) Files() []FileOutput {
+	return comp.LanguageList[comp.TargetLang].files
+}
+
+// WriteFiles writes out the target language files generated by Compile() into OutputDir,
+// leaving any unchanged files untouched.
+func (comp *Compilation) WriteFiles() error {
+	err := comp.targetDir()
 	if err == nil {
-		for _, fo := range LanguageList[l].files {
-			err = writeIfChanged(
-				tgtDir+string(os.PathSeparator)+fo.filename+LanguageList[l].FileTypeSuffix(), // Ubuntu requires the first letter of the haxe file to be uppercase
-				fo.data)
+		for _, fo := range comp.Files() {
+			err = writeIfChanged(filepath.Join(comp.OutputDir, fo.Filename), fo.Data)
 			if err != nil {
 				break
 			}
 		}
 	}
 	if err != nil {
-		LogError("Unable to write output file", "pogo", err)
+		comp.LogError("Unable to write output file", "pogo", err)
 	}
+	return err
 }
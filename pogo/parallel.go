@@ -0,0 +1,260 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pogo
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/tools/go/ssa"
+	"golang.org/x/tools/go/types"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// The code for each function is generated independently, by a copy of the Compilation made by forkForFunc(),
+// so that up to Jobs functions may be generated at once.
+// The code is then merged into the Compilation in the order of fnMapSorted(), so the output does not depend on Jobs.
+//
+// Type IDs are given in the order that types are first used, so a function's code contains placeholders for its type IDs,
+// which are replaced by the real IDs as the code is merged.
+// PosHash values are also given as placeholders, of a file and a line in it, so that the build cache can re-use the code
+// in a later compilation in which the file has a different base PosHash value, see cache.go.
+// Every function starts with no latest position, rather than that of the function before, and its classes start with a new buffer.
+
+// typeIDMark surrounds the index of a type in funcCode.typeUses, as a placeholder for its type ID in the generated code.
+const typeIDMark = '\x00'
+
+// posHashMark surrounds "file:line", where file is the index of the file in PosHashFileList,
+// as a placeholder for a PosHash value in the generated code.
+const posHashMark = '\x01'
+
+// funcCode is the code generated for one function, to be merged into the parent Compilation.
+type funcCode struct {
+	files     []FileOutput // the classes written
+	leftover  []byte       // any code left in the buffer after the classes were written
+	typeUses  []types.Type // the types used, in the order first used
+	typeIndex typeutil.Map // the index of each type in typeUses
+	posFiles  map[int]bool // the index in PosHashFileList of each file that PosHash values were made for
+	messages  []message    // errors and warnings, in the order logged
+	hadErrors bool
+
+	endPosHash   PosHash // the position state at the end of the function
+	endPos       token.Pos
+	endErrorInfo string
+}
+
+func (fc *funcCode) typeIDPlaceholder(t types.Type) string {
+	idx := fc.typeIndex.At(t)
+	if idx == nil {
+		idx = len(fc.typeUses)
+		fc.typeIndex.Set(t, idx)
+		fc.typeUses = append(fc.typeUses, t)
+	}
+	return string(typeIDMark) + strconv.Itoa(idx.(int)) + string(typeIDMark)
+}
+
+// PosHashText returns a PosHash value as text for the generated code, or as a placeholder for it when generating one function.
+func (comp *Compilation) PosHashText(ph PosHash) string {
+	if comp.forked == nil || ph == NoPosHash {
+		return strconv.Itoa(int(ph))
+	}
+	return comp.forked.posHashPlaceholder(comp.PosHashFileList, ph)
+}
+
+func (fc *funcCode) posHashPlaceholder(files []PosHashFileStruct, ph PosHash) string {
+	sign := ""
+	if ph < 0 {
+		sign = "-"
+		ph = -ph
+	}
+	f := sort.Search(len(files), func(i int) bool { return files[i].BasePosHash+files[i].LineCount >= int(ph) })
+	if f == len(files) {
+		panic(fmt.Errorf("pogo.posHashPlaceholder() invalid PosHash: %d", ph))
+	}
+	fc.posFiles[f] = true
+	return fmt.Sprintf("%s%c%d:%d%c", sign, posHashMark, f, int(ph)-files[f].BasePosHash, posHashMark)
+}
+
+// posHashAt returns the PosHash value of a line in a file, given by its index in PosHashFileList.
+func (comp *Compilation) posHashAt(file, line int) int {
+	return comp.PosHashFileList[file].BasePosHash + line
+}
+
+// relocate replaces the type ID placeholders in the code with the IDs,
+// and the PosHash placeholders with the values given by posHash for the file index and line.
+func relocate(code []byte, ids []string, posHash func(file, line int) int) []byte {
+	if bytes.IndexByte(code, typeIDMark) == -1 && bytes.IndexByte(code, posHashMark) == -1 {
+		return code
+	}
+	ret := make([]byte, 0, len(code))
+	for {
+		start := bytes.IndexAny(code, string([]byte{typeIDMark, posHashMark}))
+		if start == -1 {
+			return append(ret, code...)
+		}
+		mark := code[start]
+		end := start + 1 + bytes.IndexByte(code[start+1:], mark)
+		if end == start {
+			panic("pogo.relocate() unterminated placeholder")
+		}
+		ph := string(code[start+1 : end])
+		ret = append(ret, code[:start]...)
+		if mark == typeIDMark {
+			idx, err := strconv.Atoi(ph)
+			if err != nil {
+				panic("pogo.relocate() bad type ID placeholder: " + err.Error())
+			}
+			ret = append(ret, ids[idx]...)
+		} else {
+			var file, line int
+			if _, err := fmt.Sscanf(ph, "%d:%d", &file, &line); err != nil {
+				panic("pogo.relocate() bad PosHash placeholder: " + err.Error())
+			}
+			ret = strconv.AppendInt(ret, int64(posHash(file, line)), 10)
+		}
+		code = code[end+1:]
+	}
+}
+
+// forkForFunc makes a copy of the Compilation to generate the code for one function,
+// sharing the results of the whole-program analysis, but with its own output, position and type ID state.
+func (comp *Compilation) forkForFunc() *Compilation {
+	fc := &Compilation{
+		rootProgram:              comp.rootProgram,
+		mainPackage:              comp.mainPackage,
+		DebugFlag:                comp.DebugFlag,
+		TraceFlag:                comp.TraceFlag,
+		TargetPackage:            comp.TargetPackage,
+		OutputDir:                comp.OutputDir,
+		TargetLang:               comp.TargetLang,
+		LanguageList:             make([]LanguageEntry, len(comp.LanguageList)),
+		stopOnError:              comp.stopOnError,
+		messagesGiven:            make(map[string]bool),
+		PosHashFileList:          comp.PosHashFileList,
+		Reproducible:             comp.Reproducible,
+		PreemptLoops:             comp.PreemptLoops,
+		PreemptMillis:            comp.PreemptMillis,
+		fileNames:                comp.fileNames,
+		LatestValidPosHash:       NoPosHash,
+		NextTypeID:               1,
+		catchReferencedTypesSeen: make(map[string]bool),
+		fnMap:                    comp.fnMap,
+		grMap:                    comp.grMap,
+		cache:                    comp.cache,
+		hxPkgName:                comp.hxPkgName,
+		headerText:               comp.headerText,
+		LibListNoDCE:             comp.LibListNoDCE,
+		forked:                   &funcCode{posFiles: make(map[int]bool)},
+	}
+	copy(fc.LanguageList, comp.LanguageList)
+	for k := range fc.LanguageList {
+		fc.LanguageList[k].buffer = codeBuffer{posHash: &fc.LatestValidPosHash}
+		fc.LanguageList[k].files = nil
+		if fc.LanguageList[k].InitLang != nil {
+			fc.LanguageList[k].Language = fc.LanguageList[k].InitLang(fc, &fc.LanguageList[k])
+		}
+	}
+	return fc
+}
+
+// generateFunc generates the code for a function, it may be called from many goroutines at once.
+func (comp *Compilation) generateFunc(fn *ssa.Function) *funcCode {
+	fc := comp.forkForFunc()
+	l := fc.TargetLang
+	fc.emitFileStart()
+	headerLen := fc.LanguageList[l].buffer.Len() // the buffer always starts with the header, as it is re-written for each new class
+	fc.emitFuncIfRequired(fn)
+	code := fc.forked
+	code.files = fc.LanguageList[l].files
+	code.leftover = append([]byte(nil), fc.LanguageList[l].buffer.Bytes()[headerLen:]...)
+	code.hadErrors = fc.hadErrors
+	code.endPosHash = fc.LatestValidPosHash
+	code.endPos = fc.latestValidPos
+	code.endErrorInfo = fc.previousErrorInfo
+	return code
+}
+
+// mergeFunc adds the code generated for a function to the Compilation, giving IDs to the types it uses.
+func (comp *Compilation) mergeFunc(code *funcCode) {
+	l := comp.TargetLang
+	ids := make([]string, len(code.typeUses))
+	for i, t := range code.typeUses {
+		ids[i] = comp.LogTypeUse(t)
+	}
+	for _, fo := range code.files {
+		comp.LanguageList[l].files = append(comp.LanguageList[l].files,
+			FileOutput{fo.Filename, relocate(fo.Data, ids, comp.posHashAt)})
+	}
+	for _, m := range code.messages {
+		comp.addMessage(m)
+	}
+	comp.LatestValidPosHash = code.endPosHash
+	comp.latestValidPos = code.endPos
+	comp.previousErrorInfo = code.endErrorInfo
+	if len(code.leftover) > 0 {
+		comp.LanguageList[l].buffer.Write(relocate(code.leftover, ids, comp.posHashAt))
+	}
+}
+
+// generateFuncs generates the code for the functions, using up to Jobs goroutines.
+func (comp *Compilation) generateFuncs(fns []*ssa.Function) []*funcCode {
+	codes := make([]*funcCode, len(fns))
+	jobs := comp.Jobs
+	if jobs > len(fns) {
+		jobs = len(fns)
+	}
+	if jobs < 2 {
+		for i, fn := range fns {
+			codes[i] = comp.generateFunc(fn)
+		}
+		return codes
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for j := 0; j < jobs; j++ {
+		go func() {
+			for i := range next {
+				codes[i] = comp.generateFunc(fns[i])
+			}
+			wg.Done()
+		}()
+	}
+	for i := range fns {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return codes
+}
+
+// emitFuncList emits the functions in the order given by funcItems(), using the build cache where possible,
+// and returns the code generated for the others.
+func (comp *Compilation) emitFuncList(fns []*ssa.Function) map[*ssa.Function]*funcCode {
+	items := comp.funcItems(fns)
+	toGenerate := []*ssa.Function{}
+	for _, it := range items {
+		if it.code == nil {
+			toGenerate = append(toGenerate, it.fn)
+		}
+	}
+	codes := comp.generateFuncs(toGenerate)
+	generated := make(map[*ssa.Function]*funcCode, len(codes))
+	for i, fn := range toGenerate {
+		generated[fn] = codes[i]
+	}
+	for _, it := range items {
+		if it.code != nil {
+			comp.useCachedCode(it.pkg, it.code)
+		} else {
+			comp.mergeFunc(generated[it.fn])
+		}
+	}
+	return generated
+}
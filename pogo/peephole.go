@@ -12,29 +12,29 @@ import (
 )
 
 // peephole optimizes and emits short sequences of instructions that do not contain control flow
-func peephole(instrs []ssa.Instruction) {
+func (comp *Compilation) peephole(instrs []ssa.Instruction) {
 
 	for i := 0; i < len(instrs); i++ {
 		if len(instrs[i:]) >= 2 {
 			for j := len(instrs); j > (i + 1); j-- {
-				opt, reg := peepholeFindOpt(instrs[i:j])
+				opt, reg := comp.peepholeFindOpt(instrs[i:j])
 				if opt != "" {
 					//fmt.Println("DEBUG PEEPHOLE", opt, reg)
-					fmt.Fprintln(&LanguageList[TargetLang].buffer,
-						LanguageList[TargetLang].PeepholeOpt(opt,
+					fmt.Fprintln(&comp.LanguageList[comp.TargetLang].buffer,
+						comp.LanguageList[comp.TargetLang].PeepholeOpt(opt,
 							reg, instrs[i:j], "[ PEEPHOLE ]"))
 					i = j - 1
 					goto instrsEmitted
 				}
 			}
 		}
-		emitInstruction(instrs[i], instrs[i].Operands(make([]*ssa.Value, 0)))
+		comp.emitInstruction(instrs[i], instrs[i].Operands(make([]*ssa.Value, 0)))
 	instrsEmitted:
 	}
 }
 
 // TODO WIP...
-func peepholeFindOpt(instrs []ssa.Instruction) (optName, regName string) {
+func (comp *Compilation) peepholeFindOpt(instrs []ssa.Instruction) (optName, regName string) {
 	if len(instrs) < 2 {
 		return // fail
 	}
@@ -50,20 +50,20 @@ func peepholeFindOpt(instrs []ssa.Instruction) (optName, regName string) {
 					if instrs[0].(*ssa.UnOp).Name() == indexOrFieldXName(instrs[1]) &&
 						indexOrFieldRefCount(instrs[1]) > 0 {
 						optName = "loadObject"
-						regName = RegisterName(instrs[1].(ssa.Value))
+						regName = comp.RegisterName(instrs[1].(ssa.Value))
 						return // success
 					}
 					return // fail
 				}
 				// we are in some sequence of Index/Field ops, one after another
 				// so first check that the earlier parts of the sequene are OK
-				on, rn := peepholeFindOpt(instrs[0 : len(instrs)-1])
+				on, rn := comp.peepholeFindOpt(instrs[0 : len(instrs)-1])
 				if on == "loadObject" {
 					if rn == "_"+indexOrFieldXName(instrs[len(instrs)-1]) && // end one links to one before
 						indexOrFieldRefCount(instrs[len(instrs)-2]) == 1 && // one before only used by this one
 						indexOrFieldRefCount(instrs[len(instrs)-1]) > 0 { // end result is used
 						optName = on
-						regName = RegisterName(instrs[len(instrs)-1].(ssa.Value))
+						regName = comp.RegisterName(instrs[len(instrs)-1].(ssa.Value))
 						return // success
 					}
 				}
@@ -0,0 +1,113 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pogo
+
+import (
+	"fmt"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/tools/go/types"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// When Reproducible is set, the generated code does not depend on where the Go source files are on disk,
+// or on the order in which the code generator first uses each type, so the same Go code always gives the same output:
+//
+// Go files are named by their package path and base name, for example "fmt/print.go", rather than by their full path,
+// in PosHash values, code positions, the names of functions without a package, and source maps.
+// This requires the PackageFiles of every package.
+//
+// Type IDs are given in the sort order of the type names, rather than in the order that types are first used.
+// Because the code generator only finds out which types are used as it goes along,
+// the program is first compiled into a copy of the Compilation, and the types that copy used are given their IDs in advance.
+
+// setupFileNames makes the names used for the Go files in the generated code.
+func (comp *Compilation) setupFileNames() {
+	if !comp.Reproducible {
+		return
+	}
+	comp.fileNames = make(map[string]string)
+	for pkgPath, files := range comp.PackageFiles {
+		for _, f := range files {
+			comp.fileNames[f] = path.Join(pkgPath, filepath.Base(f))
+		}
+	}
+}
+
+// fileName returns the name used for a Go file in the generated code.
+func (comp *Compilation) fileName(name string) string {
+	if rel, found := comp.fileNames[name]; found {
+		return rel
+	}
+	return name
+}
+
+// position returns the source position of pos, with the file named as in the generated code.
+func (comp *Compilation) position(pos token.Pos) token.Position {
+	posn := comp.rootProgram.Fset.Position(pos)
+	posn.Filename = comp.fileName(posn.Filename)
+	return posn
+}
+
+// presetTypeIDs gives IDs, in the sort order of their names, to the types that the compilation will use.
+// It returns ErrStaleCache if the program must be loaded again, otherwise an error if the trial compilation could not be made or failed,
+// in which case the errors the trial compilation logged are logged by this one.
+func (comp *Compilation) presetTypeIDs() error {
+	l := comp.TargetLang
+	trial, err := NewCompilation(comp.mainPackage, comp.LanguageList[l].LanguageName())
+	if err != nil {
+		return err
+	}
+	trial.DebugFlag = comp.DebugFlag
+	trial.TraceFlag = comp.TraceFlag
+	trial.TargetPackage = comp.TargetPackage
+	trial.Jobs = comp.Jobs
+	trial.PreemptLoops = comp.PreemptLoops
+	trial.PreemptMillis = comp.PreemptMillis
+	trial.LanguageList[l].TestFS = comp.LanguageList[l].TestFS
+	trial.LanguageList[l].TestNames = comp.LanguageList[l].TestNames
+	trial.Reproducible = true
+	trial.PackageFiles = comp.PackageFiles
+	trial.Cache = comp.Cache
+	trial.trial = true
+	trial.DiagnosticHandler = func(Diagnostic) {} // the errors are logged below, if the trial fails
+	if err := trial.Compile(); err != nil {
+		if err == ErrStaleCache {
+			return err
+		}
+		for _, d := range trial.Diagnostics() {
+			if d.Severity == SeverityError {
+				comp.addMessage(message{d.String() + " \n", d})
+			}
+		}
+		return fmt.Errorf("unable to give type IDs in name order, the trial compilation failed: %s", err)
+	}
+	typs := trial.TypesEncountered.Keys()
+	sort.Sort(typesByName{typs, &trial.TypesEncountered})
+	for i, t := range typs {
+		comp.TypesEncountered.Set(t, i+1)
+	}
+	comp.NextTypeID = len(typs) + 1
+	return nil
+}
+
+// typesByName sorts types by their names, then by their IDs, as some different types have the same name.
+type typesByName struct {
+	typs []types.Type
+	ids  *typeutil.Map
+}
+
+func (a typesByName) Len() int      { return len(a.typs) }
+func (a typesByName) Swap(i, j int) { a.typs[i], a.typs[j] = a.typs[j], a.typs[i] }
+func (a typesByName) Less(i, j int) bool {
+	si, sj := a.typs[i].String(), a.typs[j].String()
+	if si != sj {
+		return si < sj
+	}
+	return a.ids.At(a.typs[i]).(int) < a.ids.At(a.typs[j]).(int)
+}
@@ -5,6 +5,7 @@ import (
 
 	"golang.org/x/tools/go/ssa"
 	"golang.org/x/tools/go/types"
+	"golang.org/x/tools/go/types/typeutil"
 )
 
 type PackageSorter []*ssa.Package
@@ -28,9 +29,9 @@ func (a fnMapSorter) Len() int           { return len(a) }
 func (a fnMapSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a fnMapSorter) Less(i, j int) bool { return a[i].String() < a[j].String() }
 
-func fnMapSorted() []*ssa.Function {
+func (comp *Compilation) fnMapSorted() []*ssa.Function {
 	var fms = fnMapSorter([]*ssa.Function{})
-	for f := range fnMap {
+	for f := range comp.fnMap {
 		fms = append(fms, f)
 	}
 	sort.Sort(fms)
@@ -42,3 +43,19 @@ type TypeSorter []types.Type
 func (a TypeSorter) Len() int           { return len(a) }
 func (a TypeSorter) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a TypeSorter) Less(i, j int) bool { return a[i].String() < a[j].String() }
+
+// typesByID sorts types by their type IDs, then by their names.
+type typesByID struct {
+	typs []types.Type
+	ids  *typeutil.Map
+}
+
+func (a typesByID) Len() int      { return len(a.typs) }
+func (a typesByID) Swap(i, j int) { a.typs[i], a.typs[j] = a.typs[j], a.typs[i] }
+func (a typesByID) Less(i, j int) bool {
+	ii, ij := a.ids.At(a.typs[i]).(int), a.ids.At(a.typs[j]).(int)
+	if ii != ij {
+		return ii < ij
+	}
+	return a.typs[i].String() < a.typs[j].String()
+}
@@ -0,0 +1,160 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pogo
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SourceMapSuffix is added to the name of each generated file to give the name of its source map.
+//
+// A source map is a text file, each line of which holds a generated-code line number, a tab,
+// then the Go position "file.go:line" that the generated code from that line onwards comes from,
+// or "-" if there is no Go position.
+// Lines are only listed where the Go position changes, in ascending order.
+const SourceMapSuffix = ".map"
+
+// codeBuffer collects the generated code, noting the latest PosHash as each line is written.
+type codeBuffer struct {
+	bytes.Buffer
+	posHash *PosHash     // where to find the latest PosHash, set by NewCompilation()
+	lines   int          // the number of complete lines in the buffer
+	marks   []sourceMark // where the PosHash changes
+	noPos   bool         // set while writing code that has no Go position
+}
+
+type sourceMark struct {
+	line    int // generated code line, numbered from 1
+	posHash PosHash
+}
+
+func (b *codeBuffer) mark() {
+	if b.posHash == nil {
+		return
+	}
+	ph := *b.posHash
+	if b.noPos {
+		ph = NoPosHash
+	}
+	if ph < 0 {
+		ph = -ph // a nearby reference is the best we have
+	}
+	line := b.lines + 1
+	if n := len(b.marks); n > 0 {
+		if b.marks[n-1].posHash == ph {
+			return
+		}
+		if b.marks[n-1].line == line {
+			b.marks[n-1].posHash = ph
+			return
+		}
+	}
+	b.marks = append(b.marks, sourceMark{line, ph})
+}
+
+// Write implements io.Writer, noting the code position of the lines written.
+func (b *codeBuffer) Write(p []byte) (int, error) {
+	if len(bytes.TrimSpace(p)) > 0 { // blank lines may be written after a class has been finished, so give no position
+		b.mark()
+	}
+	b.lines += bytes.Count(p, []byte{'\n'})
+	return b.Buffer.Write(p)
+}
+
+// WriteString appends to the buffer, noting the code position of the lines written.
+func (b *codeBuffer) WriteString(s string) (int, error) {
+	if len(strings.TrimSpace(s)) > 0 {
+		b.mark()
+	}
+	b.lines += strings.Count(s, "\n")
+	return b.Buffer.WriteString(s)
+}
+
+// Reset empties the buffer and its code position information.
+func (b *codeBuffer) Reset() {
+	b.Buffer.Reset()
+	b.lines = 0
+	b.marks = b.marks[:0]
+}
+
+// PosHashPosition returns the Go file name and line number that a PosHash value refers to.
+func (comp *Compilation) PosHashPosition(ph PosHash) (string, int, bool) {
+	if ph < 0 {
+		ph = -ph
+	}
+	for _, f := range comp.PosHashFileList {
+		if int(ph) > f.BasePosHash && int(ph) <= f.BasePosHash+f.LineCount {
+			return f.FileName, int(ph) - f.BasePosHash, true
+		}
+	}
+	return "", 0, false
+}
+
+// make the source map for the contents of the buffer
+func (comp *Compilation) sourceMap(b *codeBuffer) []byte {
+	var sm bytes.Buffer
+	last := "" // adjacent marks may still give the same position, for example NoPosHash and an invalid PosHash
+	for _, m := range b.marks {
+		pos := "-"
+		if file, line, ok := comp.PosHashPosition(m.posHash); ok {
+			pos = file + ":" + strconv.Itoa(line)
+		}
+		if pos != last {
+			fmt.Fprintf(&sm, "%d\t%s\n", m.line, pos)
+			last = pos
+		}
+	}
+	return sm.Bytes()
+}
+
+// SourceMap gives the Go position of each line of a generated file.
+type SourceMap struct {
+	lines []int    // ascending generated-code line numbers
+	posns []string // the Go position for the corresponding line onwards, "" if none
+}
+
+// ReadSourceMap reads a source map, in the format described for SourceMapSuffix.
+func ReadSourceMap(r io.Reader) (*SourceMap, error) {
+	sm := &SourceMap{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("pogo.ReadSourceMap() bad line: %q", scanner.Text())
+		}
+		line, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("pogo.ReadSourceMap() bad line number: %s", err)
+		}
+		if n := len(sm.lines); n > 0 && line <= sm.lines[n-1] {
+			return nil, fmt.Errorf("pogo.ReadSourceMap() line numbers out of order at %d", line)
+		}
+		pos := fields[1]
+		if pos == "-" {
+			pos = ""
+		}
+		sm.lines = append(sm.lines, line)
+		sm.posns = append(sm.posns, pos)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// GoPosition returns the Go position, as "file.go:line", that generated-code line comes from.
+func (sm *SourceMap) GoPosition(line int) (string, bool) {
+	i := sort.SearchInts(sm.lines, line+1) - 1 // the last entry at or before line
+	if i < 0 || sm.posns[i] == "" {
+		return "", false
+	}
+	return sm.posns[i], true
+}
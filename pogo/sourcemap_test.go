@@ -0,0 +1,50 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package pogo
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// the source map written for some generated code should give back the Go position of each line
+func TestSourceMap(t *testing.T) {
+	comp := &Compilation{PosHashFileList: []PosHashFileStruct{
+		{FileName: "a.go", LineCount: 10, BasePosHash: 0},
+		{FileName: "b.go", LineCount: 5, BasePosHash: 10},
+	}}
+	var ph PosHash
+	b := &codeBuffer{posHash: &ph}
+	b.WriteString("class A {\n") // line 1, no position yet
+	ph = 3
+	b.WriteString("x = 1;\ny = 2;\n") // lines 2 and 3
+	ph = -12                          // a nearby reference
+	b.WriteString("z = 3;\n")         // line 4
+	b.WriteString("\n")               // line 5, blank so not given a position of its own
+	b.noPos = true
+	b.WriteString("}\n") // line 6
+
+	data := comp.sourceMap(b)
+	if want := "1\t-\n2\ta.go:3\n4\tb.go:2\n6\t-\n"; string(data) != want {
+		t.Errorf("source map is %q, want %q", data, want)
+	}
+	sm, err := ReadSourceMap(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for line, want := range []string{"", "", "a.go:3", "a.go:3", "b.go:2", "b.go:2", "", ""} {
+		got, ok := sm.GoPosition(line)
+		if got != want || ok != (want != "") {
+			t.Errorf("line %d gives %q %v, want %q", line, got, ok, want)
+		}
+	}
+
+	for _, bad := range []string{"1 a.go:3\n", "x\ta.go:3\n", "2\ta.go:3\n2\tb.go:1\n"} {
+		if _, err := ReadSourceMap(strings.NewReader(bad)); err == nil {
+			t.Errorf("no error reading source map %q", bad)
+		}
+	}
+}
@@ -6,7 +6,9 @@ package pogo
 
 import (
 	"fmt"
+	"go/token"
 	"reflect"
+	"sort"
 
 	"golang.org/x/tools/go/types"
 	"golang.org/x/tools/go/types/typeutil"
@@ -15,7 +17,7 @@ import (
 // IsValidInPogo exists to screen out any types that the system does not handle correctly.
 // Currently it should say everything is valid.
 // TODO review if still required in this form.
-func IsValidInPogo(et types.Type, posStr string) bool {
+func (comp *Compilation) IsValidInPogo(et types.Type, posStr string) bool {
 	switch et.(type) {
 	case *types.Basic:
 		switch et.(*types.Basic).Kind() {
@@ -32,7 +34,7 @@ func IsValidInPogo(et types.Type, posStr string) bool {
 			if et.(*types.Basic).String() == "invalid type" { // the type of unused map value itterators!
 				return true
 			}
-			LogError(posStr, "pogo", fmt.Errorf("basic type %s is not supported", et.(*types.Basic).String()))
+			comp.LogError(posStr, "pogo", fmt.Errorf("basic type %s is not supported", et.(*types.Basic).String()))
 		}
 	case *types.Interface, *types.Slice, *types.Struct, *types.Tuple, *types.Map, *types.Pointer, *types.Array,
 		*types.Named, *types.Signature, *types.Chan:
@@ -42,55 +44,64 @@ func IsValidInPogo(et types.Type, posStr string) bool {
 		if rTyp == "*ssa.opaqueType" { // the type of map itterators!
 			return true
 		}
-		LogError(posStr, "pogo", fmt.Errorf("type %s is not supported", rTyp))
+		comp.LogError(posStr, "pogo", fmt.Errorf("type %s is not supported", rTyp))
 	}
 	return false
 }
 
-// TypesEncountered keeps track of the types we encounter using the excellent go.tools/go/types/typesmap package.
-var TypesEncountered typeutil.Map
-
-// NextTypeID is used to give each type we come across its own ID.
-var NextTypeID = 1 // entry zero is invalid
-
 // LogTypeUse : As the code generator encounters new types it logs them here, returning a string of the ID for insertion into the code.
-func LogTypeUse(t types.Type) string {
-	r := TypesEncountered.At(t)
+func (comp *Compilation) LogTypeUse(t types.Type) string {
+	if comp.forked != nil {
+		return comp.forked.typeIDPlaceholder(t)
+	}
+	r := comp.TypesEncountered.At(t)
 	if r != nil {
 		return fmt.Sprintf("%d", r)
 	}
-	TypesEncountered.Set(t, NextTypeID)
-	r = NextTypeID
-	NextTypeID++
+	comp.TypesEncountered.Set(t, comp.NextTypeID)
+	r = comp.NextTypeID
+	comp.NextTypeID++
 	return fmt.Sprintf("%d", r)
 }
 
-// TypesWithMethodSets in a utility function to only return seen types
-func TypesWithMethodSets() (sets []types.Type) {
-	typs := rootProgram.RuntimeTypes()
+// TypesWithMethodSets in a utility function to only return seen types,
+// including those required by the code of packages that were not built, see cache.go.
+func (comp *Compilation) TypesWithMethodSets() (sets []types.Type) {
+	typs := comp.rootProgram.RuntimeTypes()
+	if comp.cache != nil {
+		var all typeutil.Map
+		for _, t := range typs {
+			all.Set(t, true)
+		}
+		for _, t := range comp.cache.RuntimeTypes() {
+			if all.At(t) == nil {
+				all.Set(t, true)
+				typs = append(typs, t)
+			}
+		}
+	}
 	for _, t := range typs {
-		if TypesEncountered.At(t) != nil {
+		if comp.TypesEncountered.At(t) != nil {
 			sets = append(sets, t)
 		}
 	}
+	sort.Sort(typesByID{sets, &comp.TypesEncountered}) // RuntimeTypes() is in the random order of a map
 	return sets
 }
 
-var catchReferencedTypesSeen = make(map[string]bool)
-
-func catchReferencedTypes(et types.Type) {
-	id := LogTypeUse(et)
-	_, seen := catchReferencedTypesSeen[id]
+func (comp *Compilation) catchReferencedTypes(et types.Type) {
+	id := comp.LogTypeUse(et)
+	_, seen := comp.catchReferencedTypesSeen[id]
 	if seen {
 		return
 	}
-	catchReferencedTypesSeen[id] = true
+	comp.catchReferencedTypesSeen[id] = true
 
 	// check that we have all the required methods?
 	/*
-		for t := 1; t < NextTypeID; t++ { // make sure we do this in a consistent order
-			for _, k := range TypesEncountered.Keys() {
-				if TypesEncountered.At(k).(int) == t {
+		for t := 1; t < comp.NextTypeID; t++ { // make sure we do this in a consistent order
+			for _, k := range comp.TypesEncountered.Keys() {
+				if comp.TypesEncountered.At(k).(int) == t {
 					switch k.(type) {
 					case *types.Interface:
 						if types.Implements(et,k.(*types.Interface)) {
@@ -105,73 +116,96 @@ func catchReferencedTypes(et types.Type) {
 	//LogTypeUse(types.NewPointer(et))
 	switch et.(type) {
 	case *types.Named:
-		catchReferencedTypes(et.Underlying())
+		comp.catchReferencedTypes(et.Underlying())
 		for m := 0; m < et.(*types.Named).NumMethods(); m++ {
-			catchReferencedTypes(et.(*types.Named).Method(m).Type())
+			comp.catchReferencedTypes(et.(*types.Named).Method(m).Type())
 		}
-		catchReferencedTypes(types.NewPointer(et))
+		comp.catchReferencedTypes(types.NewPointer(et))
 	case *types.Array:
-		catchReferencedTypes(et.(*types.Array).Elem())
+		comp.catchReferencedTypes(et.(*types.Array).Elem())
 		//catchReferencedTypes(types.NewSlice(et.(*types.Array).Elem()))
 	case *types.Pointer:
-		catchReferencedTypes(et.(*types.Pointer).Elem())
+		comp.catchReferencedTypes(et.(*types.Pointer).Elem())
 	case *types.Slice:
-		catchReferencedTypes(et.(*types.Slice).Elem())
+		comp.catchReferencedTypes(et.(*types.Slice).Elem())
 	case *types.Struct:
 		for f := 0; f < et.(*types.Struct).NumFields(); f++ {
 			if et.(*types.Struct).Field(f).IsField() {
-				catchReferencedTypes(et.(*types.Struct).Field(f).Type())
+				comp.catchReferencedTypes(et.(*types.Struct).Field(f).Type())
 			}
 		}
 	case *types.Map:
-		catchReferencedTypes(et.(*types.Map).Key())
-		catchReferencedTypes(et.(*types.Map).Elem())
+		comp.catchReferencedTypes(et.(*types.Map).Key())
+		comp.catchReferencedTypes(et.(*types.Map).Elem())
 	case *types.Signature:
 		for i := 0; i < et.(*types.Signature).Params().Len(); i++ {
-			catchReferencedTypes(et.(*types.Signature).Params().At(i).Type())
+			comp.catchReferencedTypes(et.(*types.Signature).Params().At(i).Type())
 		}
 		for o := 0; o < et.(*types.Signature).Results().Len(); o++ {
-			catchReferencedTypes(et.(*types.Signature).Results().At(o).Type())
+			comp.catchReferencedTypes(et.(*types.Signature).Results().At(o).Type())
 		}
 	case *types.Chan:
-		catchReferencedTypes(et.(*types.Chan).Elem())
+		comp.catchReferencedTypes(et.(*types.Chan).Elem())
+	case *types.Interface:
+		for m := 0; m < et.(*types.Interface).NumMethods(); m++ {
+			comp.catchReferencedTypes(et.(*types.Interface).Method(m).Type())
+		}
+	}
+}
+
+// MethodFuncType returns the type of the method sel in the method set of T, as a function with a receiver of type T as its first parameter.
+func MethodFuncType(T types.Type, sel *types.Selection) *types.Signature {
+	sig := sel.Obj().Type().(*types.Signature)
+	params := []*types.Var{types.NewParam(token.NoPos, nil, "", T)}
+	for p := 0; p < sig.Params().Len(); p++ {
+		params = append(params, sig.Params().At(p))
 	}
+	return types.NewSignature(nil, nil, types.NewTuple(params...), sig.Results(), sig.Variadic())
 }
 
-func visitAllTypes() {
+func (comp *Compilation) visitAllTypes() {
 	// ...so just get the full info on the types we've seen
-	for t := 1; t < NextTypeID; t++ { // make sure we do this in a consistent order
-		for _, k := range TypesEncountered.Keys() {
-			if TypesEncountered.At(k).(int) == t {
-				catchReferencedTypes(k)
+	for t := 1; t < comp.NextTypeID; t++ { // make sure we do this in a consistent order
+		for _, k := range comp.TypesEncountered.Keys() {
+			if comp.TypesEncountered.At(k).(int) == t {
+				comp.catchReferencedTypes(k)
+			}
+		}
+	}
+	// reflect gives the type of each method of a type that may be held in an interface, with the receiver as its first parameter
+	for _, t := range comp.TypesWithMethodSets() {
+		if _, isIface := t.Underlying().(*types.Interface); !isIface {
+			ms := comp.rootProgram.MethodSets.MethodSet(t)
+			for m := 0; m < ms.Len(); m++ {
+				comp.catchReferencedTypes(MethodFuncType(t, ms.At(m)))
 			}
 		}
 	}
 }
 
 // Wrapper for target language emitTypeInfo()
-func emitTypeInfo() {
+func (comp *Compilation) emitTypeInfo() {
 	// belt-and-braces could be used here to make sure we capture every type, needed for reflect
 	// but this makes the generated code too large for Java & C++
 	/*
-		for _, pkg := range rootProgram.AllPackages() {
+		for _, pkg := range comp.rootProgram.AllPackages() {
 			for _, mem := range pkg.Members {
-				catchReferencedTypes(mem.Type())
+				comp.catchReferencedTypes(mem.Type())
 			}
 		}
 	*/
-	visitAllTypes()
-	l := TargetLang
+	comp.visitAllTypes()
+	l := comp.TargetLang
 
-	if len(LibListNoDCE) > 0 { // output target lang type to access named object
-		for t := 1; t < NextTypeID; t++ { // make sure we do this in a consistent order
-			for _, k := range TypesEncountered.Keys() {
-				if TypesEncountered.At(k).(int) == t {
+	if len(comp.LibListNoDCE) > 0 { // output target lang type to access named object
+		for t := 1; t < comp.NextTypeID; t++ { // make sure we do this in a consistent order
+			for _, k := range comp.TypesEncountered.Keys() {
+				if comp.TypesEncountered.At(k).(int) == t {
 					switch k.(type) {
 					case *types.Named:
 						if k.(*types.Named).Obj().Exported() {
-							fmt.Fprintln(&LanguageList[l].buffer,
-								LanguageList[l].TypeStart(k.(*types.Named), k.String()))
+							fmt.Fprintln(&comp.LanguageList[l].buffer,
+								comp.LanguageList[l].TypeStart(k.(*types.Named), k.String()))
 							//fmt.Fprintln(&LanguageList[l].buffer,
 							//	LanguageList[l].TypeEnd(k.(*types.Named), k.String()))
 						}
@@ -181,5 +215,5 @@ func emitTypeInfo() {
 		}
 	}
 
-	fmt.Fprintln(&LanguageList[l].buffer, LanguageList[l].EmitTypeInfo())
+	fmt.Fprintln(&comp.LanguageList[l].buffer, comp.LanguageList[l].EmitTypeInfo())
 }
@@ -14,14 +14,22 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"go/build"
+	"go/scanner"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"regexp"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	"golang.org/x/tools/go/loader"
 	"golang.org/x/tools/go/ssa"
@@ -29,6 +37,7 @@ import (
 	"golang.org/x/tools/go/types"
 
 	// TARDIS Go additions
+	"bufio"
 	"os/exec"
 
 	_ "github.com/tardisgo/tardisgo/haxe" // TARDIS Go addition
@@ -50,6 +59,8 @@ N	build [N]aive SSA form: don't replace local loads/stores with registers.
 var testFlag = flag.Bool("test", false, "Loads test code (*_test.go) for imported packages.")
 var LoadTestZipFS = false
 
+const langName = "haxe" // the target language, TODO add a flag to choose when more than one of them
+
 const TestFS = "tgotestfs.zip"
 
 var runFlag = flag.Bool("run", false, "Invokes the SSA interpreter on the program.")
@@ -66,17 +77,23 @@ var debugFlag = flag.Bool("debug", false, "Instrument the code to enable debuggi
 var traceFlag = flag.Bool("trace", false, "Output trace information for every block visited (warning: huge output)")
 var buidTags = flag.String("tags", "", "build tags separated by spaces")
 var tgoroot = flag.String("tgoroot", "", "set goroot to the given value")
+var hxPackFlag = flag.String("hxpack", "", "sets the Haxe package name to use, overriding any tardisgoHaxePackage constant (default tardis)")
+var hxDirFlag = flag.String("hxdir", "tardis", "sets the directory in which to output generated Haxe code, it is created if required and must end with the directory path of the Haxe package")
+var hxMapFlag = flag.Bool("hxmap", false, "reads Haxe compiler or runtime output from stdin and writes it to stdout with each position in the generated code followed by the Go source position it came from, using the source maps in the -hxdir directory")
+var cacheFlag = flag.String("cache", "", "sets the directory of a build cache, created if required, so that the code generated for unchanged packages can be re-used")
+var jobsFlag = flag.Int("j", runtime.NumCPU(), "the number of functions to generate code for at once, the output is the same whatever the value")
+var reproducibleFlag = flag.Bool("reproducible", false, "generate the same code wherever the Go source files are, naming them by package path rather than full path and giving type IDs in type name order (warning: the program is compiled twice)")
+var preemptFlag = flag.String("preempt", "", "make goroutines give up control at the end of a loop iteration after running that many iterations (e.g. 1000) or milliseconds (e.g. 10ms), so that loops without channel operations do not stop other goroutines and timers (warning: slower code)")
+var jsonFlag = flag.Bool("json", false, "write errors and warnings to stdout as JSON objects, one per line, rather than as text to stderr")
 
 // TODO
 //var traceFlag = flag.Bool("v", false, "Verbose compiler mode (including files written)")
-//var hxPackFlag = flag.String("hxpack", "tardis", "Sets the Haxe package name to use")
-//var hxDirFlag = flag.String("hxdir", "tardis", "Sets the directory in which to output generated Haxe code")
 //var hxLibFlag = flag.Bool("hxlib", false, "Generates code suitable for use as a Haxe library (no Dead Code Elimination)")
 
 // TARDIS Go modification TODO review words here
 const usage = `SSA builder and TARDIS Go transpiler (experimental).
 Usage: tardisgo [<flag> ...] <args> ...
-A shameless copy of the ssadump utility, but also writes a 'Go.hx' Haxe file into the 'tardis' sub-directory of the current location (which you must create by hand).
+A shameless copy of the ssadump utility, but also writes a 'Go.hx' Haxe file into the 'tardis' sub-directory of the current location (created if required, use -hxdir and -hxpack to choose another directory and Haxe package).
 Example:
 % tardisgo hello.go
 Then to compile the tardis/Go.hx file generated, type the command line: "haxe -main tardis.Go -cp tardis -js tardis/go.js", or whatever Haxe compilation options you want to use. 
@@ -115,6 +132,9 @@ func init() {
 
 func main() {
 	if err := doMain(); err != nil {
+		if code, ok := err.(exitStatus); ok {
+			os.Exit(int(code)) // the output of the failing target has already been printed
+		}
 		fmt.Fprintf(os.Stderr, "TARDISgo: %s\n", err) // TARDISgo alteration
 		os.Exit(1)
 	}
@@ -124,11 +144,51 @@ func main() {
 func doMain() error {
 	flag.Parse()
 	args := flag.Args()
+	if *hxMapFlag {
+		return newGoPositions(*hxDirFlag).filter(os.Stdin, os.Stdout)
+	}
+	if len(args) > 0 && args[0] == "test" {
+		return doTest(args[1:])
+	}
 	return doTestable(args)
 }
 
 func doTestable(args []string) error {
+	if len(args) == 0 {
+		//fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("%v", usage)
+	}
 
+	// Profiling support.
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			return err
+		}
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			return err
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	var cache *pogo.BuildCache
+	if *cacheFlag != "" && !*runFlag {
+		cache = pogo.NewBuildCache(*cacheFlag,
+			fmt.Sprintf("tags=%q tgoroot=%q build=%q debug=%v", *buidTags, *tgoroot, *buildFlag, *debugFlag))
+	}
+	for {
+		// when an unchanged package turns out to need building, the program is loaded again, see pogo.BuildCache
+		err := doProgram(args, cache)
+		if err != pogo.ErrStaleCache {
+			return err
+		}
+	}
+}
+
+// doProgram loads, builds and then interprets or compiles the program,
+// only building the packages that the cache, if not nil, does not skip.
+func doProgram(args []string, cache *pogo.BuildCache) error {
 	conf := loader.Config{
 		Build:            &build.Default,
 		ImportFromBinary: false,
@@ -198,24 +258,6 @@ func doTestable(args []string) error {
 		}
 	}
 
-	if len(args) == 0 {
-		//fmt.Fprint(os.Stderr, usage)
-		return fmt.Errorf("%v", usage)
-	}
-
-	// Profiling support.
-	if *cpuprofile != "" {
-		f, err := os.Create(*cpuprofile)
-		if err != nil {
-			return err
-		}
-		err = pprof.StartCPUProfile(f)
-		if err != nil {
-			return err
-		}
-		defer pprof.StopCPUProfile()
-	}
-
 	// TODO Eventually this might be better as an environment variable
 	if !(*runFlag) {
 		if *tgoroot == "" {
@@ -236,7 +278,7 @@ func doTestable(args []string) error {
 	}
 
 	// Use the initial packages from the command line.
-	_, err := conf.FromArgs(args, *testFlag)
+	progArgs, err := conf.FromArgs(args, *testFlag) // any arguments after "--" are for the program
 	if err != nil {
 		return err
 	}
@@ -246,8 +288,21 @@ func doTestable(args []string) error {
 		conf.Import("runtime")
 	}
 
-	// TARDIS GO additional line to add the language specific go runtime code
-	conf.Import(pogo.LanguageList[pogo.TargetLang].Goruntime) // TODO add code to set pogo.TargetLang when more than one of them
+	// TARDIS GO additional lines to add the language specific go runtime code
+	targetLang, err := pogo.FindTargetLang(langName)
+	if err != nil {
+		return err
+	}
+	conf.Import(pogo.LanguageList[targetLang].Goruntime)
+
+	if *jsonFlag {
+		conf.TypeChecker.Error = func(e error) { emitJSON(goErrorDiagnostic(e)) }
+	}
+	if cache != nil {
+		conf.TypeCheckFuncBodies = func(path string) bool {
+			return !cache.Skip(path, sourceFiles(&conf, path))
+		}
+	}
 
 	// Load, parse and type-check the whole program, including the type definitions.
 	iprog, err := conf.Load()
@@ -258,7 +313,11 @@ func doTestable(args []string) error {
 	// Create and build SSA-form program representation.
 	prog := ssa.Create(iprog, mode)
 
-	prog.BuildAll()
+	if cache != nil {
+		buildUncached(prog, cache, mode)
+	} else {
+		prog.BuildAll()
+	}
 
 	// Run the interpreter.
 	if *runFlag {
@@ -294,7 +353,7 @@ func doTestable(args []string) error {
 				build.Default.GOARCH, runtime.GOARCH)
 		}
 
-		interp.Interpret(main, interpMode, conf.TypeChecker.Sizes, main.Object.Path(), args)
+		interp.Interpret(main, interpMode, conf.TypeChecker.Sizes, main.Object.Path(), progArgs)
 	} else {
 		// if not interpreting...
 		// TARDIS Go additions: copy run interpreter code above, but call pogo class
@@ -310,13 +369,13 @@ func doTestable(args []string) error {
 			if main == nil {
 				return fmt.Errorf("no tests")
 			}
+			if testRun != nil {
+				testRun.selectTests(pkgs)
+			}
 			fd, err := os.Open(TestFS)
 			fd.Close()
 			if err == nil {
 				LoadTestZipFS = true
-				for l := range pogo.LanguageList {
-					pogo.LanguageList[l].TestFS = TestFS
-				}
 			}
 		} else {
 			// Otherwise, run main.main.
@@ -341,102 +400,114 @@ func doTestable(args []string) error {
 
 			interp.Interpret(main, interpMode, conf.TypeChecker.Sizes, main.Object.Path(), args)
 		*/
-		pogo.DebugFlag = *debugFlag
-		pogo.TraceFlag = *traceFlag
-		err = pogo.EntryPoint(main) // TARDIS Go entry point, returns an error
+		comp, err := pogo.NewCompilation(main, langName)
+		if err != nil {
+			return err
+		}
+		comp.DebugFlag = *debugFlag
+		comp.TraceFlag = *traceFlag
+		comp.OutputDir = *hxDirFlag
+		comp.Jobs = *jobsFlag
+		comp.Reproducible = *reproducibleFlag
+		comp.PreemptLoops, comp.PreemptMillis, err = parsePreempt(*preemptFlag)
+		if err != nil {
+			return err
+		}
+		comp.Cache = cache
+		if cache != nil || *reproducibleFlag {
+			comp.PackageFiles = make(map[string][]string)
+			for pkg, info := range iprog.AllPackages {
+				for _, f := range info.Files {
+					comp.PackageFiles[pkg.Path()] = append(comp.PackageFiles[pkg.Path()],
+						iprog.Fset.File(f.Pos()).Name())
+				}
+			}
+		}
+		comp.TargetPackage = *hxPackFlag
+		if *jsonFlag {
+			comp.DiagnosticHandler = emitJSON
+		}
+		if LoadTestZipFS {
+			comp.LanguageList[comp.TargetLang].TestFS = TestFS
+		}
+		if testRun != nil {
+			comp.LanguageList[comp.TargetLang].TestNames = testRun.selected
+		}
+		err = comp.Compile() // TARDIS Go entry point, returns an error
 		if err != nil {
 			return err
 		}
+		err = comp.WriteFiles()
+		if err != nil {
+			return err
+		}
+		if *allFlag == "" {
+			return nil
+		}
+		hxPack := comp.TargetPackage
+		if hxPack == "" {
+			hxPack = "tardis" // the default used by haxe.FileStart()
+		}
+		tgts, err := haxeTargets(*hxDirFlag, hxPack, progArgs)
+		if err != nil {
+			return err
+		}
+		if testRun != nil {
+			return testRun.runTargets(tgts)
+		}
 		results := make(chan resChan)
 		switch *allFlag {
-		case "": // NoOp
 		case "all":
-			for _, dir := range dirs {
+			for _, dir := range []string{"cpp", "java", "cs" /*, "php"*/} {
+				dir = filepath.Join(*hxDirFlag, dir)
 				err := os.RemoveAll(dir)
 				if err != nil {
 					fmt.Println("Error deleting existing '" + dir + "' directory: " + err.Error())
 				}
 			}
-			for _, cmd := range targets {
+			allCmds := [][][]string{tgts["cpp"], tgts["java"], tgts["cs"], tgts["js"], tgts["jsfu"]}
+			for _, cmd := range allCmds {
 				go doTarget(cmd, results)
 			}
-			for _ = range targets {
+			code := 0
+			for _ = range allCmds {
 				r := <-results
 				fmt.Println(r.output)
+				if r.err != nil && code == 0 {
+					code = exitCode(r.err)
+				}
 				r.backChan <- true
 			}
+			if code != 0 {
+				return exitStatus(code) // exit with the status of the first target to fail
+			}
 
 		case "math": // which is faster for the test with correct math processing, cpp or js?
-			//err := os.RemoveAll("tardis/cpp")
-			//if err != nil {
-			//	fmt.Println("Error deleting existing '" + "tardis/cpp" + "' directory: " + err.Error())
-			//}
-			mathCmds := [][][]string{
-				[][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-cpp", "tardis/cpp"},
-					[]string{"echo", `"CPP:"`},
-					[]string{"time", "./tardis/cpp/Go"},
-				},
-				[][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-D", "fullunsafe", "-js", "tardis/go-fu.js"},
-					[]string{"echo", `"Node/JS using fullunsafe memory mode (js dataview):"`},
-					[]string{"time", "node", "tardis/go-fu.js"},
-				},
-			}
+			mathCmds := [][][]string{tgts["cpp"], tgts["jsfu"]}
 			for _, cmd := range mathCmds {
 				go doTarget(cmd, results)
 			}
+			code := 0
 			for _ = range mathCmds {
 				r := <-results
 				fmt.Println(r.output)
+				if r.err != nil && code == 0 {
+					code = exitCode(r.err)
+				}
 				r.backChan <- true
 			}
+			if code != 0 {
+				return exitStatus(code) // exit with the status of the first target to fail
+			}
 
 		case "interp", "cpp", "cs", "js", "jsfu", "java": // for running tests
-			switch *allFlag {
-			case "interp":
-				go doTarget([][]string{
-					[]string{"echo", ``}, // Output from this line is ignored
-					[]string{"echo", `"Neko (haxe --interp):"`},
-					[]string{"time", "haxe", "-main", "tardis.Go", "-cp", "tardis", "--interp"},
-				}, results)
-			case "cpp":
-				go doTarget([][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-cpp", "tardis/cpp"},
-					[]string{"echo", `"CPP:"`},
-					[]string{"time", "./tardis/cpp/Go"},
-				}, results)
-			case "cs":
-				go doTarget([][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-cs", "tardis/cs"},
-					[]string{"echo", `"CS:"`},
-					[]string{"time", "mono", "./tardis/cs/bin/Go.exe"},
-				}, results)
-			case "js":
-				go doTarget([][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full" /*,  `-D`, `analyzer`*/, "-js", "tardis/go.js"},
-					[]string{"echo", `"Node/JS:"`},
-					[]string{"time", "node", "tardis/go.js"},
-				}, results)
-			case "jsfu":
-				go doTarget([][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full" /*, `-D`, `analyzer`*/, "-D", "fullunsafe", "-js", "tardis/go-fu.js"},
-					[]string{"echo", `"Node/JS using fullunsafe memory mode (js dataview):"`},
-					[]string{"time", "node", "tardis/go-fu.js"},
-				}, results)
-			case "java":
-				go doTarget([][]string{
-					[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-java", "tardis/java"},
-					[]string{"echo", `"Java:"`},
-					[]string{"time", "java", "-jar", "tardis/java/Go.jar"},
-				}, results)
-			}
+			go doTarget(tgts[*allFlag], results)
 			r := <-results
 			fmt.Println(r.output)
+			r.backChan <- true
 			if r.err != nil {
-				os.Exit(1) // exit with an error if the test fails
+				return exitStatus(exitCode(r.err)) // exit with the status of the program, or of the Haxe compiler if that failed
 			}
-			r.backChan <- true
 
 		default:
 			panic("invalid value for -haxe flag: " + *allFlag)
@@ -445,58 +516,87 @@ func doTestable(args []string) error {
 	return nil
 }
 
-var dirs = []string{"tardis/cpp", "tardis/java", "tardis/cs" /*, "tardis/php"*/}
-
-var targets = [][][]string{
-	[][]string{
-		[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-cpp", "tardis/cpp"},
-		[]string{"echo", `"CPP:"`},
-		[]string{"time", "./tardis/cpp/Go"},
-	},
-	[][]string{
-		[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-java", "tardis/java"},
-		[]string{"echo", `"Java:"`},
-		[]string{"time", "java", "-jar", "tardis/java/Go.jar"},
-	},
-	[][]string{
-		[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-cs", "tardis/cs"},
-		[]string{"echo", `"CS:"`},
-		[]string{"time", "mono", "./tardis/cs/bin/Go.exe"},
-	},
-	[][]string{
-		[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-js", "tardis/go.js"},
-		[]string{"echo", `"Node/JS:"`},
-		[]string{"time", "node", "tardis/go.js"},
-	},
-	[][]string{
-		[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-D", "fullunsafe", "-js", "tardis/go-fu.js"},
-		[]string{"echo", `"Node/JS using fullunsafe memory mode (js dataview):"`},
-		[]string{"time", "node", "tardis/go-fu.js"},
-	},
-	// Cannot automate testing for SWF so removed
-	//[][]string{
-	//	[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-swf", "tardis/go.swf"},
-	//	[]string{"echo", `"Opening swf file (Chrome as a file association for swf works to test on OSX):"` + "\n"},
-	//	[]string{"open", "tardis/go.swf"},
-	//},
-	// PHP will never be a reliable target, so removed
-	//[][]string{
-	//	[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-php", "tardis/php", "--php-prefix", "tgo"},
-	//	[]string{"echo", `"PHP:"`},
-	//	[]string{"time", "php", "tardis/php/index.php"},
-	//},
-	// Seldom works, so removed
-	//[][]string{
-	//	[]string{"haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "-neko", "tardis/go.n"},
-	//	[]string{"echo", `"Neko (does not work for large code):"`},
-	//	[]string{"time", "neko", "tardis/go.n"},
-	//},
-	// only really useful for testing, so can be run from the command line
-	//[][]string{
-	//	[]string{"echo", ``}, // Output from this line is ignored
-	//	[]string{"echo", `"Neko (haxe --interp):"`},
-	//	[]string{"time", "haxe", "-main", "tardis.Go", "-cp", "tardis", "-dce", "full", "--interp"},
-	//},
+// haxeClassPath returns the Haxe class path from which the given package can be found in the given directory.
+func haxeClassPath(dir, pack string) (string, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	packPath := strings.Replace(pack, ".", "/", -1)
+	if dir != packPath && !strings.HasSuffix(dir, "/"+packPath) {
+		return "", fmt.Errorf("the output directory %q does not end with %q, as required for Haxe package %q", dir, packPath, pack)
+	}
+	cp := strings.TrimSuffix(strings.TrimSuffix(dir, packPath), "/")
+	if cp == "" {
+		cp = "."
+	}
+	return cp, nil
+}
+
+// haxeTargets returns, for each value of the -haxe flag that runs a single target, the commands to compile
+// and run the code generated into the given directory with the given Haxe package name, passing it the arguments given.
+func haxeTargets(dir, pack string, progArgs []string) (map[string][][]string, error) {
+	cp, err := haxeClassPath(dir, pack)
+	if err != nil {
+		return nil, err
+	}
+	main := pack + ".Go"
+	out := func(f string) string { return filepath.Join(dir, f) }
+	tgts := map[string][][]string{
+		"cpp": [][]string{
+			[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-cpp", out("cpp")},
+			[]string{"echo", `"CPP:"`},
+			[]string{"time", out("cpp/Go")},
+		},
+		"java": [][]string{
+			[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-java", out("java")},
+			[]string{"echo", `"Java:"`},
+			[]string{"time", "java", "-jar", out("java/Go.jar")},
+		},
+		"cs": [][]string{
+			[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-cs", out("cs")},
+			[]string{"echo", `"CS:"`},
+			[]string{"time", "mono", out("cs/bin/Go.exe")},
+		},
+		"js": [][]string{
+			[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full" /*,  `-D`, `analyzer`*/, "-js", out("go.js")},
+			[]string{"echo", `"Node/JS:"`},
+			[]string{"time", "node", out("go.js")},
+		},
+		"jsfu": [][]string{
+			[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full" /*, `-D`, `analyzer`*/, "-D", "fullunsafe", "-js", out("go-fu.js")},
+			[]string{"echo", `"Node/JS using fullunsafe memory mode (js dataview):"`},
+			[]string{"time", "node", out("go-fu.js")},
+		},
+		// only really useful for testing, so can be run from the command line
+		"interp": [][]string{
+			[]string{"echo", ``}, // Output from this line is ignored
+			[]string{"echo", `"Neko (haxe --interp):"`},
+			[]string{"time", "haxe", "-main", main, "-cp", cp, "--interp"},
+		},
+		// Cannot automate testing for SWF so not included
+		//"swf": [][]string{
+		//	[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-swf", out("go.swf")},
+		//	[]string{"echo", `"Opening swf file (Chrome as a file association for swf works to test on OSX):"` + "\n"},
+		//	[]string{"open", out("go.swf")},
+		//},
+		// PHP will never be a reliable target, so not included
+		//"php": [][]string{
+		//	[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-php", out("php"), "--php-prefix", "tgo"},
+		//	[]string{"echo", `"PHP:"`},
+		//	[]string{"time", "php", out("php/index.php")},
+		//},
+		// Seldom works, so not included
+		//"neko": [][]string{
+		//	[]string{"haxe", "-main", main, "-cp", cp, "-dce", "full", "-neko", out("go.n")},
+		//	[]string{"echo", `"Neko (does not work for large code):"`},
+		//	[]string{"time", "neko", out("go.n")},
+		//},
+	}
+	for name, cmds := range tgts {
+		if name != "interp" { // the Haxe interpreter has no way to pass arguments to the program
+			run := cmds[len(cmds)-1]
+			cmds[len(cmds)-1] = append(run[:len(run):len(run)], progArgs...)
+		}
+	}
+	return tgts, nil
 }
 
 type resChan struct {
@@ -505,9 +605,78 @@ type resChan struct {
 	backChan chan bool
 }
 
+// sourceFiles returns the Go source files of a package, as the loader will find them, or nil if they are not known.
+func sourceFiles(conf *loader.Config, path string) []string {
+	bp, err := conf.Build.Import(path, "", 0)
+	if err != nil || len(bp.CgoFiles) > 0 {
+		return nil
+	}
+	names := append([]string(nil), bp.GoFiles...)
+	if conf.ImportPkgs[path] { // augmented with its tests
+		names = append(names, bp.TestGoFiles...)
+	}
+	files := make([]string, len(names))
+	for i, name := range names {
+		files[i] = filepath.Join(bp.Dir, name)
+	}
+	return files
+}
+
+// buildUncached builds the SSA form of the packages that the build cache does not skip, as prog.BuildAll() does for all of them.
+func buildUncached(prog *ssa.Program, cache *pogo.BuildCache, mode ssa.BuilderMode) {
+	var wg sync.WaitGroup
+	for _, p := range prog.AllPackages() {
+		if cache.Skipped(p.Object.Path()) {
+			continue
+		}
+		if mode&ssa.BuildSerially != 0 {
+			p.Build()
+		} else {
+			wg.Add(1)
+			go func(p *ssa.Package) {
+				p.Build()
+				wg.Done()
+			}(p)
+		}
+	}
+	wg.Wait()
+}
+
+// parsePreempt reads the value of the -preempt flag, a number of loop iterations or of milliseconds ending in "ms".
+func parsePreempt(s string) (loops, millis int, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(s, "ms"))
+	if err != nil || n <= 0 {
+		return 0, 0, fmt.Errorf("invalid -preempt value %q, it should be a number of loop iterations, or of milliseconds ending in ms", s)
+	}
+	if strings.HasSuffix(s, "ms") {
+		return 0, n, nil
+	}
+	return n, 0, nil
+}
+
+// exitStatus is returned by doMain when a Haxe target fails, so that main exits with its status
+// only after the deferred calls have run, for example to finish writing a -cpuprofile.
+type exitStatus int
+
+func (e exitStatus) Error() string { return fmt.Sprintf("exit status %d", int(e)) }
+
+// exitCode gives the exit status of a command that failed, or 1 if it did not exit normally.
+func exitCode(err error) int {
+	if ee, ok := err.(*exec.ExitError); ok {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Exited() && ws.ExitStatus() != 0 {
+			return ws.ExitStatus()
+		}
+	}
+	return 1
+}
+
 func doTarget(cl [][]string, results chan resChan) {
 	res := ""
 	var lastErr error
+	gp := newGoPositions(*hxDirFlag)
 	for j, c := range cl {
 		if lastErr != nil {
 			break
@@ -536,8 +705,8 @@ func doTarget(cl [][]string, results chan resChan) {
 				if lastErr != nil {
 					out = append(out, []byte(lastErr.Error())...)
 				}
-				if j > 0 { // ignore the output from the compile phase
-					res += string(out)
+				if j > 0 || lastErr != nil { // ignore the output from the compile phase, unless it failed
+					res += gp.rewrite(string(out))
 				}
 			}
 		}
@@ -546,3 +715,93 @@ func doTarget(cl [][]string, results chan resChan) {
 	results <- resChan{res, lastErr, bc}
 	<-bc
 }
+
+var jsonMutex sync.Mutex
+var jsonEncoder = json.NewEncoder(os.Stdout)
+
+// emitJSON writes a diagnostic to stdout as a single line of JSON, it may be called from many goroutines
+func emitJSON(d pogo.Diagnostic) {
+	jsonMutex.Lock()
+	defer jsonMutex.Unlock()
+	if err := jsonEncoder.Encode(d); err != nil {
+		fmt.Fprintf(os.Stderr, "TARDISgo: unable to write JSON diagnostic: %s\n", err)
+	}
+}
+
+// goErrorDiagnostic turns an error from the Go parser or type-checker into a diagnostic
+func goErrorDiagnostic(e error) pogo.Diagnostic {
+	d := pogo.Diagnostic{Severity: pogo.SeverityError, Subsystem: "go", Message: e.Error()}
+	switch e := e.(type) {
+	case types.Error:
+		posn := e.Fset.Position(e.Pos)
+		d.Filename, d.Line, d.Column = posn.Filename, posn.Line, posn.Column
+		d.Location = posn.String()
+		d.Message = e.Msg
+	case scanner.Error:
+		d.Filename, d.Line, d.Column = e.Pos.Filename, e.Pos.Line, e.Pos.Column
+		d.Location = e.Pos.String()
+		d.Message = e.Msg
+	}
+	return d
+}
+
+// matches the positions in Haxe compiler error messages "Go_x.hx:123: ..." and stack traces "Go_x.hx line 123"
+var hxPosRE = regexp.MustCompile(`([^\s:()]+\.hx)(:| line )(\d+)`)
+
+// goPositions translates positions in the generated Haxe code into Go positions, using the source maps written by pogo
+type goPositions struct {
+	dir  string                     // the -hxdir directory, where source maps are looked for if not found beside the named file
+	maps map[string]*pogo.SourceMap // nil if there is no usable source map for the file
+}
+
+func newGoPositions(dir string) *goPositions {
+	return &goPositions{dir: dir, maps: make(map[string]*pogo.SourceMap)}
+}
+
+func (gp *goPositions) sourceMap(hxFile string) *pogo.SourceMap {
+	sm, seen := gp.maps[hxFile]
+	if !seen {
+		for _, name := range []string{hxFile + pogo.SourceMapSuffix,
+			filepath.Join(gp.dir, filepath.Base(hxFile)+pogo.SourceMapSuffix)} {
+			f, err := os.Open(name)
+			if err == nil {
+				sm, err = pogo.ReadSourceMap(f)
+				f.Close()
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "TARDISgo: unable to read source map %s: %s\n", name, err)
+				}
+				break
+			}
+		}
+		gp.maps[hxFile] = sm
+	}
+	return sm
+}
+
+// rewrite adds the Go position after each Haxe position in the text, where it is known
+func (gp *goPositions) rewrite(text string) string {
+	return hxPosRE.ReplaceAllStringFunc(text, func(hxPos string) string {
+		parts := hxPosRE.FindStringSubmatch(hxPos)
+		sm := gp.sourceMap(parts[1])
+		if sm == nil {
+			return hxPos
+		}
+		line, _ := strconv.Atoi(parts[3])
+		goPos, ok := sm.GoPosition(line)
+		if !ok {
+			return hxPos
+		}
+		return hxPos + " [" + goPos + "]"
+	})
+}
+
+// filter rewrites each line of the input
+func (gp *goPositions) filter(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if _, err := fmt.Fprintln(out, gp.rewrite(scanner.Text())); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
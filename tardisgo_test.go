@@ -1,9 +1,16 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/tardisgo/tardisgo/pogo"
 )
 
 func TestCore(t *testing.T) {
@@ -13,6 +20,9 @@ func TestCore(t *testing.T) {
 	}
 
 	*debugFlag = true
+	*preemptFlag = "1000" // for testScheduler()
+	// so that the tests below compile in the default mode
+	defer func() { *debugFlag, *preemptFlag = false, "" }()
 	err = doTestable([]string{"test.go"})
 	if err != nil {
 		t.Error(err)
@@ -35,3 +45,242 @@ func TestCore(t *testing.T) {
 }
 
 // NOTE: main Travis CI standard library tests are in a shell script in goroot/...
+
+// a program whose goroutines are all blocked should stop with the same message as in Go
+func TestDeadlock(t *testing.T) {
+	if _, err := exec.LookPath("haxe"); err != nil {
+		t.Skip("haxe is not installed")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := ioutil.TempDir("", "tardisgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	defer os.Chdir(wd)
+	src := "package main\n\nfunc main() {\n\tc := make(chan int)\n\tgo func() { <-c }()\n\t<-c\n}\n"
+	if err := ioutil.WriteFile(filepath.Join(tmp, "deadlock.go"), []byte(src), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := doTestable([]string{"deadlock.go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := exec.Command("haxe", "-main", "tardis.Go", "-cp", "tardis", "--interp").CombinedOutput()
+	if err == nil {
+		t.Error("the deadlocked program did not fail")
+	}
+	if !strings.Contains(string(out), "fatal error: all goroutines are asleep - deadlock!") {
+		t.Errorf("the deadlock was not reported, the output was:\n%s", out)
+	}
+}
+
+// the same code should be generated from the same Go program, wherever its files are
+func TestReproducible(t *testing.T) {
+	src, err := ioutil.ReadFile("tests/core/test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp, err := ioutil.TempDir("", "tardisgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	defer os.Chdir(wd)
+
+	*reproducibleFlag = true
+	defer func() { *reproducibleFlag = false }()
+
+	var outputs []map[string]string
+	for _, dir := range []string{"a", filepath.Join("b", "c")} {
+		dir = filepath.Join(tmp, dir)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "test.go"), src, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(dir); err != nil {
+			t.Fatal(err)
+		}
+		if err := doTestable([]string{"test.go"}); err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, readHxDir(t))
+	}
+
+	if len(outputs[0]) != len(outputs[1]) {
+		t.Errorf("%d files generated in one directory, %d in the other", len(outputs[0]), len(outputs[1]))
+	}
+	for name, data := range outputs[0] {
+		other, found := outputs[1][name]
+		switch {
+		case !found:
+			t.Errorf("%s only generated in one directory", name)
+		case data != other:
+			t.Errorf("%s differs between directories", name)
+		}
+	}
+}
+
+// the same code should be generated with or without the build cache, whether it is empty or filled by an earlier run
+func TestCache(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "tardisgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	defer func() { *cacheFlag, *reproducibleFlag = "", false }()
+
+	for _, reproducible := range []bool{false, true} {
+		*reproducibleFlag = reproducible
+		cache := filepath.Join(tmp, fmt.Sprintf("reproducible-%v", reproducible))
+		mode := fmt.Sprintf(" (-reproducible=%v)", reproducible)
+
+		var outputs []map[string]string
+		for _, dir := range []string{"", cache, cache} {
+			*cacheFlag = dir
+			outputs = append(outputs, coreOutput(t))
+		}
+		if files, err := ioutil.ReadDir(cache); err != nil || len(files) == 0 {
+			t.Errorf("nothing written to the build cache%s, error %v", mode, err)
+		}
+		compareOutputs(t, outputs[0], outputs[1], "with an empty build cache"+mode)
+		compareOutputs(t, outputs[0], outputs[2], "with a full build cache"+mode)
+	}
+}
+
+// the same code should be generated each time a program is compiled, in the default mode
+func TestSameOutput(t *testing.T) {
+	first := coreOutput(t)
+	compareOutputs(t, first, coreOutput(t), "the second time")
+}
+
+// the same code should be generated however many functions are generated at once
+func TestJobs(t *testing.T) {
+	jobs := *jobsFlag
+	defer func() { *jobsFlag = jobs }()
+	*jobsFlag = 1
+	first := coreOutput(t)
+	*jobsFlag = 8
+	compareOutputs(t, first, coreOutput(t), "with -j 8")
+}
+
+// coreOutput returns the content of each file generated for tests/core, by name
+func coreOutput(t *testing.T) map[string]string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir("tests/core"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doTestable([]string{"test.go"}); err != nil {
+		t.Fatal(err)
+	}
+	return readHxDir(t)
+}
+
+// compareOutputs reports each file that differs from those first generated
+func compareOutputs(t *testing.T, first, output map[string]string, run string) {
+	if len(output) != len(first) {
+		t.Errorf("%d files generated %s, %d first", len(output), run, len(first))
+	}
+	for name, data := range first {
+		if output[name] != data {
+			t.Errorf("%s differs %s", name, run)
+		}
+	}
+}
+
+// readHxDir returns the content of each file generated, by name
+func readHxDir(t *testing.T) map[string]string {
+	files, err := ioutil.ReadDir(*hxDirFlag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := make(map[string]string)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(*hxDirFlag, f.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output[f.Name()] = string(data)
+	}
+	return output
+}
+
+// positions in Haxe compiler errors and stack traces should be followed by the Go positions they come from
+func TestGoPositions(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "tardisgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	sm := "1\t-\n5\tmain.go:12\n9\t-\n"
+	if err := ioutil.WriteFile(filepath.Join(tmp, "Go_main.hx"+pogo.SourceMapSuffix), []byte(sm), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	gp := newGoPositions(tmp)
+	for text, want := range map[string]string{
+		"tardis/Go_main.hx:7: characters 3-10 : Unknown identifier : x": "tardis/Go_main.hx:7 [main.go:12]: characters 3-10 : Unknown identifier : x",
+		"Called from Go_main.hx line 5":                                 "Called from Go_main.hx line 5 [main.go:12]",
+		"tardis/Go_main.hx:2: no Go position":                           "tardis/Go_main.hx:2: no Go position",
+		"tardis/Other.hx:7: no source map":                              "tardis/Other.hx:7: no source map",
+	} {
+		if got := gp.rewrite(text); got != want {
+			t.Errorf("%q rewritten as %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestParseTestOutput(t *testing.T) {
+	rep := parseTestOutput(`"Neko (haxe --interp):"
+=== RUN TestA
+printed by TestA
+--- PASS: TestA (0.01s)
+=== RUN TestB
+=== RUN TestB/sub
+    --- FAIL: TestB/sub (0.00s)
+    	x_test.go:12: logged by TestB/sub
+--- FAIL: TestB (0.02s)
+=== RUN TestC
+--- SKIP: TestC (0.00s)
+	x_test.go:20: not today
+=== RUN TestD
+panic in TestD
+`)
+	want := []testResult{
+		{"TestA", "PASS", "0.01", []string{"printed by TestA"}},
+		{"TestB", "FAIL", "0.02", nil},
+		{"TestB/sub", "FAIL", "0.00", []string{"x_test.go:12: logged by TestB/sub"}},
+		{"TestC", "SKIP", "0.00", []string{"x_test.go:20: not today"}},
+		{"TestD", "FAIL", "", []string{"panic in TestD"}},
+	}
+	if len(rep.results) != len(want) {
+		t.Fatalf("got %d results, want %d", len(rep.results), len(want))
+	}
+	for i, res := range rep.results {
+		if !reflect.DeepEqual(*res, want[i]) {
+			t.Errorf("result %d is %v, want %v", i, *res, want[i])
+		}
+	}
+	if rep.passed {
+		t.Error("passed, but should have failed")
+	}
+	if !parseTestOutput("=== RUN TestA\n--- PASS: TestA (0.00s)\nPASS\n").passed {
+		t.Error("failed, but should have passed")
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2014 Elliott Stoneham and The TARDIS Go Authors
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// "tardisgo test" transpiles the tests of a package, runs them on each Haxe target given by the -haxe flag,
+// and reports the results in the style of "go test".
+//
+// The replacement testing package always prints the result of each test in the format of "go test -v",
+// which is read back here to give the per-test results, and to write any JUnit XML reports.
+
+// testRun holds the options of "tardisgo test", it is nil otherwise.
+var testRun *testRunner
+
+type testRunner struct {
+	pkg      string
+	run      *regexp.Regexp // if set, only the tests whose names match are run
+	verbose  bool
+	junitDir string   // if set, where to write the JUnit XML reports
+	selected []string // the names of the tests selected by run
+}
+
+const testUsage = `Usage: tardisgo [<flag> ...] test [-run regexp] [-v] [-junit dir] <package>
+Transpiles the tests of the package, then runs them on the Haxe targets given by -haxe (default interp).
+`
+
+func doTest(args []string) error {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, testUsage)
+		fs.PrintDefaults()
+	}
+	run := fs.String("run", "", "run only the tests whose names match the regular expression")
+	verbose := fs.Bool("v", false, "print the output of every test, not only of those that fail")
+	junitDir := fs.String("junit", "", "write a JUnit XML report for each Haxe target into the directory, created if required")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("tardisgo test requires one package")
+	}
+	testRun = &testRunner{pkg: fs.Arg(0), verbose: *verbose, junitDir: *junitDir}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			return fmt.Errorf("invalid -run regular expression: %s", err)
+		}
+		testRun.run = re
+	}
+	if *allFlag == "" {
+		*allFlag = "interp"
+	}
+	*testFlag = true
+	return doTestable([]string{testRun.pkg})
+}
+
+// selectTests finds the names of the tests in the packages that the -run regular expression selects.
+func (tr *testRunner) selectTests(pkgs []*ssa.Package) {
+	if tr.run == nil {
+		return
+	}
+	tr.selected = []string{}
+	_, tests, _, _ := ssa.FindTests(pkgs)
+	for _, t := range tests {
+		if tr.run.MatchString(t.Name()) {
+			tr.selected = append(tr.selected, t.Name())
+		}
+	}
+}
+
+// the Haxe targets run for each value of the -haxe flag
+func haxeFlagTargets(value string) []string {
+	switch value {
+	case "all":
+		return []string{"cpp", "java", "cs", "js", "jsfu"}
+	case "math":
+		return []string{"cpp", "jsfu"}
+	}
+	return []string{value}
+}
+
+// runTargets runs the transpiled tests on each Haxe target, returning an error if any of them fail.
+func (tr *testRunner) runTargets(tgts map[string][][]string) error {
+	failed := []string{}
+	for _, target := range haxeFlagTargets(*allFlag) {
+		cmds, found := tgts[target]
+		if !found {
+			return fmt.Errorf("invalid value for -haxe flag: %s", *allFlag)
+		}
+		start := time.Now()
+		results := make(chan resChan)
+		go doTarget(cmds, results)
+		r := <-results
+		r.backChan <- true
+		rep := parseTestOutput(r.output)
+		rep.target = target
+		rep.elapsed = time.Since(start)
+		rep.passed = rep.passed && r.err == nil
+		tr.print(rep)
+		if tr.junitDir != "" {
+			if err := tr.writeJUnit(rep); err != nil {
+				return err
+			}
+		}
+		if !rep.passed {
+			failed = append(failed, target)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("tests failed on %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// testResult is the result of one test or subtest.
+type testResult struct {
+	name    string
+	status  string // PASS, FAIL or SKIP
+	seconds string
+	output  []string // what the test printed and logged
+}
+
+// testReport is the result of running the tests on one target.
+type testReport struct {
+	target  string
+	results []*testResult
+	output  string // everything that the target printed
+	passed  bool
+	elapsed time.Duration
+}
+
+var (
+	testRunRE    = regexp.MustCompile(`^=== RUN (\S+)$`)
+	testResultRE = regexp.MustCompile(`^(\s*)--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)$`)
+)
+
+// parseTestOutput reads the test results from the output of a target.
+// Anything printed while a test is running is part of its output, as are the indented lines logged after its result.
+// A test that was running when the output ended, because the program failed, has failed.
+func parseTestOutput(out string) *testReport {
+	rep := &testReport{output: out}
+	running := []*testResult{}
+	var logging *testResult // the test whose logged lines follow
+	logIndent := ""
+	for _, line := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		if logging != nil && strings.HasPrefix(line, logIndent) {
+			logging.output = append(logging.output, strings.TrimPrefix(line, logIndent))
+			continue
+		}
+		logging = nil
+		if m := testRunRE.FindStringSubmatch(line); m != nil {
+			res := &testResult{name: m[1]}
+			rep.results = append(rep.results, res)
+			running = append(running, res)
+			continue
+		}
+		if m := testResultRE.FindStringSubmatch(line); m != nil {
+			for i := len(running) - 1; i >= 0; i-- {
+				if running[i].name == m[3] {
+					logging = running[i]
+					running = append(running[:i], running[i+1:]...)
+					break
+				}
+			}
+			if logging == nil { // the result of a test that we did not see start
+				logging = &testResult{name: m[3]}
+				rep.results = append(rep.results, logging)
+			}
+			logging.status, logging.seconds = m[2], m[4]
+			logIndent = m[1] + "\t"
+			continue
+		}
+		switch {
+		case line == "PASS":
+			rep.passed = true
+		case line == "FAIL":
+			rep.passed = false
+		case len(running) > 0:
+			res := running[len(running)-1]
+			res.output = append(res.output, line)
+		}
+	}
+	for _, res := range running {
+		res.status = "FAIL"
+		rep.passed = false
+	}
+	for _, res := range rep.results {
+		if res.status == "FAIL" {
+			rep.passed = false
+		}
+	}
+	return rep
+}
+
+// print reports the results on a target in the style of "go test", with all the output if verbose.
+func (tr *testRunner) print(rep *testReport) {
+	if tr.verbose {
+		fmt.Print(rep.output)
+	} else {
+		failures := 0
+		for _, res := range rep.results {
+			if res.status == "FAIL" {
+				failures++
+				fmt.Printf("--- FAIL: %s (%ss)\n", res.name, res.seconds)
+				for _, line := range res.output {
+					fmt.Printf("\t%s\n", line)
+				}
+			}
+		}
+		if !rep.passed && failures == 0 { // the failure was not in a test, so show everything
+			fmt.Print(rep.output)
+		}
+	}
+	status := "ok  "
+	if !rep.passed {
+		status = "FAIL"
+	}
+	fmt.Printf("%s\t%s [%s]\t%.3fs\n", status, tr.pkg, rep.target, rep.elapsed.Seconds())
+}
+
+// The JUnit XML report format, as read by continuous integration servers.
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitFileName gives the name of the JUnit XML report for testing a package on a target.
+func (tr *testRunner) junitFileName(target string) string {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:.`, r) {
+			return '_'
+		}
+		return r
+	}, tr.pkg)
+	return filepath.Join(tr.junitDir, name+"-"+target+".xml")
+}
+
+// writeJUnit writes the JUnit XML report of the results on a target,
+// a failure that was not in any test is reported as the failure of a test case named "(run)".
+func (tr *testRunner) writeJUnit(rep *testReport) error {
+	suite := junitTestSuite{
+		Name: tr.pkg + " [" + rep.target + "]",
+		Time: strconv.FormatFloat(rep.elapsed.Seconds(), 'f', 3, 64),
+	}
+	failures := 0
+	for _, res := range rep.results {
+		tc := junitTestCase{Classname: tr.pkg, Name: res.name, Time: res.seconds}
+		text := strings.Join(res.output, "\n")
+		switch res.status {
+		case "FAIL":
+			failures++
+			tc.Failure = &junitMessage{Message: "Failed", Text: text}
+		case "SKIP":
+			suite.Skipped++
+			tc.Skipped = &junitMessage{Message: text}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if !rep.passed && failures == 0 {
+		failures++
+		suite.Cases = append(suite.Cases, junitTestCase{Classname: tr.pkg, Name: "(run)", Time: suite.Time,
+			Failure: &junitMessage{Message: "Failed", Text: rep.output}})
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Failures = failures
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(tr.junitDir, os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(tr.junitFileName(rep.target), append([]byte(xml.Header), append(data, '\n')...), 0666)
+}
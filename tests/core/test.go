@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"runtime"
+	"time"
 	"unicode"
 	"unicode/utf8"
 	"unsafe"
@@ -585,6 +586,56 @@ func testMap() { // and map-like constucts
 		_, isok = noteFrequency2[43.42]
 		TEQ("", false, isok)
 	}
+
+	if true { // a NaN key is never equal to another, so each one set is a new entry, while -0 and +0 are the same key
+		zero := 0.0
+		nan := zero / zero
+		nanKeys := map[float64]int{}
+		nanKeys[nan] = 1
+		nanKeys[nan] = 2
+		nanKeys[zero] = 3
+		nanKeys[-zero] = 4
+		TEQ("", len(nanKeys), 3)
+		_, isok := nanKeys[nan]
+		TEQ("", false, isok)
+		delete(nanKeys, nan)
+		TEQ("", len(nanKeys), 3)
+		TEQ("", nanKeys[0], 4)
+		sum := 0
+		for _, v := range nanKeys {
+			sum += v
+		}
+		TEQ("", sum, 7)
+	}
+
+	type pair struct {
+		a int
+		b string
+	}
+	pairs := map[pair]int{pair{1, "a"}: 1, pair{2, "b"}: 2}
+	pairs[pair{1, "a"}] = 3
+	TEQ("", len(pairs), 2)
+	TEQ("", pairs[pair{1, "a"}], 3)
+	delete(pairs, pair{2, "b"})
+	_, isok = pairs[pair{2, "b"}]
+	TEQ("", false, isok)
+
+	partners := map[int]int{1: 4, 2: 3, 3: 2, 4: 1}
+	seen := 0
+	for k := range partners { // an entry removed during the range, before it is reached, is not returned
+		delete(partners, partners[k])
+		seen++
+	}
+	TEQ("", seen, 2)
+	TEQ("", len(partners), 2)
+
+	type recursiveMap map[string]recursiveMap // as in encoding/gob
+	rm := make(recursiveMap)
+	rm["a"] = recursiveMap{"b": nil}
+	TEQ("", len(rm), 1)
+	TEQ("", len(rm["a"]), 1)
+	TEQ("", len(rm["a"]["b"]), 0)
+	TEQ("", len(rm["c"]), 0)
 }
 
 type MyFloat float64
@@ -693,6 +744,11 @@ func testNamed() {
 	f2a := (T).Mv
 	TEQ("", t.Mv(7), f2a(t, 7))
 
+	// each *T below is a new, identical, type, so ssa makes a thunk with the same name for each method expression
+	f3a := (*T).Mp
+	f4a := (*T).Mp
+	TEQ("", f3a(pt, 7), f4a(&t, 7))
+
 }
 
 var hypot1 = func(x, y float64) float64 {
@@ -1053,6 +1109,182 @@ func testChan() {
 	//TODO much more to come here...
 }
 
+func testChanSync() { // unbuffered channels hand over each value from sender to receiver, also in a select
+	c := make(chan int)
+	TEQ("", len(c), 0)
+	TEQ("", cap(c), 0)
+	sent := false
+	done := make(chan bool)
+	go func() {
+		c <- 1
+		sent = true
+		done <- true
+	}()
+	runtime.Gosched()
+	TEQ("unbuffered send completed without a receiver", sent, false)
+	TEQ("", <-c, 1)
+	<-done
+	TEQ("", sent, true)
+
+	select {
+	case c <- 2:
+		TEQ("select sent on an unbuffered channel without a receiver", true, false)
+	default:
+	}
+
+	go func() { c <- 3 }()
+	runtime.Gosched()
+	select {
+	case v := <-c:
+		TEQ("", v, 3)
+	default:
+		TEQ("select did not receive from a waiting sender", true, false)
+	}
+
+	go func() { done <- <-c == 4 }()
+	runtime.Gosched()
+	select {
+	case c <- 4:
+	default:
+		TEQ("select did not send to a waiting receiver", true, false)
+	}
+	TEQ("", <-done, true)
+
+	quit := make(chan bool)
+	go func() { c <- 5 }()
+	select { // blocks until the sender arrives
+	case v := <-c:
+		TEQ("", v, 5)
+	case <-quit:
+		TEQ("select received from a channel that was never sent to", true, false)
+	}
+	go func() { TEQ("", <-c, 6); close(quit) }()
+	select { // blocks until the receiver arrives
+	case <-quit:
+		TEQ("select received from a channel before it was closed", true, false)
+	case c <- 6:
+	}
+	_, ok := <-quit
+	TEQ("", ok, false)
+
+	var nc chan int
+	select {
+	case <-nc:
+		TEQ("select received from a nil channel", true, false)
+	case nc <- 1:
+		TEQ("select sent to a nil channel", true, false)
+	default:
+	}
+
+	b := make(chan int, 3)
+	b <- 1
+	b <- 2
+	TEQ("", len(b), 2)
+	TEQ("", cap(b), 3)
+	close(b)
+	TEQ("", len(b), 2)
+	for i := 1; i <= 3; i++ {
+		select {
+		case v, ok := <-b:
+			TEQ("", ok, i < 3)
+			if i < 3 {
+				TEQ("", v, i)
+			} else {
+				TEQ("", v, 0)
+			}
+		default:
+			TEQ("select blocked on a closed channel", true, false)
+		}
+	}
+	TEQ("", len(b), 0)
+
+	go func() {
+		defer func() { done <- recover() != nil }()
+		c <- 7 // blocks until c is closed, then panics
+	}()
+	runtime.Gosched()
+	close(c)
+	TEQ("blocked send did not panic when the channel was closed", <-done, true)
+}
+
+// notEarly is true if at least d has passed since start, give or take the millisecond resolution of time.Now()
+func notEarly(start time.Time, d time.Duration) bool {
+	return time.Since(start) >= d-2*time.Millisecond
+}
+
+func testTimers() { // the timers of the time package, which all run from the runtime timer heap
+	start := time.Now()
+	<-time.After(20 * time.Millisecond)
+	TEQ("time.After fired early", notEarly(start, 20*time.Millisecond), true)
+
+	start = time.Now()
+	fired := make(chan time.Time, 1)
+	time.AfterFunc(20*time.Millisecond, func() { fired <- time.Now() })
+	TEQ("time.AfterFunc ran early", (<-fired).Sub(start) >= 18*time.Millisecond, true)
+
+	t := time.NewTimer(time.Hour)
+	TEQ("Timer.Stop of an active timer", t.Stop(), true)
+	TEQ("Timer.Stop of a stopped timer", t.Stop(), false)
+	start = time.Now()
+	TEQ("Timer.Reset of a stopped timer", t.Reset(20*time.Millisecond), false)
+	<-t.C
+	TEQ("Timer.Reset timer fired early", notEarly(start, 20*time.Millisecond), true)
+	TEQ("Timer.Stop of an expired timer", t.Stop(), false)
+	TEQ("Timer.Reset of an expired timer", t.Reset(time.Hour), false)
+	TEQ("Timer.Reset of an active timer", t.Reset(time.Millisecond), true)
+	<-t.C
+
+	never := make(chan int)
+	start = time.Now()
+	select { // only the timeout can be taken
+	case <-never:
+		TEQ("select received from a channel that was never sent to", true, false)
+	case <-time.After(20 * time.Millisecond):
+		TEQ("select timed out early", notEarly(start, 20*time.Millisecond), true)
+	}
+	ready := make(chan int, 1)
+	ready <- 1
+	select { // the channel is ready long before the timeout
+	case v := <-ready:
+		TEQ("", v, 1)
+	case <-time.After(time.Hour):
+		TEQ("select timed out although a channel was ready", true, false)
+	}
+
+	start = time.Now()
+	tick := time.Tick(10 * time.Millisecond)
+	for i := 0; i < 3; i++ {
+		<-tick
+	}
+	TEQ("time.Tick ticked early", notEarly(start, 30*time.Millisecond), true)
+}
+
+func testScheduler() { // goroutines that loop or sleep do not stop the others
+	// tests/core is compiled with -preempt, so the looping goroutine gives up control at the end of its loop iterations,
+	// without that it would loop forever, as the goroutine that stops it would never run
+	stop := false
+	done := make(chan int)
+	go func() {
+		spins := 0
+		for !stop {
+			spins++
+		}
+		done <- spins
+	}()
+	go func() { stop = true }()
+	<-done
+
+	start := time.Now()
+	woke := make(chan time.Time)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		woke <- time.Now()
+	}()
+	after := (<-woke).Sub(start)
+	TEQ("time.Sleep woke early", after >= 18*time.Millisecond, true)
+	TEQ("time.Sleep woke late", after < time.Second, true)
+}
+
 func testComplex() {
 
 	var x, y, z complex64
@@ -1666,6 +1898,10 @@ func testPtr() {
 	f3.a = [3]int{4, 4, 4}
 	TEQ("", s3[1], 4) // should be 4
 
+	type rec ***rec // a pointer type that refers to itself, as in encoding/gob
+	var r3 rec
+	r3 = new(**rec)
+	TEQ("", *r3 == nil, true)
 }
 
 type tbe struct {
@@ -1710,9 +1946,25 @@ func testUnsafe() { // adapted from http://stackoverflow.com/questions/19721008/
 	// (we have to recast the uintptr to a *int to examine it)
 	TEQint32("", m[0], *(*int32)(mPtr))
 
-	if runtime.GOARCH != "neko" { // to avoid errors on the automated test
-		TEQuint32("Only works in fullunsafe mode", 219, (uint32)(*(*uint8)(mPtr)))
-	}
+	TEQuint32("", 219, (uint32)(*(*uint8)(mPtr)))
+
+	// memory holds the bytes of each value, so it can be re-used as different types
+	f := 1.0
+	TEQuint64("", *(*uint64)(unsafe.Pointer(&f)), 0x3ff0000000000000)
+	i64 := int64(-2)
+	TEQuint32("", *(*uint32)(unsafe.Pointer(&i64)), 0xfffffffe)
+	c := complex(1.5, -2)
+	TEQ("", *(*[2]float64)(unsafe.Pointer(&c)), [2]float64{1.5, -2})
+	type mixed struct {
+		n int16
+		s string
+		b []byte
+	}
+	mx := [2]mixed{{-3, "x", []byte("y")}}
+	mx[1] = mx[0]
+	TEQ("", mx[1].n, int16(-3))
+	TEQ("", mx[1].s, "x")
+	TEQ("", string(mx[1].b), "y")
 
 	// error on pointer arithmetic
 	//uip := uintptr(mPtr)
@@ -1842,6 +2094,7 @@ func main() {
 	testIntOverflow()
 	testSlices()
 	testChan()
+	testChanSync()
 	testComplex()
 	testUTF8()
 	testString()
@@ -1875,6 +2128,9 @@ func main() {
 		TEQ(""+"Num Haxe GR post-wait", runtime.NumGoroutine(), 3)
 	}
 	TEQ("", unicode.IsSpace(' '), true) // makes the test longer but more complete
+	// after the goroutine count above, as the ticker of time.Tick keeps the timer goroutine running
+	testTimers()
+	testScheduler()
 	//fmt.Println("End test running in: " + runtime.GOARCH)
 	//fmt.Println("再见！Previous two chinese characters should say goodbye! (testing unicode output)")
 	//fmt.Println()
@@ -27,14 +27,16 @@ type isOverloaded func(*ssa.Function) bool
 // each package.  The result may include anonymous functions and
 // synthetic wrappers.
 //
-// Precondition: all packages are built.
+// Precondition: all packages are built, or have their Visits in the cache, which may be nil.
 //
-func VisitedFunctions(prog *ssa.Program, packs []*ssa.Package, isOvl isOverloaded) (seen, usesGR map[*ssa.Function]bool) {
+func VisitedFunctions(prog *ssa.Program, packs []*ssa.Package, isOvl isOverloaded, cache Cache) (seen, usesGR map[*ssa.Function]bool) {
 	visit := visitor{
-		prog:   prog,
-		packs:  packs, // new
-		seen:   make(map[*ssa.Function]bool),
-		usesGR: make(map[*ssa.Function]bool),
+		prog:        prog,
+		packs:       packs, // new
+		cache:       cache,
+		seen:        make(map[*ssa.Function]bool),
+		usesGR:      make(map[*ssa.Function]bool),
+		localUsesGR: make(map[*Visit]bool),
 	}
 	visit.program(isOvl)
 	//fmt.Printf("DEBUG VisitedFunctions.usesGR %v\n", visit.usesGR)
@@ -42,11 +44,37 @@ func VisitedFunctions(prog *ssa.Program, packs []*ssa.Package, isOvl isOverloade
 	return visit.seen, visit.usesGR
 }
 
+// A Visit is what the visitor found in the code of a function: the functions it refers to, and where it uses goroutines, in order.
+// Visits are recorded for the functions whose code is built, so that they can be replayed in a later program in which it is not.
+type Visit struct {
+	Overloaded bool // so not visited
+	External   bool // there is no code, so it cannot use goroutines
+	Steps      []Step
+}
+
+// A Step refers to a function, or if Fn and Local are both nil, uses goroutines.
+type Step struct {
+	Fn    *ssa.Function
+	Local *Visit // a function that only exists in the code that was not built, so it is only known by its Visit
+}
+
+// A Cache holds the Visits of the functions whose code was not built.
+type Cache interface {
+	// Visit returns the recorded Visit of a function that has no code, or nil if it is external.
+	Visit(fn *ssa.Function) *Visit
+	// Visited is told the Visit of each function, or Local, visited, and whether it uses goroutines.
+	Visited(fn *ssa.Function, v *Visit, usesGR bool)
+	// RuntimeTypes returns the types whose method sets the code that was not built would have required.
+	RuntimeTypes() []types.Type
+}
+
 type visitor struct {
-	prog   *ssa.Program
-	packs  []*ssa.Package // new
-	seen   map[*ssa.Function]bool
-	usesGR map[*ssa.Function]bool // new
+	prog        *ssa.Program
+	packs       []*ssa.Package // new
+	cache       Cache
+	seen        map[*ssa.Function]bool
+	usesGR      map[*ssa.Function]bool // new
+	localUsesGR map[*Visit]bool        // the Locals seen, and if they use goroutines
 }
 
 func (visit *visitor) program(isOvl isOverloaded) {
@@ -66,7 +94,11 @@ func (visit *visitor) program(isOvl isOverloaded) {
 			}
 		}
 	}
-	for _, T := range visit.prog.RuntimeTypes() {
+	runtimeTypes := visit.prog.RuntimeTypes()
+	if visit.cache != nil {
+		runtimeTypes = append(runtimeTypes, visit.cache.RuntimeTypes()...)
+	}
+	for _, T := range runtimeTypes {
 		mset := visit.prog.MethodSets.MethodSet(T)
 		for i, n := 0, mset.Len(); i < n; i++ {
 			mf := visit.prog.Method(mset.At(i))
@@ -99,81 +131,124 @@ func (visit *visitor) function(fn *ssa.Function, isOvl isOverloaded) {
 		//fmt.Println("DEBUG 1st visit to: ", fn.String())
 		visit.seen[fn] = true
 		visit.usesGR[fn] = false
-		if isOvl(fn) {
+		var v *Visit
+		switch {
+		case isOvl(fn):
 			//fmt.Println("DEBUG overloaded: ", fn.String())
-			return
+			v = &Visit{Overloaded: true}
+		case len(fn.Blocks) > 0:
+			v = steps(fn)
+		case visit.cache != nil:
+			v = visit.cache.Visit(fn)
 		}
-		if len(fn.Blocks) == 0 { // exclude functions that reference C/assembler code
+		if v == nil { // exclude functions that reference C/assembler code
 			// NOTE: not marked as seen, because we don't want to include in output
 			// if used, the symbol will be included in the golibruntime replacement packages
 			// TODO review
 			//fmt.Println("DEBUG no code for: ", fn.String())
-			return // external functions cannot use goroutines
+			v = &Visit{External: true} // external functions cannot use goroutines
 		}
-		var buf [10]*ssa.Value // avoid alloc in common case
-		for _, b := range fn.Blocks {
-			for _, instr := range b.Instrs {
-				for _, op := range instr.Operands(buf[:0]) {
-					if afn, ok := (*op).(*ssa.Function); ok {
-						visit.function(afn, isOvl)
-						if visit.usesGR[afn] {
-							visit.usesGR[fn] = true
-						}
-						//println(fn.Name(), " calls ", afn.Name())
-					}
-					// TODO, review if this code should be included
-					if !visit.usesGR[fn] {
-						if _, ok := (*op).(ssa.Value); ok {
-							typ := (*op).Type()
-							typ = DeRefUl(typ)
-							switch typ.(type) {
-							case *types.Chan, *types.Interface, *types.Signature:
-								// TODO use oracle techniques to determine which interfaces or functions may require GR
-								visit.usesGR[fn] = true // may be too conservative
-							}
-						}
-					}
+		for _, s := range v.Steps {
+			if visit.step(s, isOvl) {
+				visit.usesGR[fn] = true
+			}
+		}
+		if visit.cache != nil {
+			visit.cache.Visited(fn, v, visit.usesGR[fn])
+		}
+	}
+}
+
+// local visits a function that is only known by its Visit.
+func (visit *visitor) local(v *Visit, isOvl isOverloaded) {
+	if _, seen := visit.localUsesGR[v]; !seen {
+		visit.localUsesGR[v] = false
+		for _, s := range v.Steps {
+			if visit.step(s, isOvl) {
+				visit.localUsesGR[v] = true
+			}
+		}
+		visit.cache.Visited(nil, v, visit.localUsesGR[v])
+	}
+}
+
+// step takes a step of a Visit, returning true if it makes the function use goroutines.
+func (visit *visitor) step(s Step, isOvl isOverloaded) bool {
+	switch {
+	case s.Fn != nil:
+		visit.function(s.Fn, isOvl)
+		return visit.usesGR[s.Fn]
+	case s.Local != nil:
+		visit.local(s.Local, isOvl)
+		return visit.localUsesGR[s.Local]
+	default:
+		return true
+	}
+}
+
+// steps finds the Visit of a function from its code.
+func steps(fn *ssa.Function) *Visit {
+	v := &Visit{}
+	var buf [10]*ssa.Value // avoid alloc in common case
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			for _, op := range instr.Operands(buf[:0]) {
+				if afn, ok := (*op).(*ssa.Function); ok {
+					v.Steps = append(v.Steps, Step{Fn: afn})
 				}
-				if _, ok := instr.(*ssa.Call); ok {
-					switch instr.(*ssa.Call).Call.Value.(type) {
-					case *ssa.Builtin:
-						//NoOp
-					default:
-						cc := instr.(*ssa.Call).Common()
-						if cc != nil {
-							afn := cc.StaticCallee()
-							if afn != nil {
-								visit.function(afn, isOvl)
-								if visit.usesGR[afn] {
-									visit.usesGR[fn] = true
-								}
-								//println(fn.Name(), " calls ", afn.Name())
-							}
-						}
+				// TODO, review if this code should be included
+				if _, ok := (*op).(ssa.Value); ok {
+					typ := (*op).Type()
+					typ = DeRefUl(typ)
+					switch typ.(type) {
+					case *types.Chan, *types.Interface, *types.Signature:
+						// TODO use oracle techniques to determine which interfaces or functions may require GR
+						v.Steps = append(v.Steps, Step{}) // may be too conservative
 					}
 				}
-				if !visit.usesGR[fn] {
-					switch instr.(type) {
-					case *ssa.Go, *ssa.MakeChan, *ssa.Defer, *ssa.Panic,
-						*ssa.Send, *ssa.Select:
-						//fmt.Println("usesGR", fn.Name())
-						visit.usesGR[fn] = true
-					case *ssa.UnOp:
-						if instr.(*ssa.UnOp).Op.String() == "<-" {
-							visit.usesGR[fn] = true
+			}
+			if _, ok := instr.(*ssa.Call); ok {
+				switch instr.(*ssa.Call).Call.Value.(type) {
+				case *ssa.Builtin:
+					//NoOp
+				default:
+					cc := instr.(*ssa.Call).Common()
+					if cc != nil {
+						afn := cc.StaticCallee()
+						if afn != nil {
+							v.Steps = append(v.Steps, Step{Fn: afn})
 						}
 					}
 				}
 			}
+			switch instr.(type) {
+			case *ssa.Go, *ssa.MakeChan, *ssa.Defer, *ssa.Panic,
+				*ssa.Send, *ssa.Select:
+				//fmt.Println("usesGR", fn.Name())
+				v.Steps = append(v.Steps, Step{})
+			case *ssa.UnOp:
+				if instr.(*ssa.UnOp).Op.String() == "<-" {
+					v.Steps = append(v.Steps, Step{})
+				}
+			}
 		}
 	}
+	return v
 }
 
-// DeRefUl dereferencs a type and gets to it's underlying type
+// DeRefUl dereferencs a type and gets to it's underlying type,
+// or to a pointer type that refers to itself, as in "type Rec ***Rec"
 func DeRefUl(T types.Type) types.Type {
+	var ptrs []types.Type // the pointer types seen
 deRef:
 	switch T.(type) {
 	case *types.Pointer:
+		for _, p := range ptrs {
+			if p == T {
+				return T
+			}
+		}
+		ptrs = append(ptrs, T)
 		T = T.(*types.Pointer).Elem().Underlying()
 		goto deRef
 	case *types.Named: